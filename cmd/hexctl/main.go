@@ -0,0 +1,73 @@
+// Command hexctl is the admin command line. It reads the same .env as the
+// server and drives the same services, so every change it makes goes
+// through the rules the API applies. Run it without arguments for usage.
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/LordMoMA/Hexagonal-Architecture/internal/adapters/cache"
+	"github.com/LordMoMA/Hexagonal-Architecture/internal/adapters/cli"
+	"github.com/LordMoMA/Hexagonal-Architecture/internal/adapters/pubsub"
+	"github.com/LordMoMA/Hexagonal-Architecture/internal/adapters/realtime"
+	"github.com/LordMoMA/Hexagonal-Architecture/internal/adapters/repository"
+	"github.com/LordMoMA/Hexagonal-Architecture/internal/adapters/search"
+	"github.com/LordMoMA/Hexagonal-Architecture/internal/core/services"
+	"github.com/jinzhu/gorm"
+	"github.com/joho/godotenv"
+	_ "github.com/lib/pq"
+)
+
+func main() {
+	if err := run(os.Args[1:]); err != nil {
+		if errors.Is(err, cli.ErrUsage) {
+			os.Exit(2)
+		}
+		fmt.Fprintf(os.Stderr, "hexctl: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+func run(args []string) error {
+	if len(args) == 0 {
+		return cli.New(nil, nil, nil).Run(args)
+	}
+	if err := godotenv.Load(); err != nil {
+		return err
+	}
+
+	conn := fmt.Sprintf("host=%s port=%s user=%s password=%s dbname=%s sslmode=disable",
+		os.Getenv("DB_HOST"), os.Getenv("DB_PORT"), os.Getenv("DB_USER"), os.Getenv("DB_PASSWORD"), os.Getenv("DB_NAME"))
+	db, err := gorm.Open("postgres", conn)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	// the server caches users in Redis, so changes to them must go through it
+	redisAddr := os.Getenv("REDIS_ADDR")
+	if redisAddr == "" {
+		redisAddr = "127.0.0.1:6379"
+	}
+	redisCache, err := cache.NewRedisCache(redisAddr, "")
+	if err != nil {
+		return err
+	}
+	store := repository.NewDB(db, redisCache)
+
+	// deleting or restoring a message is pushed to its conversation like any
+	// other change; the hub only publishes here, the servers deliver
+	events, err := pubsub.NewRedisPubSub(redisAddr, "")
+	if err != nil {
+		return err
+	}
+
+	app := cli.New(
+		services.NewUserService(store),
+		services.NewMessengerService(store, realtime.NewHub(events), search.NewPostgresIndex(db), nil, nil),
+		services.NewPaymentService(store),
+	)
+	return app.Run(args)
+}
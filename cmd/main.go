@@ -1,14 +1,26 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"log"
+	"net"
 	"os"
+	"strconv"
+	"time"
 
+	"github.com/LordMoMA/Hexagonal-Architecture/internal/adapters/blobstore"
 	"github.com/LordMoMA/Hexagonal-Architecture/internal/adapters/cache"
 	"github.com/LordMoMA/Hexagonal-Architecture/internal/adapters/handler"
+	"github.com/LordMoMA/Hexagonal-Architecture/internal/adapters/moderation"
+	"github.com/LordMoMA/Hexagonal-Architecture/internal/adapters/oidc"
+	"github.com/LordMoMA/Hexagonal-Architecture/internal/adapters/pubsub"
+	"github.com/LordMoMA/Hexagonal-Architecture/internal/adapters/realtime"
 	"github.com/LordMoMA/Hexagonal-Architecture/internal/adapters/repository"
+	"github.com/LordMoMA/Hexagonal-Architecture/internal/adapters/rpc"
+	"github.com/LordMoMA/Hexagonal-Architecture/internal/adapters/search"
 	"github.com/LordMoMA/Hexagonal-Architecture/internal/core/domain"
+	"github.com/LordMoMA/Hexagonal-Architecture/internal/core/ports"
 	"github.com/LordMoMA/Hexagonal-Architecture/internal/core/services"
 	"github.com/LordMoMA/Hexagonal-Architecture/internal/logger"
 	"github.com/gin-contrib/pprof"
@@ -22,6 +34,8 @@ var (
 	msgService     *services.MessengerService
 	userService    *services.UserService
 	paymentService *services.PaymentService
+	oidcService    *services.OIDCService
+	hub            *realtime.Hub
 )
 
 func main() {
@@ -44,7 +58,7 @@ func main() {
 		panic(err)
 	}
 
-	redisCache, err := cache.NewRedisCache("127.0.0.1:6379", "")
+	redisCache, err := cache.NewRedisCache(redisAddr(), "")
 	if err != nil {
 		panic(err)
 	}
@@ -52,50 +66,184 @@ func main() {
 	logger.SetupLogger()
 
 	// Create or modify the database tables based on the model structs found in the imported package
-	db.AutoMigrate(&domain.Message{}, &domain.User{}, &domain.Payment{})
+	db.AutoMigrate(&domain.Message{}, &domain.MessageRevision{}, &domain.User{}, &domain.Payment{}, &domain.RecoveryCode{}, &domain.APIKey{}, &domain.UserIdentity{}, &domain.Session{}, &domain.Conversation{}, &domain.ConversationParticipant{}, &domain.Reaction{}, &domain.Attachment{}, &domain.FlaggedMessage{}, &domain.RetentionPurge{}, &domain.ScheduledMessage{}, &domain.OrderInfo{})
+	// keyset pagination walks these in (created_at, id) order
+	db.Model(&domain.Message{}).AddIndex("idx_messages_conversation_created", "conversation_id", "created_at", "id")
+	db.Model(&domain.Message{}).AddIndex("idx_messages_created", "created_at", "id")
+	db.Model(&domain.Message{}).AddIndex("idx_messages_parent_created", "parent_id", "created_at", "id")
 
 	store := repository.NewDB(db, redisCache)
 
-	msgService = services.NewMessengerService(store)
+	searchIndex := search.NewPostgresIndex(db)
+	if err := searchIndex.Migrate(); err != nil {
+		panic(err)
+	}
+
+	// Redis pub/sub lets every instance push events to its own clients;
+	// REALTIME_PUBSUB=memory is enough when running a single instance
+	var events ports.PubSub
+	if os.Getenv("REALTIME_PUBSUB") == "memory" {
+		events = pubsub.NewMemoryPubSub()
+	} else {
+		events, err = pubsub.NewRedisPubSub(redisAddr(), "")
+		if err != nil {
+			panic(err)
+		}
+	}
+	hub = realtime.NewHub(events)
+	go hub.RunWithRetry(context.Background(), time.Second)
+
+	blobs, err := newBlobStore()
+	if err != nil {
+		panic(err)
+	}
+
+	moderationCfg := moderation.DefaultConfig()
+	if path := os.Getenv("MODERATION_CONFIG"); path != "" {
+		moderationCfg, err = moderation.LoadConfig(path)
+		if err != nil {
+			panic(err)
+		}
+	}
+	moderator, err := moderation.NewRuleModerator(moderationCfg, redisCache)
+	if err != nil {
+		panic(err)
+	}
+
+	msgService = services.NewMessengerService(store, hub, searchIndex, blobs, moderator)
+	go cleanupOrphanedBlobs(msgService)
+	go purgeExpiredMessages(msgService)
+	go runMessageScheduler(msgService)
 	userService = services.NewUserService(store)
 	paymentService = services.NewPaymentService(store)
 
+	// SSO is optional: only wire it up when an identity provider is configured
+	if issuer := os.Getenv("OIDC_ISSUER"); issuer != "" {
+		provider, err := oidc.NewProvider(issuer, os.Getenv("OIDC_CLIENT_ID"), os.Getenv("OIDC_CLIENT_SECRET"), os.Getenv("OIDC_REDIRECT_URL"))
+		if err != nil {
+			log.Printf("OIDC login disabled: %v", err)
+		} else {
+			oidcService = services.NewOIDCService(provider, store, redisCache)
+		}
+	}
+
+	// the gRPC API runs next to the HTTP one, on its own port, when GRPC_PORT is set
+	if port := os.Getenv("GRPC_PORT"); port != "" {
+		go serveGRPC(":" + port)
+	}
+
 	InitRoutes()
 }
 
-func InitRoutes() {
-	router := gin.Default()
-	router2 := gin.Default()
+func serveGRPC(addr string) {
+	apiCfg, err := repository.LoadAPIConfig()
+	if err != nil {
+		log.Fatalf("Error starting gRPC server: %v", err)
+	}
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		log.Fatalf("Error starting gRPC server: %v", err)
+	}
+	server := rpc.NewServer(userService, msgService, paymentService, apiCfg.JWTSecret)
+	if err := server.Serve(listener); err != nil {
+		log.Fatalf("gRPC server stopped: %v", err)
+	}
+}
 
-	pprof.Register(router)
-	pprof.Register(router2)
+// redisAddr is REDIS_ADDR, or the local Redis when it isn't set.
+func redisAddr() string {
+	if addr := os.Getenv("REDIS_ADDR"); addr != "" {
+		return addr
+	}
+	return "127.0.0.1:6379"
+}
 
-	v1 := router.Group("/v1")
+// newBlobStore picks where attachments are kept: an S3 compatible bucket when
+// BLOB_STORE=s3, otherwise the ATTACHMENTS_DIR directory.
+func newBlobStore() (ports.BlobStore, error) {
+	if os.Getenv("BLOB_STORE") == "s3" {
+		return blobstore.NewS3Store(os.Getenv("S3_ENDPOINT"), os.Getenv("S3_REGION"), os.Getenv("S3_BUCKET"),
+			os.Getenv("S3_ACCESS_KEY_ID"), os.Getenv("S3_SECRET_ACCESS_KEY"))
+	}
+	dir := os.Getenv("ATTACHMENTS_DIR")
+	if dir == "" {
+		dir = "data/attachments"
+	}
+	return blobstore.NewLocalStore(dir)
+}
 
-	messageHandler := handler.NewMessageHandler(*msgService)
-	v1.GET("/messages/:id", messageHandler.ReadMessage)
-	v1.GET("/messages", messageHandler.ReadMessages)
-	v1.POST("/messages", messageHandler.CreateMessage)
-	v1.PUT("/messages/:id", messageHandler.UpdateMessage)
-	v1.DELETE("/messages/:id", messageHandler.DeleteMessage)
+// cleanupOrphanedBlobs sweeps the blob store hourly. A day's grace leaves
+// plenty of room for uploads whose message is still being saved.
+func cleanupOrphanedBlobs(svc *services.MessengerService) {
+	for range time.Tick(time.Hour) {
+		deleted, err := svc.CleanupOrphanedBlobs(24 * time.Hour)
+		if err != nil {
+			log.Printf("orphaned blob cleanup failed: %v", err)
+		}
+		if deleted > 0 {
+			log.Printf("deleted %d orphaned blobs", deleted)
+		}
+	}
+}
 
-	userHandler := handler.NewUserHandler(*userService)
-	v1.GET("/users/:id", userHandler.ReadUser)
-	v1.GET("/users", userHandler.ReadUsers)
-	v1.POST("/users", userHandler.CreateUser)
-	v1.PUT("/users", userHandler.UpdateUser)
-	v1.DELETE("/users", userHandler.DeleteUser)
+// purgeExpiredMessages applies message retention hourly. MESSAGE_RETENTION_DAYS
+// is the default for conversations without their own; unset or 0 keeps their
+// messages forever.
+func purgeExpiredMessages(svc *services.MessengerService) {
+	days := 0
+	if v := os.Getenv("MESSAGE_RETENTION_DAYS"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			log.Printf("message retention disabled: invalid MESSAGE_RETENTION_DAYS %q", v)
+			return
+		}
+		days = n
+	}
+	for range time.Tick(time.Hour) {
+		purged, err := svc.PurgeExpiredMessages(days)
+		if err != nil {
+			log.Printf("message retention purge failed: %v", err)
+		}
+		if purged > 0 {
+			log.Printf("purged %d messages past retention", purged)
+		}
+	}
+}
+
+// runMessageScheduler delivers scheduled messages and deletes expired ones.
+// Both live in the database, so nothing is lost across restarts, and any
+// number of instances can run this side by side.
+func runMessageScheduler(svc *services.MessengerService) {
+	for now := range time.Tick(5 * time.Second) {
+		delivered, err := svc.DeliverScheduledMessages(now)
+		if err != nil {
+			log.Printf("scheduled message delivery failed: %v", err)
+		}
+		if delivered > 0 {
+			log.Printf("delivered %d scheduled messages", delivered)
+		}
+		if _, err := svc.ExpireMessages(now); err != nil {
+			log.Printf("message expiry failed: %v", err)
+		}
+	}
+}
 
-	v1.POST("/login", userHandler.LoginUser)
-	v1.POST("/membership/webhooks", userHandler.UpdateMembershipStatus)
+func InitRoutes() {
+	router := gin.New()
+	router.Use(handler.AccessLog(gin.DefaultWriter), gin.Recovery())
 
-	v2 := router2.Group("/v2")
-	paymentHandler := handler.NewPaymentHandler(*paymentService)
-	v2.POST("/create-checkout-session", paymentHandler.CreateCheckoutSession)
+	pprof.Register(router)
 
-	// v2.POST("?success=true", paymentHandler.CreateCheckoutSession)
-	// v2.POST("/wallet/deposit", paymentHandler.Deposit)
-	// v2.POST("/wallet/withdraw", paymentHandler.Withdraw)
+	svc := handler.Services{
+		Messages: msgService,
+		Users:    userService,
+		Payments: paymentService,
+		OIDC:     oidcService,
+		Hub:      hub,
+	}
+	handler.RegisterRoutes(router, svc)
+	// how much each API version is still used, to know when one can go
+	go handler.LogVersionUsage(time.Hour)
 
 	err := router.Run(":4242")
 	if err != nil {
@@ -108,8 +256,4 @@ func InitRoutes() {
 	// 	}
 	// }()
 
-	// if err := router2.Run(":4242"); err != nil {
-	// 	log.Fatalf("failed to run payments service: %v", err)
-	// }
-
 }
@@ -0,0 +1,131 @@
+package blobstore
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/LordMoMA/Hexagonal-Architecture/internal/core/domain"
+)
+
+var (
+	ErrBlobNotFound   = errors.New("blob not found")
+	ErrInvalidBlobKey = errors.New("invalid blob key")
+)
+
+// partial uploads are written under this prefix and renamed into place once
+// complete, so readers never see half a file
+const tempPrefix = ".upload-"
+
+// LocalStore keeps blobs as files under a root directory. It suits a single
+// instance or a shared volume.
+type LocalStore struct {
+	root string
+}
+
+func NewLocalStore(root string) (*LocalStore, error) {
+	if err := os.MkdirAll(root, 0o750); err != nil {
+		return nil, fmt.Errorf("blob directory not created: %v", err)
+	}
+	return &LocalStore{root: root}, nil
+}
+
+func (s *LocalStore) Put(key string, content io.Reader, size int64, contentType string) error {
+	name, err := s.path(key)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(name), 0o750); err != nil {
+		return fmt.Errorf("blob directory not created: %v", err)
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(name), tempPrefix+"*")
+	if err != nil {
+		return fmt.Errorf("blob not saved: %v", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	n, err := io.Copy(tmp, io.LimitReader(content, size+1))
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return fmt.Errorf("blob not saved: %v", err)
+	}
+	if n != size {
+		return fmt.Errorf("blob not saved: got %d bytes, expected %d", n, size)
+	}
+	if err := os.Rename(tmp.Name(), name); err != nil {
+		return fmt.Errorf("blob not saved: %v", err)
+	}
+	return nil
+}
+
+func (s *LocalStore) Get(key string) (io.ReadCloser, error) {
+	name, err := s.path(key)
+	if err != nil {
+		return nil, err
+	}
+	f, err := os.Open(name)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, ErrBlobNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("blob not read: %v", err)
+	}
+	return f, nil
+}
+
+func (s *LocalStore) Delete(key string) error {
+	name, err := s.path(key)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(name); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("blob not deleted: %v", err)
+	}
+	return nil
+}
+
+func (s *LocalStore) List(prefix string) ([]domain.BlobInfo, error) {
+	var blobs []domain.BlobInfo
+	err := filepath.WalkDir(s.root, func(name string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() || strings.HasPrefix(d.Name(), tempPrefix) {
+			return nil
+		}
+		rel, err := filepath.Rel(s.root, name)
+		if err != nil {
+			return err
+		}
+		key := filepath.ToSlash(rel)
+		if !strings.HasPrefix(key, prefix) {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		blobs = append(blobs, domain.BlobInfo{Key: key, Size: info.Size(), ModifiedAt: info.ModTime()})
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("blobs not listed: %v", err)
+	}
+	return blobs, nil
+}
+
+// path maps a key to a file under root, refusing keys that would escape it.
+func (s *LocalStore) path(key string) (string, error) {
+	if key == "" || strings.Contains(key, "\\") || path.Clean("/"+key) != "/"+key {
+		return "", ErrInvalidBlobKey
+	}
+	return filepath.Join(s.root, filepath.FromSlash(key)), nil
+}
@@ -62,3 +62,19 @@ func (c *RedisCache) Delete(key string) error {
 	}
 	return nil
 }
+
+// Increment atomically bumps the counter at key, starting the expiration
+// window when the counter is first created.
+func (c *RedisCache) Increment(key string, expiration time.Duration) (int64, error) {
+	ctx := context.Background()
+	n, err := c.client.Incr(ctx, key).Result()
+	if err != nil {
+		return 0, fmt.Errorf("failed to increment value for key %q: %v", key, err)
+	}
+	if n == 1 {
+		if err := c.client.Expire(ctx, key, expiration).Err(); err != nil {
+			return n, fmt.Errorf("failed to set expiration for key %q: %v", key, err)
+		}
+	}
+	return n, nil
+}
@@ -0,0 +1,199 @@
+// Package cli is the admin command line, a driving adapter like the HTTP
+// handlers. Ops tasks go through the same services, and so the same rules,
+// as the API rather than through raw SQL.
+//
+//	hexctl [-o table|json] [-dry-run] <group> <command> [flags] [args]
+//
+// Every command that changes something honours -dry-run: it checks what it
+// would act on and says what it would do, without doing it.
+package cli
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/LordMoMA/Hexagonal-Architecture/internal/core/ports"
+)
+
+// ErrUsage is returned, wrapped, for a command line that doesn't parse. The
+// usage has already been written to Err by then.
+var ErrUsage = errors.New("usage")
+
+// App runs hexctl commands against the services.
+type App struct {
+	users    ports.UserService
+	messages ports.MessengerService
+	payments ports.PaymentService
+
+	In  io.Reader
+	Out io.Writer
+	Err io.Writer
+
+	format string
+	dryRun bool
+}
+
+func New(users ports.UserService, messages ports.MessengerService, payments ports.PaymentService) *App {
+	return &App{
+		users:    users,
+		messages: messages,
+		payments: payments,
+		In:       os.Stdin,
+		Out:      os.Stdout,
+		Err:      os.Stderr,
+	}
+}
+
+type command struct {
+	args    string
+	summary string
+	run     func(a *App, args []string) (*output, error)
+}
+
+// groups is filled in by init, as the commands refer back to it for usage.
+var groups map[string]map[string]command
+
+func init() {
+	groups = map[string]map[string]command{
+		"users":    userCommands,
+		"messages": messageCommands,
+		"payments": paymentCommands,
+	}
+}
+
+// Run runs the command named by args, which are the process's arguments
+// without the program name.
+func (a *App) Run(args []string) error {
+	fs := flag.NewFlagSet("hexctl", flag.ContinueOnError)
+	fs.SetOutput(a.Err)
+	fs.StringVar(&a.format, "o", "table", "output format: table or json")
+	fs.BoolVar(&a.dryRun, "dry-run", false, "say what a command would change without changing it")
+	fs.Usage = a.usage
+	if err := fs.Parse(args); err != nil {
+		return fmt.Errorf("%w: %v", ErrUsage, err)
+	}
+	if a.format != "table" && a.format != "json" {
+		return a.usageError("-o must be table or json")
+	}
+
+	args = fs.Args()
+	if len(args) < 2 {
+		return a.usageError("missing command")
+	}
+	cmd, ok := groups[args[0]][args[1]]
+	if !ok {
+		return a.usageError("unknown command %q", strings.Join(args[:2], " "))
+	}
+
+	out, err := cmd.run(a, args[2:])
+	if err != nil {
+		return err
+	}
+	return a.write(out)
+}
+
+func (a *App) usage() {
+	fmt.Fprintln(a.Err, "usage: hexctl [-o table|json] [-dry-run] <group> <command> [flags] [args]")
+	fmt.Fprintln(a.Err)
+	w := tabwriter.NewWriter(a.Err, 0, 4, 2, ' ', 0)
+	for _, group := range sortedKeys(groups) {
+		for _, name := range sortedKeys(groups[group]) {
+			cmd := groups[group][name]
+			fmt.Fprintf(w, "  %s %s %s\t%s\n", group, name, cmd.args, cmd.summary)
+		}
+	}
+	w.Flush()
+}
+
+func (a *App) usageError(format string, args ...interface{}) error {
+	fmt.Fprintf(a.Err, "hexctl: "+format+"\n\n", args...)
+	a.usage()
+	return fmt.Errorf("%w: "+format, append([]interface{}{ErrUsage}, args...)...)
+}
+
+// flags parses a command's own flags, which come before its arguments, and
+// checks it got exactly nargs arguments, or at least one when nargs is -1.
+func (a *App) flags(fs *flag.FlagSet, args []string, nargs int) ([]string, error) {
+	fs.SetOutput(a.Err)
+	if err := fs.Parse(args); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrUsage, err)
+	}
+	rest := fs.Args()
+	if (nargs >= 0 && len(rest) != nargs) || (nargs < 0 && len(rest) == 0) {
+		return nil, a.usageError("%s: wrong number of arguments", fs.Name())
+	}
+	return rest, nil
+}
+
+// password reads a password given as "-" from In, so it needn't end up in
+// the shell's history.
+func (a *App) password(flagValue string) (string, error) {
+	if flagValue != "-" {
+		return flagValue, nil
+	}
+	line, err := bufio.NewReader(a.In).ReadString('\n')
+	if err != nil && !errors.Is(err, io.EOF) {
+		return "", fmt.Errorf("password not read: %v", err)
+	}
+	return strings.TrimRight(line, "\r\n"), nil
+}
+
+// output is what a command prints: value as JSON, or rows under headers as
+// a table. Rows without headers are printed as plain lines.
+type output struct {
+	value   interface{}
+	headers []string
+	rows    [][]string
+}
+
+type statusMessage struct {
+	Message string `json:"message"`
+	DryRun  bool   `json:"dry_run,omitempty"`
+}
+
+// changed reports the change a command made, or under -dry-run the one it
+// would have made.
+func (a *App) changed(did, would string) *output {
+	message := did
+	if a.dryRun {
+		message = would + " (dry run)"
+	}
+	return &output{
+		value: statusMessage{Message: message, DryRun: a.dryRun},
+		rows:  [][]string{{message}},
+	}
+}
+
+func (a *App) write(out *output) error {
+	if a.format == "json" {
+		enc := json.NewEncoder(a.Out)
+		enc.SetIndent("", "  ")
+		return enc.Encode(out.value)
+	}
+
+	w := tabwriter.NewWriter(a.Out, 0, 4, 2, ' ', 0)
+	if out.headers != nil {
+		fmt.Fprintln(w, strings.Join(out.headers, "\t"))
+	}
+	for _, row := range out.rows {
+		fmt.Fprintln(w, strings.Join(row, "\t"))
+	}
+	return w.Flush()
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,83 @@
+package cli
+
+import (
+	"flag"
+	"time"
+
+	"github.com/LordMoMA/Hexagonal-Architecture/internal/core/domain"
+)
+
+var messageCommands = map[string]command{
+	"revisions": {"ID", "show a message's earlier bodies", messageRevisions},
+	"delete":    {"ID", "delete a message, leaving a tombstone", deleteMessage},
+	"restore":   {"ID", "bring back a deleted message", restoreMessage},
+	"flagged":   {"[-status pending|reviewed] [-limit n] [-cursor c]", "list the moderation review queue", flaggedMessages},
+}
+
+func messageRevisions(a *App, args []string) (*output, error) {
+	rest, err := a.flags(flag.NewFlagSet("messages revisions", flag.ContinueOnError), args, 1)
+	if err != nil {
+		return nil, err
+	}
+	revisions, err := a.messages.ReadMessageRevisions(rest[0])
+	if err != nil {
+		return nil, err
+	}
+	out := &output{value: revisions, headers: []string{"ID", "KIND", "CREATED", "BODY"}}
+	for _, r := range revisions {
+		out.rows = append(out.rows, []string{r.ID, r.Kind, r.CreatedAt.Format(time.RFC3339), r.Body})
+	}
+	return out, nil
+}
+
+func deleteMessage(a *App, args []string) (*output, error) {
+	return changeMessage(a, "delete", args, a.messages.DeleteMessage, "Deleted", "Would delete")
+}
+
+func restoreMessage(a *App, args []string) (*output, error) {
+	return changeMessage(a, "restore", args, a.messages.RestoreMessage, "Restored", "Would restore")
+}
+
+// changeMessage checks the message exists, through its revisions, before
+// changing it, so that -dry-run can tell too.
+func changeMessage(a *App, name string, args []string, change func(id string) error, did, would string) (*output, error) {
+	rest, err := a.flags(flag.NewFlagSet("messages "+name, flag.ContinueOnError), args, 1)
+	if err != nil {
+		return nil, err
+	}
+	id := rest[0]
+	if _, err := a.messages.ReadMessageRevisions(id); err != nil {
+		return nil, err
+	}
+	if !a.dryRun {
+		if err := change(id); err != nil {
+			return nil, err
+		}
+	}
+	return a.changed(did+" message "+id, would+" message "+id), nil
+}
+
+func flaggedMessages(a *App, args []string) (*output, error) {
+	fs := flag.NewFlagSet("messages flagged", flag.ContinueOnError)
+	status := fs.String("status", "pending", "pending or reviewed")
+	opts := listFlags(fs)
+	if _, err := a.flags(fs, args, 0); err != nil {
+		return nil, err
+	}
+	if *status != "pending" && *status != "reviewed" {
+		return nil, domain.Validation("invalid_status", "status must be pending or reviewed")
+	}
+	if err := checkLimit(opts.Limit); err != nil {
+		return nil, err
+	}
+
+	page, err := a.messages.ReadFlaggedMessages(*status == "pending", *opts)
+	if err != nil {
+		return nil, err
+	}
+	out := &output{value: page, headers: []string{"ID", "MESSAGE", "USER", "FLAGGED", "REASONS", "DECISION"}}
+	for _, f := range page.Items {
+		out.rows = append(out.rows, []string{f.ID, f.MessageID, f.UserID, f.CreatedAt.Format(time.RFC3339), f.Reasons, f.Decision})
+	}
+	return withCursor(out, page.NextCursor), nil
+}
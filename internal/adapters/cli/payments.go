@@ -0,0 +1,26 @@
+package cli
+
+import (
+	"flag"
+	"time"
+)
+
+var paymentCommands = map[string]command{
+	"orders": {"USER_ID...", "list the orders of one or more users, newest first", listOrders},
+}
+
+func listOrders(a *App, args []string) (*output, error) {
+	userIDs, err := a.flags(flag.NewFlagSet("payments orders", flag.ContinueOnError), args, -1)
+	if err != nil {
+		return nil, err
+	}
+	orders, err := a.payments.ReadOrders(userIDs)
+	if err != nil {
+		return nil, err
+	}
+	out := &output{value: orders, headers: []string{"ORDER", "REFERENCE", "USER", "CHECKOUT", "AMOUNT", "CURRENCY", "STATUS", "CREATED"}}
+	for _, o := range orders {
+		out.rows = append(out.rows, []string{o.ID, o.OrderID, o.UserID, o.CheckoutID, o.Amount, o.Currency, o.Status, o.CreatedAt.Format(time.RFC3339)})
+	}
+	return out, nil
+}
@@ -0,0 +1,217 @@
+package cli
+
+import (
+	"flag"
+	"fmt"
+	"strconv"
+
+	"github.com/LordMoMA/Hexagonal-Architecture/internal/adapters/validation"
+	"github.com/LordMoMA/Hexagonal-Architecture/internal/core/domain"
+)
+
+var userCommands = map[string]command{
+	"list":              {"[-limit n] [-cursor c] [-sort field]", "list users", listUsers},
+	"show":              {"ID", "show a user", showUser},
+	"create":            {"-email e -password p|- [-admin]", "create a user, or an admin with -admin", createUser},
+	"reset-password":    {"-password p|- ID", "set a new password for a user", resetPassword},
+	"grant-membership":  {"ID", "give a user membership", setUserFlag("membership", true)},
+	"revoke-membership": {"ID", "take a user's membership away", setUserFlag("membership", false)},
+	"grant-admin":       {"ID", "make a user an admin", setUserFlag("admin", true)},
+	"revoke-admin":      {"ID", "make an admin a regular user", setUserFlag("admin", false)},
+	"unlock":            {"ID", "unlock a user locked out by failed logins", unlockUser},
+	"delete":            {"ID", "delete a user", deleteUser},
+}
+
+var userHeaders = []string{"ID", "EMAIL", "ADMIN", "MEMBERSHIP", "TOTP"}
+
+func userRow(u *domain.User) []string {
+	return []string{u.ID, u.Email, strconv.FormatBool(u.Admin), strconv.FormatBool(u.Membership), strconv.FormatBool(u.TOTPEnabled)}
+}
+
+func userOutput(u *domain.User) *output {
+	return &output{value: u, headers: userHeaders, rows: [][]string{userRow(u)}}
+}
+
+func listUsers(a *App, args []string) (*output, error) {
+	fs := flag.NewFlagSet("users list", flag.ContinueOnError)
+	opts := listFlags(fs)
+	if _, err := a.flags(fs, args, 0); err != nil {
+		return nil, err
+	}
+	if err := checkLimit(opts.Limit); err != nil {
+		return nil, err
+	}
+
+	page, err := a.users.ReadUsers(*opts)
+	if err != nil {
+		return nil, err
+	}
+	out := &output{value: page, headers: userHeaders}
+	for _, u := range page.Items {
+		out.rows = append(out.rows, userRow(u))
+	}
+	return withCursor(out, page.NextCursor), nil
+}
+
+func showUser(a *App, args []string) (*output, error) {
+	rest, err := a.flags(flag.NewFlagSet("users show", flag.ContinueOnError), args, 1)
+	if err != nil {
+		return nil, err
+	}
+	user, err := a.users.ReadUser(rest[0])
+	if err != nil {
+		return nil, err
+	}
+	return userOutput(user), nil
+}
+
+func createUser(a *App, args []string) (*output, error) {
+	fs := flag.NewFlagSet("users create", flag.ContinueOnError)
+	email := fs.String("email", "", "the user's email")
+	password := fs.String("password", "", `the user's password, or "-" to read it from stdin`)
+	admin := fs.Bool("admin", false, "make the user an admin")
+	if _, err := a.flags(fs, args, 0); err != nil {
+		return nil, err
+	}
+	pw, err := a.password(*password)
+	if err != nil {
+		return nil, err
+	}
+	if err := validation.Struct(validation.CreateUserRequest{Email: *email, Password: pw}); err != nil {
+		return nil, err
+	}
+
+	kind := "user"
+	if *admin {
+		kind = "admin"
+	}
+	if a.dryRun {
+		return a.changed("", "Would create "+kind+" "+*email), nil
+	}
+	user, err := a.users.CreateUser(*email, pw)
+	if err != nil {
+		return nil, err
+	}
+	if *admin {
+		// the user is there either way; say so, so a retry grants rather
+		// than creates
+		if err := a.users.UpdateAdminStatus(user.ID, true); err != nil {
+			return nil, fmt.Errorf("created user %s (%s) but could not make them an admin, run \"users grant-admin %s\": %w", user.ID, user.Email, user.ID, err)
+		}
+		user.Admin = true
+	}
+	return userOutput(user), nil
+}
+
+func resetPassword(a *App, args []string) (*output, error) {
+	fs := flag.NewFlagSet("users reset-password", flag.ContinueOnError)
+	password := fs.String("password", "", `the new password, or "-" to read it from stdin`)
+	rest, err := a.flags(fs, args, 1)
+	if err != nil {
+		return nil, err
+	}
+	user, err := a.users.ReadUser(rest[0])
+	if err != nil {
+		return nil, err
+	}
+	pw, err := a.password(*password)
+	if err != nil {
+		return nil, err
+	}
+	if err := validation.Struct(validation.UpdateUserRequest{Email: user.Email, Password: pw}); err != nil {
+		return nil, err
+	}
+
+	if !a.dryRun {
+		if err := a.users.UpdateUser(user.ID, user.Email, pw); err != nil {
+			return nil, err
+		}
+	}
+	return a.changed("Reset the password of "+user.Email, "Would reset the password of "+user.Email), nil
+}
+
+// setUserFlag builds the commands granting and revoking membership and
+// admin rights.
+func setUserFlag(name string, value bool) func(a *App, args []string) (*output, error) {
+	return func(a *App, args []string) (*output, error) {
+		verb := map[bool]string{true: "grant", false: "revoke"}[value]
+		rest, err := a.flags(flag.NewFlagSet("users "+verb+"-"+name, flag.ContinueOnError), args, 1)
+		if err != nil {
+			return nil, err
+		}
+		user, err := a.users.ReadUser(rest[0])
+		if err != nil {
+			return nil, err
+		}
+
+		if !a.dryRun {
+			update := a.users.UpdateMembershipStatus
+			if name == "admin" {
+				update = a.users.UpdateAdminStatus
+			}
+			if err := update(user.ID, value); err != nil {
+				return nil, err
+			}
+		}
+		past := map[bool]string{true: "Granted", false: "Revoked"}[value]
+		return a.changed(past+" "+name+" for "+user.Email, "Would "+verb+" "+name+" for "+user.Email), nil
+	}
+}
+
+func unlockUser(a *App, args []string) (*output, error) {
+	rest, err := a.flags(flag.NewFlagSet("users unlock", flag.ContinueOnError), args, 1)
+	if err != nil {
+		return nil, err
+	}
+	user, err := a.users.ReadUser(rest[0])
+	if err != nil {
+		return nil, err
+	}
+	if !a.dryRun {
+		if err := a.users.UnlockUser(user.ID); err != nil {
+			return nil, err
+		}
+	}
+	return a.changed("Unlocked "+user.Email, "Would unlock "+user.Email), nil
+}
+
+func deleteUser(a *App, args []string) (*output, error) {
+	rest, err := a.flags(flag.NewFlagSet("users delete", flag.ContinueOnError), args, 1)
+	if err != nil {
+		return nil, err
+	}
+	user, err := a.users.ReadUser(rest[0])
+	if err != nil {
+		return nil, err
+	}
+	if !a.dryRun {
+		if err := a.users.DeleteUser(user.ID); err != nil {
+			return nil, err
+		}
+	}
+	return a.changed("Deleted user "+user.ID+" ("+user.Email+")", "Would delete user "+user.ID+" ("+user.Email+")"), nil
+}
+
+// listFlags are the paging flags of list commands.
+func listFlags(fs *flag.FlagSet) *domain.ListOptions {
+	opts := &domain.ListOptions{}
+	fs.IntVar(&opts.Limit, "limit", 0, "page size, at most 100")
+	fs.StringVar(&opts.Cursor, "cursor", "", "next cursor of the previous page")
+	fs.StringVar(&opts.Sort, "sort", "", "field to sort by, prefixed with - for descending order")
+	return opts
+}
+
+func checkLimit(limit int) error {
+	if limit < 0 || limit > domain.MaxPageLimit {
+		return domain.Validation("invalid_limit", "limit must be between 1 and %d", domain.MaxPageLimit)
+	}
+	return nil
+}
+
+// withCursor adds a line telling how to get the next page to a table.
+func withCursor(out *output, cursor string) *output {
+	if cursor != "" {
+		out.rows = append(out.rows, []string{}, []string{"next page: -cursor " + cursor})
+	}
+	return out
+}
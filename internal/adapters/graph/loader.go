@@ -0,0 +1,75 @@
+package graph
+
+import "sync"
+
+// loader batches the reads of one kind made while resolving a query, so a
+// page of messages costs one read of their authors rather than one each.
+// Resolvers of a list queue the keys their items will need; the first load
+// after that fetches everything queued in one call, and the loads after it
+// find their value already there. Values are kept for the whole query, which
+// is also what makes them consistent within it.
+type loader[V any] struct {
+	fetch func(keys []string) (map[string]V, error)
+
+	mu      sync.Mutex
+	queued  []string
+	results map[string]*result[V]
+}
+
+type result[V any] struct {
+	done  chan struct{}
+	value V
+	err   error
+}
+
+func newLoader[V any](fetch func(keys []string) (map[string]V, error)) *loader[V] {
+	return &loader[V]{fetch: fetch, results: map[string]*result[V]{}}
+}
+
+// queue has keys fetched along with the next load.
+func (l *loader[V]) queue(keys ...string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for _, key := range keys {
+		if _, ok := l.results[key]; !ok && key != "" {
+			l.queued = append(l.queued, key)
+		}
+	}
+}
+
+// load returns the value fetched for key, or the zero value when there is
+// none, such as for a user who has since been deleted or an unset id.
+func (l *loader[V]) load(key string) (V, error) {
+	if key == "" {
+		var none V
+		return none, nil
+	}
+
+	l.mu.Lock()
+	if r, ok := l.results[key]; ok {
+		l.mu.Unlock()
+		<-r.done
+		return r.value, r.err
+	}
+
+	var keys []string
+	batch := map[string]*result[V]{}
+	for _, k := range append(l.queued, key) {
+		if _, ok := l.results[k]; ok {
+			continue
+		}
+		r := &result[V]{done: make(chan struct{})}
+		l.results[k] = r
+		batch[k] = r
+		keys = append(keys, k)
+	}
+	l.queued = nil
+	l.mu.Unlock()
+
+	values, err := l.fetch(keys)
+	for k, r := range batch {
+		r.value, r.err = values[k], err
+		close(r.done)
+	}
+	return batch[key].value, err
+}
@@ -0,0 +1,134 @@
+package graph
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/LordMoMA/Hexagonal-Architecture/internal/core/domain"
+	graphql "github.com/graph-gophers/graphql-go"
+)
+
+type queryResolver struct{}
+
+type pageArgs struct {
+	First *int32
+	After *string
+}
+
+func (a pageArgs) options() (domain.ListOptions, error) {
+	var opts domain.ListOptions
+	if a.First != nil {
+		if *a.First < 1 || *a.First > domain.MaxPageLimit {
+			return opts, domain.Validation("invalid_limit", "first must be between 1 and %d", domain.MaxPageLimit)
+		}
+		opts.Limit = int(*a.First)
+	}
+	if a.After != nil {
+		opts.Cursor = *a.After
+	}
+	return opts, nil
+}
+
+func (*queryResolver) Me(ctx context.Context) (*userResolver, error) {
+	req := requestFrom(ctx)
+	user, err := req.users.load(req.userID)
+	if err != nil {
+		return nil, fail(err)
+	}
+	if user == nil {
+		return nil, fail(domain.NotFound("user_not_found", "user not found"))
+	}
+	return &userResolver{req: req, user: user}, nil
+}
+
+func (*queryResolver) User(ctx context.Context, args struct{ ID graphql.ID }) (*userResolver, error) {
+	req := requestFrom(ctx)
+	user, err := req.users.load(string(args.ID))
+	if err != nil || user == nil {
+		return nil, failOrNull(err)
+	}
+	return &userResolver{req: req, user: user}, nil
+}
+
+func (*queryResolver) Users(ctx context.Context, args pageArgs) (*userPageResolver, error) {
+	req := requestFrom(ctx)
+	opts, err := args.options()
+	if err != nil {
+		return nil, fail(err)
+	}
+	page, err := req.schema.users.ReadUsers(opts)
+	if err != nil {
+		return nil, fail(err)
+	}
+
+	ids := make([]string, len(page.Items))
+	for i, u := range page.Items {
+		ids[i] = u.ID
+	}
+	items := make([]*userResolver, len(page.Items))
+	for i, u := range page.Items {
+		items[i] = &userResolver{req: req, user: u, page: ids}
+	}
+	return &userPageResolver{items, page.NextCursor}, nil
+}
+
+func (*queryResolver) Message(ctx context.Context, args struct{ ID graphql.ID }) (*messageResolver, error) {
+	req := requestFrom(ctx)
+	message, err := req.schema.messages.ReadMessage(req.userID, string(args.ID))
+	if err != nil {
+		return nil, failOrNull(err)
+	}
+	return &messageResolver{req, message}, nil
+}
+
+func (*queryResolver) Messages(ctx context.Context, args pageArgs) (*messagePageResolver, error) {
+	req := requestFrom(ctx)
+	opts, err := args.options()
+	if err != nil {
+		return nil, fail(err)
+	}
+	opts.Sort = "-created_at"
+	page, err := req.schema.messages.ReadMessages(req.userID, opts)
+	if err != nil {
+		return nil, fail(err)
+	}
+	return newMessagePage(req, page), nil
+}
+
+func (*queryResolver) Conversations(ctx context.Context) ([]*conversationResolver, error) {
+	req := requestFrom(ctx)
+	conversations, err := req.schema.messages.ReadConversations(req.userID)
+	if err != nil {
+		return nil, fail(err)
+	}
+	resolvers := make([]*conversationResolver, len(conversations))
+	for i, c := range conversations {
+		req.queueUsers(c)
+		resolvers[i] = &conversationResolver{req, c}
+	}
+	return resolvers, nil
+}
+
+// failOrNull leaves a missing item out as null rather than an error, the way
+// GraphQL clients expect of a lookup by id. Any other failure is an error.
+func failOrNull(err error) error {
+	if err == nil || errors.Is(err, domain.ErrNotFound) {
+		return nil
+	}
+	return fail(err)
+}
+
+func timeOrNil(t *time.Time) *graphql.Time {
+	if t == nil {
+		return nil
+	}
+	return &graphql.Time{Time: *t}
+}
+
+func cursorOrNil(cursor string) *string {
+	if cursor == "" {
+		return nil
+	}
+	return &cursor
+}
@@ -0,0 +1,148 @@
+// Package graph is the GraphQL driving adapter. It reads through the same
+// services as the HTTP handlers, on behalf of a caller the transport has
+// already authenticated, and batches the reads a query fans out into so
+// that nested fields don't cost a repository call per item.
+package graph
+
+import (
+	"context"
+	_ "embed"
+	"errors"
+
+	"github.com/LordMoMA/Hexagonal-Architecture/internal/core/domain"
+	"github.com/LordMoMA/Hexagonal-Architecture/internal/core/services"
+	graphql "github.com/graph-gophers/graphql-go"
+)
+
+//go:embed schema.graphql
+var schemaSDL string
+
+// maxDepth keeps a query from following message.conversation.messages and
+// back again without end.
+const maxDepth = 10
+
+// Schema is the GraphQL API over the user, message and payment services.
+type Schema struct {
+	schema   *graphql.Schema
+	users    *services.UserService
+	messages *services.MessengerService
+	payments *services.PaymentService
+}
+
+// NewSchema checks the resolvers against schema.graphql, so a mismatch
+// fails at startup rather than on the first query.
+func NewSchema(users *services.UserService, messages *services.MessengerService, payments *services.PaymentService) *Schema {
+	s := &Schema{users: users, messages: messages, payments: payments}
+	s.schema = graphql.MustParseSchema(schemaSDL, &queryResolver{}, graphql.MaxDepth(maxDepth))
+	return s
+}
+
+// Exec runs query for userID. Errors in resolving single fields come back in
+// the response next to whatever data could be read, as GraphQL has it.
+func (s *Schema) Exec(ctx context.Context, userID, query, operationName string, variables map[string]interface{}) *graphql.Response {
+	ctx = context.WithValue(ctx, requestKey{}, s.newRequest(userID))
+	return s.schema.Exec(ctx, query, operationName, variables)
+}
+
+type requestKey struct{}
+
+// request is the state of one query: who is asking and the loaders batching
+// what its resolvers read.
+type request struct {
+	schema        *Schema
+	userID        string
+	users         *loader[*domain.User]
+	conversations *loader[*domain.Conversation]
+	orders        *loader[[]*domain.OrderInfo]
+}
+
+func (s *Schema) newRequest(userID string) *request {
+	req := &request{schema: s, userID: userID}
+
+	req.users = newLoader(func(ids []string) (map[string]*domain.User, error) {
+		users, err := s.users.ReadUsersByIDs(ids)
+		if err != nil {
+			return nil, err
+		}
+		byID := make(map[string]*domain.User, len(users))
+		for _, u := range users {
+			byID[u.ID] = u
+		}
+		return byID, nil
+	})
+
+	req.conversations = newLoader(func(ids []string) (map[string]*domain.Conversation, error) {
+		conversations, err := s.messages.ReadConversationsByIDs(userID, ids)
+		if err != nil {
+			return nil, err
+		}
+		byID := make(map[string]*domain.Conversation, len(conversations))
+		for _, c := range conversations {
+			byID[c.ID] = c
+			req.queueUsers(c)
+		}
+		return byID, nil
+	})
+
+	req.orders = newLoader(func(userIDs []string) (map[string][]*domain.OrderInfo, error) {
+		orders, err := s.payments.ReadOrders(userIDs)
+		if err != nil {
+			return nil, err
+		}
+		byUser := make(map[string][]*domain.OrderInfo, len(userIDs))
+		for _, o := range orders {
+			byUser[o.UserID] = append(byUser[o.UserID], o)
+		}
+		return byUser, nil
+	})
+
+	return req
+}
+
+func requestFrom(ctx context.Context) *request {
+	return ctx.Value(requestKey{}).(*request)
+}
+
+// queueUsers has the users a conversation refers to read with the next batch.
+func (r *request) queueUsers(c *domain.Conversation) {
+	r.users.queue(c.CreatedBy)
+	r.users.queue(c.ParticipantIDs...)
+}
+
+func (r *request) callerIsAdmin() (bool, error) {
+	caller, err := r.users.load(r.userID)
+	if err != nil {
+		return false, err
+	}
+	return caller != nil && caller.Admin, nil
+}
+
+// queryError carries a domain error's code into the error's extensions, where
+// clients find it the way they find it in a problem+json body.
+type queryError struct {
+	err error
+}
+
+func (e *queryError) Error() string {
+	return e.err.Error()
+}
+
+func (e *queryError) Unwrap() error {
+	return e.err
+}
+
+func (e *queryError) Extensions() map[string]interface{} {
+	var domainErr *domain.Error
+	if !errors.As(e.err, &domainErr) {
+		return nil
+	}
+	extensions := map[string]interface{}{"code": domainErr.Code}
+	if len(domainErr.Fields) > 0 {
+		extensions["errors"] = domainErr.Fields
+	}
+	return extensions
+}
+
+func fail(err error) error {
+	return &queryError{err: err}
+}
@@ -0,0 +1,282 @@
+package graph
+
+import (
+	"github.com/LordMoMA/Hexagonal-Architecture/internal/core/domain"
+	graphql "github.com/graph-gophers/graphql-go"
+)
+
+type userResolver struct {
+	req  *request
+	user *domain.User
+	// page holds the ids of the users listed along with this one, if any
+	page []string
+}
+
+func (r *userResolver) ID() graphql.ID {
+	return graphql.ID(r.user.ID)
+}
+
+func (r *userResolver) Email() string {
+	return r.user.Email
+}
+
+func (r *userResolver) Membership() bool {
+	return r.user.Membership
+}
+
+func (r *userResolver) Admin() bool {
+	return r.user.Admin
+}
+
+func (r *userResolver) TotpEnabled() bool {
+	return r.user.TOTPEnabled
+}
+
+// Orders are for the user themselves to see, and for admins.
+func (r *userResolver) Orders() (*[]*orderResolver, error) {
+	admin, err := r.req.callerIsAdmin()
+	if err != nil {
+		return nil, fail(err)
+	}
+	if !admin && r.user.ID != r.req.userID {
+		return nil, fail(domain.Forbidden("orders_forbidden", "only the user and admins can see their orders"))
+	}
+	if admin {
+		// an admin sees everyone's, so read those of the whole page at once
+		r.req.orders.queue(r.page...)
+	}
+	orders, err := r.req.orders.load(r.user.ID)
+	if err != nil {
+		return nil, fail(err)
+	}
+	resolvers := make([]*orderResolver, len(orders))
+	for i, o := range orders {
+		resolvers[i] = &orderResolver{o}
+	}
+	return &resolvers, nil
+}
+
+type userPageResolver struct {
+	items      []*userResolver
+	nextCursor string
+}
+
+func (p *userPageResolver) Items() []*userResolver {
+	return p.items
+}
+
+func (p *userPageResolver) NextCursor() *string {
+	return cursorOrNil(p.nextCursor)
+}
+
+type messageResolver struct {
+	req     *request
+	message *domain.Message
+}
+
+// newMessagePage queues the authors and conversations of page's messages,
+// so whichever of them the query asks for is read in one go.
+func newMessagePage(req *request, page *domain.Page[*domain.Message]) *messagePageResolver {
+	items := make([]*messageResolver, len(page.Items))
+	for i, m := range page.Items {
+		req.users.queue(m.UserID)
+		req.conversations.queue(m.ConversationID)
+		items[i] = &messageResolver{req, m}
+	}
+	return &messagePageResolver{items, page.NextCursor}
+}
+
+func (r *messageResolver) ID() graphql.ID {
+	return graphql.ID(r.message.ID)
+}
+
+// Conversation is null for a conversation the caller has since left.
+func (r *messageResolver) Conversation() (*conversationResolver, error) {
+	conversation, err := r.req.conversations.load(r.message.ConversationID)
+	if err != nil || conversation == nil {
+		return nil, failOrNull(err)
+	}
+	return &conversationResolver{r.req, conversation}, nil
+}
+
+// Author is null for a deleted account.
+func (r *messageResolver) Author() (*userResolver, error) {
+	return loadUser(r.req, r.message.UserID)
+}
+
+func (r *messageResolver) ParentId() *graphql.ID {
+	if r.message.ParentID == "" {
+		return nil
+	}
+	id := graphql.ID(r.message.ParentID)
+	return &id
+}
+
+func (r *messageResolver) ReplyCount() int32 {
+	return int32(r.message.ReplyCount)
+}
+
+func (r *messageResolver) Body() string {
+	return r.message.Body
+}
+
+func (r *messageResolver) CreatedAt() graphql.Time {
+	return graphql.Time{Time: r.message.CreatedAt}
+}
+
+func (r *messageResolver) Edited() bool {
+	return r.message.Edited
+}
+
+func (r *messageResolver) EditedAt() *graphql.Time {
+	return timeOrNil(r.message.EditedAt)
+}
+
+func (r *messageResolver) Deleted() bool {
+	return r.message.Deleted
+}
+
+func (r *messageResolver) ExpiresAt() *graphql.Time {
+	return timeOrNil(r.message.ExpiresAt)
+}
+
+func (r *messageResolver) Reactions() []*reactionResolver {
+	resolvers := make([]*reactionResolver, len(r.message.Reactions))
+	for i := range r.message.Reactions {
+		resolvers[i] = &reactionResolver{r.message.Reactions[i]}
+	}
+	return resolvers
+}
+
+type messagePageResolver struct {
+	items      []*messageResolver
+	nextCursor string
+}
+
+func (p *messagePageResolver) Items() []*messageResolver {
+	return p.items
+}
+
+func (p *messagePageResolver) NextCursor() *string {
+	return cursorOrNil(p.nextCursor)
+}
+
+type reactionResolver struct {
+	reaction domain.ReactionCount
+}
+
+func (r *reactionResolver) Emoji() string {
+	return r.reaction.Emoji
+}
+
+func (r *reactionResolver) Count() int32 {
+	return int32(r.reaction.Count)
+}
+
+type conversationResolver struct {
+	req          *request
+	conversation *domain.Conversation
+}
+
+func (r *conversationResolver) ID() graphql.ID {
+	return graphql.ID(r.conversation.ID)
+}
+
+func (r *conversationResolver) Kind() string {
+	return r.conversation.Kind
+}
+
+func (r *conversationResolver) Title() string {
+	return r.conversation.Title
+}
+
+func (r *conversationResolver) CreatedBy() (*userResolver, error) {
+	return loadUser(r.req, r.conversation.CreatedBy)
+}
+
+func (r *conversationResolver) CreatedAt() graphql.Time {
+	return graphql.Time{Time: r.conversation.CreatedAt}
+}
+
+// Participants leaves out deleted accounts.
+func (r *conversationResolver) Participants() ([]*userResolver, error) {
+	resolvers := make([]*userResolver, 0, len(r.conversation.ParticipantIDs))
+	for _, id := range r.conversation.ParticipantIDs {
+		user, err := r.req.users.load(id)
+		if err != nil {
+			return nil, fail(err)
+		}
+		if user != nil {
+			resolvers = append(resolvers, &userResolver{req: r.req, user: user})
+		}
+	}
+	return resolvers, nil
+}
+
+func (r *conversationResolver) UnreadCount() int32 {
+	return int32(r.conversation.UnreadCount)
+}
+
+func (r *conversationResolver) RetentionDays() *int32 {
+	if r.conversation.RetentionDays == nil {
+		return nil
+	}
+	days := int32(*r.conversation.RetentionDays)
+	return &days
+}
+
+func (r *conversationResolver) Messages(args pageArgs) (*messagePageResolver, error) {
+	opts, err := args.options()
+	if err != nil {
+		return nil, fail(err)
+	}
+	page, err := r.req.schema.messages.ReadConversationMessages(r.req.userID, r.conversation.ID, opts)
+	if err != nil {
+		return nil, fail(err)
+	}
+	return newMessagePage(r.req, page), nil
+}
+
+type orderResolver struct {
+	order *domain.OrderInfo
+}
+
+func (r *orderResolver) ID() graphql.ID {
+	return graphql.ID(r.order.ID)
+}
+
+func (r *orderResolver) OrderId() string {
+	return r.order.OrderID
+}
+
+func (r *orderResolver) CheckoutId() string {
+	return r.order.CheckoutID
+}
+
+func (r *orderResolver) SellerAccount() string {
+	return r.order.SellerAccount
+}
+
+func (r *orderResolver) Amount() string {
+	return r.order.Amount
+}
+
+func (r *orderResolver) Currency() string {
+	return r.order.Currency
+}
+
+func (r *orderResolver) Status() string {
+	return r.order.Status
+}
+
+func (r *orderResolver) CreatedAt() graphql.Time {
+	return graphql.Time{Time: r.order.CreatedAt}
+}
+
+func loadUser(req *request, id string) (*userResolver, error) {
+	user, err := req.users.load(id)
+	if err != nil || user == nil {
+		return nil, failOrNull(err)
+	}
+	return &userResolver{req: req, user: user}, nil
+}
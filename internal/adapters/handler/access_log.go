@@ -0,0 +1,62 @@
+package handler
+
+import (
+	"fmt"
+	"io"
+	"net/url"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// redactedParams are query parameters the access log leaves out, such as
+// the access token streaming clients pass in the URL.
+var redactedParams = []string{"access_token"}
+
+// AccessLog is gin's request log, written to out, with redactedParams
+// masked in the logged path.
+func AccessLog(out io.Writer) gin.HandlerFunc {
+	return gin.LoggerWithConfig(gin.LoggerConfig{
+		Output: out,
+		Formatter: func(param gin.LogFormatterParams) string {
+			var statusColor, methodColor, resetColor string
+			if param.IsOutputColor() {
+				statusColor = param.StatusCodeColor()
+				methodColor = param.MethodColor()
+				resetColor = param.ResetColor()
+			}
+			if param.Latency > time.Minute {
+				param.Latency = param.Latency.Truncate(time.Second)
+			}
+			return fmt.Sprintf("[GIN] %v |%s %3d %s| %13v | %15s |%s %-7s %s %#v\n%s",
+				param.TimeStamp.Format("2006/01/02 - 15:04:05"),
+				statusColor, param.StatusCode, resetColor,
+				param.Latency,
+				param.ClientIP,
+				methodColor, param.Method, resetColor,
+				redactPath(param.Path),
+				param.ErrorMessage,
+			)
+		},
+	})
+}
+
+func redactPath(path string) string {
+	u, err := url.Parse(path)
+	if err != nil {
+		return path
+	}
+	query := u.Query()
+	redacted := false
+	for _, name := range redactedParams {
+		if query.Has(name) {
+			query.Set(name, "REDACTED")
+			redacted = true
+		}
+	}
+	if !redacted {
+		return path
+	}
+	u.RawQuery = query.Encode()
+	return u.String()
+}
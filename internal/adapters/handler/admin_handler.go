@@ -0,0 +1,188 @@
+package handler
+
+import (
+	"net/http"
+
+	"github.com/LordMoMA/Hexagonal-Architecture/internal/adapters/repository"
+	"github.com/LordMoMA/Hexagonal-Architecture/internal/adapters/validation"
+	"github.com/LordMoMA/Hexagonal-Architecture/internal/core/domain"
+	"github.com/LordMoMA/Hexagonal-Architecture/internal/core/services"
+	"github.com/gin-gonic/gin"
+)
+
+// requireAdmin validates the bearer token and checks the caller is an admin.
+// It writes the error response itself, so callers just return when ok is false.
+func requireAdmin(ctx *gin.Context, svc services.UserService) (string, bool) {
+	apiCfg, err := repository.LoadAPIConfig()
+	if err != nil {
+		HandleError(ctx, http.StatusBadRequest, err)
+		return "", false
+	}
+
+	userID, err := validation.AccessToken(ctx.Request.Header.Get("Authorization"), apiCfg.JWTSecret)
+	if err != nil {
+		HandleError(ctx, http.StatusUnauthorized, err)
+		return "", false
+	}
+
+	user, err := svc.ReadUser(userID)
+	if err != nil || !user.Admin {
+		HandleError(ctx, http.StatusForbidden, domain.Forbidden("admin_required", "admin access required"))
+		return "", false
+	}
+	return userID, true
+}
+
+func (h *UserHandler) UnlockUser(ctx *gin.Context) {
+	if _, ok := requireAdmin(ctx, h.svc); !ok {
+		return
+	}
+
+	err := h.svc.UnlockUser(ctx.Param("id"))
+	if err != nil {
+		HandleError(ctx, http.StatusBadRequest, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{
+		"message": "User unlocked successfully",
+	})
+}
+
+// AdminHandler serves the admin endpoints that reach across services, such
+// as moderating messages, which needs the user service for the admin check.
+type AdminHandler struct {
+	users    services.UserService
+	messages services.MessengerService
+}
+
+func NewAdminHandler(users services.UserService, messages services.MessengerService) *AdminHandler {
+	return &AdminHandler{
+		users:    users,
+		messages: messages,
+	}
+}
+
+func (h *AdminHandler) ReadMessageRevisions(ctx *gin.Context) {
+	if _, ok := requireAdmin(ctx, h.users); !ok {
+		return
+	}
+
+	revisions, err := h.messages.ReadMessageRevisions(ctx.Param("id"))
+	if err != nil {
+		HandleError(ctx, http.StatusBadRequest, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, domain.Page[*domain.MessageRevision]{Items: revisions})
+}
+
+func (h *AdminHandler) RestoreMessage(ctx *gin.Context) {
+	if _, ok := requireAdmin(ctx, h.users); !ok {
+		return
+	}
+
+	err := h.messages.RestoreMessage(ctx.Param("id"))
+	if err != nil {
+		HandleError(ctx, http.StatusBadRequest, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{
+		"message": "Message restored successfully",
+	})
+}
+
+type ReviewRequest struct {
+	Decision string `json:"decision" binding:"required,oneof=approve remove"`
+}
+
+// ReadFlaggedMessages handles GET /admin/moderation/queue. ?status=reviewed
+// lists past decisions instead of what still awaits review.
+func (h *AdminHandler) ReadFlaggedMessages(ctx *gin.Context) {
+	if _, ok := requireAdmin(ctx, h.users); !ok {
+		return
+	}
+
+	status := ctx.DefaultQuery("status", "pending")
+	if status != "pending" && status != "reviewed" {
+		HandleError(ctx, http.StatusBadRequest, domain.Validation("invalid_status", "status must be pending or reviewed"))
+		return
+	}
+	opts, err := parseListOptions(ctx)
+	if err != nil {
+		HandleError(ctx, http.StatusBadRequest, err)
+		return
+	}
+
+	flagged, err := h.messages.ReadFlaggedMessages(status == "pending", opts)
+	if err != nil {
+		HandleError(ctx, http.StatusBadRequest, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, flagged)
+}
+
+func (h *AdminHandler) ReviewFlaggedMessage(ctx *gin.Context) {
+	adminID, ok := requireAdmin(ctx, h.users)
+	if !ok {
+		return
+	}
+
+	var req ReviewRequest
+	if !bindJSON(ctx, &req) {
+		return
+	}
+
+	flagged, err := h.messages.ReviewFlaggedMessage(adminID, ctx.Param("id"), req.Decision)
+	if err != nil {
+		HandleError(ctx, http.StatusBadRequest, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, flagged)
+}
+
+type RetentionRequest struct {
+	RetentionDays *int `json:"retention_days"`
+}
+
+// SetConversationRetention handles PUT /admin/conversations/:id/retention.
+// A null retention_days goes back to the default.
+func (h *AdminHandler) SetConversationRetention(ctx *gin.Context) {
+	if _, ok := requireAdmin(ctx, h.users); !ok {
+		return
+	}
+
+	var req RetentionRequest
+	if !bindJSON(ctx, &req) {
+		return
+	}
+
+	err := h.messages.SetConversationRetention(ctx.Param("id"), req.RetentionDays)
+	if err != nil {
+		HandleError(ctx, http.StatusBadRequest, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{
+		"message": "Retention updated successfully",
+	})
+}
+
+func (h *AdminHandler) ReadRetentionPurges(ctx *gin.Context) {
+	if _, ok := requireAdmin(ctx, h.users); !ok {
+		return
+	}
+
+	opts, err := parseListOptions(ctx)
+	if err != nil {
+		HandleError(ctx, http.StatusBadRequest, err)
+		return
+	}
+
+	purges, err := h.messages.ReadRetentionPurges(opts)
+	if err != nil {
+		HandleError(ctx, http.StatusBadRequest, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, purges)
+}
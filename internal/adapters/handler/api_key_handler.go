@@ -0,0 +1,94 @@
+package handler
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/LordMoMA/Hexagonal-Architecture/internal/adapters/repository"
+	"github.com/LordMoMA/Hexagonal-Architecture/internal/adapters/validation"
+	"github.com/LordMoMA/Hexagonal-Architecture/internal/core/domain"
+	"github.com/gin-gonic/gin"
+)
+
+type CreateAPIKeyRequest struct {
+	Name      string     `json:"name" binding:"required"`
+	Scopes    []string   `json:"scopes" binding:"required"`
+	ExpiresAt *time.Time `json:"expires_at"`
+}
+
+// API keys are managed with a bearer token only, so a leaked key can't be
+// used to mint more keys.
+func (h *UserHandler) CreateAPIKey(ctx *gin.Context) {
+	apiCfg, err := repository.LoadAPIConfig()
+	if err != nil {
+		HandleError(ctx, http.StatusBadRequest, err)
+		return
+	}
+
+	userID, err := validation.AccessToken(ctx.Request.Header.Get("Authorization"), apiCfg.JWTSecret)
+	if err != nil {
+		HandleError(ctx, http.StatusBadRequest, err)
+		return
+	}
+
+	var req CreateAPIKeyRequest
+	if !bindJSON(ctx, &req) {
+		return
+	}
+
+	key, plaintext, err := h.svc.CreateAPIKey(userID, req.Name, req.Scopes, req.ExpiresAt)
+	if err != nil {
+		HandleError(ctx, http.StatusBadRequest, err)
+		return
+	}
+
+	ctx.JSON(http.StatusCreated, gin.H{
+		"api_key": key,
+		"key":     plaintext,
+	})
+}
+
+func (h *UserHandler) ListAPIKeys(ctx *gin.Context) {
+	apiCfg, err := repository.LoadAPIConfig()
+	if err != nil {
+		HandleError(ctx, http.StatusBadRequest, err)
+		return
+	}
+
+	userID, err := validation.AccessToken(ctx.Request.Header.Get("Authorization"), apiCfg.JWTSecret)
+	if err != nil {
+		HandleError(ctx, http.StatusBadRequest, err)
+		return
+	}
+
+	keys, err := h.svc.ListAPIKeys(userID)
+	if err != nil {
+		HandleError(ctx, http.StatusBadRequest, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, domain.Page[*domain.APIKey]{Items: keys})
+}
+
+func (h *UserHandler) RevokeAPIKey(ctx *gin.Context) {
+	apiCfg, err := repository.LoadAPIConfig()
+	if err != nil {
+		HandleError(ctx, http.StatusBadRequest, err)
+		return
+	}
+
+	userID, err := validation.AccessToken(ctx.Request.Header.Get("Authorization"), apiCfg.JWTSecret)
+	if err != nil {
+		HandleError(ctx, http.StatusBadRequest, err)
+		return
+	}
+
+	err = h.svc.RevokeAPIKey(userID, ctx.Param("id"))
+	if err != nil {
+		HandleError(ctx, http.StatusBadRequest, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{
+		"message": "API key revoked successfully",
+	})
+}
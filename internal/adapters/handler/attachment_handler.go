@@ -0,0 +1,174 @@
+package handler
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/LordMoMA/Hexagonal-Architecture/internal/adapters/repository"
+	"github.com/LordMoMA/Hexagonal-Architecture/internal/core/domain"
+	"github.com/LordMoMA/Hexagonal-Architecture/internal/core/services"
+	"github.com/gin-gonic/gin"
+)
+
+const attachmentLinkTTL = 15 * time.Minute
+
+var errInvalidAttachmentLink = domain.Forbidden("invalid_download_link", "download link is invalid or has expired")
+
+// bindMultipartMessage reads a message posted as multipart/form-data: the
+// conversation_id, parent_id and body fields plus up to
+// MaxAttachmentsPerPost files under "files".
+func bindMultipartMessage(ctx *gin.Context) (CreateMessageRequest, []domain.AttachmentUpload, error) {
+	// room for every file at its limit plus the text fields
+	maxBody := int64(domain.MaxAttachmentsPerPost*domain.MaxAttachmentSize + 1<<20)
+	ctx.Request.Body = http.MaxBytesReader(ctx.Writer, ctx.Request.Body, maxBody)
+
+	form, err := ctx.MultipartForm()
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			return CreateMessageRequest{}, nil, services.ErrAttachmentTooLarge
+		}
+		return CreateMessageRequest{}, nil, domain.Validation("invalid_form", "form not readable: %v", err)
+	}
+
+	req := CreateMessageRequest{
+		ConversationID: ctx.PostForm("conversation_id"),
+		ParentID:       ctx.PostForm("parent_id"),
+		Body:           ctx.PostForm("body"),
+		Files:          len(form.File["files"]),
+	}
+	if req.SendAt, err = formTime(ctx, "send_at"); err != nil {
+		return req, nil, err
+	}
+	if req.ExpiresAt, err = formTime(ctx, "expires_at"); err != nil {
+		return req, nil, err
+	}
+	if err := validateRequest(req); err != nil {
+		return req, nil, err
+	}
+
+	files := form.File["files"]
+	if len(files) > domain.MaxAttachmentsPerPost {
+		return req, nil, services.ErrTooManyAttachments
+	}
+	uploads := make([]domain.AttachmentUpload, 0, len(files))
+	for _, file := range files {
+		if file.Size > domain.MaxAttachmentSize {
+			return req, nil, services.ErrAttachmentTooLarge
+		}
+		// the parts are closed with the form once the request is done
+		content, err := file.Open()
+		if err != nil {
+			return req, nil, domain.Validation("attachment_unreadable", "attachment not readable: %v", err)
+		}
+		uploads = append(uploads, domain.AttachmentUpload{
+			Filename: file.Filename,
+			Size:     file.Size,
+			Content:  content,
+		})
+	}
+	return req, uploads, nil
+}
+
+// formTime reads an optional RFC 3339 form field, as JSON bodies carry times.
+func formTime(ctx *gin.Context, field string) (*time.Time, error) {
+	value := ctx.PostForm(field)
+	if value == "" {
+		return nil, nil
+	}
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return nil, domain.Validation("invalid_time", "%s must be an RFC 3339 time", field)
+	}
+	return &t, nil
+}
+
+// AttachmentLink handles GET /attachments/:id/link. It hands a participant a
+// download URL that works without credentials until it expires, so it can
+// be used directly in an <img> or <a> tag.
+func (h *MessageHandler) AttachmentLink(ctx *gin.Context) {
+	apiCfg, err := repository.LoadAPIConfig()
+	if err != nil {
+		HandleError(ctx, http.StatusBadRequest, err)
+		return
+	}
+
+	userID, err := authenticatedUserID(ctx, apiCfg.JWTSecret)
+	if err != nil {
+		HandleError(ctx, http.StatusBadRequest, err)
+		return
+	}
+
+	attachment, err := h.svc.ReadAttachment(userID, ctx.Param("id"))
+	if err != nil {
+		HandleError(ctx, http.StatusBadRequest, err)
+		return
+	}
+
+	expires := time.Now().Add(attachmentLinkTTL).Unix()
+	url := fmt.Sprintf("/v1/attachments/%s/download?expires=%d&signature=%s",
+		attachment.ID, expires, signAttachmentLink(apiCfg.JWTSecret, attachment.ID, expires))
+
+	ctx.JSON(http.StatusOK, gin.H{
+		"url":        url,
+		"expires_at": time.Unix(expires, 0).UTC(),
+	})
+}
+
+// DownloadAttachment handles GET /attachments/:id/download with the query
+// string from AttachmentLink.
+func (h *MessageHandler) DownloadAttachment(ctx *gin.Context) {
+	apiCfg, err := repository.LoadAPIConfig()
+	if err != nil {
+		HandleError(ctx, http.StatusBadRequest, err)
+		return
+	}
+
+	id := ctx.Param("id")
+	expires, err := strconv.ParseInt(ctx.Query("expires"), 10, 64)
+	if err != nil || time.Now().Unix() > expires {
+		HandleError(ctx, http.StatusForbidden, errInvalidAttachmentLink)
+		return
+	}
+	expected := signAttachmentLink(apiCfg.JWTSecret, id, expires)
+	if !hmac.Equal([]byte(ctx.Query("signature")), []byte(expected)) {
+		HandleError(ctx, http.StatusForbidden, errInvalidAttachmentLink)
+		return
+	}
+
+	attachment, content, err := h.svc.OpenAttachment(id)
+	if err != nil {
+		HandleError(ctx, http.StatusNotFound, err)
+		return
+	}
+	defer content.Close()
+
+	header := ctx.Writer.Header()
+	header.Set("Content-Type", attachment.ContentType)
+	header.Set("Content-Length", strconv.FormatInt(attachment.Size, 10))
+	disposition := mime.FormatMediaType("attachment", map[string]string{"filename": attachment.Filename})
+	if disposition == "" {
+		disposition = "attachment"
+	}
+	header.Set("Content-Disposition", disposition)
+	header.Set("X-Content-Type-Options", "nosniff")
+	header.Set("Cache-Control", "private, max-age="+strconv.FormatInt(expires-time.Now().Unix(), 10))
+	ctx.Status(http.StatusOK)
+	if _, err := io.Copy(ctx.Writer, content); err != nil {
+		fmt.Printf("Error streaming attachment %s: %v", id, err)
+	}
+}
+
+func signAttachmentLink(secret, id string, expires int64) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	fmt.Fprintf(mac, "attachment-download:%s:%d", id, expires)
+	return hex.EncodeToString(mac.Sum(nil))
+}
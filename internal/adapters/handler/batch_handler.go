@@ -0,0 +1,177 @@
+package handler
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/LordMoMA/Hexagonal-Architecture/internal/adapters/repository"
+	"github.com/LordMoMA/Hexagonal-Architecture/internal/core/domain"
+	"github.com/LordMoMA/Hexagonal-Architecture/internal/core/services"
+	"github.com/gin-gonic/gin"
+)
+
+// BatchRequest carries the items of a :batch route, at most
+// domain.MaxBatchSize of them, or domain.MaxUserBatchSize users. Atomic saves
+// them in one transaction, all or none; otherwise each is saved on its own
+// and can fail alone.
+type BatchRequest[T any] struct {
+	Atomic bool `json:"atomic"`
+	Items  []T  `json:"items" binding:"required,min=1"`
+}
+
+// BatchResponse has the outcome of every item of a batch, in order. A batch
+// that could be read is answered with 200 however its items fared.
+type BatchResponse struct {
+	Atomic    bool          `json:"atomic"`
+	Succeeded int           `json:"succeeded"`
+	Failed    int           `json:"failed"`
+	Results   []BatchResult `json:"results"`
+}
+
+// BatchResult is what became of one item: the status a call of its own
+// would have had, and the ID it was saved under or why it wasn't.
+type BatchResult struct {
+	Index  int      `json:"index"`
+	Status int      `json:"status"`
+	ID     string   `json:"id,omitempty"`
+	Error  *Problem `json:"error,omitempty"`
+}
+
+// ErrScheduledBatch rejects a batch item with send_at set: scheduled messages
+// have their own route.
+var ErrScheduledBatch = domain.Validation("scheduled_batch", "scheduled messages can't be posted in a batch")
+
+// customMethod has handler serve a custom method route such as
+// /messages:batch. gin takes the colon for the start of a parameter named
+// after the method, which also matches e.g. /messagesfoo, so anything but the
+// method itself is not found.
+func customMethod(name string, handler gin.HandlerFunc) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		if ctx.Param(name) != ":"+name {
+			HandleError(ctx, http.StatusNotFound, domain.NotFound("route_not_found", "%s not found", ctx.Request.URL.Path))
+			return
+		}
+		handler(ctx)
+	}
+}
+
+func (h *MessageHandler) CreateMessages(ctx *gin.Context) {
+	apiCfg, err := repository.LoadAPIConfig()
+	if err != nil {
+		HandleError(ctx, http.StatusBadRequest, err)
+		return
+	}
+
+	userID, err := authenticatedUserID(ctx, apiCfg.JWTSecret)
+	if err != nil {
+		HandleError(ctx, http.StatusBadRequest, err)
+		return
+	}
+
+	var req BatchRequest[CreateMessageRequest]
+	if !bindJSON(ctx, &req) {
+		return
+	}
+	checkMessage := func(item CreateMessageRequest) error {
+		if item.SendAt != nil {
+			return ErrScheduledBatch
+		}
+		return nil
+	}
+	runBatch(ctx, req, domain.MaxBatchSize, checkMessage, func(items []CreateMessageRequest) ([]string, []error) {
+		messages := make([]domain.Message, len(items))
+		for i, item := range items {
+			messages[i] = item.Message()
+		}
+		created, errs := h.svc.CreateMessages(userID, messages, req.Atomic)
+		ids := make([]string, len(created))
+		for i, message := range created {
+			if message != nil {
+				ids[i] = message.ID
+			}
+		}
+		return ids, errs
+	})
+}
+
+// CreateUsers signs many users up at once, for imports, and so is for admins
+// only.
+func (h *UserHandler) CreateUsers(ctx *gin.Context) {
+	if _, ok := requireAdmin(ctx, h.svc); !ok {
+		return
+	}
+
+	var req BatchRequest[CreateUserRequest]
+	if !bindJSON(ctx, &req) {
+		return
+	}
+	runBatch(ctx, req, domain.MaxUserBatchSize, nil, func(items []CreateUserRequest) ([]string, []error) {
+		users := make([]domain.NewUser, len(items))
+		for i, item := range items {
+			users[i] = domain.NewUser{Email: item.Email, Password: item.Password}
+		}
+		created, errs := h.svc.CreateUsers(users, req.Atomic)
+		ids := make([]string, len(created))
+		for i, user := range created {
+			if user != nil {
+				ids[i] = user.ID
+			}
+		}
+		return ids, errs
+	})
+}
+
+// runBatch turns away a batch of more than max items. Otherwise it holds
+// every item of req to its request type's binding rules and check, when there
+// is one, then has save save those that passed and writes the outcome of
+// each. An atomic batch with an invalid item saves nothing.
+func runBatch[T any](ctx *gin.Context, req BatchRequest[T], max int, check func(T) error, save func(items []T) ([]string, []error)) {
+	if len(req.Items) > max {
+		HandleError(ctx, http.StatusBadRequest, domain.InvalidFields([]domain.FieldError{{
+			Field:   "items",
+			Code:    "max",
+			Message: fmt.Sprintf("must have at most %d items", max),
+		}}))
+		return
+	}
+
+	ids := make([]string, len(req.Items))
+	errs := make([]error, len(req.Items))
+	var valid []T
+	var at []int
+	for i, item := range req.Items {
+		errs[i] = validateRequest(item)
+		if errs[i] == nil && check != nil {
+			errs[i] = check(item)
+		}
+		if errs[i] == nil {
+			valid = append(valid, item)
+			at = append(at, i)
+		}
+	}
+
+	switch {
+	case req.Atomic && len(valid) < len(req.Items):
+		services.AbortBatch(errs)
+	case len(valid) > 0:
+		saved, saveErrs := save(valid)
+		for j, i := range at {
+			ids[i], errs[i] = saved[j], saveErrs[j]
+		}
+	}
+
+	response := BatchResponse{Atomic: req.Atomic, Results: make([]BatchResult, len(req.Items))}
+	for i, err := range errs {
+		result := BatchResult{Index: i, Status: http.StatusCreated, ID: ids[i]}
+		if err != nil {
+			problem := newProblem(http.StatusBadRequest, err)
+			problem.Instance = ctx.Request.URL.Path
+			result.Status, result.Error = problem.Status, &problem
+			response.Failed++
+		} else {
+			response.Succeeded++
+		}
+		response.Results[i] = result
+	}
+	ctx.JSON(http.StatusOK, response)
+}
@@ -0,0 +1,88 @@
+package handler
+
+import (
+	"net/http"
+
+	"github.com/LordMoMA/Hexagonal-Architecture/internal/adapters/repository"
+	"github.com/LordMoMA/Hexagonal-Architecture/internal/core/domain"
+	"github.com/gin-gonic/gin"
+)
+
+func (h *MessageHandler) CreateConversation(ctx *gin.Context) {
+	apiCfg, err := repository.LoadAPIConfig()
+	if err != nil {
+		HandleError(ctx, http.StatusBadRequest, err)
+		return
+	}
+
+	userID, err := authenticatedUserID(ctx, apiCfg.JWTSecret)
+	if err != nil {
+		HandleError(ctx, http.StatusBadRequest, err)
+		return
+	}
+
+	var req CreateConversationRequest
+	if !bindJSON(ctx, &req) {
+		return
+	}
+
+	conversation, err := h.svc.CreateConversation(userID, domain.Conversation{
+		Kind:           req.Kind,
+		Title:          req.Title,
+		ParticipantIDs: req.ParticipantIDs,
+	})
+	if err != nil {
+		HandleError(ctx, http.StatusBadRequest, err)
+		return
+	}
+
+	ctx.JSON(http.StatusCreated, conversation)
+}
+
+func (h *MessageHandler) ReadConversations(ctx *gin.Context) {
+	apiCfg, err := repository.LoadAPIConfig()
+	if err != nil {
+		HandleError(ctx, http.StatusBadRequest, err)
+		return
+	}
+
+	userID, err := authenticatedUserID(ctx, apiCfg.JWTSecret)
+	if err != nil {
+		HandleError(ctx, http.StatusBadRequest, err)
+		return
+	}
+
+	conversations, err := h.svc.ReadConversations(userID)
+	if err != nil {
+		HandleError(ctx, http.StatusBadRequest, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, domain.Page[*domain.Conversation]{Items: conversations})
+}
+
+func (h *MessageHandler) ReadConversationMessages(ctx *gin.Context) {
+	apiCfg, err := repository.LoadAPIConfig()
+	if err != nil {
+		HandleError(ctx, http.StatusBadRequest, err)
+		return
+	}
+
+	userID, err := authenticatedUserID(ctx, apiCfg.JWTSecret)
+	if err != nil {
+		HandleError(ctx, http.StatusBadRequest, err)
+		return
+	}
+
+	opts, err := parseListOptions(ctx)
+	if err != nil {
+		HandleError(ctx, http.StatusBadRequest, err)
+		return
+	}
+
+	messages, err := h.svc.ReadConversationMessages(userID, ctx.Param("id"), opts)
+	if err != nil {
+		HandleError(ctx, http.StatusBadRequest, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, messages)
+}
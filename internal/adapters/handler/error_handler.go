@@ -1,9 +1,67 @@
 package handler
 
-import "github.com/gin-gonic/gin"
+import (
+	"errors"
+	"net/http"
+	"strings"
 
+	"github.com/LordMoMA/Hexagonal-Architecture/internal/core/domain"
+	"github.com/gin-gonic/gin"
+)
+
+// Problem is an RFC 7807 problem details body. Code is a stable, machine
+// readable name for the error, e.g. "message_not_found".
+type Problem struct {
+	Type     string `json:"type"`
+	Title    string `json:"title"`
+	Status   int    `json:"status"`
+	Detail   string `json:"detail"`
+	Instance string `json:"instance,omitempty"`
+	Code     string `json:"code"`
+	// Errors lists every invalid field of a request that failed validation
+	Errors []domain.FieldError `json:"errors,omitempty"`
+}
+
+// errorStatuses maps the kinds of domain error to their HTTP status.
+var errorStatuses = map[error]int{
+	domain.ErrNotFound:     http.StatusNotFound,
+	domain.ErrConflict:     http.StatusConflict,
+	domain.ErrUnauthorized: http.StatusUnauthorized,
+	domain.ErrForbidden:    http.StatusForbidden,
+	domain.ErrValidation:   http.StatusUnprocessableEntity,
+	domain.ErrUnavailable:  http.StatusServiceUnavailable,
+	domain.ErrRateLimited:  http.StatusTooManyRequests,
+	domain.ErrTooLarge:     http.StatusRequestEntityTooLarge,
+	domain.ErrUnsupported:  http.StatusUnsupportedMediaType,
+}
+
+// HandleError writes err as application/problem+json, downgraded for older
+// API versions like any other response. A domain.Error is sent
+// with the status of its kind; statusCode is for everything else, such as a
+// request body that isn't JSON.
 func HandleError(ctx *gin.Context, statusCode int, err error) {
-	ctx.JSON(statusCode, gin.H{
-		"error": err.Error(),
-	})
+	problem := newProblem(statusCode, err)
+	problem.Instance = ctx.Request.URL.Path
+	writeProblem(ctx, problem)
+}
+
+func newProblem(statusCode int, err error) Problem {
+	code := strings.ToLower(strings.ReplaceAll(http.StatusText(statusCode), " ", "_"))
+	var fields []domain.FieldError
+	var domainErr *domain.Error
+	if errors.As(err, &domainErr) {
+		if status, ok := errorStatuses[domainErr.Kind]; ok {
+			statusCode = status
+		}
+		code = domainErr.Code
+		fields = domainErr.Fields
+	}
+	return Problem{
+		Type:   "about:blank",
+		Title:  http.StatusText(statusCode),
+		Status: statusCode,
+		Detail: err.Error(),
+		Code:   code,
+		Errors: fields,
+	}
 }
@@ -0,0 +1,49 @@
+package handler
+
+import (
+	"net/http"
+
+	"github.com/LordMoMA/Hexagonal-Architecture/internal/adapters/graph"
+	"github.com/LordMoMA/Hexagonal-Architecture/internal/adapters/repository"
+	"github.com/gin-gonic/gin"
+)
+
+type GraphQLHandler struct {
+	schema *graph.Schema
+}
+
+func NewGraphQLHandler(schema *graph.Schema) *GraphQLHandler {
+	return &GraphQLHandler{
+		schema: schema,
+	}
+}
+
+type GraphQLRequest struct {
+	Query         string                 `json:"query" binding:"required"`
+	OperationName string                 `json:"operationName"`
+	Variables     map[string]interface{} `json:"variables"`
+}
+
+// Query runs a GraphQL query for the caller. Only the request as a whole
+// fails with a problem+json body; errors resolving single fields are listed
+// in the response's errors next to its data, with a 200.
+func (h *GraphQLHandler) Query(ctx *gin.Context) {
+	apiCfg, err := repository.LoadAPIConfig()
+	if err != nil {
+		HandleError(ctx, http.StatusBadRequest, err)
+		return
+	}
+
+	userID, err := authenticatedUserID(ctx, apiCfg.JWTSecret)
+	if err != nil {
+		HandleError(ctx, http.StatusUnauthorized, err)
+		return
+	}
+
+	var req GraphQLRequest
+	if !bindJSON(ctx, &req) {
+		return
+	}
+
+	ctx.JSON(http.StatusOK, h.schema.Exec(ctx.Request.Context(), userID, req.Query, req.OperationName, req.Variables))
+}
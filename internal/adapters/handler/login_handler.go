@@ -3,28 +3,43 @@ package handler
 import (
 	"net/http"
 
+	"github.com/LordMoMA/Hexagonal-Architecture/internal/adapters/repository"
 	"github.com/LordMoMA/Hexagonal-Architecture/internal/core/domain"
 	"github.com/gin-gonic/gin"
 )
 
 func (h *UserHandler) LoginUser(ctx *gin.Context) {
-	var user domain.User
-	if err := ctx.ShouldBindJSON(&user); err != nil {
-		HandleError(ctx, http.StatusBadRequest, err)
+	var req LoginRequest
+	if !bindJSON(ctx, &req) {
 		return
 	}
 
-	response, err := h.svc.LoginUser(user.Email, user.Password)
+	response, err := h.svc.LoginUser(req.Email, req.Password, clientInfo(ctx))
 	if err != nil {
 		HandleError(ctx, http.StatusBadRequest, err)
 		return
 	}
 
-	ctx.JSON(http.StatusOK, gin.H{
-		"id":            response.ID,
-		"email":         response.Email,
-		"access_token":  response.AccessToken,
-		"refresh_token": response.RefreshToken,
-		"is_member":     response.Membership,
-	})
+	writeLoginResponse(ctx, response)
+}
+
+// writeLoginResponse sends the token pair, or only the MFA challenge when the
+// user has 2FA on.
+func writeLoginResponse(ctx *gin.Context, response *repository.LoginResponse) {
+	if response.MFARequired {
+		ctx.JSON(http.StatusOK, gin.H{
+			"mfa_required": true,
+			"mfa_token":    response.MFAToken,
+		})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, newLoginResponse(response))
+}
+
+func clientInfo(ctx *gin.Context) domain.ClientInfo {
+	return domain.ClientInfo{
+		IP:        ctx.ClientIP(),
+		UserAgent: ctx.Request.UserAgent(),
+	}
 }
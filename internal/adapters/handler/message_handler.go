@@ -1,8 +1,9 @@
 package handler
 
 import (
-	"fmt"
 	"net/http"
+	"strconv"
+	"strings"
 
 	"github.com/LordMoMA/Hexagonal-Architecture/internal/adapters/repository"
 	"github.com/LordMoMA/Hexagonal-Architecture/internal/core/domain"
@@ -28,21 +29,40 @@ func (h *MessageHandler) CreateMessage(ctx *gin.Context) {
 	}
 
 	// Validate token
-	userID, err := ValidateToken(ctx.Request.Header.Get("Authorization"), apiCfg.JWTSecret)
+	userID, err := authenticatedUserID(ctx, apiCfg.JWTSecret)
 	if err != nil {
 		HandleError(ctx, http.StatusBadRequest, err)
 		return
 	}
 
-	var message domain.Message
-	message.UserID = userID
+	var req CreateMessageRequest
+	var uploads []domain.AttachmentUpload
+	if strings.HasPrefix(ctx.ContentType(), "multipart/form-data") {
+		req, uploads, err = bindMultipartMessage(ctx)
+		if err != nil {
+			HandleError(ctx, http.StatusBadRequest, err)
+			return
+		}
+	} else if !bindJSON(ctx, &req) {
+		return
+	}
+	message := req.Message()
 
-	if err := ctx.ShouldBindJSON(&message); err != nil {
-		HandleError(ctx, http.StatusBadRequest, err)
+	if message.SendAt != nil {
+		if len(uploads) > 0 {
+			HandleError(ctx, http.StatusBadRequest, services.ErrScheduledAttachments)
+			return
+		}
+		scheduled, err := h.svc.ScheduleMessage(userID, message)
+		if err != nil {
+			HandleError(ctx, http.StatusBadRequest, err)
+			return
+		}
+		ctx.JSON(http.StatusCreated, scheduled)
 		return
 	}
 
-	err = h.svc.CreateMessage(userID, message)
+	err = h.svc.CreateMessage(userID, message, uploads...)
 	if err != nil {
 		HandleError(ctx, http.StatusBadRequest, err)
 		return
@@ -54,8 +74,20 @@ func (h *MessageHandler) CreateMessage(ctx *gin.Context) {
 }
 
 func (h *MessageHandler) ReadMessage(ctx *gin.Context) {
+	apiCfg, err := repository.LoadAPIConfig()
+	if err != nil {
+		HandleError(ctx, http.StatusBadRequest, err)
+		return
+	}
+
+	userID, err := authenticatedUserID(ctx, apiCfg.JWTSecret)
+	if err != nil {
+		HandleError(ctx, http.StatusBadRequest, err)
+		return
+	}
+
 	id := ctx.Param("id")
-	message, err := h.svc.ReadMessage(id)
+	message, err := h.svc.ReadMessage(userID, id)
 
 	if err != nil {
 		HandleError(ctx, http.StatusBadRequest, err)
@@ -65,8 +97,25 @@ func (h *MessageHandler) ReadMessage(ctx *gin.Context) {
 }
 
 func (h *MessageHandler) ReadMessages(ctx *gin.Context) {
+	apiCfg, err := repository.LoadAPIConfig()
+	if err != nil {
+		HandleError(ctx, http.StatusBadRequest, err)
+		return
+	}
+
+	userID, err := authenticatedUserID(ctx, apiCfg.JWTSecret)
+	if err != nil {
+		HandleError(ctx, http.StatusBadRequest, err)
+		return
+	}
+
+	opts, err := parseListOptions(ctx)
+	if err != nil {
+		HandleError(ctx, http.StatusBadRequest, err)
+		return
+	}
 
-	messages, err := h.svc.ReadMessages()
+	messages, err := h.svc.ReadMessages(userID, opts)
 
 	if err != nil {
 		HandleError(ctx, http.StatusBadRequest, err)
@@ -75,6 +124,67 @@ func (h *MessageHandler) ReadMessages(ctx *gin.Context) {
 	ctx.JSON(http.StatusOK, messages)
 }
 
+// ReadReplies handles GET /messages/:id/replies, one page of the message's
+// thread.
+func (h *MessageHandler) ReadReplies(ctx *gin.Context) {
+	apiCfg, err := repository.LoadAPIConfig()
+	if err != nil {
+		HandleError(ctx, http.StatusBadRequest, err)
+		return
+	}
+
+	userID, err := authenticatedUserID(ctx, apiCfg.JWTSecret)
+	if err != nil {
+		HandleError(ctx, http.StatusBadRequest, err)
+		return
+	}
+
+	opts, err := parseListOptions(ctx)
+	if err != nil {
+		HandleError(ctx, http.StatusBadRequest, err)
+		return
+	}
+
+	replies, err := h.svc.ReadReplies(userID, ctx.Param("id"), opts)
+	if err != nil {
+		HandleError(ctx, http.StatusBadRequest, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, replies)
+}
+
+// SearchMessages handles GET /messages/search?q=&conversation_id=&limit=.
+// Only conversations the caller takes part in are searched.
+func (h *MessageHandler) SearchMessages(ctx *gin.Context) {
+	apiCfg, err := repository.LoadAPIConfig()
+	if err != nil {
+		HandleError(ctx, http.StatusBadRequest, err)
+		return
+	}
+
+	userID, err := authenticatedUserID(ctx, apiCfg.JWTSecret)
+	if err != nil {
+		HandleError(ctx, http.StatusBadRequest, err)
+		return
+	}
+
+	limit := 0
+	if value := ctx.Query("limit"); value != "" {
+		limit, err = strconv.Atoi(value)
+		if err != nil || limit < 1 || limit > domain.MaxPageLimit {
+			HandleError(ctx, http.StatusBadRequest, domain.Validation("invalid_limit", "limit must be between 1 and %d", domain.MaxPageLimit))
+			return
+		}
+	}
+
+	hits, err := h.svc.SearchMessages(userID, ctx.Query("q"), ctx.Query("conversation_id"), limit)
+	if err != nil {
+		HandleError(ctx, http.StatusBadRequest, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, domain.Page[*domain.SearchHit]{Items: hits})
+}
+
 func (h *MessageHandler) UpdateMessage(ctx *gin.Context) {
 	apiCfg, err := repository.LoadAPIConfig()
 	if err != nil {
@@ -83,7 +193,7 @@ func (h *MessageHandler) UpdateMessage(ctx *gin.Context) {
 	}
 
 	// Validate token
-	userID, err := ValidateToken(ctx.Request.Header.Get("Authorization"), apiCfg.JWTSecret)
+	userID, err := authenticatedUserID(ctx, apiCfg.JWTSecret)
 	if err != nil {
 		HandleError(ctx, http.StatusBadRequest, err)
 		return
@@ -91,23 +201,22 @@ func (h *MessageHandler) UpdateMessage(ctx *gin.Context) {
 
 	// check if userID match with message.UserID
 	id := ctx.Param("id")
-	msg, err := h.svc.ReadMessage(id)
+	msg, err := h.svc.ReadMessage(userID, id)
 	if err != nil {
 		HandleError(ctx, http.StatusBadRequest, err)
 		return
 	}
 	if msg.UserID != userID {
-		HandleError(ctx, http.StatusBadRequest, fmt.Errorf("you are not authorized to update this message"))
+		HandleError(ctx, http.StatusBadRequest, domain.Forbidden("not_message_author", "you are not authorized to update this message"))
 		return
 	}
 
-	var message domain.Message
-	if err := ctx.ShouldBindJSON(&message); err != nil {
-		HandleError(ctx, http.StatusBadRequest, err)
+	var req UpdateMessageRequest
+	if !bindJSON(ctx, &req) {
 		return
 	}
 
-	err = h.svc.UpdateMessage(id, message)
+	err = h.svc.UpdateMessage(id, domain.Message{Body: req.Body})
 	if err != nil {
 		HandleError(ctx, http.StatusBadRequest, err)
 		return
@@ -125,7 +234,7 @@ func (h *MessageHandler) DeleteMessage(ctx *gin.Context) {
 		return
 	}
 
-	userID, err := ValidateToken(ctx.Request.Header.Get("Authorization"), apiCfg.JWTSecret)
+	userID, err := authenticatedUserID(ctx, apiCfg.JWTSecret)
 	if err != nil {
 		HandleError(ctx, http.StatusBadRequest, err)
 		return
@@ -133,13 +242,13 @@ func (h *MessageHandler) DeleteMessage(ctx *gin.Context) {
 
 	// check if userID match with message.UserID
 	id := ctx.Param("id")
-	message, err := h.svc.ReadMessage(id)
+	message, err := h.svc.ReadMessage(userID, id)
 	if err != nil {
 		HandleError(ctx, http.StatusBadRequest, err)
 		return
 	}
 	if message.UserID != userID {
-		HandleError(ctx, http.StatusBadRequest, fmt.Errorf("you are not authorized to delete this message"))
+		HandleError(ctx, http.StatusBadRequest, domain.Forbidden("not_message_author", "you are not authorized to delete this message"))
 		return
 	}
 
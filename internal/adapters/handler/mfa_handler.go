@@ -0,0 +1,81 @@
+package handler
+
+import (
+	"net/http"
+
+	"github.com/LordMoMA/Hexagonal-Architecture/internal/adapters/repository"
+	"github.com/LordMoMA/Hexagonal-Architecture/internal/adapters/validation"
+	"github.com/gin-gonic/gin"
+)
+
+type TOTPCodeRequest struct {
+	Code string `json:"code" binding:"required"`
+}
+
+// LoginUserMFA is the second login step: it exchanges the mfa_token from
+// /login plus a TOTP or recovery code for the access/refresh token pair.
+func (h *UserHandler) LoginUserMFA(ctx *gin.Context) {
+	var req MFALoginRequest
+	if !bindJSON(ctx, &req) {
+		return
+	}
+
+	response, err := h.svc.LoginUserMFA(req.MFAToken, req.Code, clientInfo(ctx))
+	if err != nil {
+		HandleError(ctx, http.StatusUnauthorized, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, newLoginResponse(response))
+}
+
+func (h *UserHandler) EnrollTOTP(ctx *gin.Context) {
+	apiCfg, err := repository.LoadAPIConfig()
+	if err != nil {
+		HandleError(ctx, http.StatusBadRequest, err)
+		return
+	}
+
+	userID, err := validation.AccessToken(ctx.Request.Header.Get("Authorization"), apiCfg.JWTSecret)
+	if err != nil {
+		HandleError(ctx, http.StatusBadRequest, err)
+		return
+	}
+
+	enrollment, err := h.svc.EnrollTOTP(userID)
+	if err != nil {
+		HandleError(ctx, http.StatusBadRequest, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, enrollment)
+}
+
+func (h *UserHandler) ActivateTOTP(ctx *gin.Context) {
+	apiCfg, err := repository.LoadAPIConfig()
+	if err != nil {
+		HandleError(ctx, http.StatusBadRequest, err)
+		return
+	}
+
+	userID, err := validation.AccessToken(ctx.Request.Header.Get("Authorization"), apiCfg.JWTSecret)
+	if err != nil {
+		HandleError(ctx, http.StatusBadRequest, err)
+		return
+	}
+
+	var req TOTPCodeRequest
+	if !bindJSON(ctx, &req) {
+		return
+	}
+
+	err = h.svc.ActivateTOTP(userID, req.Code)
+	if err != nil {
+		HandleError(ctx, http.StatusBadRequest, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{
+		"message": "Two-factor authentication enabled successfully",
+	})
+}
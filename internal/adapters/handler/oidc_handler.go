@@ -0,0 +1,52 @@
+package handler
+
+import (
+	"net/http"
+
+	"github.com/LordMoMA/Hexagonal-Architecture/internal/core/domain"
+	"github.com/LordMoMA/Hexagonal-Architecture/internal/core/services"
+	"github.com/gin-gonic/gin"
+)
+
+type OIDCHandler struct {
+	svc services.OIDCService
+}
+
+func NewOIDCHandler(OIDCService services.OIDCService) *OIDCHandler {
+	return &OIDCHandler{
+		svc: OIDCService,
+	}
+}
+
+// BeginLogin redirects the browser to the identity provider's sign-in page.
+func (h *OIDCHandler) BeginLogin(ctx *gin.Context) {
+	authURL, err := h.svc.BeginLogin()
+	if err != nil {
+		HandleError(ctx, http.StatusBadRequest, err)
+		return
+	}
+	ctx.Redirect(http.StatusFound, authURL)
+}
+
+// Callback is the redirect URI registered with the identity provider.
+func (h *OIDCHandler) Callback(ctx *gin.Context) {
+	if errCode := ctx.Query("error"); errCode != "" {
+		HandleError(ctx, http.StatusUnauthorized, domain.Unauthorized("oidc_login_refused", "identity provider returned %s", errCode))
+		return
+	}
+
+	state := ctx.Query("state")
+	code := ctx.Query("code")
+	if state == "" || code == "" {
+		HandleError(ctx, http.StatusBadRequest, domain.Validation("missing_state_or_code", "missing state or code"))
+		return
+	}
+
+	response, err := h.svc.CompleteLogin(state, code, clientInfo(ctx))
+	if err != nil {
+		HandleError(ctx, http.StatusUnauthorized, err)
+		return
+	}
+
+	writeLoginResponse(ctx, response)
+}
@@ -0,0 +1,535 @@
+package handler
+
+import (
+	_ "embed"
+	"fmt"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/LordMoMA/Hexagonal-Architecture/internal/adapters/repository"
+	"github.com/LordMoMA/Hexagonal-Architecture/internal/core/domain"
+	"github.com/gin-gonic/gin"
+)
+
+// jsonSchema is a schema, or any other object of the OpenAPI document, as
+// written out.
+type jsonSchema map[string]interface{}
+
+// alternatives documents a body that is one of several types.
+type alternatives []interface{}
+
+// apiOperation describes one route for the OpenAPI document. Path is as
+// registered with gin within each API version, e.g. /messages/:id, or as a
+// whole for Root routes. Request and Response are a value of the body's type,
+// whose schema is worked out from its fields and their json and binding
+// tags, or a jsonSchema for anything that isn't JSON.
+type apiOperation struct {
+	ID      string
+	Method  string
+	Path    string
+	Root    bool
+	Tag     string
+	Summary string
+	// Public routes need no credentials
+	Public  bool
+	Query   []apiParam
+	Request interface{}
+	// Form is the multipart/form-data alternative to a JSON Request
+	Form jsonSchema
+	// Status is that of a successful response, 200 when zero
+	Status      int
+	Response    interface{}
+	ContentType string
+
+	// version is the API version a versioned operation was documented for
+	version APIVersion
+}
+
+type apiParam struct {
+	Name        string
+	Type        string
+	Description string
+	Required    bool
+}
+
+// These mirror the gin.H bodies handlers reply with.
+type statusMessage struct {
+	Message string `json:"message"`
+}
+
+type mfaChallenge struct {
+	MFARequired bool   `json:"mfa_required"`
+	MFAToken    string `json:"mfa_token"`
+}
+
+type createdAPIKey struct {
+	APIKey *domain.APIKey `json:"api_key"`
+	// Key is the only time the full key is shown
+	Key string `json:"key"`
+}
+
+type attachmentLink struct {
+	URL       string    `json:"url"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+type reactionToggled struct {
+	Emoji   string `json:"emoji"`
+	Reacted bool   `json:"reacted"`
+}
+
+type graphQLResponse struct {
+	Data   map[string]interface{} `json:"data"`
+	Errors []graphQLError         `json:"errors,omitempty"`
+}
+
+type graphQLError struct {
+	Message string        `json:"message"`
+	Path    []interface{} `json:"path,omitempty"`
+	// Extensions carries the error code, as in a Problem
+	Extensions map[string]interface{} `json:"extensions,omitempty"`
+}
+
+var listParams = []apiParam{
+	{Name: "limit", Type: "integer", Description: "page size, at most 100"},
+	{Name: "cursor", Description: "next_cursor of the previous page"},
+	{Name: "sort", Description: "field to sort by, prefixed with - for descending order"},
+	{Name: "user_id", Description: "only items by this user"},
+	{Name: "since", Description: "RFC 3339 timestamp"},
+	{Name: "until", Description: "RFC 3339 timestamp"},
+}
+
+var streamParams = []apiParam{
+	{Name: "access_token", Description: "for clients that can't set the Authorization header"},
+}
+
+// apiOperations lists every route RegisterRoutes mounts, as the current API
+// version serves it. A test fails when a route is missing here.
+var apiOperations = []apiOperation{
+	{ID: "openAPI", Method: http.MethodGet, Path: "/openapi.json", Root: true, Tag: "docs", Summary: "This document", Public: true, Response: jsonSchema{"type": "object"}},
+	{ID: "apiDocs", Method: http.MethodGet, Path: "/docs", Root: true, Tag: "docs", Summary: "Browsable documentation for this document", Public: true, ContentType: "text/html", Response: jsonSchema{"type": "string"}},
+
+	{ID: "searchMessages", Method: http.MethodGet, Path: "/messages/search", Tag: "messages", Summary: "Full-text search of the caller's conversations",
+		Query: []apiParam{
+			{Name: "q", Description: "search terms", Required: true},
+			{Name: "conversation_id", Description: "search only this conversation"},
+			{Name: "limit", Type: "integer", Description: "at most 100"},
+		},
+		Response: domain.Page[*domain.SearchHit]{}},
+	{ID: "readScheduledMessages", Method: http.MethodGet, Path: "/messages/scheduled", Tag: "messages", Summary: "The caller's messages waiting to be sent", Query: listParams, Response: domain.Page[*domain.ScheduledMessage]{}},
+	{ID: "cancelScheduledMessage", Method: http.MethodDelete, Path: "/messages/scheduled/:id", Tag: "messages", Summary: "Cancel a scheduled message before it is sent", Response: statusMessage{}},
+	{ID: "readMessage", Method: http.MethodGet, Path: "/messages/:id", Tag: "messages", Summary: "Read a message", Response: domain.Message{}},
+	{ID: "readReplies", Method: http.MethodGet, Path: "/messages/:id/replies", Tag: "messages", Summary: "One page of a message's thread", Query: listParams, Response: domain.Page[*domain.Message]{}},
+	{ID: "toggleReaction", Method: http.MethodPost, Path: "/messages/:id/reactions", Tag: "messages", Summary: "Add an emoji reaction, or take it back if already there", Request: ReactionRequest{}, Response: reactionToggled{}},
+	{ID: "attachmentLink", Method: http.MethodGet, Path: "/attachments/:id/link", Tag: "messages", Summary: "A short-lived signed download link for an attachment", Response: attachmentLink{}},
+	{ID: "downloadAttachment", Method: http.MethodGet, Path: "/attachments/:id/download", Tag: "messages", Summary: "Download an attachment through a signed link", Public: true,
+		Query: []apiParam{
+			{Name: "expires", Type: "integer", Description: "unix time the link expires at", Required: true},
+			{Name: "signature", Description: "link signature", Required: true},
+		},
+		ContentType: "application/octet-stream", Response: jsonSchema{"type": "string", "format": "binary"}},
+	{ID: "readMessages", Method: http.MethodGet, Path: "/messages", Tag: "messages", Summary: "One page of messages in the caller's conversations", Query: listParams, Response: domain.Page[*domain.Message]{}},
+	{ID: "createMessage", Method: http.MethodPost, Path: "/messages", Tag: "messages", Summary: "Post a message, or schedule it when send_at is given",
+		Request: CreateMessageRequest{},
+		Form: jsonSchema{
+			"type": "object",
+			"properties": jsonSchema{
+				"conversation_id": jsonSchema{"type": "string"},
+				"parent_id":       jsonSchema{"type": "string"},
+				"body":            jsonSchema{"type": "string", "maxLength": domain.MaxMessageBodyLength},
+				"send_at":         jsonSchema{"type": "string", "format": "date-time"},
+				"expires_at":      jsonSchema{"type": "string", "format": "date-time"},
+				"files":           jsonSchema{"type": "array", "maxItems": domain.MaxAttachmentsPerPost, "items": jsonSchema{"type": "string", "format": "binary"}},
+			},
+		},
+		Status: http.StatusCreated, Response: alternatives{statusMessage{}, domain.ScheduledMessage{}}},
+	{ID: "createMessages", Method: http.MethodPost, Path: "/messages:batch", Tag: "messages", Summary: fmt.Sprintf("Post up to %d messages at once, all or nothing when atomic, with the outcome of each", domain.MaxBatchSize),
+		Request: BatchRequest[CreateMessageRequest]{}, Response: BatchResponse{}},
+	{ID: "updateMessage", Method: http.MethodPut, Path: "/messages/:id", Tag: "messages", Summary: "Edit a message", Request: UpdateMessageRequest{}, Response: statusMessage{}},
+	{ID: "deleteMessage", Method: http.MethodDelete, Path: "/messages/:id", Tag: "messages", Summary: "Delete a message, leaving a tombstone", Response: statusMessage{}},
+
+	{ID: "webSocket", Method: http.MethodGet, Path: "/ws", Tag: "realtime", Summary: "WebSocket pushing a MessageEvent for each change in the caller's conversations", Query: streamParams, Status: http.StatusSwitchingProtocols},
+	{ID: "events", Method: http.MethodGet, Path: "/events", Tag: "realtime", Summary: "Server-Sent Events fallback for the WebSocket, one event per MessageEvent", Query: streamParams, ContentType: "text/event-stream", Response: domain.MessageEvent{}},
+
+	{ID: "createConversation", Method: http.MethodPost, Path: "/conversations", Tag: "conversations", Summary: "Start a direct or group conversation", Request: CreateConversationRequest{}, Status: http.StatusCreated, Response: domain.Conversation{}},
+	{ID: "readConversations", Method: http.MethodGet, Path: "/conversations", Tag: "conversations", Summary: "The caller's conversations with their unread counts", Response: domain.Page[*domain.Conversation]{}},
+	{ID: "readConversationMessages", Method: http.MethodGet, Path: "/conversations/:id/messages", Tag: "conversations", Summary: "One page of a conversation's messages", Query: listParams, Response: domain.Page[*domain.Message]{}},
+	{ID: "markConversationRead", Method: http.MethodPost, Path: "/conversations/:id/read", Tag: "conversations", Summary: "Mark a conversation read up to a message, or all of it", Request: MarkReadRequest{}, Response: statusMessage{}},
+
+	{ID: "readUser", Method: http.MethodGet, Path: "/users/:id", Tag: "users", Summary: "Read a user", Response: UserResponse{}},
+	{ID: "readUsers", Method: http.MethodGet, Path: "/users", Tag: "users", Summary: "One page of users", Query: listParams, Response: domain.Page[UserResponse]{}},
+	{ID: "createUser", Method: http.MethodPost, Path: "/users", Tag: "users", Summary: "Sign up", Public: true, Request: CreateUserRequest{}, Status: http.StatusCreated, Response: statusMessage{}},
+	{ID: "createUsers", Method: http.MethodPost, Path: "/users:batch", Tag: "users", Summary: fmt.Sprintf("Sign up to %d users at once, all or nothing when atomic, with the outcome of each (admins only)", domain.MaxUserBatchSize),
+		Request: BatchRequest[CreateUserRequest]{}, Response: BatchResponse{}},
+	{ID: "updateUser", Method: http.MethodPut, Path: "/users", Tag: "users", Summary: "Change the caller's email and password", Request: UpdateUserRequest{}, Response: statusMessage{}},
+	{ID: "deleteUser", Method: http.MethodDelete, Path: "/users", Tag: "users", Summary: "Delete the caller's account", Response: statusMessage{}},
+
+	{ID: "loginUser", Method: http.MethodPost, Path: "/login", Tag: "auth", Summary: "Log in, or get an MFA challenge when two-factor authentication is on", Public: true, Request: LoginRequest{}, Response: alternatives{LoginResponse{}, mfaChallenge{}}},
+	{ID: "loginUserMFA", Method: http.MethodPost, Path: "/login/mfa", Tag: "auth", Summary: "Answer an MFA challenge with a TOTP or recovery code", Public: true, Request: MFALoginRequest{}, Response: LoginResponse{}},
+	{ID: "refreshSession", Method: http.MethodPost, Path: "/refresh", Tag: "auth", Summary: "Swap a refresh token for new tokens", Public: true, Request: RefreshRequest{}, Response: LoginResponse{}},
+	{ID: "listSessions", Method: http.MethodGet, Path: "/sessions", Tag: "auth", Summary: "The caller's signed-in devices", Response: domain.Page[*domain.Session]{}},
+	{ID: "revokeSession", Method: http.MethodDelete, Path: "/sessions/:id", Tag: "auth", Summary: "Sign a device out", Response: statusMessage{}},
+	{ID: "enrollTOTP", Method: http.MethodPost, Path: "/mfa/totp", Tag: "auth", Summary: "Start setting up an authenticator app", Response: repository.TOTPEnrollment{}},
+	{ID: "activateTOTP", Method: http.MethodPost, Path: "/mfa/totp/activate", Tag: "auth", Summary: "Turn on two-factor authentication with a first code", Request: TOTPCodeRequest{}, Response: statusMessage{}},
+	{ID: "updateMembershipStatus", Method: http.MethodPost, Path: "/membership/webhooks", Tag: "users", Summary: "Membership webhook, authenticated with the shared webhook key", Request: WebhookRequest{}, Response: statusMessage{}},
+
+	{ID: "unlockUser", Method: http.MethodPost, Path: "/admin/users/:id/unlock", Tag: "admin", Summary: "Unlock a user locked out by failed logins", Response: statusMessage{}},
+	{ID: "readMessageRevisions", Method: http.MethodGet, Path: "/admin/messages/:id/revisions", Tag: "admin", Summary: "A message's earlier bodies", Response: domain.Page[*domain.MessageRevision]{}},
+	{ID: "restoreMessage", Method: http.MethodPost, Path: "/admin/messages/:id/restore", Tag: "admin", Summary: "Bring back a deleted message", Response: statusMessage{}},
+	{ID: "readFlaggedMessages", Method: http.MethodGet, Path: "/admin/moderation/queue", Tag: "admin", Summary: "One page of the moderation review queue",
+		Query:    append([]apiParam{{Name: "status", Description: "pending (the default) or reviewed"}}, listParams...),
+		Response: domain.Page[*domain.FlaggedMessage]{}},
+	{ID: "reviewFlaggedMessage", Method: http.MethodPost, Path: "/admin/moderation/queue/:id/review", Tag: "admin", Summary: "Approve or remove a flagged message", Request: ReviewRequest{}, Response: domain.FlaggedMessage{}},
+	{ID: "setConversationRetention", Method: http.MethodPut, Path: "/admin/conversations/:id/retention", Tag: "admin", Summary: "Override how long a conversation's messages are kept", Request: RetentionRequest{}, Response: statusMessage{}},
+	{ID: "readRetentionPurges", Method: http.MethodGet, Path: "/admin/retention/purges", Tag: "admin", Summary: "One page of the retention purge log", Query: listParams, Response: domain.Page[*domain.RetentionPurge]{}},
+
+	{ID: "beginOIDCLogin", Method: http.MethodGet, Path: "/auth/oidc/login", Tag: "auth", Summary: "Redirect to the identity provider to sign in, when SSO is configured", Public: true, Status: http.StatusFound},
+	{ID: "oidcCallback", Method: http.MethodGet, Path: "/auth/oidc/callback", Tag: "auth", Summary: "Where the identity provider sends the browser back to; an MFA challenge when two-factor authentication is on", Public: true,
+		Query: []apiParam{
+			{Name: "state", Description: "state from the login redirect"},
+			{Name: "code", Description: "authorization code"},
+			{Name: "error", Description: "set by the identity provider when sign-in failed"},
+		},
+		Response: alternatives{LoginResponse{}, mfaChallenge{}}},
+
+	{ID: "createAPIKey", Method: http.MethodPost, Path: "/api-keys", Tag: "api-keys", Summary: "Create a personal API key", Request: CreateAPIKeyRequest{}, Status: http.StatusCreated, Response: createdAPIKey{}},
+	{ID: "listAPIKeys", Method: http.MethodGet, Path: "/api-keys", Tag: "api-keys", Summary: "The caller's API keys", Response: domain.Page[*domain.APIKey]{}},
+	{ID: "revokeAPIKey", Method: http.MethodDelete, Path: "/api-keys/:id", Tag: "api-keys", Summary: "Revoke an API key", Response: statusMessage{}},
+
+	{ID: "graphQL", Method: http.MethodPost, Path: "/graphql", Tag: "graphql", Summary: "Run a GraphQL query over users, messages, conversations and orders", Request: GraphQLRequest{}, Response: graphQLResponse{}},
+
+	{ID: "createCheckoutSession", Method: http.MethodPost, Path: "/create-checkout-session", Tag: "payments", Summary: "Redirect to a Stripe Checkout page", Status: http.StatusSeeOther},
+}
+
+var openAPISpec = buildOpenAPISpec(apiOperations)
+
+// OpenAPI serves the OpenAPI 3 document for the API.
+func OpenAPI(ctx *gin.Context) {
+	ctx.JSON(http.StatusOK, openAPISpec)
+}
+
+//go:embed docs.html
+var docsPage []byte
+
+// APIDocs serves a page rendering /openapi.json. It is self-contained, so
+// it works without access to a CDN.
+func APIDocs(ctx *gin.Context) {
+	ctx.Data(http.StatusOK, "text/html; charset=utf-8", docsPage)
+}
+
+func buildOpenAPISpec(operations []apiOperation) jsonSchema {
+	schemas := schemaBuilder{}
+	problem := schemas.of(reflect.TypeOf(Problem{}))
+
+	paths := jsonSchema{}
+	for _, op := range versionedOperations(operations) {
+		path, params := openAPIPath(op.Path)
+		for _, q := range op.Query {
+			typ := q.Type
+			if typ == "" {
+				typ = "string"
+			}
+			params = append(params, jsonSchema{
+				"name":        q.Name,
+				"in":          "query",
+				"required":    q.Required,
+				"description": q.Description,
+				"schema":      jsonSchema{"type": typ},
+			})
+		}
+
+		operation := jsonSchema{
+			"operationId": op.ID,
+			"tags":        []string{op.Tag},
+			"summary":     op.Summary,
+			"responses": jsonSchema{
+				strconv.Itoa(statusOr(op.Status)): schemas.response(op),
+				"default":                         schemas.problem(op, problem),
+			},
+		}
+		if len(params) > 0 {
+			operation["parameters"] = params
+		}
+		if op.Request != nil {
+			content := jsonSchema{"application/json": jsonSchema{"schema": schemas.body(op.Request)}}
+			if op.Form != nil {
+				content["multipart/form-data"] = jsonSchema{"schema": op.Form}
+			}
+			operation["requestBody"] = jsonSchema{"required": true, "content": content}
+		}
+		if op.Public {
+			operation["security"] = []jsonSchema{}
+		}
+		if !op.version.Deprecated.IsZero() {
+			operation["deprecated"] = true
+		}
+
+		if paths[path] == nil {
+			paths[path] = jsonSchema{}
+		}
+		paths[path].(jsonSchema)[strings.ToLower(op.Method)] = operation
+	}
+
+	return jsonSchema{
+		"openapi": "3.0.3",
+		"info": jsonSchema{
+			"title":   "Hexagonal Architecture API",
+			"version": "1.0.0",
+			"description": "Messages, conversations, users and payments under each API version. " +
+				"Deprecated versions answer with Deprecation and Sunset headers until they are removed. " +
+				"Errors are application/problem+json, with a stable code to tell them apart.",
+		},
+		"servers":  []jsonSchema{{"url": "http://localhost:4242"}},
+		"security": []jsonSchema{{"bearerAuth": []string{}}, {"apiKeyAuth": []string{}}},
+		"paths":    paths,
+		"components": jsonSchema{
+			"schemas": schemas,
+			"securitySchemes": jsonSchema{
+				"bearerAuth": jsonSchema{"type": "http", "scheme": "bearer", "bearerFormat": "JWT"},
+				"apiKeyAuth": jsonSchema{
+					"type":        "apiKey",
+					"in":          "header",
+					"name":        "Authorization",
+					"description": `A personal API key, sent as "ApiKey hex_..."`,
+				},
+			},
+		},
+	}
+}
+
+// versionedOperations documents every operation that isn't Root once for
+// each API version, with the response that version gets.
+func versionedOperations(operations []apiOperation) []apiOperation {
+	current := apiVersions[len(apiVersions)-1].Name
+	var all []apiOperation
+	for _, op := range operations {
+		if op.Root {
+			all = append(all, op)
+			continue
+		}
+		for _, version := range apiVersions {
+			versioned := op
+			versioned.Path = "/" + version.Name + op.Path
+			versioned.version = version
+			if version.Name != current {
+				versioned.ID += strings.ToUpper(version.Name)
+			}
+			if changes := downgrades(version.Name, op.Method, op.Path); len(changes) > 0 {
+				versioned.Response = changes[len(changes)-1].Response
+			}
+			all = append(all, versioned)
+		}
+	}
+	return all
+}
+
+// openAPIPath turns /v1/messages/:id into /v1/messages/{id} and its path
+// parameters.
+func openAPIPath(path string) (string, []jsonSchema) {
+	var params []jsonSchema
+	segments := strings.Split(path, "/")
+	for i, segment := range segments {
+		if !strings.HasPrefix(segment, ":") && !strings.HasPrefix(segment, "*") {
+			continue
+		}
+		name := segment[1:]
+		segments[i] = "{" + name + "}"
+		params = append(params, jsonSchema{
+			"name":     name,
+			"in":       "path",
+			"required": true,
+			"schema":   jsonSchema{"type": "string"},
+		})
+	}
+	return strings.Join(segments, "/"), params
+}
+
+func statusOr(status int) int {
+	if status == 0 {
+		return http.StatusOK
+	}
+	return status
+}
+
+// schemaBuilder collects the schemas of named types as components, and
+// refers to them by $ref.
+type schemaBuilder jsonSchema
+
+var timeType = reflect.TypeOf(time.Time{})
+
+func (b schemaBuilder) response(op apiOperation) jsonSchema {
+	response := jsonSchema{"description": http.StatusText(statusOr(op.Status))}
+	if !op.version.Deprecated.IsZero() {
+		response["headers"] = jsonSchema{
+			"Deprecation": jsonSchema{"description": "When the version was deprecated, as @ and Unix time", "schema": jsonSchema{"type": "string"}},
+			"Sunset":      jsonSchema{"description": "When the version will be removed", "schema": jsonSchema{"type": "string"}},
+		}
+	}
+	if op.Response != nil {
+		contentType := op.ContentType
+		if contentType == "" {
+			contentType = "application/json"
+		}
+		response["content"] = jsonSchema{contentType: jsonSchema{"schema": b.body(op.Response)}}
+	}
+	return response
+}
+
+// problem is the error response of op, as its version sends errors.
+func (b schemaBuilder) problem(op apiOperation, problem jsonSchema) jsonSchema {
+	contentType, schema := "application/problem+json", problem
+	if op.version.Name != "" {
+		for _, change := range errorDowngrades(op.version.Name) {
+			schema = b.body(change.Response)
+			if change.ContentType != "" {
+				contentType = change.ContentType
+			}
+		}
+	}
+	return jsonSchema{
+		"description": "An RFC 7807 problem",
+		"content":     jsonSchema{contentType: jsonSchema{"schema": schema}},
+	}
+}
+
+func (b schemaBuilder) body(v interface{}) jsonSchema {
+	switch v := v.(type) {
+	case jsonSchema:
+		return v
+	case alternatives:
+		oneOf := make([]jsonSchema, len(v))
+		for i, alt := range v {
+			oneOf[i] = b.body(alt)
+		}
+		return jsonSchema{"oneOf": oneOf}
+	}
+	return b.of(reflect.TypeOf(v))
+}
+
+func (b schemaBuilder) of(t reflect.Type) jsonSchema {
+	nullable := false
+	for t.Kind() == reflect.Pointer {
+		t, nullable = t.Elem(), true
+	}
+
+	var schema jsonSchema
+	switch {
+	case t == timeType:
+		schema = jsonSchema{"type": "string", "format": "date-time"}
+	case t.Kind() == reflect.String:
+		schema = jsonSchema{"type": "string"}
+	case t.Kind() == reflect.Bool:
+		schema = jsonSchema{"type": "boolean"}
+	case t.Kind() >= reflect.Int && t.Kind() <= reflect.Uint64:
+		schema = jsonSchema{"type": "integer"}
+	case t.Kind() == reflect.Float32 || t.Kind() == reflect.Float64:
+		schema = jsonSchema{"type": "number"}
+	case t.Kind() == reflect.Slice || t.Kind() == reflect.Array:
+		schema = jsonSchema{"type": "array", "items": b.of(t.Elem())}
+	case t.Kind() == reflect.Map:
+		schema = jsonSchema{"type": "object", "additionalProperties": b.of(t.Elem())}
+	case t.Kind() == reflect.Struct:
+		// a $ref can't carry nullable in OpenAPI 3.0
+		return b.ref(t)
+	default:
+		schema = jsonSchema{}
+	}
+	if nullable {
+		schema["nullable"] = true
+	}
+	return schema
+}
+
+func (b schemaBuilder) ref(t reflect.Type) jsonSchema {
+	name := schemaName(t)
+	if _, ok := b[name]; !ok {
+		// claim the name first in case the type refers back to itself
+		b[name] = jsonSchema{}
+		b[name] = b.object(t)
+	}
+	return jsonSchema{"$ref": "#/components/schemas/" + name}
+}
+
+func (b schemaBuilder) object(t reflect.Type) jsonSchema {
+	properties := jsonSchema{}
+	var required []string
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if !field.IsExported() || name == "-" {
+			continue
+		}
+		if name == "" && field.Anonymous && field.Type.Kind() == reflect.Struct {
+			// promoted, as encoding/json does
+			embedded := b.object(field.Type)
+			for name, schema := range embedded["properties"].(jsonSchema) {
+				properties[name] = schema
+			}
+			if fields, ok := embedded["required"].([]string); ok {
+				required = append(required, fields...)
+			}
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+
+		schema := b.of(field.Type)
+		if applyBinding(schema, field.Tag.Get("binding")) {
+			required = append(required, name)
+		}
+		properties[name] = schema
+	}
+
+	object := jsonSchema{"type": "object", "properties": properties}
+	if len(required) > 0 {
+		object["required"] = required
+	}
+	return object
+}
+
+// applyBinding documents the validator rules of a field's binding tag on
+// its schema, and reports whether the field is required. Rules after "dive"
+// apply to the elements of a slice and are left out.
+func applyBinding(schema jsonSchema, binding string) bool {
+	required := false
+	for _, rule := range strings.Split(binding, ",") {
+		name, param, _ := strings.Cut(rule, "=")
+		switch name {
+		case "dive":
+			return required
+		case "required":
+			required = true
+		case "email":
+			schema["format"] = "email"
+		case "oneof":
+			schema["enum"] = strings.Fields(param)
+		case "min", "max":
+			n, err := strconv.Atoi(param)
+			if err != nil {
+				continue
+			}
+			if schema["type"] == "array" {
+				schema[name+"Items"] = n
+			} else if schema["type"] == "string" {
+				schema[name+"Length"] = n
+			}
+		}
+	}
+	return required
+}
+
+// schemaName names a type's component: its Go name, capitalised, with type
+// arguments in front, e.g. domain.Page[*domain.Message] is MessagePage.
+func schemaName(t reflect.Type) string {
+	name := t.Name()
+	if i := strings.Index(name, "["); i >= 0 {
+		arg := strings.TrimSuffix(name[i+1:], "]")
+		arg = arg[strings.LastIndex(arg, ".")+1:]
+		name = arg + name[:i]
+	}
+	return strings.ToUpper(name[:1]) + name[1:]
+}
@@ -0,0 +1,41 @@
+package handler
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/LordMoMA/Hexagonal-Architecture/internal/core/domain"
+	"github.com/gin-gonic/gin"
+)
+
+// parseListOptions reads ?limit=&cursor=&sort=&user_id=&since=&until= from
+// the query string. Dates are RFC 3339.
+func parseListOptions(ctx *gin.Context) (domain.ListOptions, error) {
+	opts := domain.ListOptions{
+		Cursor: ctx.Query("cursor"),
+		Sort:   ctx.Query("sort"),
+		UserID: ctx.Query("user_id"),
+	}
+
+	if limit := ctx.Query("limit"); limit != "" {
+		n, err := strconv.Atoi(limit)
+		if err != nil || n < 1 || n > domain.MaxPageLimit {
+			return opts, domain.Validation("invalid_limit", "limit must be between 1 and %d", domain.MaxPageLimit)
+		}
+		opts.Limit = n
+	}
+
+	for name, dst := range map[string]**time.Time{"since": &opts.Since, "until": &opts.Until} {
+		value := ctx.Query(name)
+		if value == "" {
+			continue
+		}
+		t, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			return opts, domain.Validation("invalid_time", "%s must be an RFC 3339 timestamp", name)
+		}
+		*dst = &t
+	}
+
+	return opts, nil
+}
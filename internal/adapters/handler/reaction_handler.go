@@ -0,0 +1,77 @@
+package handler
+
+import (
+	"net/http"
+
+	"github.com/LordMoMA/Hexagonal-Architecture/internal/adapters/repository"
+	"github.com/gin-gonic/gin"
+)
+
+type MarkReadRequest struct {
+	MessageID string `json:"message_id"`
+}
+
+// ToggleReaction handles POST /messages/:id/reactions. Sending the same emoji
+// twice takes the reaction back.
+func (h *MessageHandler) ToggleReaction(ctx *gin.Context) {
+	apiCfg, err := repository.LoadAPIConfig()
+	if err != nil {
+		HandleError(ctx, http.StatusBadRequest, err)
+		return
+	}
+
+	userID, err := authenticatedUserID(ctx, apiCfg.JWTSecret)
+	if err != nil {
+		HandleError(ctx, http.StatusBadRequest, err)
+		return
+	}
+
+	var req ReactionRequest
+	if !bindJSON(ctx, &req) {
+		return
+	}
+
+	reacted, err := h.svc.ToggleReaction(userID, ctx.Param("id"), req.Emoji)
+	if err != nil {
+		HandleError(ctx, http.StatusBadRequest, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{
+		"emoji":   req.Emoji,
+		"reacted": reacted,
+	})
+}
+
+// MarkConversationRead handles POST /conversations/:id/read. The body is
+// optional; without a message_id everything up to now is marked read.
+func (h *MessageHandler) MarkConversationRead(ctx *gin.Context) {
+	apiCfg, err := repository.LoadAPIConfig()
+	if err != nil {
+		HandleError(ctx, http.StatusBadRequest, err)
+		return
+	}
+
+	userID, err := authenticatedUserID(ctx, apiCfg.JWTSecret)
+	if err != nil {
+		HandleError(ctx, http.StatusBadRequest, err)
+		return
+	}
+
+	var req MarkReadRequest
+	if ctx.Request.ContentLength != 0 {
+		if !bindJSON(ctx, &req) {
+			return
+		}
+	}
+
+	err = h.svc.MarkConversationRead(userID, ctx.Param("id"), req.MessageID)
+	if err != nil {
+		HandleError(ctx, http.StatusBadRequest, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{
+		"message": "Conversation marked as read",
+	})
+}
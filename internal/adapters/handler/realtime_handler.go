@@ -0,0 +1,141 @@
+package handler
+
+import (
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/LordMoMA/Hexagonal-Architecture/internal/adapters/realtime"
+	"github.com/LordMoMA/Hexagonal-Architecture/internal/adapters/repository"
+	"github.com/LordMoMA/Hexagonal-Architecture/internal/adapters/validation"
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+)
+
+const (
+	streamPingInterval = 25 * time.Second
+	wsWriteTimeout     = 10 * time.Second
+)
+
+var upgrader = websocket.Upgrader{
+	ReadBufferSize:  1024,
+	WriteBufferSize: 1024,
+}
+
+type RealtimeHandler struct {
+	hub *realtime.Hub
+}
+
+func NewRealtimeHandler(hub *realtime.Hub) *RealtimeHandler {
+	return &RealtimeHandler{
+		hub: hub,
+	}
+}
+
+// WebSocket pushes message events for the caller's conversations over a
+// WebSocket connection. The connection is receive-only; anything the client
+// sends is ignored.
+func (h *RealtimeHandler) WebSocket(ctx *gin.Context) {
+	userID, err := streamUserID(ctx)
+	if err != nil {
+		HandleError(ctx, http.StatusUnauthorized, err)
+		return
+	}
+
+	conn, err := upgrader.Upgrade(ctx.Writer, ctx.Request, nil)
+	if err != nil {
+		// Upgrade has already written the error response
+		return
+	}
+	defer conn.Close()
+
+	sub := h.hub.Subscribe(userID)
+	defer h.hub.Unsubscribe(sub)
+
+	// the read loop only exists to notice the client going away
+	closed := make(chan struct{})
+	go func() {
+		defer close(closed)
+		for {
+			if _, _, err := conn.NextReader(); err != nil {
+				return
+			}
+		}
+	}()
+
+	ping := time.NewTicker(streamPingInterval)
+	defer ping.Stop()
+
+	for {
+		select {
+		case <-closed:
+			return
+		case event, ok := <-sub.Events():
+			if !ok {
+				_ = conn.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseTryAgainLater, "too slow"), time.Now().Add(wsWriteTimeout))
+				return
+			}
+			_ = conn.SetWriteDeadline(time.Now().Add(wsWriteTimeout))
+			if err := conn.WriteJSON(event); err != nil {
+				return
+			}
+		case <-ping.C:
+			if err := conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(wsWriteTimeout)); err != nil {
+				return
+			}
+		}
+	}
+}
+
+// Events is the Server-Sent Events fallback for clients that can't open a
+// WebSocket. Each event is named after its type, e.g. "message.created".
+func (h *RealtimeHandler) Events(ctx *gin.Context) {
+	userID, err := streamUserID(ctx)
+	if err != nil {
+		HandleError(ctx, http.StatusUnauthorized, err)
+		return
+	}
+
+	sub := h.hub.Subscribe(userID)
+	defer h.hub.Unsubscribe(sub)
+
+	ctx.Header("Cache-Control", "no-cache")
+	ctx.Header("Connection", "keep-alive")
+	ctx.Header("X-Accel-Buffering", "no") // stop nginx from buffering the stream
+
+	ping := time.NewTicker(streamPingInterval)
+	defer ping.Stop()
+
+	ctx.Stream(func(w io.Writer) bool {
+		select {
+		case <-ctx.Request.Context().Done():
+			return false
+		case event, ok := <-sub.Events():
+			if !ok {
+				return false
+			}
+			ctx.SSEvent(event.Type, event)
+			return true
+		case <-ping.C:
+			_, err := io.WriteString(w, ": ping\n\n")
+			return err == nil
+		}
+	})
+}
+
+// streamUserID authenticates a streaming request. Browsers can't set headers
+// on WebSocket or EventSource requests, so the access token may also be
+// passed as ?access_token=, which AccessLog keeps out of the request log.
+func streamUserID(ctx *gin.Context) (string, error) {
+	apiCfg, err := repository.LoadAPIConfig()
+	if err != nil {
+		return "", err
+	}
+
+	if ctx.Request.Header.Get("Authorization") == "" {
+		if token := ctx.Query("access_token"); token != "" {
+			return validation.AccessToken("Bearer "+token, apiCfg.JWTSecret)
+		}
+	}
+	return authenticatedUserID(ctx, apiCfg.JWTSecret)
+}
@@ -0,0 +1,101 @@
+package handler
+
+import (
+	"github.com/LordMoMA/Hexagonal-Architecture/internal/adapters/graph"
+	"github.com/LordMoMA/Hexagonal-Architecture/internal/adapters/realtime"
+	"github.com/LordMoMA/Hexagonal-Architecture/internal/core/services"
+	"github.com/gin-gonic/gin"
+)
+
+// Services are what the HTTP API drives. OIDC is nil when no identity
+// provider is configured, which leaves out the SSO routes.
+type Services struct {
+	Messages *services.MessengerService
+	Users    *services.UserService
+	Payments *services.PaymentService
+	OIDC     *services.OIDCService
+	Hub      *realtime.Hub
+}
+
+// RegisterRoutes mounts the API on router once for every version in
+// apiVersions, GraphQL at /<version>/graphql included, along with the OpenAPI
+// document describing it at /openapi.json and its docs page at /docs.
+func RegisterRoutes(router gin.IRouter, svc Services) {
+	router.GET("/openapi.json", OpenAPI)
+	router.GET("/docs", APIDocs)
+
+	messageHandler := NewMessageHandler(*svc.Messages)
+	realtimeHandler := NewRealtimeHandler(svc.Hub)
+	userHandler := NewUserHandler(*svc.Users)
+	adminHandler := NewAdminHandler(*svc.Users, *svc.Messages)
+	paymentHandler := NewPaymentHandler(*svc.Payments)
+	graphQLHandler := NewGraphQLHandler(graph.NewSchema(svc.Users, svc.Messages, svc.Payments))
+	var oidcHandler *OIDCHandler
+	if svc.OIDC != nil {
+		oidcHandler = NewOIDCHandler(*svc.OIDC)
+	}
+
+	for _, version := range apiVersions {
+		v := router.Group("/"+version.Name, version.Serve, APIKeyAuth(*svc.Users))
+		v.GET("/messages/search", messageHandler.SearchMessages)
+		v.GET("/messages/scheduled", messageHandler.ReadScheduledMessages)
+		v.DELETE("/messages/scheduled/:id", messageHandler.CancelScheduledMessage)
+		v.GET("/messages/:id", messageHandler.ReadMessage)
+		v.GET("/messages/:id/replies", messageHandler.ReadReplies)
+		v.POST("/messages/:id/reactions", messageHandler.ToggleReaction)
+		v.GET("/attachments/:id/link", messageHandler.AttachmentLink)
+		v.GET("/attachments/:id/download", messageHandler.DownloadAttachment)
+		v.GET("/messages", messageHandler.ReadMessages)
+		v.POST("/messages", messageHandler.CreateMessage)
+		v.POST("/messages:batch", customMethod("batch", messageHandler.CreateMessages))
+		v.PUT("/messages/:id", messageHandler.UpdateMessage)
+		v.DELETE("/messages/:id", messageHandler.DeleteMessage)
+
+		v.GET("/ws", realtimeHandler.WebSocket)
+		v.GET("/events", realtimeHandler.Events)
+
+		v.POST("/conversations", messageHandler.CreateConversation)
+		v.GET("/conversations", messageHandler.ReadConversations)
+		v.GET("/conversations/:id/messages", messageHandler.ReadConversationMessages)
+		v.POST("/conversations/:id/read", messageHandler.MarkConversationRead)
+
+		v.GET("/users/:id", userHandler.ReadUser)
+		v.GET("/users", userHandler.ReadUsers)
+		v.POST("/users", userHandler.CreateUser)
+		v.POST("/users:batch", customMethod("batch", userHandler.CreateUsers))
+		v.PUT("/users", userHandler.UpdateUser)
+		v.DELETE("/users", userHandler.DeleteUser)
+
+		v.POST("/login", userHandler.LoginUser)
+		v.POST("/login/mfa", userHandler.LoginUserMFA)
+		v.POST("/refresh", userHandler.RefreshSession)
+		v.GET("/sessions", userHandler.ListSessions)
+		v.DELETE("/sessions/:id", userHandler.RevokeSession)
+		v.POST("/mfa/totp", userHandler.EnrollTOTP)
+		v.POST("/mfa/totp/activate", userHandler.ActivateTOTP)
+		v.POST("/membership/webhooks", userHandler.UpdateMembershipStatus)
+		v.POST("/admin/users/:id/unlock", userHandler.UnlockUser)
+
+		v.GET("/admin/messages/:id/revisions", adminHandler.ReadMessageRevisions)
+		v.POST("/admin/messages/:id/restore", adminHandler.RestoreMessage)
+		v.GET("/admin/moderation/queue", adminHandler.ReadFlaggedMessages)
+		v.POST("/admin/moderation/queue/:id/review", adminHandler.ReviewFlaggedMessage)
+		v.PUT("/admin/conversations/:id/retention", adminHandler.SetConversationRetention)
+		v.GET("/admin/retention/purges", adminHandler.ReadRetentionPurges)
+
+		if oidcHandler != nil {
+			v.GET("/auth/oidc/login", oidcHandler.BeginLogin)
+			v.GET("/auth/oidc/callback", oidcHandler.Callback)
+		}
+
+		v.POST("/api-keys", userHandler.CreateAPIKey)
+		v.GET("/api-keys", userHandler.ListAPIKeys)
+		v.DELETE("/api-keys/:id", userHandler.RevokeAPIKey)
+
+		v.POST("/create-checkout-session", paymentHandler.CreateCheckoutSession)
+		// v.POST("/wallet/deposit", paymentHandler.Deposit)
+		// v.POST("/wallet/withdraw", paymentHandler.Withdraw)
+
+		v.POST("/graphql", graphQLHandler.Query)
+	}
+}
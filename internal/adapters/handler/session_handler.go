@@ -0,0 +1,70 @@
+package handler
+
+import (
+	"net/http"
+
+	"github.com/LordMoMA/Hexagonal-Architecture/internal/adapters/repository"
+	"github.com/LordMoMA/Hexagonal-Architecture/internal/adapters/validation"
+	"github.com/LordMoMA/Hexagonal-Architecture/internal/core/domain"
+	"github.com/gin-gonic/gin"
+)
+
+func (h *UserHandler) RefreshSession(ctx *gin.Context) {
+	var req RefreshRequest
+	if !bindJSON(ctx, &req) {
+		return
+	}
+
+	response, err := h.svc.RefreshSession(req.RefreshToken, clientInfo(ctx))
+	if err != nil {
+		HandleError(ctx, http.StatusUnauthorized, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, newLoginResponse(response))
+}
+
+func (h *UserHandler) ListSessions(ctx *gin.Context) {
+	apiCfg, err := repository.LoadAPIConfig()
+	if err != nil {
+		HandleError(ctx, http.StatusBadRequest, err)
+		return
+	}
+
+	userID, err := validation.AccessToken(ctx.Request.Header.Get("Authorization"), apiCfg.JWTSecret)
+	if err != nil {
+		HandleError(ctx, http.StatusBadRequest, err)
+		return
+	}
+
+	sessions, err := h.svc.ListSessions(userID)
+	if err != nil {
+		HandleError(ctx, http.StatusBadRequest, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, domain.Page[*domain.Session]{Items: sessions})
+}
+
+func (h *UserHandler) RevokeSession(ctx *gin.Context) {
+	apiCfg, err := repository.LoadAPIConfig()
+	if err != nil {
+		HandleError(ctx, http.StatusBadRequest, err)
+		return
+	}
+
+	userID, err := validation.AccessToken(ctx.Request.Header.Get("Authorization"), apiCfg.JWTSecret)
+	if err != nil {
+		HandleError(ctx, http.StatusBadRequest, err)
+		return
+	}
+
+	err = h.svc.RevokeSession(userID, ctx.Param("id"))
+	if err != nil {
+		HandleError(ctx, http.StatusBadRequest, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{
+		"message": "Session revoked successfully",
+	})
+}
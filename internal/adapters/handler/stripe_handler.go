@@ -1,10 +1,12 @@
 package handler
 
 import (
+	"fmt"
 	"log"
 	"net/http"
 
 	"github.com/LordMoMA/Hexagonal-Architecture/internal/adapters/repository"
+	"github.com/LordMoMA/Hexagonal-Architecture/internal/core/domain"
 	"github.com/LordMoMA/Hexagonal-Architecture/internal/core/services"
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
@@ -22,6 +24,8 @@ func NewPaymentHandler(paymentService services.PaymentService) *PaymentHandler {
 	}
 }
 
+// CreateCheckoutSession redirects to a Stripe Checkout page. When the caller
+// is signed in, the order is kept under them, pending until it is paid.
 func (h *PaymentHandler) CreateCheckoutSession(ctx *gin.Context) {
 	apiCfg, err := repository.LoadAPIConfig()
 	if err != nil {
@@ -30,7 +34,8 @@ func (h *PaymentHandler) CreateCheckoutSession(ctx *gin.Context) {
 	}
 	stripe.Key = apiCfg.StripeKey
 
-	domain := "http://localhost:4242"
+	baseURL := "http://localhost:4242"
+	orderID := generateOrderID()
 	params := &stripe.CheckoutSessionParams{
 		LineItems: []*stripe.CheckoutSessionLineItemParams{
 			&stripe.CheckoutSessionLineItemParams{
@@ -40,20 +45,30 @@ func (h *PaymentHandler) CreateCheckoutSession(ctx *gin.Context) {
 			},
 		},
 		Mode:       stripe.String(string(stripe.CheckoutSessionModePayment)),
-		SuccessURL: stripe.String(domain + "?success=true"),
-		CancelURL:  stripe.String(domain + "?canceled=true"),
+		SuccessURL: stripe.String(baseURL + "?success=true"),
+		CancelURL:  stripe.String(baseURL + "?canceled=true"),
 	}
+	// Add the order ID to the checkout session metadata
+	params.AddMetadata("order_id", orderID)
 
 	s, err := session.New(params)
-
 	if err != nil {
 		log.Printf("session.New: %v", err)
+		HandleError(ctx, http.StatusBadGateway, domain.Unavailable("payments_unavailable", "checkout session not created"))
+		return
 	}
-	orderID := generateOrderID()
 
-	// Add the order ID to the checkout session metadata
-	s.Metadata = map[string]string{
-		"order_id": orderID,
+	if userID, err := authenticatedUserID(ctx, apiCfg.JWTSecret); err == nil {
+		order := &domain.OrderInfo{
+			ID:       orderID,
+			Amount:   fmt.Sprintf("%d.%02d", s.AmountTotal/100, s.AmountTotal%100),
+			Currency: string(s.Currency),
+		}
+		payment := domain.Payment{CheckoutID: s.ID, Orders: []*domain.OrderInfo{order}}
+		if err := h.svc.CreateCheckoutSession(userID, payment); err != nil {
+			HandleError(ctx, http.StatusBadRequest, err)
+			return
+		}
 	}
 
 	ctx.Redirect(http.StatusSeeOther, s.URL)
@@ -89,8 +104,7 @@ func (h *PaymentHandler) ProcessPaymentWithStripe(ctx *gin.Context) {
 	stripe.Key = apiCfg.StripeKey
 	// Parse request parameters
 	var req CreatePaymentRequest
-	if err := ctx.ShouldBindJSON(&req); err != nil {
-		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	if !bindJSON(ctx, &req) {
 		return
 	}
 
@@ -103,7 +117,7 @@ func (h *PaymentHandler) ProcessPaymentWithStripe(ctx *gin.Context) {
 	}
 	pi, _ := paymentintent.New(params)
 
-	// userID, err := ValidateToken(ctx.Request.Header.Get("Authorization"), apiCfg.JWTSecret)
+	// userID, err := validation.AccessToken(ctx.Request.Header.Get("Authorization"), apiCfg.JWTSecret)
 	// if err != nil {
 	// 	HandleError(ctx, http.StatusBadRequest, err)
 	// 	return
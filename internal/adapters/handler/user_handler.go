@@ -1,16 +1,11 @@
 package handler
 
 import (
-	"errors"
-	"fmt"
 	"net/http"
-	"time"
 
 	"github.com/LordMoMA/Hexagonal-Architecture/internal/adapters/repository"
-	"github.com/LordMoMA/Hexagonal-Architecture/internal/core/domain"
 	"github.com/LordMoMA/Hexagonal-Architecture/internal/core/services"
 	"github.com/gin-gonic/gin"
-	"github.com/golang-jwt/jwt/v5"
 )
 
 type UserHandler struct {
@@ -24,13 +19,12 @@ func NewUserHandler(UserService services.UserService) *UserHandler {
 }
 
 func (h *UserHandler) CreateUser(ctx *gin.Context) {
-	var user domain.User
-	if err := ctx.ShouldBindJSON(&user); err != nil {
-		HandleError(ctx, http.StatusBadRequest, err)
+	var req CreateUserRequest
+	if !bindJSON(ctx, &req) {
 		return
 	}
 
-	_, err := h.svc.CreateUser(user.Email, user.Password)
+	_, err := h.svc.CreateUser(req.Email, req.Password)
 	if err != nil {
 		HandleError(ctx, http.StatusBadRequest, err)
 		return
@@ -50,17 +44,36 @@ func (h *UserHandler) ReadUser(ctx *gin.Context) {
 		HandleError(ctx, http.StatusBadRequest, err)
 		return
 	}
-	ctx.JSON(http.StatusOK, user)
+	ctx.JSON(http.StatusOK, newUserResponse(user, h.viewer(ctx)))
 }
 
 func (h *UserHandler) ReadUsers(ctx *gin.Context) {
+	opts, err := parseListOptions(ctx)
+	if err != nil {
+		HandleError(ctx, http.StatusBadRequest, err)
+		return
+	}
 
-	users, err := h.svc.ReadUsers()
+	users, err := h.svc.ReadUsers(opts)
 	if err != nil {
 		HandleError(ctx, http.StatusBadRequest, err)
 		return
 	}
-	ctx.JSON(http.StatusOK, users)
+	ctx.JSON(http.StatusOK, newUserPage(users, h.viewer(ctx)))
+}
+
+// viewer is the caller of a route that can be called without signing in.
+func (h *UserHandler) viewer(ctx *gin.Context) viewer {
+	apiCfg, err := repository.LoadAPIConfig()
+	if err != nil {
+		return viewer{}
+	}
+	userID, err := authenticatedUserID(ctx, apiCfg.JWTSecret)
+	if err != nil {
+		return viewer{}
+	}
+	caller, err := h.svc.ReadUser(userID)
+	return viewer{id: userID, admin: err == nil && caller.Admin}
 }
 
 func (h *UserHandler) UpdateUser(ctx *gin.Context) {
@@ -72,20 +85,19 @@ func (h *UserHandler) UpdateUser(ctx *gin.Context) {
 	}
 
 	// Validate token
-	userID, err := ValidateToken(ctx.Request.Header.Get("Authorization"), apiCfg.JWTSecret)
+	userID, err := authenticatedUserID(ctx, apiCfg.JWTSecret)
 	if err != nil {
 		HandleError(ctx, http.StatusBadRequest, err)
 		return
 	}
 
 	// Update user
-	var user domain.User
-	if err := ctx.ShouldBindJSON(&user); err != nil {
-		HandleError(ctx, http.StatusBadRequest, err)
+	var req UpdateUserRequest
+	if !bindJSON(ctx, &req) {
 		return
 	}
 
-	err = h.svc.UpdateUser(userID, user.Email, user.Password)
+	err = h.svc.UpdateUser(userID, req.Email, req.Password)
 	if err != nil {
 		HandleError(ctx, http.StatusBadRequest, err)
 		return
@@ -103,7 +115,7 @@ func (h *UserHandler) DeleteUser(ctx *gin.Context) {
 		return
 	}
 
-	userID, err := ValidateToken(ctx.Request.Header.Get("Authorization"), apiCfg.JWTSecret)
+	userID, err := authenticatedUserID(ctx, apiCfg.JWTSecret)
 	if err != nil {
 		HandleError(ctx, http.StatusBadRequest, err)
 		return
@@ -119,44 +131,3 @@ func (h *UserHandler) DeleteUser(ctx *gin.Context) {
 		"message": "User deleted successfully",
 	})
 }
-
-func ValidateToken(authHeader string, jwtSecret string) (string, error) {
-	// Check if token exists in the header
-	if authHeader == "" {
-		return "", errors.New("token not found")
-	}
-
-	// Extract token from header
-	tokenString := authHeader[7:]
-
-	// Parse and validate token
-	token, err := jwt.ParseWithClaims(tokenString, &jwt.RegisteredClaims{}, func(token *jwt.Token) (interface{}, error) {
-		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
-		}
-		return []byte(jwtSecret), nil
-	})
-	if err != nil {
-		return "", err
-	}
-
-	if !token.Valid {
-		return "", errors.New("token not valid")
-	}
-
-	// Check if token has expired
-	claims, ok := token.Claims.(*jwt.RegisteredClaims)
-	if !ok || claims.ExpiresAt == nil || claims.ExpiresAt.Before(time.Now().UTC()) {
-		return "", errors.New("token has expired")
-	}
-
-	// Check if token is a refresh token
-	if claims.Issuer == "LordMoMA-refresh" {
-		return "", errors.New("token is a refresh token, please use access token")
-	}
-
-	// Extract user ID from token
-	userID := claims.Subject
-
-	return userID, nil
-}
@@ -0,0 +1,69 @@
+package handler
+
+import (
+	"github.com/LordMoMA/Hexagonal-Architecture/internal/adapters/repository"
+	"github.com/LordMoMA/Hexagonal-Architecture/internal/core/domain"
+)
+
+// UserResponse is a user as the API shows it. It is built field by field
+// so nothing secret on domain.User, such as the password hash or the TOTP
+// secret, can reach a response by being added there.
+type UserResponse struct {
+	ID         string `json:"id"`
+	Email      string `json:"email"`
+	Membership bool   `json:"membership"`
+	// Admin and TOTPEnabled are left out for anyone but the user themselves
+	// and admins: users can be read without signing in
+	Admin       *bool `json:"admin,omitempty"`
+	TOTPEnabled *bool `json:"totp_enabled,omitempty"`
+}
+
+// viewer is who a user is shown to; id is empty when they aren't signed in.
+type viewer struct {
+	id    string
+	admin bool
+}
+
+func (v viewer) seesAccount(user *domain.User) bool {
+	return v.admin || (v.id != "" && v.id == user.ID)
+}
+
+// LoginResponse is what a completed login or token refresh returns.
+type LoginResponse struct {
+	ID           string `json:"id"`
+	Email        string `json:"email"`
+	AccessToken  string `json:"access_token"`
+	RefreshToken string `json:"refresh_token"`
+	IsMember     bool   `json:"is_member"`
+}
+
+func newUserResponse(user *domain.User, v viewer) UserResponse {
+	response := UserResponse{
+		ID:         user.ID,
+		Email:      user.Email,
+		Membership: user.Membership,
+	}
+	if v.seesAccount(user) {
+		admin, totpEnabled := user.Admin, user.TOTPEnabled
+		response.Admin, response.TOTPEnabled = &admin, &totpEnabled
+	}
+	return response
+}
+
+func newUserPage(page *domain.Page[*domain.User], v viewer) domain.Page[UserResponse] {
+	users := make([]UserResponse, len(page.Items))
+	for i, user := range page.Items {
+		users[i] = newUserResponse(user, v)
+	}
+	return domain.Page[UserResponse]{Items: users, NextCursor: page.NextCursor}
+}
+
+func newLoginResponse(response *repository.LoginResponse) LoginResponse {
+	return LoginResponse{
+		ID:           response.ID,
+		Email:        response.Email,
+		AccessToken:  response.AccessToken,
+		RefreshToken: response.RefreshToken,
+		IsMember:     response.Membership,
+	}
+}
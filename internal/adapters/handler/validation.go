@@ -0,0 +1,50 @@
+package handler
+
+import (
+	"net/http"
+
+	"github.com/LordMoMA/Hexagonal-Architecture/internal/adapters/validation"
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/go-playground/validator/v10"
+)
+
+// The requests the other driving adapters share live in validation, so they
+// are held to the same rules.
+type (
+	CreateUserRequest         = validation.CreateUserRequest
+	UpdateUserRequest         = validation.UpdateUserRequest
+	LoginRequest              = validation.LoginRequest
+	MFALoginRequest           = validation.MFALoginRequest
+	RefreshRequest            = validation.RefreshRequest
+	CreateConversationRequest = validation.CreateConversationRequest
+	CreateMessageRequest      = validation.CreateMessageRequest
+	UpdateMessageRequest      = validation.UpdateMessageRequest
+	ReactionRequest           = validation.ReactionRequest
+)
+
+func init() {
+	if v, ok := binding.Validator.Engine().(*validator.Validate); ok {
+		validation.Register(v)
+	}
+}
+
+// bindJSON decodes the request body into req and validates it. It writes the
+// error response itself, listing every invalid field, so callers just
+// return when it reports false.
+func bindJSON(ctx *gin.Context, req interface{}) bool {
+	if err := ctx.ShouldBindJSON(req); err != nil {
+		HandleError(ctx, http.StatusBadRequest, validation.Error(err))
+		return false
+	}
+	return true
+}
+
+// validateRequest checks a request that was read some other way than
+// bindJSON, such as from a multipart form.
+func validateRequest(req interface{}) error {
+	if err := binding.Validator.ValidateStruct(req); err != nil {
+		return validation.Error(err)
+	}
+	return nil
+}
@@ -0,0 +1,339 @@
+package handler
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/LordMoMA/Hexagonal-Architecture/internal/core/domain"
+	"github.com/gin-gonic/gin"
+)
+
+const apiVersionKey = "api_version"
+
+// APIVersion is one version of the API, served under /<Name>. Every version
+// is served by the same handlers, which write the current representation;
+// the Changes of older versions turn it into theirs on the way out, so a
+// breaking change costs a VersionChange rather than a copy of the handler.
+type APIVersion struct {
+	Name string
+	// Deprecated, when set, goes out in the Deprecation header of every
+	// response of the version, and Sunset in the Sunset header as the date
+	// it will be removed.
+	Deprecated time.Time
+	Sunset     time.Time
+	// Changes undo, for clients of this version, the breaking changes made
+	// in the version after it
+	Changes []VersionChange
+}
+
+// VersionChange is a breaking change to the JSON response of one route.
+// Downgrade turns the newer body, decoded as by encoding/json, into the older
+// one. Response is a value of the older body's type, for the OpenAPI
+// document.
+type VersionChange struct {
+	Method string
+	// Path is as registered, without the version, e.g. /conversations
+	Path      string
+	Summary   string
+	Downgrade func(body interface{}) interface{}
+	Response  interface{}
+	// Errors makes the change apply to the problem bodies of every route,
+	// rather than to the successful responses of Method and Path
+	Errors bool
+	// ContentType, when set, is sent instead of the newer one
+	ContentType string
+}
+
+// apiVersions lists the versions served, oldest first; the last is current.
+var apiVersions = []APIVersion{
+	{
+		// not deprecated until v2 is announced; then set Deprecated and a
+		// Sunset far enough out for clients to move
+		Name: "v1",
+		Changes: []VersionChange{
+			listAsPage(http.MethodGet, "/messages", []*domain.Message{}),
+			listAsPage(http.MethodGet, "/users", []UserResponse{}),
+			listAsPage(http.MethodGet, "/conversations", []*domain.Conversation{}),
+			listAsPage(http.MethodGet, "/conversations/:id/messages", []*domain.Message{}),
+			listAsPage(http.MethodGet, "/sessions", []*domain.Session{}),
+			listAsPage(http.MethodGet, "/api-keys", []*domain.APIKey{}),
+			listAsPage(http.MethodGet, "/admin/messages/:id/revisions", []*domain.MessageRevision{}),
+			errorMember,
+		},
+	},
+	{Name: "v2"},
+}
+
+// listAsPage is the v2 change making lists that used to be bare arrays
+// pages, like every other list.
+func listAsPage(method, path string, v1Response interface{}) VersionChange {
+	return VersionChange{
+		Method:  method,
+		Path:    path,
+		Summary: "v2 returns a page object instead of a bare array",
+		Downgrade: func(body interface{}) interface{} {
+			if page, ok := body.(map[string]interface{}); ok {
+				return page["items"]
+			}
+			return body
+		},
+		Response: v1Response,
+	}
+}
+
+// errorMember is the v2 change making errors problem+json bodies; v1 clients
+// read the error from the "error" member of a JSON object, so v1 gets it
+// there too.
+var errorMember = VersionChange{
+	Summary: `v2 sends errors as application/problem+json, without the "error" member`,
+	Downgrade: func(body interface{}) interface{} {
+		if problem, ok := body.(map[string]interface{}); ok {
+			problem["error"] = problem["detail"]
+		}
+		return body
+	},
+	Response:    v1Problem{},
+	Errors:      true,
+	ContentType: "application/json; charset=utf-8",
+}
+
+// v1Problem is a Problem as v1 sends it.
+type v1Problem struct {
+	Problem
+	// Error is the same as Detail
+	Error string `json:"error"`
+}
+
+// downgrades returns the changes that turn the current response of a route
+// into version's, in the order they apply.
+func downgrades(version, method, path string) []VersionChange {
+	return changesSince(version, func(change VersionChange) bool {
+		return !change.Errors && change.Method == method && change.Path == path
+	})
+}
+
+// errorDowngrades returns the changes that turn a current problem body into
+// version's, in the order they apply.
+func errorDowngrades(version string) []VersionChange {
+	return changesSince(version, func(change VersionChange) bool { return change.Errors })
+}
+
+// changesSince returns the matching changes of version and every version
+// after it, newest first. A request outside any version gets none.
+func changesSince(version string, match func(VersionChange) bool) []VersionChange {
+	var changes []VersionChange
+	for i := len(apiVersions) - 1; i >= 0; i-- {
+		for _, change := range apiVersions[i].Changes {
+			if match(change) {
+				changes = append(changes, change)
+			}
+		}
+		if apiVersions[i].Name == version {
+			return changes
+		}
+	}
+	return nil
+}
+
+// Serve is the middleware of the version's route group. It marks deprecated
+// versions as such, downgrades responses that changed since, and counts the
+// request towards the version's usage.
+func (v APIVersion) Serve(ctx *gin.Context) {
+	ctx.Set(apiVersionKey, v.Name)
+	header := ctx.Writer.Header()
+	if !v.Deprecated.IsZero() {
+		header.Set("Deprecation", fmt.Sprintf("@%d", v.Deprecated.Unix()))
+		header.Set("Link", `</docs>; rel="deprecation"; type="text/html"`)
+	}
+	if !v.Sunset.IsZero() {
+		header.Set("Sunset", v.Sunset.UTC().Format(http.TimeFormat))
+	}
+
+	route := strings.TrimPrefix(ctx.FullPath(), "/"+v.Name)
+	if changes := downgrades(v.Name, ctx.Request.Method, route); len(changes) > 0 {
+		downgradeResponse(ctx, changes)
+	} else {
+		ctx.Next()
+	}
+
+	caller := ctx.GetString(authUserIDKey)
+	if caller == "" {
+		caller = ctx.ClientIP()
+	}
+	usage.record(v.Name, ctx.Request.Method+" "+route, caller)
+}
+
+// downgradeResponse holds back what the handlers write, so that a successful
+// JSON response can go through changes before it is sent.
+func downgradeResponse(ctx *gin.Context, changes []VersionChange) {
+	buffered := &bufferedWriter{ResponseWriter: ctx.Writer, status: http.StatusOK}
+	ctx.Writer = buffered
+	ctx.Next()
+	ctx.Writer = buffered.ResponseWriter
+
+	body := buffered.body.Bytes()
+	isJSON := strings.HasPrefix(ctx.Writer.Header().Get("Content-Type"), "application/json")
+	if buffered.status >= 200 && buffered.status < 300 && isJSON {
+		body = downgradeBody(body, changes)
+	}
+
+	ctx.Writer.WriteHeader(buffered.status)
+	ctx.Writer.WriteHeaderNow()
+	_, _ = ctx.Writer.Write(body)
+}
+
+func downgradeBody(body []byte, changes []VersionChange) []byte {
+	var decoded interface{}
+	dec := json.NewDecoder(bytes.NewReader(body))
+	dec.UseNumber()
+	if err := dec.Decode(&decoded); err != nil {
+		return body
+	}
+	for _, change := range changes {
+		decoded = change.Downgrade(decoded)
+	}
+	downgraded, err := json.Marshal(decoded)
+	if err != nil {
+		return body
+	}
+	return downgraded
+}
+
+// writeProblem writes problem as the version of the request sends errors.
+func writeProblem(ctx *gin.Context, problem Problem) {
+	contentType := "application/problem+json"
+	body, err := json.Marshal(problem)
+	if err != nil {
+		ctx.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+	changes := errorDowngrades(ctx.GetString(apiVersionKey))
+	if len(changes) > 0 {
+		body = downgradeBody(body, changes)
+	}
+	for _, change := range changes {
+		if change.ContentType != "" {
+			contentType = change.ContentType
+		}
+	}
+	ctx.Data(problem.Status, contentType, body)
+}
+
+type bufferedWriter struct {
+	gin.ResponseWriter
+	body   bytes.Buffer
+	status int
+}
+
+func (w *bufferedWriter) WriteHeader(status int) {
+	w.status = status
+}
+
+func (w *bufferedWriter) WriteHeaderNow() {}
+
+func (w *bufferedWriter) Write(b []byte) (int, error) {
+	return w.body.Write(b)
+}
+
+func (w *bufferedWriter) WriteString(s string) (int, error) {
+	return w.body.WriteString(s)
+}
+
+func (w *bufferedWriter) Status() int {
+	return w.status
+}
+
+func (w *bufferedWriter) Size() int {
+	return w.body.Len()
+}
+
+func (w *bufferedWriter) Written() bool {
+	return w.body.Len() > 0
+}
+
+// VersionUsage is how much a version was used since usage was last taken.
+// Callers are counted by user for API keys and by IP otherwise.
+type VersionUsage struct {
+	Version  string
+	Requests int
+	Callers  int
+	Routes   map[string]int
+}
+
+type versionUsage struct {
+	mu       sync.Mutex
+	requests map[string]int
+	routes   map[string]map[string]int
+	callers  map[string]map[string]bool
+}
+
+var usage = &versionUsage{}
+
+func (u *versionUsage) record(version, route, caller string) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	if u.requests == nil {
+		u.requests = map[string]int{}
+		u.routes = map[string]map[string]int{}
+		u.callers = map[string]map[string]bool{}
+	}
+	if u.routes[version] == nil {
+		u.routes[version] = map[string]int{}
+		u.callers[version] = map[string]bool{}
+	}
+	u.requests[version]++
+	u.routes[version][route]++
+	u.callers[version][caller] = true
+}
+
+// TakeVersionUsage returns the usage of every version since it was last
+// taken, and starts counting afresh.
+func TakeVersionUsage() []VersionUsage {
+	usage.mu.Lock()
+	defer usage.mu.Unlock()
+	report := make([]VersionUsage, len(apiVersions))
+	for i, v := range apiVersions {
+		report[i] = VersionUsage{
+			Version:  v.Name,
+			Requests: usage.requests[v.Name],
+			Callers:  len(usage.callers[v.Name]),
+			Routes:   usage.routes[v.Name],
+		}
+	}
+	usage.requests, usage.routes, usage.callers = nil, nil, nil
+	return report
+}
+
+// LogVersionUsage logs each version's usage every interval, with the routes
+// still called on deprecated versions, so it shows when one can go.
+func LogVersionUsage(interval time.Duration) {
+	for range time.Tick(interval) {
+		deprecated := map[string]APIVersion{}
+		for _, v := range apiVersions {
+			if !v.Deprecated.IsZero() {
+				deprecated[v.Name] = v
+			}
+		}
+		for _, u := range TakeVersionUsage() {
+			v, ok := deprecated[u.Version]
+			if !ok {
+				log.Printf("api %s: %d requests from %d callers", u.Version, u.Requests, u.Callers)
+				continue
+			}
+			routes := make([]string, 0, len(u.Routes))
+			for route, n := range u.Routes {
+				routes = append(routes, fmt.Sprintf("%s (%d)", route, n))
+			}
+			sort.Strings(routes)
+			log.Printf("api %s (deprecated, sunset %s): %d requests from %d callers: %s",
+				u.Version, v.Sunset.Format("2006-01-02"), u.Requests, u.Callers, strings.Join(routes, ", "))
+		}
+	}
+}
@@ -2,7 +2,6 @@ package handler
 
 import (
 	"encoding/json"
-	"errors"
 	"fmt"
 	"log"
 	"net/http"
@@ -10,6 +9,7 @@ import (
 	"strings"
 
 	"github.com/LordMoMA/Hexagonal-Architecture/internal/adapters/repository"
+	"github.com/LordMoMA/Hexagonal-Architecture/internal/core/domain"
 	"github.com/gin-gonic/gin"
 	"github.com/stripe/stripe-go"
 	"github.com/stripe/stripe-go/webhook"
@@ -33,24 +33,23 @@ func (h *UserHandler) UpdateMembershipStatus(ctx *gin.Context) {
 	// check if api key is valid
 	authHeader := ctx.Request.Header.Get("Authorization")
 	if authHeader == "" {
-		HandleError(ctx, http.StatusBadRequest, errors.New("no api key provided"))
+		HandleError(ctx, http.StatusUnauthorized, domain.Unauthorized("missing_api_key", "no api key provided"))
 		return
 	}
 	apiString := strings.TrimPrefix(authHeader, "ApiKey ")
 
 	if apiString != apiKey {
-		HandleError(ctx, http.StatusBadRequest, errors.New("invalid api key"))
+		HandleError(ctx, http.StatusUnauthorized, domain.Unauthorized("invalid_api_key", "invalid api key"))
 		return
 	}
 
 	var req WebhookRequest
-	if err := ctx.ShouldBindJSON(&req); err != nil {
-		HandleError(ctx, http.StatusBadRequest, err)
+	if !bindJSON(ctx, &req) {
 		return
 	}
 
 	if req.Event != "membership_status_updated" {
-		HandleError(ctx, http.StatusBadRequest, errors.New("invalid event type"))
+		HandleError(ctx, http.StatusBadRequest, domain.Validation("invalid_event_type", "invalid event type"))
 		return
 	}
 
@@ -0,0 +1,204 @@
+package moderation
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/LordMoMA/Hexagonal-Architecture/internal/core/domain"
+	"github.com/LordMoMA/Hexagonal-Architecture/internal/core/ports"
+)
+
+var linkPattern = regexp.MustCompile(`(?i)\b(?:https?://|www\.)\S+`)
+
+// Config is the rule set of a RuleModerator, usually loaded from a JSON
+// file. Zero limits switch the matching check off.
+type Config struct {
+	// RejectWords and FlagWords match whole words, ignoring case
+	RejectWords []string `json:"reject_words"`
+	FlagWords   []string `json:"flag_words"`
+	Rules       []Rule   `json:"rules"`
+	// MaxLinks flags messages carrying more links than this
+	MaxLinks int `json:"max_links"`
+	// MaxRepeatedChars flags runs like "!!!!!!!!" longer than this
+	MaxRepeatedChars int `json:"max_repeated_chars"`
+	// PostsPerMinute rejects a user's messages beyond this many a minute
+	PostsPerMinute int `json:"posts_per_minute"`
+}
+
+// Rule applies Action, flag or reject, to bodies matching the regular
+// expression Pattern.
+type Rule struct {
+	Pattern string `json:"pattern"`
+	Action  string `json:"action"`
+	Reason  string `json:"reason"`
+}
+
+func DefaultConfig() Config {
+	return Config{
+		MaxLinks:         5,
+		MaxRepeatedChars: 30,
+		PostsPerMinute:   30,
+	}
+}
+
+// LoadConfig reads a Config from a JSON file. Fields missing from the file
+// keep their DefaultConfig values.
+func LoadConfig(path string) (Config, error) {
+	cfg := DefaultConfig()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return cfg, fmt.Errorf("moderation config not read: %v", err)
+	}
+	if err := json.Unmarshal(data, &cfg); err != nil {
+		return cfg, fmt.Errorf("moderation config not valid: %v", err)
+	}
+	return cfg, nil
+}
+
+// RuleModerator is the built-in Moderator: word lists, regular expression
+// rules, spam heuristics and a per-user posting rate.
+type RuleModerator struct {
+	cfg         Config
+	rejectWords *regexp.Regexp
+	flagWords   *regexp.Regexp
+	rules       []compiledRule
+	cache       ports.CacheRepository
+}
+
+type compiledRule struct {
+	pattern *regexp.Regexp
+	action  string
+	reason  string
+}
+
+// NewRuleModerator compiles cfg. cache counts posts for the rate limit and
+// may be nil to switch it off.
+func NewRuleModerator(cfg Config, cache ports.CacheRepository) (*RuleModerator, error) {
+	m := &RuleModerator{
+		cfg:         cfg,
+		rejectWords: wordPattern(cfg.RejectWords),
+		flagWords:   wordPattern(cfg.FlagWords),
+		cache:       cache,
+	}
+	for _, rule := range cfg.Rules {
+		if rule.Action != domain.ModerationFlag && rule.Action != domain.ModerationReject {
+			return nil, fmt.Errorf("moderation rule %q: action must be %q or %q", rule.Pattern, domain.ModerationFlag, domain.ModerationReject)
+		}
+		pattern, err := regexp.Compile(rule.Pattern)
+		if err != nil {
+			return nil, fmt.Errorf("moderation rule %q: %v", rule.Pattern, err)
+		}
+		reason := rule.Reason
+		if reason == "" {
+			reason = "matches rule " + rule.Pattern
+		}
+		m.rules = append(m.rules, compiledRule{pattern: pattern, action: rule.Action, reason: reason})
+	}
+	return m, nil
+}
+
+func (m *RuleModerator) Moderate(message domain.Message) (domain.ModerationVerdict, error) {
+	return m.verdict(message, m.overRate(message.UserID)), nil
+}
+
+// ModerateBatch counts the batch once against the posting rate of the user
+// of its first message; every message posts as that user.
+func (m *RuleModerator) ModerateBatch(messages []domain.Message) ([]domain.ModerationVerdict, error) {
+	verdicts := make([]domain.ModerationVerdict, len(messages))
+	if len(messages) == 0 {
+		return verdicts, nil
+	}
+	tooFast := m.overRate(messages[0].UserID)
+	for i, message := range messages {
+		verdicts[i] = m.verdict(message, tooFast)
+	}
+	return verdicts, nil
+}
+
+func (m *RuleModerator) verdict(message domain.Message, tooFast bool) domain.ModerationVerdict {
+	var rejected, flagged []string
+
+	if tooFast {
+		rejected = append(rejected, "posting too fast")
+	}
+	if m.rejectWords != nil && m.rejectWords.MatchString(message.Body) {
+		rejected = append(rejected, "contains a blocked word")
+	}
+	if m.flagWords != nil && m.flagWords.MatchString(message.Body) {
+		flagged = append(flagged, "contains a watched word")
+	}
+	for _, rule := range m.rules {
+		if !rule.pattern.MatchString(message.Body) {
+			continue
+		}
+		if rule.action == domain.ModerationReject {
+			rejected = append(rejected, rule.reason)
+		} else {
+			flagged = append(flagged, rule.reason)
+		}
+	}
+	if m.cfg.MaxLinks > 0 && len(linkPattern.FindAllStringIndex(message.Body, m.cfg.MaxLinks+1)) > m.cfg.MaxLinks {
+		flagged = append(flagged, "too many links")
+	}
+	if m.cfg.MaxRepeatedChars > 0 && longestRun(message.Body) > m.cfg.MaxRepeatedChars {
+		flagged = append(flagged, "repeated characters")
+	}
+
+	switch {
+	case len(rejected) > 0:
+		return domain.ModerationVerdict{Action: domain.ModerationReject, Reasons: rejected}
+	case len(flagged) > 0:
+		return domain.ModerationVerdict{Action: domain.ModerationFlag, Reasons: flagged}
+	default:
+		return domain.ModerationVerdict{Action: domain.ModerationAllow}
+	}
+}
+
+// overRate counts the post against the user's current minute. A cache
+// outage fails open rather than stopping everyone from posting.
+func (m *RuleModerator) overRate(userID string) bool {
+	if m.cache == nil || m.cfg.PostsPerMinute <= 0 {
+		return false
+	}
+	window := time.Now().Unix() / 60
+	count, err := m.cache.Increment(fmt.Sprintf("moderation:rate:%s:%d", userID, window), 2*time.Minute)
+	if err != nil {
+		fmt.Printf("Error counting posts for %s: %v", userID, err)
+		return false
+	}
+	return count > int64(m.cfg.PostsPerMinute)
+}
+
+func wordPattern(words []string) *regexp.Regexp {
+	var quoted []string
+	for _, word := range words {
+		if word = strings.TrimSpace(word); word != "" {
+			quoted = append(quoted, regexp.QuoteMeta(word))
+		}
+	}
+	if len(quoted) == 0 {
+		return nil
+	}
+	return regexp.MustCompile(`(?i)\b(?:` + strings.Join(quoted, "|") + `)\b`)
+}
+
+func longestRun(s string) int {
+	longest, run := 0, 0
+	var prev rune
+	for i, r := range s {
+		if i > 0 && r == prev {
+			run++
+		} else {
+			run = 1
+		}
+		prev = r
+		if run > longest {
+			longest = run
+		}
+	}
+	return longest
+}
@@ -0,0 +1,295 @@
+package oidc
+
+import (
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/LordMoMA/Hexagonal-Architecture/internal/core/domain"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// jwksRefreshInterval bounds how often an unknown key ID can trigger a JWKS
+// refetch, so forged tokens can't be used to hammer the provider.
+const jwksRefreshInterval = 30 * time.Second
+
+// Provider is an OpenID Connect relying party for a single identity
+// provider, using the authorization code flow with PKCE.
+type Provider struct {
+	issuer       string
+	clientID     string
+	clientSecret string
+	redirectURL  string
+
+	authEndpoint  string
+	tokenEndpoint string
+	jwksURI       string
+
+	client *http.Client
+
+	mu          sync.RWMutex
+	keys        map[string]*rsa.PublicKey
+	keysFetched time.Time
+}
+
+type discoveryDocument struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	JWKSURI               string `json:"jwks_uri"`
+}
+
+type tokenResponse struct {
+	IDToken          string `json:"id_token"`
+	AccessToken      string `json:"access_token"`
+	TokenType        string `json:"token_type"`
+	Error            string `json:"error"`
+	ErrorDescription string `json:"error_description"`
+}
+
+type idTokenClaims struct {
+	jwt.RegisteredClaims
+	Email         string `json:"email"`
+	EmailVerified bool   `json:"email_verified"`
+	Nonce         string `json:"nonce"`
+}
+
+type jsonWebKey struct {
+	Kid string `json:"kid"`
+	Kty string `json:"kty"`
+	Use string `json:"use"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+}
+
+// NewProvider loads the provider's discovery document from
+// {issuer}/.well-known/openid-configuration.
+func NewProvider(issuer, clientID, clientSecret, redirectURL string) (*Provider, error) {
+	p := &Provider{
+		issuer:       strings.TrimSuffix(issuer, "/"),
+		clientID:     clientID,
+		clientSecret: clientSecret,
+		redirectURL:  redirectURL,
+		client:       &http.Client{Timeout: 10 * time.Second},
+	}
+
+	var doc discoveryDocument
+	if err := p.getJSON(p.issuer+"/.well-known/openid-configuration", &doc); err != nil {
+		return nil, fmt.Errorf("oidc discovery failed: %v", err)
+	}
+	if strings.TrimSuffix(doc.Issuer, "/") != p.issuer {
+		return nil, fmt.Errorf("oidc discovery issuer mismatch: got %q, want %q", doc.Issuer, p.issuer)
+	}
+	if doc.AuthorizationEndpoint == "" || doc.TokenEndpoint == "" || doc.JWKSURI == "" {
+		return nil, errors.New("oidc discovery document is incomplete")
+	}
+
+	p.authEndpoint = doc.AuthorizationEndpoint
+	p.tokenEndpoint = doc.TokenEndpoint
+	p.jwksURI = doc.JWKSURI
+	return p, nil
+}
+
+// AuthCodeURL is where the user is sent to sign in. The S256 code challenge
+// is derived from codeVerifier, which must be presented again in Exchange.
+func (p *Provider) AuthCodeURL(state, nonce, codeVerifier string) string {
+	params := url.Values{}
+	params.Set("response_type", "code")
+	params.Set("client_id", p.clientID)
+	params.Set("redirect_uri", p.redirectURL)
+	params.Set("scope", "openid email profile")
+	params.Set("state", state)
+	params.Set("nonce", nonce)
+	params.Set("code_challenge", CodeChallengeS256(codeVerifier))
+	params.Set("code_challenge_method", "S256")
+
+	sep := "?"
+	if strings.Contains(p.authEndpoint, "?") {
+		sep = "&"
+	}
+	return p.authEndpoint + sep + params.Encode()
+}
+
+// Exchange redeems an authorization code and returns the identity asserted
+// by the validated ID token.
+func (p *Provider) Exchange(code, codeVerifier, nonce string) (*domain.ExternalIdentity, error) {
+	form := url.Values{}
+	form.Set("grant_type", "authorization_code")
+	form.Set("code", code)
+	form.Set("redirect_uri", p.redirectURL)
+	form.Set("client_id", p.clientID)
+	form.Set("code_verifier", codeVerifier)
+
+	req, err := http.NewRequest(http.MethodPost, p.tokenEndpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	if p.clientSecret != "" {
+		req.SetBasicAuth(url.QueryEscape(p.clientID), url.QueryEscape(p.clientSecret))
+	}
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("oidc token request failed: %v", err)
+	}
+	defer resp.Body.Close()
+
+	var token tokenResponse
+	if err := json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(&token); err != nil {
+		return nil, fmt.Errorf("oidc token response not readable: %v", err)
+	}
+	if resp.StatusCode != http.StatusOK || token.Error != "" {
+		return nil, fmt.Errorf("oidc token request rejected: %s %s", token.Error, token.ErrorDescription)
+	}
+	if token.IDToken == "" {
+		return nil, errors.New("oidc token response has no id_token")
+	}
+
+	return p.VerifyIDToken(token.IDToken, nonce)
+}
+
+// VerifyIDToken checks the token's signature against the provider's JWKS as
+// well as its issuer, audience, expiry and nonce.
+func (p *Provider) VerifyIDToken(rawIDToken, nonce string) (*domain.ExternalIdentity, error) {
+	claims := &idTokenClaims{}
+	token, err := jwt.ParseWithClaims(rawIDToken, claims, p.keyFunc,
+		jwt.WithValidMethods([]string{"RS256"}),
+		jwt.WithIssuer(p.issuer),
+		jwt.WithAudience(p.clientID),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("id token not valid: %v", err)
+	}
+	if !token.Valid {
+		return nil, errors.New("id token not valid")
+	}
+	if claims.ExpiresAt == nil {
+		return nil, errors.New("id token has no expiry")
+	}
+	if claims.Nonce != nonce {
+		return nil, errors.New("id token nonce mismatch")
+	}
+	if claims.Subject == "" {
+		return nil, errors.New("id token has no subject")
+	}
+
+	return &domain.ExternalIdentity{
+		Issuer:        p.issuer,
+		Subject:       claims.Subject,
+		Email:         claims.Email,
+		EmailVerified: claims.EmailVerified,
+	}, nil
+}
+
+func (p *Provider) keyFunc(token *jwt.Token) (interface{}, error) {
+	kid, _ := token.Header["kid"].(string)
+
+	if key := p.cachedKey(kid); key != nil {
+		return key, nil
+	}
+
+	// an unknown kid usually means the provider rotated its keys
+	if err := p.refreshKeys(); err != nil {
+		return nil, err
+	}
+	if key := p.cachedKey(kid); key != nil {
+		return key, nil
+	}
+	return nil, fmt.Errorf("signing key %q not found", kid)
+}
+
+func (p *Provider) cachedKey(kid string) *rsa.PublicKey {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	if kid == "" && len(p.keys) == 1 {
+		for _, key := range p.keys {
+			return key
+		}
+	}
+	return p.keys[kid]
+}
+
+func (p *Provider) refreshKeys() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.keys != nil && time.Since(p.keysFetched) < jwksRefreshInterval {
+		return nil
+	}
+
+	var set struct {
+		Keys []jsonWebKey `json:"keys"`
+	}
+	if err := p.getJSON(p.jwksURI, &set); err != nil {
+		return fmt.Errorf("jwks fetch failed: %v", err)
+	}
+
+	keys := make(map[string]*rsa.PublicKey)
+	for _, jwk := range set.Keys {
+		if jwk.Kty != "RSA" || (jwk.Use != "" && jwk.Use != "sig") {
+			continue
+		}
+		key, err := parseRSAKey(jwk)
+		if err != nil {
+			return err
+		}
+		keys[jwk.Kid] = key
+	}
+
+	p.keys = keys
+	p.keysFetched = time.Now()
+	return nil
+}
+
+func (p *Provider) getJSON(url string, v interface{}) error {
+	resp, err := p.client.Get(url)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("unexpected status %d from %s", resp.StatusCode, url)
+	}
+	return json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(v)
+}
+
+func parseRSAKey(jwk jsonWebKey) (*rsa.PublicKey, error) {
+	n, err := base64.RawURLEncoding.DecodeString(jwk.N)
+	if err != nil {
+		return nil, fmt.Errorf("jwk %q has invalid modulus: %v", jwk.Kid, err)
+	}
+	e, err := base64.RawURLEncoding.DecodeString(jwk.E)
+	if err != nil {
+		return nil, fmt.Errorf("jwk %q has invalid exponent: %v", jwk.Kid, err)
+	}
+
+	exponent := new(big.Int).SetBytes(e)
+	if !exponent.IsInt64() || exponent.Int64() > 1<<31-1 {
+		return nil, fmt.Errorf("jwk %q exponent out of range", jwk.Kid)
+	}
+	return &rsa.PublicKey{
+		N: new(big.Int).SetBytes(n),
+		E: int(exponent.Int64()),
+	}, nil
+}
+
+// CodeChallengeS256 derives the PKCE code challenge for a verifier, RFC 7636
+// section 4.2.
+func CodeChallengeS256(codeVerifier string) string {
+	sum := sha256.Sum256([]byte(codeVerifier))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
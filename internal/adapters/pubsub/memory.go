@@ -0,0 +1,64 @@
+package pubsub
+
+import (
+	"context"
+	"sync"
+)
+
+// MemoryPubSub is an in-process PubSub for single-instance deployments and
+// tests.
+type MemoryPubSub struct {
+	mu          sync.RWMutex
+	subscribers map[string]map[*memorySubscription]struct{}
+}
+
+type memorySubscription struct {
+	ch   chan []byte
+	done chan struct{}
+}
+
+func NewMemoryPubSub() *MemoryPubSub {
+	return &MemoryPubSub{
+		subscribers: make(map[string]map[*memorySubscription]struct{}),
+	}
+}
+
+func (p *MemoryPubSub) Publish(channel string, payload []byte) error {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	for sub := range p.subscribers[channel] {
+		// copy so subscribers can't see each other's mutations
+		data := append([]byte(nil), payload...)
+		select {
+		case sub.ch <- data:
+		case <-sub.done:
+		}
+	}
+	return nil
+}
+
+func (p *MemoryPubSub) Subscribe(ctx context.Context, channel string) (<-chan []byte, error) {
+	sub := &memorySubscription{
+		ch:   make(chan []byte, 64),
+		done: make(chan struct{}),
+	}
+
+	p.mu.Lock()
+	if p.subscribers[channel] == nil {
+		p.subscribers[channel] = make(map[*memorySubscription]struct{})
+	}
+	p.subscribers[channel][sub] = struct{}{}
+	p.mu.Unlock()
+
+	go func() {
+		<-ctx.Done()
+		// unblock any Publish waiting on us before taking the write lock
+		close(sub.done)
+		p.mu.Lock()
+		delete(p.subscribers[channel], sub)
+		p.mu.Unlock()
+		close(sub.ch)
+	}()
+	return sub.ch, nil
+}
@@ -0,0 +1,69 @@
+package pubsub
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// RedisPubSub fans payloads out to every server instance subscribed to the
+// same Redis.
+type RedisPubSub struct {
+	client *redis.Client
+}
+
+func NewRedisPubSub(addr, password string) (*RedisPubSub, error) {
+	client := redis.NewClient(&redis.Options{
+		Addr:     addr,
+		Password: password,
+		DB:       0, // use default DB
+	})
+
+	_, err := client.Ping(context.Background()).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to Redis: %v", err)
+	}
+
+	return &RedisPubSub{client: client}, nil
+}
+
+func (p *RedisPubSub) Publish(channel string, payload []byte) error {
+	if err := p.client.Publish(context.Background(), channel, payload).Err(); err != nil {
+		return fmt.Errorf("failed to publish to channel %q: %v", channel, err)
+	}
+	return nil
+}
+
+func (p *RedisPubSub) Subscribe(ctx context.Context, channel string) (<-chan []byte, error) {
+	sub := p.client.Subscribe(ctx, channel)
+	// wait for the subscription to be confirmed so no publish is missed
+	if _, err := sub.Receive(ctx); err != nil {
+		sub.Close()
+		return nil, fmt.Errorf("failed to subscribe to channel %q: %v", channel, err)
+	}
+
+	out := make(chan []byte)
+	go func() {
+		defer close(out)
+		defer sub.Close()
+
+		msgs := sub.Channel()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case msg, ok := <-msgs:
+				if !ok {
+					return
+				}
+				select {
+				case out <- []byte(msg.Payload):
+				case <-ctx.Done():
+					return
+				}
+			}
+		}
+	}()
+	return out, nil
+}
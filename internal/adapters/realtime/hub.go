@@ -0,0 +1,161 @@
+package realtime
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/LordMoMA/Hexagonal-Architecture/internal/core/domain"
+	"github.com/LordMoMA/Hexagonal-Architecture/internal/core/ports"
+)
+
+const (
+	messageEventsChannel = "hex:message-events"
+	subscriberBuffer     = 32
+)
+
+// Hub delivers message events to the WebSocket and SSE connections held by
+// this instance. Events are published through a PubSub rather than straight
+// to local subscribers so every instance behind the load balancer sees them.
+type Hub struct {
+	pubsub ports.PubSub
+
+	mu          sync.RWMutex
+	subscribers map[string]map[*Subscriber]struct{}
+}
+
+// Subscriber is one open connection for a user. Its Events channel is closed
+// when it is unsubscribed or falls too far behind.
+type Subscriber struct {
+	userID string
+	events chan domain.MessageEvent
+}
+
+// envelope is what travels over the PubSub: the event plus who may see it.
+type envelope struct {
+	Event      domain.MessageEvent `json:"event"`
+	Recipients []string            `json:"recipients"`
+}
+
+func NewHub(pubsub ports.PubSub) *Hub {
+	return &Hub{
+		pubsub:      pubsub,
+		subscribers: make(map[string]map[*Subscriber]struct{}),
+	}
+}
+
+func (s *Subscriber) Events() <-chan domain.MessageEvent {
+	return s.events
+}
+
+// Run relays events from the PubSub to local subscribers until ctx is done.
+func (h *Hub) Run(ctx context.Context) error {
+	payloads, err := h.pubsub.Subscribe(ctx, messageEventsChannel)
+	if err != nil {
+		return err
+	}
+	for payload := range payloads {
+		var env envelope
+		if err := json.Unmarshal(payload, &env); err != nil {
+			fmt.Printf("Error decoding message event: %v", err)
+			continue
+		}
+		h.deliver(env)
+	}
+	return ctx.Err()
+}
+
+// maxRetryDelay caps the wait between attempts of RunWithRetry.
+const maxRetryDelay = time.Minute
+
+// RunWithRetry runs the hub until ctx is done, starting it again whenever
+// the PubSub can't be subscribed to or drops the subscription. It waits
+// delay before the first retry and twice as long before each one after,
+// up to a minute, starting over once the hub has run for that long.
+func (h *Hub) RunWithRetry(ctx context.Context, delay time.Duration) {
+	wait := delay
+	for {
+		started := time.Now()
+		err := h.Run(ctx)
+		if ctx.Err() != nil {
+			return
+		}
+		if time.Since(started) >= maxRetryDelay {
+			wait = delay
+		}
+		log.Printf("realtime hub stopped: %v; retrying in %v", err, wait)
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(wait):
+		}
+		if wait *= 2; wait > maxRetryDelay {
+			wait = maxRetryDelay
+		}
+	}
+}
+
+func (h *Hub) PublishMessageEvent(event domain.MessageEvent, recipientIDs []string) error {
+	payload, err := json.Marshal(envelope{Event: event, Recipients: recipientIDs})
+	if err != nil {
+		return fmt.Errorf("failed to encode message event: %v", err)
+	}
+	return h.pubsub.Publish(messageEventsChannel, payload)
+}
+
+func (h *Hub) Subscribe(userID string) *Subscriber {
+	sub := &Subscriber{
+		userID: userID,
+		events: make(chan domain.MessageEvent, subscriberBuffer),
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.subscribers[userID] == nil {
+		h.subscribers[userID] = make(map[*Subscriber]struct{})
+	}
+	h.subscribers[userID][sub] = struct{}{}
+	return sub
+}
+
+func (h *Hub) Unsubscribe(sub *Subscriber) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.remove(sub)
+}
+
+func (h *Hub) deliver(env envelope) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	for _, userID := range env.Recipients {
+		for sub := range h.subscribers[userID] {
+			select {
+			case sub.events <- env.Event:
+			default:
+				// a stalled client must not hold up everyone else; dropping
+				// it makes the client reconnect and refetch
+				h.remove(sub)
+			}
+		}
+	}
+}
+
+// remove must be called with h.mu held.
+func (h *Hub) remove(sub *Subscriber) {
+	subs, ok := h.subscribers[sub.userID]
+	if !ok {
+		return
+	}
+	if _, ok := subs[sub]; !ok {
+		return
+	}
+	delete(subs, sub)
+	if len(subs) == 0 {
+		delete(h.subscribers, sub.userID)
+	}
+	close(sub.events)
+}
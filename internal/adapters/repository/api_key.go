@@ -0,0 +1,157 @@
+package repository
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/LordMoMA/Hexagonal-Architecture/internal/core/domain"
+	"github.com/google/uuid"
+)
+
+const (
+	apiKeyPrefix = "hex_"
+	// last_used_at is only rewritten once per interval so busy keys don't
+	// turn every request into a write
+	apiKeyTouchInterval = time.Minute
+)
+
+var ErrInvalidAPIKey = domain.Unauthorized("invalid_api_key", "invalid api key")
+
+// IsPersonalAPIKey tells personal keys apart from the shared webhook key,
+// which travels in the same Authorization: ApiKey header.
+func IsPersonalAPIKey(key string) bool {
+	return strings.HasPrefix(key, apiKeyPrefix)
+}
+
+// CreateAPIKey stores a new key for the user and returns it together with the
+// plaintext key, which is never retrievable again.
+func (u *DB) CreateAPIKey(userID, name string, scopes []string, expiresAt *time.Time) (*domain.APIKey, string, error) {
+	if strings.TrimSpace(name) == "" {
+		return nil, "", domain.Validation("api_key_name_required", "api key name is required")
+	}
+	if len(scopes) == 0 {
+		return nil, "", domain.Validation("api_key_scopes_required", "api key needs at least one scope")
+	}
+	for _, scope := range scopes {
+		if !validScope(scope) {
+			return nil, "", domain.Validation("unknown_scope", "unknown scope %q", scope)
+		}
+	}
+	if expiresAt != nil && !expiresAt.After(time.Now()) {
+		return nil, "", domain.Validation("invalid_api_key_expiry", "api key expiry must be in the future")
+	}
+
+	prefix, secret, err := generateAPIKey()
+	if err != nil {
+		return nil, "", err
+	}
+	plaintext := prefix + "_" + secret
+
+	key := &domain.APIKey{
+		ID:        uuid.New().String(),
+		UserID:    userID,
+		Name:      name,
+		Prefix:    prefix,
+		KeyHash:   hashAPIKey(plaintext),
+		Scopes:    strings.Join(scopes, " "),
+		ExpiresAt: expiresAt,
+	}
+	req := u.db.Create(&key)
+	if req.RowsAffected == 0 {
+		return nil, "", domain.Unavailable("database_unavailable", "api key not saved: %v", req.Error)
+	}
+	return key, plaintext, nil
+}
+
+func (u *DB) ListAPIKeys(userID string) ([]*domain.APIKey, error) {
+	var keys []*domain.APIKey
+	req := u.db.Where("user_id = ? AND revoked_at IS NULL", userID).Order("created_at desc").Find(&keys)
+	if req.Error != nil {
+		return nil, domain.Unavailable("database_unavailable", "api keys not found: %v", req.Error)
+	}
+	return keys, nil
+}
+
+func (u *DB) RevokeAPIKey(userID, id string) error {
+	req := u.db.Model(&domain.APIKey{}).
+		Where("id = ? AND user_id = ? AND revoked_at IS NULL", id, userID).
+		Update("revoked_at", time.Now().UTC())
+	if req.RowsAffected == 0 {
+		return domain.NotFound("api_key_not_found", "api key not found")
+	}
+	return nil
+}
+
+// AuthenticateAPIKey resolves a plaintext key to its stored record, rejecting
+// revoked and expired keys, and records when it was last used.
+func (u *DB) AuthenticateAPIKey(plaintext string) (*domain.APIKey, error) {
+	prefix, _, ok := splitAPIKey(plaintext)
+	if !ok {
+		return nil, ErrInvalidAPIKey
+	}
+
+	key := &domain.APIKey{}
+	req := u.db.First(&key, "prefix = ?", prefix)
+	if req.RowsAffected == 0 {
+		return nil, ErrInvalidAPIKey
+	}
+	if subtle.ConstantTimeCompare([]byte(key.KeyHash), []byte(hashAPIKey(plaintext))) != 1 {
+		return nil, ErrInvalidAPIKey
+	}
+
+	now := time.Now().UTC()
+	if key.RevokedAt != nil || (key.ExpiresAt != nil && key.ExpiresAt.Before(now)) {
+		return nil, ErrInvalidAPIKey
+	}
+
+	if key.LastUsedAt == nil || now.Sub(*key.LastUsedAt) > apiKeyTouchInterval {
+		req = u.db.Model(&domain.APIKey{}).Where("id = ?", key.ID).Update("last_used_at", now)
+		if req.Error != nil {
+			fmt.Printf("Error recording api key use: %v", req.Error)
+		}
+		key.LastUsedAt = &now
+	}
+	return key, nil
+}
+
+func validScope(scope string) bool {
+	for _, s := range domain.APIKeyScopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// generateAPIKey returns the visible prefix ("hex_" plus 8 hex chars) and the
+// secret part of a new key.
+func generateAPIKey() (string, string, error) {
+	b := make([]byte, 20)
+	if _, err := rand.Read(b); err != nil {
+		return "", "", fmt.Errorf("api key not generated: %v", err)
+	}
+	raw := hex.EncodeToString(b)
+	return apiKeyPrefix + raw[:8], raw[8:], nil
+}
+
+func splitAPIKey(plaintext string) (string, string, bool) {
+	if !IsPersonalAPIKey(plaintext) {
+		return "", "", false
+	}
+	i := strings.LastIndex(plaintext, "_")
+	if i <= len(apiKeyPrefix) || i == len(plaintext)-1 {
+		return "", "", false
+	}
+	return plaintext[:i], plaintext[i+1:], true
+}
+
+// the secret part carries 128 random bits, so a fast hash is enough here
+func hashAPIKey(plaintext string) string {
+	sum := sha256.Sum256([]byte(plaintext))
+	return hex.EncodeToString(sum[:])
+}
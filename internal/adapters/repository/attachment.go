@@ -0,0 +1,75 @@
+package repository
+
+import (
+	"github.com/LordMoMA/Hexagonal-Architecture/internal/core/domain"
+)
+
+func (m *DB) ReadAttachment(id string) (*domain.Attachment, error) {
+	attachment := &domain.Attachment{}
+	req := m.db.First(&attachment, "id = ?", id)
+	if req.RowsAffected == 0 {
+		return nil, domain.NotFound("attachment_not_found", "attachment not found")
+	}
+	return attachment, nil
+}
+
+// ReadAttachmentKeys returns which of keys belong to an attachment. Blobs
+// under the others are orphans.
+func (m *DB) ReadAttachmentKeys(keys []string) ([]string, error) {
+	var found []string
+	if len(keys) == 0 {
+		return found, nil
+	}
+	req := m.db.Model(&domain.Attachment{}).Where("blob_key IN (?)", keys).Pluck("blob_key", &found)
+	if req.Error != nil {
+		return nil, domain.Unavailable("database_unavailable", "attachments not found: %v", req.Error)
+	}
+	return found, nil
+}
+
+// attachAttachments fills in the attachments of messages with one query. A
+// tombstone keeps its attachments for a restore but doesn't show them.
+func (m *DB) attachAttachments(messages []*domain.Message) error {
+	var ids []string
+	byID := make(map[string]*domain.Message, len(messages))
+	for _, message := range messages {
+		message.Attachments = []domain.Attachment{}
+		if !message.Deleted {
+			ids = append(ids, message.ID)
+			byID[message.ID] = message
+		}
+	}
+	if len(ids) == 0 {
+		return nil
+	}
+
+	var attachments []domain.Attachment
+	req := m.db.Where("message_id IN (?)", ids).Order("created_at, id").Find(&attachments)
+	if req.Error != nil {
+		return domain.Unavailable("database_unavailable", "attachments not found: %v", req.Error)
+	}
+	for _, attachment := range attachments {
+		message := byID[attachment.MessageID]
+		message.Attachments = append(message.Attachments, attachment)
+	}
+	return nil
+}
+
+// fillMessages loads what is stored alongside messages: their reactions and
+// attachments.
+func (m *DB) fillMessages(messages []*domain.Message) error {
+	if err := m.attachReactions(messages); err != nil {
+		return err
+	}
+	return m.attachAttachments(messages)
+}
+
+func (m *DB) fillPage(page *domain.Page[*domain.Message], err error) (*domain.Page[*domain.Message], error) {
+	if err != nil {
+		return nil, err
+	}
+	if err := m.fillMessages(page.Items); err != nil {
+		return nil, err
+	}
+	return page, nil
+}
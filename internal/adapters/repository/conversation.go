@@ -0,0 +1,166 @@
+package repository
+
+import (
+	"time"
+
+	"github.com/LordMoMA/Hexagonal-Architecture/internal/core/domain"
+	"github.com/jinzhu/gorm"
+)
+
+func (m *DB) CreateConversation(conversation domain.Conversation) (*domain.Conversation, error) {
+	var count int
+	req := m.db.Model(&domain.User{}).Where("id IN (?)", conversation.ParticipantIDs).Count(&count)
+	if req.Error != nil {
+		return nil, domain.Unavailable("database_unavailable", "participants not checked: %v", req.Error)
+	}
+	if count != len(conversation.ParticipantIDs) {
+		return nil, domain.NotFound("participant_not_found", "participant not found")
+	}
+
+	tx := m.db.Begin()
+	if err := tx.Create(&conversation).Error; err != nil {
+		tx.Rollback()
+		return nil, domain.Unavailable("database_unavailable", "conversation not saved: %v", err)
+	}
+	now := time.Now().UTC()
+	for _, userID := range conversation.ParticipantIDs {
+		participant := &domain.ConversationParticipant{
+			ConversationID: conversation.ID,
+			UserID:         userID,
+			JoinedAt:       now,
+		}
+		if err := tx.Create(&participant).Error; err != nil {
+			tx.Rollback()
+			return nil, domain.Unavailable("database_unavailable", "participants not saved: %v", err)
+		}
+	}
+	if err := tx.Commit().Error; err != nil {
+		return nil, domain.Unavailable("database_unavailable", "conversation not saved: %v", err)
+	}
+	return &conversation, nil
+}
+
+func (m *DB) FindDirectConversation(userID, otherUserID string) (*domain.Conversation, error) {
+	conversation := &domain.Conversation{}
+	req := m.db.
+		Joins("JOIN conversation_participants p1 ON p1.conversation_id = conversations.id AND p1.user_id = ?", userID).
+		Joins("JOIN conversation_participants p2 ON p2.conversation_id = conversations.id AND p2.user_id = ?", otherUserID).
+		Where("conversations.kind = ?", domain.ConversationDirect).
+		First(&conversation)
+	if req.RowsAffected == 0 {
+		return nil, domain.NotFound("conversation_not_found", "conversation not found")
+	}
+	conversation.ParticipantIDs = []string{userID, otherUserID}
+	return conversation, nil
+}
+
+func (m *DB) ReadConversations(userID string) ([]*domain.Conversation, error) {
+	return m.readConversations(userID, m.db.Order("conversations.created_at desc"))
+}
+
+// ReadConversationsByIDs reads those of ids userID takes part in, in no
+// particular order. The others are left out, as if they didn't exist.
+func (m *DB) ReadConversationsByIDs(userID string, ids []string) ([]*domain.Conversation, error) {
+	if len(ids) == 0 {
+		return []*domain.Conversation{}, nil
+	}
+	return m.readConversations(userID, m.db.Where("conversations.id IN (?)", ids))
+}
+
+// readConversations reads the conversations of query userID takes part in,
+// with their participants and userID's unread counts.
+func (m *DB) readConversations(userID string, query *gorm.DB) ([]*domain.Conversation, error) {
+	var conversations []*domain.Conversation
+	req := query.
+		Joins("JOIN conversation_participants p ON p.conversation_id = conversations.id AND p.user_id = ?", userID).
+		Find(&conversations)
+	if req.Error != nil {
+		return nil, domain.Unavailable("database_unavailable", "conversations not found: %v", req.Error)
+	}
+	if len(conversations) == 0 {
+		return conversations, nil
+	}
+
+	ids := make([]string, len(conversations))
+	byID := make(map[string]*domain.Conversation, len(conversations))
+	for i, c := range conversations {
+		ids[i] = c.ID
+		byID[c.ID] = c
+	}
+	var participants []*domain.ConversationParticipant
+	req = m.db.Where("conversation_id IN (?)", ids).Order("joined_at").Find(&participants)
+	if req.Error != nil {
+		return nil, domain.Unavailable("database_unavailable", "participants not found: %v", req.Error)
+	}
+	for _, p := range participants {
+		c := byID[p.ConversationID]
+		c.ParticipantIDs = append(c.ParticipantIDs, p.UserID)
+	}
+
+	var unread []struct {
+		ConversationID string
+		Count          int
+	}
+	req = m.db.Table("messages").
+		Select("messages.conversation_id, count(*) AS count").
+		Joins("JOIN conversation_participants p ON p.conversation_id = messages.conversation_id AND p.user_id = ?", userID).
+		Where("messages.conversation_id IN (?) AND messages.user_id <> ? AND NOT messages.deleted", ids, userID).
+		Where("p.last_read_at IS NULL OR messages.created_at > p.last_read_at").
+		Group("messages.conversation_id").
+		Scan(&unread)
+	if req.Error != nil {
+		return nil, domain.Unavailable("database_unavailable", "unread counts not found: %v", req.Error)
+	}
+	for _, u := range unread {
+		byID[u.ConversationID].UnreadCount = u.Count
+	}
+	return conversations, nil
+}
+
+// MarkConversationRead moves userID's read marker to readAt. The marker only
+// moves forward, so a late request from another device can't mark messages
+// unread again.
+func (m *DB) MarkConversationRead(conversationID, userID string, readAt time.Time) error {
+	req := m.db.Model(&domain.ConversationParticipant{}).
+		Where("conversation_id = ? AND user_id = ?", conversationID, userID).
+		Where("last_read_at IS NULL OR last_read_at < ?", readAt).
+		Update("last_read_at", readAt)
+	if req.Error != nil {
+		return domain.Unavailable("database_unavailable", "read marker not saved: %v", req.Error)
+	}
+	return nil
+}
+
+func (m *DB) ReadConversationIDs(userID string) ([]string, error) {
+	var ids []string
+	req := m.db.Model(&domain.ConversationParticipant{}).Where("user_id = ?", userID).Pluck("conversation_id", &ids)
+	if req.Error != nil {
+		return nil, domain.Unavailable("database_unavailable", "conversations not found: %v", req.Error)
+	}
+	return ids, nil
+}
+
+func (m *DB) ReadConversationMessages(conversationID string, opts domain.ListOptions) (*domain.Page[*domain.Message], error) {
+	// replies are read through their thread, not the conversation timeline
+	return m.fillPage(pageMessages(m.db.Where("messages.conversation_id = ? AND messages.parent_id = ''", conversationID), opts))
+}
+
+func (m *DB) ReadParticipantIDs(conversationID string) ([]string, error) {
+	var ids []string
+	req := m.db.Model(&domain.ConversationParticipant{}).Where("conversation_id = ?", conversationID).Pluck("user_id", &ids)
+	if req.Error != nil {
+		return nil, domain.Unavailable("database_unavailable", "participants not found: %v", req.Error)
+	}
+	return ids, nil
+}
+
+func (m *DB) IsParticipant(conversationID, userID string) (bool, error) {
+	var count int
+	req := m.db.Model(&domain.ConversationParticipant{}).
+		Where("conversation_id = ? AND user_id = ?", conversationID, userID).
+		Count(&count)
+	if req.Error != nil {
+		return false, domain.Unavailable("database_unavailable", "participant not checked: %v", req.Error)
+	}
+	return count > 0, nil
+}
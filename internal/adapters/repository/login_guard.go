@@ -0,0 +1,115 @@
+package repository
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/LordMoMA/Hexagonal-Architecture/internal/core/domain"
+	"golang.org/x/crypto/bcrypt"
+)
+
+const (
+	accountLockThreshold = 5
+	ipLockThreshold      = 20
+	baseLockout          = time.Minute
+	maxLockout           = time.Hour
+	failureWindow        = 24 * time.Hour
+)
+
+var (
+	ErrInvalidCredentials = domain.Unauthorized("invalid_credentials", "invalid email or password")
+	ErrLoginLocked        = domain.RateLimited("login_locked", "too many failed login attempts, please try again later")
+)
+
+var (
+	dummyHashOnce sync.Once
+	dummyHash     []byte
+)
+
+// loginLocked reports whether either the account or the client IP is
+// currently serving a lockout. A cache outage fails open so login stays
+// available.
+func (u *DB) loginLocked(email, ip string) bool {
+	var locked bool
+	if err := u.cache.Get(lockKey("account", email), &locked); err == nil && locked {
+		return true
+	}
+	if err := u.cache.Get(lockKey("ip", ip), &locked); err == nil && locked {
+		return true
+	}
+	return false
+}
+
+// recordLoginFailure counts a failed attempt against both the account and the
+// IP. Once a counter reaches its threshold every further failure doubles the
+// lockout, up to maxLockout.
+func (u *DB) recordLoginFailure(email, ip string) {
+	u.countFailure("account", email, accountLockThreshold)
+	u.countFailure("ip", ip, ipLockThreshold)
+}
+
+func (u *DB) countFailure(scope, subject string, threshold int64) {
+	failures, err := u.cache.Increment(failKey(scope, subject), failureWindow)
+	if err != nil {
+		fmt.Printf("Error counting login failure: %v", err)
+		return
+	}
+	if failures < threshold {
+		return
+	}
+
+	err = u.cache.Set(lockKey(scope, subject), true, lockoutDuration(failures-threshold))
+	if err != nil {
+		fmt.Printf("Error storing login lockout: %v", err)
+	}
+}
+
+func (u *DB) clearAccountLockout(email string) {
+	if err := u.cache.Delete(failKey("account", email)); err != nil {
+		fmt.Printf("Error deleting login failures in cache: %v", err)
+	}
+	if err := u.cache.Delete(lockKey("account", email)); err != nil {
+		fmt.Printf("Error deleting login lockout in cache: %v", err)
+	}
+}
+
+// UnlockUser lifts any lockout on the account and resets its failure count.
+func (u *DB) UnlockUser(id string) error {
+	user := &domain.User{}
+	req := u.db.First(&user, "id = ? ", id)
+	if req.RowsAffected == 0 {
+		return domain.NotFound("user_not_found", "user not found")
+	}
+	u.clearAccountLockout(user.Email)
+	return nil
+}
+
+// burnPasswordCheck spends the same bcrypt work as a real comparison so that
+// unknown emails can't be told apart by response time.
+func burnPasswordCheck(password string) {
+	dummyHashOnce.Do(func() {
+		dummyHash, _ = bcrypt.GenerateFromPassword([]byte("not-a-real-password"), bcrypt.DefaultCost)
+	})
+	_ = bcrypt.CompareHashAndPassword(dummyHash, []byte(password))
+}
+
+func lockoutDuration(excess int64) time.Duration {
+	d := baseLockout
+	for i := int64(0); i < excess && d < maxLockout; i++ {
+		d *= 2
+	}
+	if d > maxLockout {
+		d = maxLockout
+	}
+	return d
+}
+
+func failKey(scope, subject string) string {
+	return "login:fail:" + scope + ":" + strings.ToLower(strings.TrimSpace(subject))
+}
+
+func lockKey(scope, subject string) string {
+	return "login:lock:" + scope + ":" + strings.ToLower(strings.TrimSpace(subject))
+}
@@ -1,28 +1,88 @@
 package repository
 
 import (
-	"errors"
-	"fmt"
+	"strings"
+	"time"
 
 	"github.com/LordMoMA/Hexagonal-Architecture/internal/core/domain"
 	"github.com/google/uuid"
+	"github.com/jinzhu/gorm"
 	_ "github.com/jinzhu/gorm/dialects/postgres"
 )
 
+var ErrMessageDeleted = domain.Conflict("message_deleted", "message has been deleted")
+
 // 	type MessengerPostgresRepository struct {
 // 	db *gorm.DB
 // }
 
+// CreateMessage saves the message together with the records of its
+// attachments, whose files are already in the blob store, and its place in
+// the review queue if moderation flagged it.
 func (m *DB) CreateMessage(userID string, message domain.Message) error {
-	message.ID = uuid.New().String()
+	tx := m.db.Begin()
+	if err := createMessage(tx, userID, message); err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit().Error
+}
+
+// CreateMessages saves messages in one transaction. When one can't be saved
+// none are, and the error is a *domain.ItemError saying which.
+func (m *DB) CreateMessages(userID string, messages []domain.Message) error {
+	tx := m.db.Begin()
+	for i, message := range messages {
+		if err := createMessage(tx, userID, message); err != nil {
+			tx.Rollback()
+			return &domain.ItemError{Index: i, Err: err}
+		}
+	}
+	return tx.Commit().Error
+}
+
+func createMessage(tx *gorm.DB, userID string, message domain.Message) error {
+	if message.ID == "" {
+		message.ID = uuid.New().String()
+	}
+	attachments := message.Attachments
+	flaggedFor := message.FlaggedFor
 	message = domain.Message{
-		ID:     message.ID,
-		UserID: userID,
-		Body:   message.Body,
+		ID:             message.ID,
+		ConversationID: message.ConversationID,
+		UserID:         userID,
+		ParentID:       message.ParentID,
+		Body:           message.Body,
+		CreatedAt:      message.CreatedAt,
+		ExpiresAt:      message.ExpiresAt,
 	}
-	req := m.db.Create(&message)
+
+	req := tx.Create(&message)
 	if req.RowsAffected == 0 {
-		return fmt.Errorf("messages not saved: %v", req.Error)
+		return domain.Unavailable("database_unavailable", "messages not saved: %v", req.Error)
+	}
+	if len(flaggedFor) > 0 {
+		flagged := &domain.FlaggedMessage{
+			ID:        uuid.New().String(),
+			MessageID: message.ID,
+			UserID:    userID,
+			Reasons:   strings.Join(flaggedFor, "; "),
+			CreatedAt: time.Now().UTC(),
+		}
+		if err := tx.Create(&flagged).Error; err != nil {
+			return domain.Unavailable("database_unavailable", "message not queued for review: %v", err)
+		}
+	}
+	for _, attachment := range attachments {
+		attachment.MessageID = message.ID
+		if err := tx.Create(&attachment).Error; err != nil {
+			return domain.Unavailable("database_unavailable", "attachment not saved: %v", err)
+		}
+	}
+	if message.ParentID != "" {
+		if err := adjustReplyCount(tx, message.ParentID, 1); err != nil {
+			return err
+		}
 	}
 	return nil
 }
@@ -30,34 +90,170 @@ func (m *DB) CreateMessage(userID string, message domain.Message) error {
 func (m *DB) ReadMessage(id string) (*domain.Message, error) {
 	message := &domain.Message{}
 	req := m.db.First(&message, "id = ? ", id)
-	if req.RowsAffected == 0 {
-		return nil, errors.New("message not found")
+	if gorm.IsRecordNotFoundError(req.Error) {
+		return nil, domain.NotFound("message_not_found", "message not found")
+	}
+	if req.Error != nil {
+		return nil, domain.Unavailable("database_unavailable", "message not read: %v", req.Error)
+	}
+	if err := m.fillMessages([]*domain.Message{message}); err != nil {
+		return nil, err
 	}
 	return message, nil
 }
 
-func (m *DB) ReadMessages() ([]*domain.Message, error) {
-	var messages []*domain.Message
-	req := m.db.Find(&messages)
+func (m *DB) ReadMessages(userID string, opts domain.ListOptions) (*domain.Page[*domain.Message], error) {
+	query := m.db.Where("messages.conversation_id IN (?)",
+		m.db.Table("conversation_participants").Select("conversation_id").Where("user_id = ?", userID).QueryExpr())
+	return m.fillPage(pageMessages(query, opts))
+}
+
+// UpdateMessage replaces the body, keeping the old one as a revision.
+func (m *DB) UpdateMessage(id string, message domain.Message) error {
+	tx := m.db.Begin()
+	current, err := lockMessage(tx, id)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	if current.Deleted {
+		tx.Rollback()
+		return ErrMessageDeleted
+	}
+	if err := saveRevision(tx, current, domain.RevisionEdit); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	// only the body is editable; author and conversation are fixed
+	err = tx.Model(&domain.Message{}).Where("id = ?", id).Updates(map[string]interface{}{
+		"body":      message.Body,
+		"edited":    true,
+		"edited_at": time.Now().UTC(),
+	}).Error
+	if err != nil {
+		tx.Rollback()
+		return domain.Unavailable("database_unavailable", "message not updated: %v", err)
+	}
+	return tx.Commit().Error
+}
+
+// DeleteMessage leaves a tombstone in place of the message. The body moves
+// to a revision so an admin can still see it and restore the message.
+func (m *DB) DeleteMessage(id string) error {
+	tx := m.db.Begin()
+	current, err := lockMessage(tx, id)
+	if err != nil || current.Deleted {
+		tx.Rollback()
+		return domain.NotFound("message_not_found", "message not found")
+	}
+	if err := saveRevision(tx, current, domain.RevisionDelete); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	err = tx.Model(&domain.Message{}).Where("id = ?", id).Updates(map[string]interface{}{
+		"body":       "",
+		"deleted":    true,
+		"removed_at": time.Now().UTC(),
+	}).Error
+	if err != nil {
+		tx.Rollback()
+		return domain.Unavailable("database_unavailable", "message not deleted: %v", err)
+	}
+	if current.ParentID != "" {
+		if err := adjustReplyCount(tx, current.ParentID, -1); err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+	return tx.Commit().Error
+}
+
+func (m *DB) ReadMessageRevisions(id string) ([]*domain.MessageRevision, error) {
+	var revisions []*domain.MessageRevision
+	req := m.db.Where("message_id = ?", id).Order("created_at").Find(&revisions)
 	if req.Error != nil {
-		return nil, fmt.Errorf("messages not found: %v", req.Error)
+		return nil, domain.Unavailable("database_unavailable", "revisions not found: %v", req.Error)
 	}
-	return messages, nil
+	return revisions, nil
 }
 
-func (m *DB) UpdateMessage(id string, message domain.Message) error {
-	req := m.db.Model(&message).Where("id = ?", id).Update(message)
+// RestoreMessage brings a deleted message back with the body it had when it
+// was deleted.
+func (m *DB) RestoreMessage(id string) error {
+	tx := m.db.Begin()
+	current, err := lockMessage(tx, id)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	if !current.Deleted {
+		tx.Rollback()
+		return domain.Conflict("message_not_deleted", "message is not deleted")
+	}
+
+	revision := &domain.MessageRevision{}
+	req := tx.Where("message_id = ? AND kind = ?", id, domain.RevisionDelete).Order("created_at desc").First(&revision)
 	if req.RowsAffected == 0 {
-		return errors.New("message not found")
+		tx.Rollback()
+		return domain.Conflict("message_not_restorable", "message has no deleted revision to restore")
+	}
+
+	err = tx.Model(&domain.Message{}).Where("id = ?", id).Updates(map[string]interface{}{
+		"body":       revision.Body,
+		"deleted":    false,
+		"removed_at": gorm.Expr("NULL"),
+	}).Error
+	if err != nil {
+		tx.Rollback()
+		return domain.Unavailable("database_unavailable", "message not restored: %v", err)
+	}
+	if current.ParentID != "" {
+		if err := adjustReplyCount(tx, current.ParentID, 1); err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+	return tx.Commit().Error
+}
+
+// ReadReplies pages through a message's thread, oldest reply first unless
+// opts asks otherwise.
+func (m *DB) ReadReplies(parentID string, opts domain.ListOptions) (*domain.Page[*domain.Message], error) {
+	return m.fillPage(pageMessages(m.db.Where("messages.parent_id = ?", parentID), opts))
+}
+
+func adjustReplyCount(tx *gorm.DB, parentID string, delta int) error {
+	err := tx.Model(&domain.Message{}).Where("id = ?", parentID).
+		UpdateColumn("reply_count", gorm.Expr("reply_count + ?", delta)).Error
+	if err != nil {
+		return domain.Unavailable("database_unavailable", "reply count not updated: %v", err)
 	}
 	return nil
 }
 
-func (m *DB) DeleteMessage(id string) error {
+// lockMessage reads a message inside tx and locks its row until tx ends, so
+// concurrent edits can't both record the same previous body.
+func lockMessage(tx *gorm.DB, id string) (*domain.Message, error) {
 	message := &domain.Message{}
-	req := m.db.Where("id = ?", id).Delete(&message)
+	req := tx.Set("gorm:query_option", "FOR UPDATE").First(&message, "id = ?", id)
 	if req.RowsAffected == 0 {
-		return errors.New("message not found")
+		return nil, domain.NotFound("message_not_found", "message not found")
+	}
+	return message, nil
+}
+
+func saveRevision(tx *gorm.DB, message *domain.Message, kind string) error {
+	revision := &domain.MessageRevision{
+		ID:        uuid.New().String(),
+		MessageID: message.ID,
+		Kind:      kind,
+		Body:      message.Body,
+		CreatedAt: time.Now().UTC(),
+	}
+	if err := tx.Create(&revision).Error; err != nil {
+		return domain.Unavailable("database_unavailable", "revision not saved: %v", err)
 	}
 	return nil
 }
@@ -0,0 +1,327 @@
+package repository
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1" // #nosec G505 -- RFC 6238 TOTP uses HMAC-SHA1 for authenticator app compatibility
+	"crypto/sha256"
+	"encoding/base32"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/LordMoMA/Hexagonal-Architecture/internal/core/domain"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+)
+
+const (
+	totpIssuer        = "LordMoMA"
+	totpPeriod        = 30
+	totpDigits        = 6
+	totpSkew          = 1 // accepted time steps either side of now
+	recoveryCodeCount = 10
+	mfaTokenIssuer    = "LordMoMA-mfa"
+)
+
+var totpEncoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+type TOTPEnrollment struct {
+	Secret        string   `json:"secret"`
+	URI           string   `json:"otpauth_uri"`
+	RecoveryCodes []string `json:"recovery_codes"`
+}
+
+func (u *DB) EnrollTOTP(id string) (*TOTPEnrollment, error) {
+	user := &domain.User{}
+	req := u.db.First(&user, "id = ? ", id)
+	if req.RowsAffected == 0 {
+		return nil, domain.NotFound("user_not_found", "user not found")
+	}
+	if user.TOTPEnabled {
+		return nil, domain.Conflict("mfa_already_enabled", "two-factor authentication already enabled")
+	}
+
+	secret, err := GenerateTOTPSecret()
+	if err != nil {
+		return nil, err
+	}
+
+	codes := make([]string, recoveryCodeCount)
+	for i := range codes {
+		codes[i], err = generateRecoveryCode()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	tx := u.db.Begin()
+	req = tx.Model(&domain.User{}).Where("id = ?", id).Updates(map[string]interface{}{
+		"totp_secret":    secret,
+		"totp_enabled":   false,
+		"totp_last_step": 0,
+	})
+	if req.Error != nil {
+		tx.Rollback()
+		return nil, domain.Unavailable("database_unavailable", "two-factor secret not saved: %v", req.Error)
+	}
+
+	// a fresh enrolment invalidates any codes issued by an earlier one
+	if err := tx.Where("user_id = ?", id).Delete(&domain.RecoveryCode{}).Error; err != nil {
+		tx.Rollback()
+		return nil, domain.Unavailable("database_unavailable", "recovery codes not reset: %v", err)
+	}
+	for _, code := range codes {
+		rc := &domain.RecoveryCode{
+			ID:       uuid.New().String(),
+			UserID:   id,
+			CodeHash: hashRecoveryCode(code),
+		}
+		if err := tx.Create(&rc).Error; err != nil {
+			tx.Rollback()
+			return nil, domain.Unavailable("database_unavailable", "recovery codes not saved: %v", err)
+		}
+	}
+	if err := tx.Commit().Error; err != nil {
+		return nil, domain.Unavailable("database_unavailable", "two-factor enrolment not saved: %v", err)
+	}
+
+	err = u.cache.Delete(id)
+	if err != nil {
+		fmt.Printf("Error deleting user in cache: %v", err)
+	}
+
+	return &TOTPEnrollment{
+		Secret:        secret,
+		URI:           TOTPURI(user.Email, secret),
+		RecoveryCodes: codes,
+	}, nil
+}
+
+func (u *DB) ActivateTOTP(id, code string) error {
+	user := &domain.User{}
+	req := u.db.First(&user, "id = ? ", id)
+	if req.RowsAffected == 0 {
+		return domain.NotFound("user_not_found", "user not found")
+	}
+	if user.TOTPSecret == "" {
+		return domain.Conflict("mfa_not_enrolled", "two-factor authentication not enrolled")
+	}
+	if user.TOTPEnabled {
+		return domain.Conflict("mfa_already_enabled", "two-factor authentication already enabled")
+	}
+
+	step, ok := ValidateTOTPCode(user.TOTPSecret, code, time.Now())
+	if !ok {
+		return domain.Unauthorized("invalid_mfa_code", "invalid two-factor code")
+	}
+
+	req = u.db.Model(&domain.User{}).Where("id = ?", id).Updates(map[string]interface{}{
+		"totp_enabled":   true,
+		"totp_last_step": step,
+	})
+	if req.RowsAffected == 0 {
+		return domain.Unavailable("database_unavailable", "unable to enable two-factor authentication :(")
+	}
+
+	err := u.cache.Delete(id)
+	if err != nil {
+		fmt.Printf("Error deleting user in cache: %v", err)
+	}
+	return nil
+}
+
+// LoginUserMFA completes a login started by LoginUser for a user with TOTP
+// enabled. code is either a current TOTP code or an unused recovery code.
+func (u *DB) LoginUserMFA(mfaToken, code string, client domain.ClientInfo) (*LoginResponse, error) {
+	apiCfg, err := LoadAPIConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	userID, err := parseMFAToken(mfaToken, apiCfg.JWTSecret)
+	if err != nil {
+		return nil, err
+	}
+
+	user := &domain.User{}
+	req := u.db.First(&user, "id = ? ", userID)
+	if req.RowsAffected == 0 {
+		return nil, domain.NotFound("user_not_found", "user not found")
+	}
+	if !user.TOTPEnabled {
+		return nil, domain.Conflict("mfa_not_enabled", "two-factor authentication not enabled")
+	}
+	if u.loginLocked(user.Email, client.IP) {
+		return nil, ErrLoginLocked
+	}
+
+	if isTOTPCode(code) {
+		err = u.consumeTOTPCode(user, code)
+	} else {
+		err = u.consumeRecoveryCode(user.ID, code)
+	}
+	if err != nil {
+		u.recordLoginFailure(user.Email, client.IP)
+		return nil, err
+	}
+	u.clearAccountLockout(user.Email)
+
+	return u.newLoginResponse(user, apiCfg.JWTSecret, client)
+}
+
+// consumeTOTPCode accepts each time step at most once so an observed code
+// cannot be replayed within its validity window.
+func (u *DB) consumeTOTPCode(user *domain.User, code string) error {
+	step, ok := ValidateTOTPCode(user.TOTPSecret, code, time.Now())
+	if !ok || step <= user.TOTPLastStep {
+		return domain.Unauthorized("invalid_mfa_code", "invalid two-factor code")
+	}
+
+	req := u.db.Model(&domain.User{}).
+		Where("id = ? AND totp_last_step < ?", user.ID, step).
+		Update("totp_last_step", step)
+	if req.RowsAffected == 0 {
+		return domain.Unauthorized("invalid_mfa_code", "invalid two-factor code")
+	}
+	return nil
+}
+
+func (u *DB) consumeRecoveryCode(userID, code string) error {
+	req := u.db.Model(&domain.RecoveryCode{}).
+		Where("user_id = ? AND code_hash = ? AND used = ?", userID, hashRecoveryCode(code), false).
+		Update("used", true)
+	if req.RowsAffected == 0 {
+		return domain.Unauthorized("invalid_recovery_code", "invalid recovery code")
+	}
+	return nil
+}
+
+func (u *DB) generateMFAToken(userID, jwtSecret string) (string, error) {
+	claims := jwt.RegisteredClaims{
+		Issuer:    mfaTokenIssuer,
+		Subject:   userID,
+		IssuedAt:  jwt.NewNumericDate(time.Now().UTC()),
+		ExpiresAt: jwt.NewNumericDate(time.Now().Add(5 * time.Minute).UTC()),
+	}
+
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	return token.SignedString([]byte(jwtSecret))
+}
+
+func parseMFAToken(tokenString, jwtSecret string) (string, error) {
+	claims := &jwt.RegisteredClaims{}
+	token, err := jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
+		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
+		}
+		return []byte(jwtSecret), nil
+	})
+	if err != nil {
+		return "", err
+	}
+	if !token.Valid || claims.Issuer != mfaTokenIssuer {
+		return "", domain.Unauthorized("invalid_mfa_token", "mfa token not valid")
+	}
+	return claims.Subject, nil
+}
+
+// GenerateTOTPSecret returns a random 160-bit secret in unpadded base32, the
+// form authenticator apps expect.
+func GenerateTOTPSecret() (string, error) {
+	key := make([]byte, 20)
+	if _, err := rand.Read(key); err != nil {
+		return "", fmt.Errorf("secret not generated: %v", err)
+	}
+	return totpEncoding.EncodeToString(key), nil
+}
+
+// TOTPURI builds the otpauth:// URI rendered as a QR code during enrolment.
+func TOTPURI(email, secret string) string {
+	params := url.Values{}
+	params.Set("secret", secret)
+	params.Set("issuer", totpIssuer)
+	params.Set("algorithm", "SHA1")
+	params.Set("digits", fmt.Sprint(totpDigits))
+	params.Set("period", fmt.Sprint(totpPeriod))
+
+	label := url.PathEscape(totpIssuer + ":" + email)
+	return "otpauth://totp/" + label + "?" + params.Encode()
+}
+
+// GenerateTOTPCode computes the RFC 6238 code for secret at time t.
+func GenerateTOTPCode(secret string, t time.Time) (string, error) {
+	return totpCode(secret, t.Unix()/totpPeriod)
+}
+
+// ValidateTOTPCode reports whether code is valid for secret at time t,
+// tolerating totpSkew steps of clock drift, and returns the matched step.
+func ValidateTOTPCode(secret, code string, t time.Time) (int64, bool) {
+	if !isTOTPCode(code) {
+		return 0, false
+	}
+	now := t.Unix() / totpPeriod
+	for step := now - totpSkew; step <= now+totpSkew; step++ {
+		expected, err := totpCode(secret, step)
+		if err != nil {
+			return 0, false
+		}
+		if hmac.Equal([]byte(expected), []byte(code)) {
+			return step, true
+		}
+	}
+	return 0, false
+}
+
+func totpCode(secret string, step int64) (string, error) {
+	key, err := totpEncoding.DecodeString(strings.ToUpper(strings.TrimRight(secret, "=")))
+	if err != nil {
+		return "", fmt.Errorf("invalid totp secret: %v", err)
+	}
+
+	var msg [8]byte
+	binary.BigEndian.PutUint64(msg[:], uint64(step))
+
+	mac := hmac.New(sha1.New, key)
+	mac.Write(msg[:])
+	sum := mac.Sum(nil)
+
+	// dynamic truncation, RFC 4226 section 5.3
+	offset := sum[len(sum)-1] & 0x0f
+	bin := binary.BigEndian.Uint32(sum[offset:offset+4]) & 0x7fffffff
+
+	mod := uint32(1)
+	for i := 0; i < totpDigits; i++ {
+		mod *= 10
+	}
+	return fmt.Sprintf("%0*d", totpDigits, bin%mod), nil
+}
+
+func isTOTPCode(code string) bool {
+	if len(code) != totpDigits {
+		return false
+	}
+	for _, c := range code {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+func generateRecoveryCode() (string, error) {
+	b := make([]byte, 5)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("recovery code not generated: %v", err)
+	}
+	code := hex.EncodeToString(b)
+	return code[:5] + "-" + code[5:], nil
+}
+
+func hashRecoveryCode(code string) string {
+	sum := sha256.Sum256([]byte(strings.ToLower(strings.TrimSpace(code))))
+	return hex.EncodeToString(sum[:])
+}
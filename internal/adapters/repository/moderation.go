@@ -0,0 +1,99 @@
+package repository
+
+import (
+	"time"
+
+	"github.com/LordMoMA/Hexagonal-Architecture/internal/core/domain"
+)
+
+// ReadFlaggedMessages pages through the review queue oldest first, either
+// what still awaits review or what has been reviewed, with each message.
+func (m *DB) ReadFlaggedMessages(pending bool, opts domain.ListOptions) (*domain.Page[*domain.FlaggedMessage], error) {
+	if opts.Sort != "" || opts.UserID != "" || opts.Since != nil || opts.Until != nil {
+		return nil, domain.Validation("invalid_list_options", "the review queue can't be sorted or filtered")
+	}
+
+	query := m.db.Where("flagged_messages.reviewed_at IS NULL")
+	if !pending {
+		query = m.db.Where("flagged_messages.reviewed_at IS NOT NULL")
+	}
+	if opts.Cursor != "" {
+		c, err := decodeCursor(opts.Cursor)
+		if err != nil {
+			return nil, err
+		}
+		createdAt, err := time.Parse(time.RFC3339Nano, c.Key)
+		if err != nil {
+			return nil, ErrInvalidCursor
+		}
+		query = afterCursor(query, "flagged_messages", "created_at", false, createdAt, c.ID)
+	}
+
+	limit := pageLimit(opts.Limit)
+	var flagged []*domain.FlaggedMessage
+	req := orderBy(query, "flagged_messages", "created_at", false).Limit(limit + 1).Find(&flagged)
+	if req.Error != nil {
+		return nil, domain.Unavailable("database_unavailable", "flagged messages not found: %v", req.Error)
+	}
+
+	page := &domain.Page[*domain.FlaggedMessage]{Items: flagged}
+	if len(flagged) > limit {
+		page.Items = flagged[:limit]
+		last := page.Items[limit-1]
+		page.NextCursor = encodeCursor(last.CreatedAt.UTC().Format(time.RFC3339Nano), last.ID)
+	}
+
+	if len(page.Items) == 0 {
+		return page, nil
+	}
+	ids := make([]string, len(page.Items))
+	for i, f := range page.Items {
+		ids[i] = f.MessageID
+	}
+	var messages []*domain.Message
+	req = m.db.Where("id IN (?)", ids).Find(&messages)
+	if req.Error != nil {
+		return nil, domain.Unavailable("database_unavailable", "flagged messages not found: %v", req.Error)
+	}
+	byID := make(map[string]*domain.Message, len(messages))
+	for _, message := range messages {
+		byID[message.ID] = message
+	}
+	for _, f := range page.Items {
+		f.Message = byID[f.MessageID]
+	}
+	return page, nil
+}
+
+func (m *DB) ReadFlaggedMessage(id string) (*domain.FlaggedMessage, error) {
+	flagged := &domain.FlaggedMessage{}
+	req := m.db.First(&flagged, "id = ?", id)
+	if req.RowsAffected == 0 {
+		return nil, domain.NotFound("flagged_message_not_found", "flagged message not found")
+	}
+	return flagged, nil
+}
+
+// ReviewFlaggedMessage records an admin's decision. Each flag is reviewed
+// once; a second review of the same flag fails.
+func (m *DB) ReviewFlaggedMessage(id, reviewerID, decision string) (*domain.FlaggedMessage, error) {
+	req := m.db.Model(&domain.FlaggedMessage{}).
+		Where("id = ? AND reviewed_at IS NULL", id).
+		Updates(map[string]interface{}{
+			"reviewed_at": time.Now().UTC(),
+			"reviewed_by": reviewerID,
+			"decision":    decision,
+		})
+	if req.Error != nil {
+		return nil, domain.Unavailable("database_unavailable", "review not saved: %v", req.Error)
+	}
+	if req.RowsAffected == 0 {
+		return nil, domain.NotFound("flagged_message_not_found", "flagged message not found or already reviewed")
+	}
+
+	flagged := &domain.FlaggedMessage{}
+	if err := m.db.First(&flagged, "id = ?", id).Error; err != nil {
+		return nil, domain.Unavailable("database_unavailable", "flagged message not found: %v", err)
+	}
+	return flagged, nil
+}
@@ -0,0 +1,79 @@
+package repository
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+
+	"github.com/LordMoMA/Hexagonal-Architecture/internal/core/domain"
+	"github.com/google/uuid"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// LoginUserOIDC signs in the user linked to an external identity. On first
+// login the identity is linked to the local account with the same (verified)
+// email, or a new account is created for it. The identity provider stands in
+// for the password only, so users with 2FA on still get the TOTP challenge.
+func (u *DB) LoginUserOIDC(identity domain.ExternalIdentity, client domain.ClientInfo) (*LoginResponse, error) {
+	apiCfg, err := LoadAPIConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	link := &domain.UserIdentity{}
+	req := u.db.First(&link, "issuer = ? AND subject = ?", identity.Issuer, identity.Subject)
+	if req.RowsAffected != 0 {
+		user := &domain.User{}
+		req = u.db.First(&user, "id = ? ", link.UserID)
+		if req.RowsAffected == 0 {
+			return nil, domain.NotFound("user_not_found", "user not found")
+		}
+		return u.firstFactorResponse(user, apiCfg.JWTSecret, client)
+	}
+
+	user, err := u.findUserByEmail(identity.Email)
+	if err != nil {
+		user, err = u.createExternalUser(identity.Email)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	link = &domain.UserIdentity{
+		ID:      uuid.New().String(),
+		UserID:  user.ID,
+		Issuer:  identity.Issuer,
+		Subject: identity.Subject,
+	}
+	req = u.db.Create(&link)
+	if req.RowsAffected == 0 {
+		return nil, domain.Unavailable("database_unavailable", "identity not linked: %v", req.Error)
+	}
+
+	return u.firstFactorResponse(user, apiCfg.JWTSecret, client)
+}
+
+// createExternalUser creates an account for someone who only signs in through
+// an identity provider. The password is random and never disclosed, so the
+// account can't be used with /login until the user sets one.
+func (u *DB) createExternalUser(email string) (*domain.User, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return nil, fmt.Errorf("password not generated: %v", err)
+	}
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(hex.EncodeToString(b)), bcrypt.DefaultCost)
+	if err != nil {
+		return nil, fmt.Errorf("password not hashed: %v", err)
+	}
+
+	user := &domain.User{
+		ID:       uuid.New().String(),
+		Email:    email,
+		Password: string(hashedPassword),
+	}
+	req := u.db.Create(&user)
+	if req.RowsAffected == 0 {
+		return nil, domain.Unavailable("database_unavailable", "user not saved: %v", req.Error)
+	}
+	return user, nil
+}
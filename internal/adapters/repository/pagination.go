@@ -0,0 +1,157 @@
+package repository
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/LordMoMA/Hexagonal-Architecture/internal/core/domain"
+	"github.com/jinzhu/gorm"
+)
+
+var ErrInvalidCursor = domain.Validation("invalid_cursor", "invalid cursor")
+
+// cursor is the last row of a page: its sort key and, to break ties, its ID.
+// It is handed to clients base64 encoded and treated as opaque by them.
+type cursor struct {
+	Key string `json:"k"`
+	ID  string `json:"id"`
+}
+
+func encodeCursor(key, id string) string {
+	data, _ := json.Marshal(cursor{Key: key, ID: id})
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func decodeCursor(s string) (*cursor, error) {
+	data, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+	c := &cursor{}
+	if err := json.Unmarshal(data, c); err != nil || c.ID == "" {
+		return nil, ErrInvalidCursor
+	}
+	return c, nil
+}
+
+// sortOrder validates opts.Sort for a list that can only be sorted by field
+// and reports whether the order is descending.
+func sortOrder(sort, field string) (bool, error) {
+	switch sort {
+	case "", field:
+		return false, nil
+	case "-" + field:
+		return true, nil
+	default:
+		return false, domain.Validation("invalid_sort", "cannot sort by %q, use %q or %q", sort, field, "-"+field)
+	}
+}
+
+func pageLimit(limit int) int {
+	if limit <= 0 {
+		return domain.DefaultPageLimit
+	}
+	if limit > domain.MaxPageLimit {
+		return domain.MaxPageLimit
+	}
+	return limit
+}
+
+// afterCursor restricts query to the rows after (key, id) in the given order,
+// using a row comparison so Postgres can walk the (column, id) index.
+func afterCursor(query *gorm.DB, table, column string, desc bool, key interface{}, id string) *gorm.DB {
+	op := ">"
+	if desc {
+		op = "<"
+	}
+	return query.Where(fmt.Sprintf("(%s.%s, %s.id) %s (?, ?)", table, column, table, op), key, id)
+}
+
+func orderBy(query *gorm.DB, table, column string, desc bool) *gorm.DB {
+	dir := "asc"
+	if desc {
+		dir = "desc"
+	}
+	return query.Order(fmt.Sprintf("%s.%s %s", table, column, dir)).Order(fmt.Sprintf("%s.id %s", table, dir))
+}
+
+// pageMessages runs query as one page of messages ordered by created_at,
+// applying the author and date filters from opts.
+func pageMessages(query *gorm.DB, opts domain.ListOptions) (*domain.Page[*domain.Message], error) {
+	desc, err := sortOrder(opts.Sort, "created_at")
+	if err != nil {
+		return nil, err
+	}
+
+	if opts.UserID != "" {
+		query = query.Where("messages.user_id = ?", opts.UserID)
+	}
+	if opts.Since != nil {
+		query = query.Where("messages.created_at >= ?", opts.Since.UTC())
+	}
+	if opts.Until != nil {
+		query = query.Where("messages.created_at < ?", opts.Until.UTC())
+	}
+	if opts.Cursor != "" {
+		c, err := decodeCursor(opts.Cursor)
+		if err != nil {
+			return nil, err
+		}
+		createdAt, err := time.Parse(time.RFC3339Nano, c.Key)
+		if err != nil {
+			return nil, ErrInvalidCursor
+		}
+		query = afterCursor(query, "messages", "created_at", desc, createdAt, c.ID)
+	}
+
+	limit := pageLimit(opts.Limit)
+	var messages []*domain.Message
+	req := orderBy(query, "messages", "created_at", desc).Limit(limit + 1).Find(&messages)
+	if req.Error != nil {
+		return nil, domain.Unavailable("database_unavailable", "messages not found: %v", req.Error)
+	}
+
+	page := &domain.Page[*domain.Message]{Items: messages}
+	if len(messages) > limit {
+		page.Items = messages[:limit]
+		last := page.Items[limit-1]
+		page.NextCursor = encodeCursor(last.CreatedAt.UTC().Format(time.RFC3339Nano), last.ID)
+	}
+	return page, nil
+}
+
+// pageUsers runs query as one page of users ordered by email, which is unique.
+func pageUsers(query *gorm.DB, opts domain.ListOptions) (*domain.Page[*domain.User], error) {
+	desc, err := sortOrder(opts.Sort, "email")
+	if err != nil {
+		return nil, err
+	}
+	if opts.UserID != "" || opts.Since != nil || opts.Until != nil {
+		return nil, domain.Validation("invalid_list_options", "users can't be filtered by user_id or date")
+	}
+
+	if opts.Cursor != "" {
+		c, err := decodeCursor(opts.Cursor)
+		if err != nil {
+			return nil, err
+		}
+		query = afterCursor(query, "users", "email", desc, c.Key, c.ID)
+	}
+
+	limit := pageLimit(opts.Limit)
+	var users []*domain.User
+	req := orderBy(query, "users", "email", desc).Limit(limit + 1).Find(&users)
+	if req.Error != nil {
+		return nil, domain.Unavailable("database_unavailable", "users not found: %v", req.Error)
+	}
+
+	page := &domain.Page[*domain.User]{Items: users}
+	if len(users) > limit {
+		page.Items = users[:limit]
+		last := page.Items[limit-1]
+		page.NextCursor = encodeCursor(last.Email, last.ID)
+	}
+	return page, nil
+}
@@ -1,11 +1,52 @@
 package repository
 
-import "github.com/LordMoMA/Hexagonal-Architecture/internal/core/domain"
+import (
+	"time"
 
+	"github.com/LordMoMA/Hexagonal-Architecture/internal/core/domain"
+	"github.com/google/uuid"
+)
+
+// CreateCheckoutSession keeps the orders of a checkout under userID, the
+// buyer. They stay pending until the payment goes through.
 func (c *DB) CreateCheckoutSession(userID string, payment domain.Payment) error {
+	tx := c.db.Begin()
+	now := time.Now().UTC()
+	for _, o := range payment.Orders {
+		order := *o
+		if order.ID == "" {
+			order.ID = uuid.New().String()
+		}
+		if order.Status == "" {
+			order.Status = domain.OrderPending
+		}
+		order.UserID = userID
+		order.CheckoutID = payment.CheckoutID
+		order.CreatedAt = now
+		if err := tx.Create(&order).Error; err != nil {
+			tx.Rollback()
+			return domain.Unavailable("database_unavailable", "order not saved: %v", err)
+		}
+	}
+	if err := tx.Commit().Error; err != nil {
+		return domain.Unavailable("database_unavailable", "orders not saved: %v", err)
+	}
 	return nil
 }
 
+// ReadOrders reads the orders of all of userIDs in one query, newest first.
+func (c *DB) ReadOrders(userIDs []string) ([]*domain.OrderInfo, error) {
+	var orders []*domain.OrderInfo
+	if len(userIDs) == 0 {
+		return orders, nil
+	}
+	req := c.db.Where("user_id IN (?)", userIDs).Order("created_at desc").Find(&orders)
+	if req.Error != nil {
+		return nil, domain.Unavailable("database_unavailable", "orders not found: %v", req.Error)
+	}
+	return orders, nil
+}
+
 // payment event and wallet two servieces
 
 /*
@@ -0,0 +1,63 @@
+package repository
+
+import (
+	"time"
+
+	"github.com/LordMoMA/Hexagonal-Architecture/internal/core/domain"
+)
+
+// ToggleReaction adds userID's emoji to the message, or takes it back if it
+// is already there, and reports whether the reaction now exists.
+func (m *DB) ToggleReaction(messageID, userID, emoji string) (bool, error) {
+	req := m.db.Where("message_id = ? AND user_id = ? AND emoji = ?", messageID, userID, emoji).Delete(&domain.Reaction{})
+	if req.Error != nil {
+		return false, domain.Unavailable("database_unavailable", "reaction not removed: %v", req.Error)
+	}
+	if req.RowsAffected > 0 {
+		return false, nil
+	}
+
+	// a concurrent toggle may have inserted it first; either way it exists
+	req = m.db.Exec(`INSERT INTO reactions (message_id, user_id, emoji, created_at) VALUES (?, ?, ?, ?)
+		ON CONFLICT (message_id, user_id, emoji) DO NOTHING`, messageID, userID, emoji, time.Now().UTC())
+	if req.Error != nil {
+		return false, domain.Unavailable("database_unavailable", "reaction not saved: %v", req.Error)
+	}
+	return true, nil
+}
+
+// attachReactions fills in the reaction counts of messages with one query,
+// most used emoji first.
+func (m *DB) attachReactions(messages []*domain.Message) error {
+	if len(messages) == 0 {
+		return nil
+	}
+
+	ids := make([]string, len(messages))
+	byID := make(map[string]*domain.Message, len(messages))
+	for i, message := range messages {
+		ids[i] = message.ID
+		byID[message.ID] = message
+		message.Reactions = []domain.ReactionCount{}
+	}
+
+	var rows []struct {
+		MessageID string
+		Emoji     string
+		Count     int
+	}
+	req := m.db.Table("reactions").
+		Select("message_id, emoji, count(*) AS count").
+		Where("message_id IN (?)", ids).
+		Group("message_id, emoji").
+		Order("count desc, min(created_at)").
+		Scan(&rows)
+	if req.Error != nil {
+		return domain.Unavailable("database_unavailable", "reactions not found: %v", req.Error)
+	}
+	for _, row := range rows {
+		message := byID[row.MessageID]
+		message.Reactions = append(message.Reactions, domain.ReactionCount{Emoji: row.Emoji, Count: row.Count})
+	}
+	return nil
+}
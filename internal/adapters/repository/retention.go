@@ -0,0 +1,174 @@
+package repository
+
+import (
+	"strings"
+	"time"
+
+	"github.com/LordMoMA/Hexagonal-Architecture/internal/core/domain"
+	"github.com/google/uuid"
+	"github.com/jinzhu/gorm"
+)
+
+// expiredMessagesSQL picks the oldest messages past their conversation's
+// retention. Messages whose conversation is gone fall under the default. A
+// message that still has replies waits for them, so a thread goes as a unit
+// once its last reply expires. SKIP LOCKED lets a purge run
+// alongside edits, and a second purge, without waiting on their locks.
+const expiredMessagesSQL = `
+SELECT m.id, m.conversation_id, m.parent_id, m.deleted, m.created_at,
+	COALESCE(c.retention_days, ?) AS retention_days
+FROM messages m
+LEFT JOIN conversations c ON c.id = m.conversation_id
+WHERE COALESCE(c.retention_days, ?) > 0
+	AND m.created_at < now() - COALESCE(c.retention_days, ?) * interval '1 day'
+	AND NOT EXISTS (SELECT 1 FROM messages r WHERE r.parent_id = m.id)
+ORDER BY m.created_at
+LIMIT ?
+FOR UPDATE OF m SKIP LOCKED`
+
+type expiredMessage struct {
+	ID             string
+	ConversationID string
+	ParentID       string
+	Deleted        bool
+	CreatedAt      time.Time
+	RetentionDays  int
+}
+
+func (m *DB) SetConversationRetention(conversationID string, days *int) error {
+	req := m.db.Model(&domain.Conversation{}).Where("id = ?", conversationID).Update("retention_days", days)
+	if req.Error != nil {
+		return domain.Unavailable("database_unavailable", "retention not saved: %v", req.Error)
+	}
+	if req.RowsAffected == 0 {
+		return domain.NotFound("conversation_not_found", "conversation not found")
+	}
+	return nil
+}
+
+// PurgeExpiredMessages deletes up to limit expired messages, with their
+// reactions, revisions, attachments and review queue entries, in one short
+// transaction. It records the purge and returns it along with the blob keys
+// of the deleted attachments, whose files the caller removes.
+func (m *DB) PurgeExpiredMessages(defaultDays, limit int) ([]*domain.RetentionPurge, []string, error) {
+	tx := m.db.Begin()
+	purges, keys, err := purgeExpired(tx, defaultDays, limit)
+	if err != nil {
+		tx.Rollback()
+		return nil, nil, err
+	}
+	if err := tx.Commit().Error; err != nil {
+		return nil, nil, domain.Unavailable("database_unavailable", "purge not committed: %v", err)
+	}
+	return purges, keys, nil
+}
+
+func purgeExpired(tx *gorm.DB, defaultDays, limit int) ([]*domain.RetentionPurge, []string, error) {
+	var expired []expiredMessage
+	req := tx.Raw(expiredMessagesSQL, defaultDays, defaultDays, defaultDays, limit).Scan(&expired)
+	if req.Error != nil {
+		return nil, nil, domain.Unavailable("database_unavailable", "expired messages not found: %v", req.Error)
+	}
+	if len(expired) == 0 {
+		return nil, nil, nil
+	}
+
+	ids := make([]string, len(expired))
+	repliesGone := map[string]int{}
+	for i, message := range expired {
+		ids[i] = message.ID
+		if message.ParentID != "" && !message.Deleted {
+			repliesGone[message.ParentID]++
+		}
+	}
+
+	var keys []string
+	if err := tx.Model(&domain.Attachment{}).Where("message_id IN (?)", ids).Pluck("blob_key", &keys).Error; err != nil {
+		return nil, nil, domain.Unavailable("database_unavailable", "attachments not found: %v", err)
+	}
+	for _, model := range []interface{}{&domain.Attachment{}, &domain.Reaction{}, &domain.MessageRevision{}, &domain.FlaggedMessage{}} {
+		if err := tx.Where("message_id IN (?)", ids).Delete(model).Error; err != nil {
+			return nil, nil, domain.Unavailable("database_unavailable", "message records not purged: %v", err)
+		}
+	}
+	for parentID, n := range repliesGone {
+		if err := adjustReplyCount(tx, parentID, -n); err != nil {
+			return nil, nil, err
+		}
+	}
+	if err := tx.Where("id IN (?)", ids).Delete(&domain.Message{}).Error; err != nil {
+		return nil, nil, domain.Unavailable("database_unavailable", "messages not purged: %v", err)
+	}
+
+	purges := purgeRecords(expired)
+	for _, purge := range purges {
+		if err := tx.Create(purge).Error; err != nil {
+			return nil, nil, domain.Unavailable("database_unavailable", "purge not recorded: %v", err)
+		}
+	}
+	return purges, keys, nil
+}
+
+// purgeRecords groups a batch by conversation, oldest message first.
+func purgeRecords(expired []expiredMessage) []*domain.RetentionPurge {
+	now := time.Now().UTC()
+	var purges []*domain.RetentionPurge
+	byConversation := map[string]*domain.RetentionPurge{}
+	ids := map[string][]string{}
+	for _, message := range expired {
+		purge, ok := byConversation[message.ConversationID]
+		if !ok {
+			purge = &domain.RetentionPurge{
+				ID:             uuid.New().String(),
+				ConversationID: message.ConversationID,
+				RetentionDays:  message.RetentionDays,
+				OldestAt:       message.CreatedAt,
+				PurgedAt:       now,
+			}
+			byConversation[message.ConversationID] = purge
+			purges = append(purges, purge)
+		}
+		purge.MessageCount++
+		purge.NewestAt = message.CreatedAt
+		ids[message.ConversationID] = append(ids[message.ConversationID], message.ID)
+	}
+	for _, purge := range purges {
+		purge.MessageIDs = strings.Join(ids[purge.ConversationID], " ")
+	}
+	return purges
+}
+
+// ReadRetentionPurges pages through the purge log, most recent first.
+func (m *DB) ReadRetentionPurges(opts domain.ListOptions) (*domain.Page[*domain.RetentionPurge], error) {
+	if opts.Sort != "" || opts.UserID != "" || opts.Since != nil || opts.Until != nil {
+		return nil, domain.Validation("invalid_list_options", "the purge log can't be sorted or filtered")
+	}
+
+	query := m.db
+	if opts.Cursor != "" {
+		c, err := decodeCursor(opts.Cursor)
+		if err != nil {
+			return nil, err
+		}
+		purgedAt, err := time.Parse(time.RFC3339Nano, c.Key)
+		if err != nil {
+			return nil, ErrInvalidCursor
+		}
+		query = afterCursor(query, "retention_purges", "purged_at", true, purgedAt, c.ID)
+	}
+
+	limit := pageLimit(opts.Limit)
+	var purges []*domain.RetentionPurge
+	req := orderBy(query, "retention_purges", "purged_at", true).Limit(limit + 1).Find(&purges)
+	if req.Error != nil {
+		return nil, domain.Unavailable("database_unavailable", "purges not found: %v", req.Error)
+	}
+
+	page := &domain.Page[*domain.RetentionPurge]{Items: purges}
+	if len(purges) > limit {
+		page.Items = purges[:limit]
+		last := page.Items[limit-1]
+		page.NextCursor = encodeCursor(last.PurgedAt.UTC().Format(time.RFC3339Nano), last.ID)
+	}
+	return page, nil
+}
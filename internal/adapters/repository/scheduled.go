@@ -0,0 +1,116 @@
+package repository
+
+import (
+	"time"
+
+	"github.com/LordMoMA/Hexagonal-Architecture/internal/core/domain"
+)
+
+func (m *DB) CreateScheduledMessage(scheduled domain.ScheduledMessage) error {
+	req := m.db.Create(&scheduled)
+	if req.RowsAffected == 0 {
+		return domain.Unavailable("database_unavailable", "scheduled message not saved: %v", req.Error)
+	}
+	return nil
+}
+
+// ReadScheduledMessages pages through userID's pending scheduled messages,
+// soonest first.
+func (m *DB) ReadScheduledMessages(userID string, opts domain.ListOptions) (*domain.Page[*domain.ScheduledMessage], error) {
+	if opts.UserID != "" || opts.Since != nil || opts.Until != nil {
+		return nil, domain.Validation("invalid_list_options", "scheduled messages can't be filtered")
+	}
+	desc, err := sortOrder(opts.Sort, "send_at")
+	if err != nil {
+		return nil, err
+	}
+
+	query := m.db.Where("user_id = ?", userID)
+	if opts.Cursor != "" {
+		c, err := decodeCursor(opts.Cursor)
+		if err != nil {
+			return nil, err
+		}
+		sendAt, err := time.Parse(time.RFC3339Nano, c.Key)
+		if err != nil {
+			return nil, ErrInvalidCursor
+		}
+		query = afterCursor(query, "scheduled_messages", "send_at", desc, sendAt, c.ID)
+	}
+
+	limit := pageLimit(opts.Limit)
+	var scheduled []*domain.ScheduledMessage
+	req := orderBy(query, "scheduled_messages", "send_at", desc).Limit(limit + 1).Find(&scheduled)
+	if req.Error != nil {
+		return nil, domain.Unavailable("database_unavailable", "scheduled messages not found: %v", req.Error)
+	}
+
+	page := &domain.Page[*domain.ScheduledMessage]{Items: scheduled}
+	if len(scheduled) > limit {
+		page.Items = scheduled[:limit]
+		last := page.Items[limit-1]
+		page.NextCursor = encodeCursor(last.SendAt.UTC().Format(time.RFC3339Nano), last.ID)
+	}
+	return page, nil
+}
+
+// ReadDueScheduledMessages returns up to limit scheduled messages whose time
+// has come, longest overdue first.
+func (m *DB) ReadDueScheduledMessages(now time.Time, limit int) ([]*domain.ScheduledMessage, error) {
+	var scheduled []*domain.ScheduledMessage
+	req := m.db.Where("send_at <= ?", now).Order("send_at, id").Limit(limit).Find(&scheduled)
+	if req.Error != nil {
+		return nil, domain.Unavailable("database_unavailable", "scheduled messages not found: %v", req.Error)
+	}
+	return scheduled, nil
+}
+
+// DeliverScheduledMessage turns scheduled message id into message in one
+// transaction. Taking the scheduled row out first means that of two
+// instances delivering it, or a delivery racing a cancel, only one wins.
+func (m *DB) DeliverScheduledMessage(id string, message domain.Message) error {
+	tx := m.db.Begin()
+	req := tx.Where("id = ?", id).Delete(&domain.ScheduledMessage{})
+	if req.Error != nil {
+		tx.Rollback()
+		return domain.Unavailable("database_unavailable", "scheduled message not delivered: %v", req.Error)
+	}
+	if req.RowsAffected == 0 {
+		tx.Rollback()
+		return domain.ErrScheduledMessageGone
+	}
+	if err := createMessage(tx, message.UserID, message); err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit().Error
+}
+
+// DeleteScheduledMessage cancels one of userID's scheduled messages, or
+// drops it when userID is empty.
+func (m *DB) DeleteScheduledMessage(userID, id string) error {
+	query := m.db.Where("id = ?", id)
+	if userID != "" {
+		query = query.Where("user_id = ?", userID)
+	}
+	req := query.Delete(&domain.ScheduledMessage{})
+	if req.Error != nil {
+		return domain.Unavailable("database_unavailable", "scheduled message not deleted: %v", req.Error)
+	}
+	if req.RowsAffected == 0 {
+		return domain.ErrScheduledMessageGone
+	}
+	return nil
+}
+
+// ReadExpiredMessageIDs returns up to limit messages past their expiry that
+// are not deleted yet.
+func (m *DB) ReadExpiredMessageIDs(now time.Time, limit int) ([]string, error) {
+	var ids []string
+	req := m.db.Model(&domain.Message{}).Where("expires_at <= ? AND deleted = ?", now, false).
+		Order("expires_at").Limit(limit).Pluck("id", &ids)
+	if req.Error != nil {
+		return nil, domain.Unavailable("database_unavailable", "expired messages not found: %v", req.Error)
+	}
+	return ids, nil
+}
@@ -0,0 +1,136 @@
+package repository
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/LordMoMA/Hexagonal-Architecture/internal/core/domain"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+)
+
+const refreshTokenIssuer = "LordMoMA-refresh"
+
+var ErrRefreshTokenReused = domain.Unauthorized("refresh_token_reused", "refresh token already used, session revoked")
+
+type refreshClaims struct {
+	jwt.RegisteredClaims
+	SessionID string `json:"sid"`
+}
+
+func (u *DB) createSession(userID string, client domain.ClientInfo) (*domain.Session, error) {
+	now := time.Now().UTC()
+	session := &domain.Session{
+		ID:             uuid.New().String(),
+		UserID:         userID,
+		UserAgent:      client.UserAgent,
+		IP:             client.IP,
+		RefreshTokenID: uuid.New().String(),
+		CreatedAt:      now,
+		LastSeenAt:     now,
+	}
+	req := u.db.Create(&session)
+	if req.RowsAffected == 0 {
+		return nil, domain.Unavailable("database_unavailable", "session not saved: %v", req.Error)
+	}
+	return session, nil
+}
+
+// RefreshSession trades a refresh token for a new token pair, rotating the
+// session's refresh token. Presenting a token that was already rotated out
+// means it leaked, so the whole session is revoked.
+func (u *DB) RefreshSession(refreshToken string, client domain.ClientInfo) (*LoginResponse, error) {
+	apiCfg, err := LoadAPIConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	claims, err := parseRefreshToken(refreshToken, apiCfg.JWTSecret)
+	if err != nil {
+		return nil, err
+	}
+
+	session := &domain.Session{}
+	req := u.db.First(&session, "id = ? AND user_id = ?", claims.SessionID, claims.Subject)
+	if req.RowsAffected == 0 || session.RevokedAt != nil {
+		return nil, domain.Unauthorized("session_revoked", "session not found or revoked")
+	}
+	if session.RefreshTokenID != claims.ID {
+		u.revokeSession(session.ID)
+		return nil, ErrRefreshTokenReused
+	}
+
+	user := &domain.User{}
+	req = u.db.First(&user, "id = ? ", session.UserID)
+	if req.RowsAffected == 0 {
+		return nil, domain.NotFound("user_not_found", "user not found")
+	}
+
+	// the WHERE on the old token ID makes concurrent refreshes race safely:
+	// only one of them rotates, the other sees a reused token
+	next := uuid.New().String()
+	now := time.Now().UTC()
+	req = u.db.Model(&domain.Session{}).
+		Where("id = ? AND refresh_token_id = ? AND revoked_at IS NULL", session.ID, claims.ID).
+		Updates(map[string]interface{}{
+			"refresh_token_id": next,
+			"last_seen_at":     now,
+			"ip":               client.IP,
+			"user_agent":       client.UserAgent,
+		})
+	if req.RowsAffected == 0 {
+		u.revokeSession(session.ID)
+		return nil, ErrRefreshTokenReused
+	}
+	session.RefreshTokenID = next
+
+	return u.issueTokens(user, session, apiCfg.JWTSecret)
+}
+
+func (u *DB) ListSessions(userID string) ([]*domain.Session, error) {
+	var sessions []*domain.Session
+	req := u.db.Where("user_id = ? AND revoked_at IS NULL", userID).Order("last_seen_at desc").Find(&sessions)
+	if req.Error != nil {
+		return nil, domain.Unavailable("database_unavailable", "sessions not found: %v", req.Error)
+	}
+	return sessions, nil
+}
+
+func (u *DB) RevokeSession(userID, id string) error {
+	req := u.db.Model(&domain.Session{}).
+		Where("id = ? AND user_id = ? AND revoked_at IS NULL", id, userID).
+		Update("revoked_at", time.Now().UTC())
+	if req.RowsAffected == 0 {
+		return domain.NotFound("session_not_found", "session not found")
+	}
+	return nil
+}
+
+func (u *DB) revokeSession(id string) {
+	req := u.db.Model(&domain.Session{}).
+		Where("id = ? AND revoked_at IS NULL", id).
+		Update("revoked_at", time.Now().UTC())
+	if req.Error != nil {
+		fmt.Printf("Error revoking session: %v", req.Error)
+	}
+}
+
+func parseRefreshToken(tokenString, jwtSecret string) (*refreshClaims, error) {
+	claims := &refreshClaims{}
+	token, err := jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
+		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
+		}
+		return []byte(jwtSecret), nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if !token.Valid || claims.Issuer != refreshTokenIssuer {
+		return nil, domain.Unauthorized("invalid_refresh_token", "refresh token not valid")
+	}
+	if claims.SessionID == "" || claims.ID == "" {
+		return nil, domain.Unauthorized("invalid_refresh_token", "refresh token has no session, please log in again")
+	}
+	return claims, nil
+}
@@ -1,7 +1,6 @@
 package repository
 
 import (
-	"errors"
 	"fmt"
 	"time"
 
@@ -9,6 +8,7 @@ import (
 
 	"github.com/LordMoMA/Hexagonal-Architecture/internal/core/domain"
 	"github.com/google/uuid"
+	"github.com/jinzhu/gorm"
 	"golang.org/x/crypto/bcrypt"
 )
 
@@ -18,37 +18,79 @@ type LoginResponse struct {
 	AccessToken  string `json:"access_token"`
 	RefreshToken string `json:"refresh_token"`
 	Membership   bool   `json:"membership"`
+	MFARequired  bool   `json:"mfa_required,omitempty"`
+	MFAToken     string `json:"mfa_token,omitempty"`
 }
 
 func (u *DB) CreateUser(email, password string) (*domain.User, error) {
+	user, err := newUser(email, password)
+	if err != nil {
+		return nil, err
+	}
+	if err := createUser(u.db, user); err != nil {
+		return nil, err
+	}
+	return user, nil
+}
 
-	user := &domain.User{}
-	req := u.db.First(&user, "email = ?", email)
-	if req.RowsAffected != 0 {
-		return nil, errors.New("user already exists")
+// CreateUsers saves users in one transaction. When one can't be saved none
+// are, and the error is a *domain.ItemError saying which. The passwords are
+// hashed one after another before the transaction starts, as that takes a
+// while: tens of milliseconds each, which is why a batch of users is held to
+// domain.MaxUserBatchSize.
+func (u *DB) CreateUsers(users []domain.NewUser) ([]*domain.User, error) {
+	created := make([]*domain.User, len(users))
+	for i, nu := range users {
+		user, err := newUser(nu.Email, nu.Password)
+		if err != nil {
+			return nil, &domain.ItemError{Index: i, Err: err}
+		}
+		created[i] = user
+	}
+
+	tx := u.db.Begin()
+	for i, user := range created {
+		if err := createUser(tx, user); err != nil {
+			tx.Rollback()
+			return nil, &domain.ItemError{Index: i, Err: err}
+		}
+	}
+	if err := tx.Commit().Error; err != nil {
+		return nil, err
 	}
+	return created, nil
+}
 
+func newUser(email, password string) (*domain.User, error) {
 	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
 	if err != nil {
 		return nil, fmt.Errorf("password not hashed: %v", err)
 	}
-
-	user = &domain.User{
+	return &domain.User{
 		ID:         uuid.New().String(),
 		Email:      email,
 		Password:   string(hashedPassword),
 		Membership: false,
+	}, nil
+}
+
+func createUser(tx *gorm.DB, user *domain.User) error {
+	existing := &domain.User{}
+	req := tx.First(&existing, "email = ?", user.Email)
+	if req.RowsAffected != 0 {
+		return domain.Conflict("user_exists", "user already exists")
 	}
-	req = u.db.Create(&user)
+
+	req = tx.Create(&user)
 	if req.RowsAffected == 0 {
-		return nil, fmt.Errorf("user not saved: %v", req.Error)
+		return domain.Unavailable("database_unavailable", "user not saved: %v", req.Error)
 	}
-	return user, nil
+	return nil
 }
 
 func (u *DB) ReadUser(id string) (*domain.User, error) {
 	user := &domain.User{}
-	cachekey := user.ID
+	cachekey := id
 	err := u.cache.Get(cachekey, &user)
 	if err == nil {
 		return user, nil
@@ -56,7 +98,7 @@ func (u *DB) ReadUser(id string) (*domain.User, error) {
 
 	req := u.db.First(&user, "id = ? ", id)
 	if req.RowsAffected == 0 {
-		return nil, errors.New("user not found")
+		return nil, domain.NotFound("user_not_found", "user not found")
 	}
 
 	err = u.cache.Set(cachekey, user, time.Minute*10)
@@ -66,14 +108,21 @@ func (u *DB) ReadUser(id string) (*domain.User, error) {
 	return user, nil
 }
 
-func (u *DB) ReadUsers() ([]*domain.User, error) {
-	var users []*domain.User
+func (u *DB) ReadUsers(opts domain.ListOptions) (*domain.Page[*domain.User], error) {
+	return pageUsers(u.db.Model(&domain.User{}), opts)
+}
 
-	req := u.db.Find(&users)
+// ReadUsersByIDs reads the users of ids in one query, in no particular
+// order. Ids with no user are left out rather than failing the batch.
+func (u *DB) ReadUsersByIDs(ids []string) ([]*domain.User, error) {
+	var users []*domain.User
+	if len(ids) == 0 {
+		return users, nil
+	}
+	req := u.db.Where("id IN (?)", ids).Find(&users)
 	if req.Error != nil {
-		return nil, fmt.Errorf("users not found: %v", req.Error)
+		return nil, domain.Unavailable("database_unavailable", "users not found: %v", req.Error)
 	}
-
 	return users, nil
 }
 
@@ -81,7 +130,7 @@ func (u *DB) UpdateUser(id, email, password string) error {
 	user := &domain.User{}
 	req := u.db.First(&user, "id = ? ", id)
 	if req.RowsAffected == 0 {
-		return errors.New("user not found")
+		return domain.NotFound("user_not_found", "user not found")
 	}
 
 	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
@@ -100,7 +149,7 @@ func (u *DB) UpdateUser(id, email, password string) error {
 
 	req = u.db.Model(&user).Where("id = ?", id).Update(user)
 	if req.RowsAffected == 0 {
-		return errors.New("unable to update user :(")
+		return domain.Unavailable("database_unavailable", "unable to update user :(")
 	}
 
 	// delete user in the cache
@@ -117,7 +166,7 @@ func (u *DB) DeleteUser(id string) error {
 	user := &domain.User{}
 	req := u.db.Where("id = ?", id).Delete(&user)
 	if req.RowsAffected == 0 {
-		return errors.New("user not found")
+		return domain.NotFound("user_not_found", "user not found")
 	}
 	err := u.cache.Delete(id)
 	if err != nil {
@@ -126,28 +175,75 @@ func (u *DB) DeleteUser(id string) error {
 	return nil
 }
 
-func (u *DB) LoginUser(email, password string) (*LoginResponse, error) {
+func (u *DB) LoginUser(email, password string, client domain.ClientInfo) (*LoginResponse, error) {
 	apiCfg, err := LoadAPIConfig()
 	if err != nil {
 		return nil, err
 	}
 
+	if u.loginLocked(email, client.IP) {
+		return nil, ErrLoginLocked
+	}
+
+	// unknown emails and wrong passwords fail identically so the endpoint
+	// can't be used to discover which accounts exist
 	user, err := u.findUserByEmail(email)
 	if err != nil {
-		return nil, err
+		burnPasswordCheck(password)
+		u.recordLoginFailure(email, client.IP)
+		return nil, ErrInvalidCredentials
 	}
 
 	err = u.VerifyPassword(user.Password, password)
+	if err != nil {
+		u.recordLoginFailure(email, client.IP)
+		return nil, ErrInvalidCredentials
+	}
+
+	// the IP counter is left to expire so one valid account can't be used
+	// to keep spraying guesses at others from the same address
+	u.clearAccountLockout(email)
+
+	return u.firstFactorResponse(user, apiCfg.JWTSecret, client)
+}
+
+// firstFactorResponse is what a password or identity provider login earns.
+// With 2FA on that is only a short-lived challenge token that LoginUserMFA
+// exchanges for the real token pair.
+func (u *DB) firstFactorResponse(user *domain.User, jwtSecret string, client domain.ClientInfo) (*LoginResponse, error) {
+	if user.TOTPEnabled {
+		mfaToken, err := u.generateMFAToken(user.ID, jwtSecret)
+		if err != nil {
+			return nil, err
+		}
+		return &LoginResponse{
+			ID:          user.ID,
+			Email:       user.Email,
+			MFARequired: true,
+			MFAToken:    mfaToken,
+		}, nil
+	}
+
+	return u.newLoginResponse(user, jwtSecret, client)
+}
+
+// newLoginResponse opens a new session for the client and issues the first
+// token pair of its refresh token family.
+func (u *DB) newLoginResponse(user *domain.User, jwtSecret string, client domain.ClientInfo) (*LoginResponse, error) {
+	session, err := u.createSession(user.ID, client)
 	if err != nil {
 		return nil, err
 	}
+	return u.issueTokens(user, session, jwtSecret)
+}
 
-	accessToken, err := u.generateAccessToken(user.ID, apiCfg.JWTSecret)
+func (u *DB) issueTokens(user *domain.User, session *domain.Session, jwtSecret string) (*LoginResponse, error) {
+	accessToken, err := u.generateAccessToken(user.ID, jwtSecret)
 	if err != nil {
 		return nil, err
 	}
 
-	refreshToken, err := u.generateRefreshToken(user.ID, apiCfg.JWTSecret)
+	refreshToken, err := u.generateRefreshToken(user.ID, session.ID, session.RefreshTokenID, jwtSecret)
 	if err != nil {
 		return nil, err
 	}
@@ -162,18 +258,25 @@ func (u *DB) LoginUser(email, password string) (*LoginResponse, error) {
 }
 
 func (u *DB) UpdateMembershipStatus(id string, membership bool) error {
-	user := &domain.User{}
-	req := u.db.First(&user, "id = ? ", id)
-	if req.RowsAffected == 0 {
-		return errors.New("user not found")
-	}
+	return u.updateUserFlag(id, "membership", membership)
+}
+
+func (u *DB) UpdateAdminStatus(id string, admin bool) error {
+	return u.updateUserFlag(id, "admin", admin)
+}
 
-	user = &domain.User{
-		Membership: membership,
+// updateUserFlag sets one boolean column of a user. Updating it by name
+// rather than from a struct is what lets it be set to false.
+func (u *DB) updateUserFlag(id, column string, value bool) error {
+	req := u.db.Model(&domain.User{}).Where("id = ?", id).Update(column, value)
+	if req.Error != nil {
+		return domain.Unavailable("database_unavailable", "unable to update %s status: %v", column, req.Error)
 	}
-	req = u.db.Model(&user).Where("id = ?", id).Update(user)
 	if req.RowsAffected == 0 {
-		return errors.New("unable to update membership status :(")
+		return domain.NotFound("user_not_found", "user not found")
+	}
+	if err := u.cache.Delete(id); err != nil {
+		fmt.Printf("Error deleting user in cache: %v", err)
 	}
 	return nil
 }
@@ -182,7 +285,7 @@ func (u *DB) findUserByEmail(email string) (*domain.User, error) {
 	user := &domain.User{}
 	req := u.db.First(&user, "email = ?", email)
 	if req.RowsAffected == 0 {
-		return nil, errors.New("user not found")
+		return nil, domain.NotFound("user_not_found", "user not found")
 	}
 	return user, nil
 }
@@ -190,7 +293,7 @@ func (u *DB) findUserByEmail(email string) (*domain.User, error) {
 func (u *DB) VerifyPassword(hash, password string) error {
 	err := bcrypt.CompareHashAndPassword([]byte(hash), []byte(password))
 	if err != nil {
-		return errors.New("password not matched")
+		return domain.Unauthorized("invalid_credentials", "password not matched")
 	}
 	return nil
 }
@@ -207,12 +310,16 @@ func (u *DB) generateAccessToken(userID, jwtSecret string) (string, error) {
 	return token.SignedString([]byte(jwtSecret))
 }
 
-func (u *DB) generateRefreshToken(userID, jwtSecret string) (string, error) {
-	claims := jwt.RegisteredClaims{
-		Issuer:    "LordMoMA-refresh",
-		Subject:   userID,
-		IssuedAt:  jwt.NewNumericDate(time.Now().UTC()),
-		ExpiresAt: jwt.NewNumericDate(time.Now().Add(7 * 24 * time.Hour).UTC()),
+func (u *DB) generateRefreshToken(userID, sessionID, tokenID, jwtSecret string) (string, error) {
+	claims := refreshClaims{
+		RegisteredClaims: jwt.RegisteredClaims{
+			Issuer:    refreshTokenIssuer,
+			Subject:   userID,
+			ID:        tokenID,
+			IssuedAt:  jwt.NewNumericDate(time.Now().UTC()),
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(7 * 24 * time.Hour).UTC()),
+		},
+		SessionID: sessionID,
 	}
 
 	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
@@ -0,0 +1,181 @@
+package rpc
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/LordMoMA/Hexagonal-Architecture/internal/adapters/repository"
+	"github.com/LordMoMA/Hexagonal-Architecture/internal/adapters/validation"
+	"github.com/LordMoMA/Hexagonal-Architecture/internal/core/domain"
+	"github.com/LordMoMA/Hexagonal-Architecture/internal/core/services"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+type userIDKey struct{}
+
+// publicMethods can be called without credentials.
+var publicMethods = map[string]bool{
+	"/hexarch.v1.UserService/CreateUser":     true,
+	"/hexarch.v1.UserService/Login":          true,
+	"/hexarch.v1.UserService/LoginMFA":       true,
+	"/hexarch.v1.UserService/RefreshSession": true,
+}
+
+// methodScopes is the API key scope each method needs, as routeScope works
+// it out for the matching HTTP route. Methods missing here can't be called
+// with an API key.
+var methodScopes = map[string]string{
+	"/hexarch.v1.UserService/GetUser":    "users:read",
+	"/hexarch.v1.UserService/ListUsers":  "users:read",
+	"/hexarch.v1.UserService/UpdateUser": "users:write",
+	"/hexarch.v1.UserService/DeleteUser": "users:write",
+
+	"/hexarch.v1.MessageService/CreateMessage":            "messages:write",
+	"/hexarch.v1.MessageService/GetMessage":               "messages:read",
+	"/hexarch.v1.MessageService/ListMessages":             "messages:read",
+	"/hexarch.v1.MessageService/ListReplies":              "messages:read",
+	"/hexarch.v1.MessageService/SearchMessages":           "messages:read",
+	"/hexarch.v1.MessageService/UpdateMessage":            "messages:write",
+	"/hexarch.v1.MessageService/DeleteMessage":            "messages:write",
+	"/hexarch.v1.MessageService/ToggleReaction":           "messages:write",
+	"/hexarch.v1.MessageService/CreateConversation":       "conversations:write",
+	"/hexarch.v1.MessageService/ListConversations":        "conversations:read",
+	"/hexarch.v1.MessageService/ListConversationMessages": "conversations:read",
+	"/hexarch.v1.MessageService/MarkConversationRead":     "conversations:write",
+}
+
+// authenticator resolves the caller from the "authorization" metadata,
+// which holds "Bearer <access token>" or "ApiKey hex_..." as the
+// Authorization header does over HTTP.
+type authenticator struct {
+	users     *services.UserService
+	jwtSecret string
+}
+
+func (a *authenticator) intercept(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, next grpc.UnaryHandler) (interface{}, error) {
+	if publicMethods[info.FullMethod] {
+		return next(ctx, req)
+	}
+
+	var authHeader string
+	if md, ok := metadata.FromIncomingContext(ctx); ok && len(md.Get("authorization")) > 0 {
+		authHeader = md.Get("authorization")[0]
+	}
+	userID, err := a.authenticate(authHeader, info.FullMethod)
+	if err != nil {
+		return nil, err
+	}
+	return next(context.WithValue(ctx, userIDKey{}, userID), req)
+}
+
+func (a *authenticator) authenticate(authHeader, method string) (string, error) {
+	key, isAPIKey := strings.CutPrefix(authHeader, "ApiKey ")
+	if !isAPIKey || !repository.IsPersonalAPIKey(key) {
+		return validation.AccessToken(authHeader, a.jwtSecret)
+	}
+
+	apiKey, err := a.users.AuthenticateAPIKey(key)
+	if err != nil {
+		return "", err
+	}
+	scope := methodScopes[method]
+	if scope == "" || !apiKey.HasScope(scope) {
+		return "", domain.Forbidden("insufficient_scope", "api key lacks the required scope")
+	}
+	return apiKey.UserID, nil
+}
+
+// callerID is the user authenticated for the call.
+func callerID(ctx context.Context) string {
+	userID, _ := ctx.Value(userIDKey{}).(string)
+	return userID
+}
+
+// clientInfo describes the calling device, as the HTTP handlers record it
+// for sessions.
+func clientInfo(ctx context.Context) domain.ClientInfo {
+	var client domain.ClientInfo
+	if p, ok := peer.FromContext(ctx); ok {
+		client.IP = p.Addr.String()
+		if host, _, err := net.SplitHostPort(client.IP); err == nil {
+			client.IP = host
+		}
+	}
+	if md, ok := metadata.FromIncomingContext(ctx); ok && len(md.Get("user-agent")) > 0 {
+		client.UserAgent = md.Get("user-agent")[0]
+	}
+	return client
+}
+
+func logCalls(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, next grpc.UnaryHandler) (interface{}, error) {
+	start := time.Now()
+	resp, err := next(ctx, req)
+	log.Printf("[GRPC] %s | %s | %v", info.FullMethod, status.Code(err), time.Since(start))
+	return resp, err
+}
+
+// errorCodes maps the kinds of domain error to their gRPC status code, as
+// HandleError maps them to HTTP statuses.
+var errorCodes = map[error]codes.Code{
+	domain.ErrNotFound:     codes.NotFound,
+	domain.ErrConflict:     codes.FailedPrecondition,
+	domain.ErrUnauthorized: codes.Unauthenticated,
+	domain.ErrForbidden:    codes.PermissionDenied,
+	domain.ErrValidation:   codes.InvalidArgument,
+	domain.ErrUnavailable:  codes.Unavailable,
+	domain.ErrRateLimited:  codes.ResourceExhausted,
+	domain.ErrTooLarge:     codes.InvalidArgument,
+	domain.ErrUnsupported:  codes.InvalidArgument,
+}
+
+func mapErrors(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, next grpc.UnaryHandler) (interface{}, error) {
+	resp, err := next(ctx, req)
+	if err != nil {
+		return nil, statusError(err)
+	}
+	return resp, nil
+}
+
+// statusError turns err into a gRPC status. A domain error's code travels
+// as the reason of an ErrorInfo detail, and its invalid fields as a
+// BadRequest detail, so clients get what the problem+json body tells HTTP
+// clients.
+func statusError(err error) error {
+	if _, ok := status.FromError(err); ok {
+		return err
+	}
+
+	var domainErr *domain.Error
+	if !errors.As(err, &domainErr) {
+		// as over HTTP, where these are a 400
+		return status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	code, ok := errorCodes[domainErr.Kind]
+	if !ok {
+		code = codes.Unknown
+	}
+	st := status.New(code, err.Error())
+	if withInfo, err := st.WithDetails(&errdetails.ErrorInfo{Reason: domainErr.Code, Domain: "hexarch"}); err == nil {
+		st = withInfo
+	}
+	if len(domainErr.Fields) > 0 {
+		violations := make([]*errdetails.BadRequest_FieldViolation, len(domainErr.Fields))
+		for i, field := range domainErr.Fields {
+			violations[i] = &errdetails.BadRequest_FieldViolation{Field: field.Field, Description: field.Message}
+		}
+		if withFields, err := st.WithDetails(&errdetails.BadRequest{FieldViolations: violations}); err == nil {
+			st = withFields
+		}
+	}
+	return st.Err()
+}
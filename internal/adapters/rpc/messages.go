@@ -0,0 +1,262 @@
+package rpc
+
+import (
+	"context"
+	"time"
+
+	"github.com/LordMoMA/Hexagonal-Architecture/internal/adapters/rpc/pb"
+	"github.com/LordMoMA/Hexagonal-Architecture/internal/adapters/validation"
+	"github.com/LordMoMA/Hexagonal-Architecture/internal/core/domain"
+	"github.com/LordMoMA/Hexagonal-Architecture/internal/core/services"
+	"google.golang.org/protobuf/types/known/emptypb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+type messageServer struct {
+	pb.UnimplementedMessageServiceServer
+	svc *services.MessengerService
+}
+
+func (s *messageServer) CreateMessage(ctx context.Context, req *pb.CreateMessageRequest) (*pb.CreateMessageResponse, error) {
+	message := domain.Message{
+		ConversationID: req.ConversationId,
+		ParentID:       req.ParentId,
+		Body:           req.Body,
+		SendAt:         timeOrNil(req.SendAt),
+		ExpiresAt:      timeOrNil(req.ExpiresAt),
+	}
+	err := validation.Struct(validation.CreateMessageRequest{
+		ConversationID: message.ConversationID,
+		ParentID:       message.ParentID,
+		Body:           message.Body,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if message.SendAt != nil {
+		scheduled, err := s.svc.ScheduleMessage(callerID(ctx), message)
+		if err != nil {
+			return nil, err
+		}
+		return &pb.CreateMessageResponse{Scheduled: scheduledMessage(scheduled)}, nil
+	}
+	return &pb.CreateMessageResponse{}, s.svc.CreateMessage(callerID(ctx), message)
+}
+
+func (s *messageServer) GetMessage(ctx context.Context, req *pb.GetMessageRequest) (*pb.Message, error) {
+	message, err := s.svc.ReadMessage(callerID(ctx), req.Id)
+	if err != nil {
+		return nil, err
+	}
+	return messageMessage(message), nil
+}
+
+func (s *messageServer) ListMessages(ctx context.Context, req *pb.ListMessagesRequest) (*pb.ListMessagesResponse, error) {
+	opts, err := listOptions(req.Options)
+	if err != nil {
+		return nil, err
+	}
+	return messagePage(s.svc.ReadMessages(callerID(ctx), opts))
+}
+
+func (s *messageServer) ListReplies(ctx context.Context, req *pb.ListRepliesRequest) (*pb.ListMessagesResponse, error) {
+	opts, err := listOptions(req.Options)
+	if err != nil {
+		return nil, err
+	}
+	return messagePage(s.svc.ReadReplies(callerID(ctx), req.Id, opts))
+}
+
+func (s *messageServer) SearchMessages(ctx context.Context, req *pb.SearchMessagesRequest) (*pb.SearchMessagesResponse, error) {
+	if req.Limit < 0 || req.Limit > domain.MaxPageLimit {
+		return nil, domain.Validation("invalid_limit", "limit must be between 1 and %d", domain.MaxPageLimit)
+	}
+	hits, err := s.svc.SearchMessages(callerID(ctx), req.Query, req.ConversationId, int(req.Limit))
+	if err != nil {
+		return nil, err
+	}
+	response := &pb.SearchMessagesResponse{Hits: make([]*pb.SearchHit, len(hits))}
+	for i, hit := range hits {
+		response.Hits[i] = &pb.SearchHit{Message: messageMessage(&hit.Message), Rank: hit.Rank, Snippet: hit.Snippet}
+	}
+	return response, nil
+}
+
+func (s *messageServer) UpdateMessage(ctx context.Context, req *pb.UpdateMessageRequest) (*emptypb.Empty, error) {
+	if err := validation.Struct(validation.UpdateMessageRequest{Body: req.Body}); err != nil {
+		return nil, err
+	}
+	if err := s.checkAuthor(ctx, req.Id, "update"); err != nil {
+		return nil, err
+	}
+	return &emptypb.Empty{}, s.svc.UpdateMessage(req.Id, domain.Message{Body: req.Body})
+}
+
+func (s *messageServer) DeleteMessage(ctx context.Context, req *pb.DeleteMessageRequest) (*emptypb.Empty, error) {
+	if err := s.checkAuthor(ctx, req.Id, "delete"); err != nil {
+		return nil, err
+	}
+	return &emptypb.Empty{}, s.svc.DeleteMessage(req.Id)
+}
+
+// checkAuthor lets only the author of a message change it, as the HTTP
+// handlers do.
+func (s *messageServer) checkAuthor(ctx context.Context, id, action string) error {
+	message, err := s.svc.ReadMessage(callerID(ctx), id)
+	if err != nil {
+		return err
+	}
+	if message.UserID != callerID(ctx) {
+		return domain.Forbidden("not_message_author", "you are not authorized to %s this message", action)
+	}
+	return nil
+}
+
+func (s *messageServer) ToggleReaction(ctx context.Context, req *pb.ToggleReactionRequest) (*pb.ToggleReactionResponse, error) {
+	if err := validation.Struct(validation.ReactionRequest{Emoji: req.Emoji}); err != nil {
+		return nil, err
+	}
+	reacted, err := s.svc.ToggleReaction(callerID(ctx), req.MessageId, req.Emoji)
+	if err != nil {
+		return nil, err
+	}
+	return &pb.ToggleReactionResponse{Reacted: reacted}, nil
+}
+
+func (s *messageServer) CreateConversation(ctx context.Context, req *pb.CreateConversationRequest) (*pb.Conversation, error) {
+	err := validation.Struct(validation.CreateConversationRequest{Kind: req.Kind, Title: req.Title, ParticipantIDs: req.ParticipantIds})
+	if err != nil {
+		return nil, err
+	}
+	conversation, err := s.svc.CreateConversation(callerID(ctx), domain.Conversation{
+		Kind:           req.Kind,
+		Title:          req.Title,
+		ParticipantIDs: req.ParticipantIds,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return conversationMessage(conversation), nil
+}
+
+func (s *messageServer) ListConversations(ctx context.Context, _ *emptypb.Empty) (*pb.ListConversationsResponse, error) {
+	conversations, err := s.svc.ReadConversations(callerID(ctx))
+	if err != nil {
+		return nil, err
+	}
+	response := &pb.ListConversationsResponse{Conversations: make([]*pb.Conversation, len(conversations))}
+	for i, conversation := range conversations {
+		response.Conversations[i] = conversationMessage(conversation)
+	}
+	return response, nil
+}
+
+func (s *messageServer) ListConversationMessages(ctx context.Context, req *pb.ListConversationMessagesRequest) (*pb.ListMessagesResponse, error) {
+	opts, err := listOptions(req.Options)
+	if err != nil {
+		return nil, err
+	}
+	return messagePage(s.svc.ReadConversationMessages(callerID(ctx), req.ConversationId, opts))
+}
+
+func (s *messageServer) MarkConversationRead(ctx context.Context, req *pb.MarkConversationReadRequest) (*emptypb.Empty, error) {
+	return &emptypb.Empty{}, s.svc.MarkConversationRead(callerID(ctx), req.ConversationId, req.MessageId)
+}
+
+func messageMessage(message *domain.Message) *pb.Message {
+	m := &pb.Message{
+		Id:             message.ID,
+		ConversationId: message.ConversationID,
+		UserId:         message.UserID,
+		ParentId:       message.ParentID,
+		ReplyCount:     int32(message.ReplyCount),
+		Body:           message.Body,
+		CreatedAt:      timestamppb.New(message.CreatedAt),
+		Edited:         message.Edited,
+		EditedAt:       timestampOrNil(message.EditedAt),
+		Deleted:        message.Deleted,
+		ExpiresAt:      timestampOrNil(message.ExpiresAt),
+	}
+	for _, reaction := range message.Reactions {
+		m.Reactions = append(m.Reactions, &pb.ReactionCount{Emoji: reaction.Emoji, Count: int32(reaction.Count)})
+	}
+	for _, attachment := range message.Attachments {
+		m.Attachments = append(m.Attachments, &pb.Attachment{
+			Id:          attachment.ID,
+			Filename:    attachment.Filename,
+			ContentType: attachment.ContentType,
+			Size:        attachment.Size,
+		})
+	}
+	return m
+}
+
+func messagePage(page *domain.Page[*domain.Message], err error) (*pb.ListMessagesResponse, error) {
+	if err != nil {
+		return nil, err
+	}
+	response := &pb.ListMessagesResponse{Messages: make([]*pb.Message, len(page.Items)), NextCursor: page.NextCursor}
+	for i, message := range page.Items {
+		response.Messages[i] = messageMessage(message)
+	}
+	return response, nil
+}
+
+func scheduledMessage(message *domain.ScheduledMessage) *pb.ScheduledMessage {
+	return &pb.ScheduledMessage{
+		Id:             message.ID,
+		ConversationId: message.ConversationID,
+		ParentId:       message.ParentID,
+		Body:           message.Body,
+		SendAt:         timestamppb.New(message.SendAt),
+		ExpiresAt:      timestampOrNil(message.ExpiresAt),
+		CreatedAt:      timestamppb.New(message.CreatedAt),
+	}
+}
+
+func conversationMessage(conversation *domain.Conversation) *pb.Conversation {
+	return &pb.Conversation{
+		Id:             conversation.ID,
+		Kind:           conversation.Kind,
+		Title:          conversation.Title,
+		CreatedBy:      conversation.CreatedBy,
+		CreatedAt:      timestamppb.New(conversation.CreatedAt),
+		ParticipantIds: conversation.ParticipantIDs,
+		UnreadCount:    int32(conversation.UnreadCount),
+	}
+}
+
+// listOptions checks a page request the way parseListOptions checks the
+// query string. A zero limit asks for the default page size.
+func listOptions(opts *pb.ListOptions) (domain.ListOptions, error) {
+	if opts == nil {
+		return domain.ListOptions{}, nil
+	}
+	if opts.Limit < 0 || opts.Limit > domain.MaxPageLimit {
+		return domain.ListOptions{}, domain.Validation("invalid_limit", "limit must be between 1 and %d", domain.MaxPageLimit)
+	}
+	return domain.ListOptions{
+		Limit:  int(opts.Limit),
+		Cursor: opts.Cursor,
+		Sort:   opts.Sort,
+		UserID: opts.UserId,
+		Since:  timeOrNil(opts.Since),
+		Until:  timeOrNil(opts.Until),
+	}, nil
+}
+
+func timeOrNil(ts *timestamppb.Timestamp) *time.Time {
+	if ts == nil {
+		return nil
+	}
+	t := ts.AsTime()
+	return &t
+}
+
+func timestampOrNil(t *time.Time) *timestamppb.Timestamp {
+	if t == nil {
+		return nil
+	}
+	return timestamppb.New(*t)
+}
@@ -0,0 +1,37 @@
+package rpc
+
+import (
+	"context"
+
+	"github.com/LordMoMA/Hexagonal-Architecture/internal/adapters/rpc/pb"
+	"github.com/LordMoMA/Hexagonal-Architecture/internal/core/domain"
+	"github.com/LordMoMA/Hexagonal-Architecture/internal/core/services"
+	"google.golang.org/protobuf/types/known/emptypb"
+)
+
+type paymentServer struct {
+	pb.UnimplementedPaymentServiceServer
+	svc *services.PaymentService
+}
+
+func (s *paymentServer) CreateCheckoutSession(ctx context.Context, req *pb.CreateCheckoutSessionRequest) (*emptypb.Empty, error) {
+	userID := callerID(ctx)
+	payment := domain.Payment{
+		CheckoutID: req.CheckoutId,
+		BuyerInfo:  &domain.BuyerInfo{UserID: userID},
+	}
+	if buyer := req.BuyerInfo; buyer != nil {
+		payment.BuyerInfo.FirstName = buyer.FirstName
+		payment.BuyerInfo.LastName = buyer.LastName
+		payment.BuyerInfo.Email = buyer.Email
+	}
+	for _, order := range req.Orders {
+		payment.Orders = append(payment.Orders, &domain.OrderInfo{
+			OrderID:       order.OrderId,
+			SellerAccount: order.SellerAccount,
+			Amount:        order.Amount,
+			Currency:      order.Currency,
+		})
+	}
+	return &emptypb.Empty{}, s.svc.CreateCheckoutSession(userID, payment)
+}
@@ -0,0 +1,30 @@
+// Package rpc is the gRPC driving adapter. It serves the same services as
+// the HTTP handlers, so the two can run side by side against one set of
+// service instances.
+//
+// The pb package is generated from the definitions in /proto:
+//
+//	protoc -I proto --go_out=internal/adapters/rpc/pb --go_opt=paths=source_relative \
+//		--go-grpc_out=internal/adapters/rpc/pb --go-grpc_opt=paths=source_relative \
+//		proto/hexarch/v1/*.proto
+package rpc
+
+import (
+	"github.com/LordMoMA/Hexagonal-Architecture/internal/adapters/rpc/pb"
+	"github.com/LordMoMA/Hexagonal-Architecture/internal/core/services"
+	"google.golang.org/grpc"
+)
+
+// NewServer registers the user, message and payment services on a gRPC
+// server. Calls are logged, authenticated with the same access tokens and
+// API keys as the HTTP API, and fail with the status code matching their
+// domain error.
+func NewServer(users *services.UserService, messages *services.MessengerService, payments *services.PaymentService, jwtSecret string) *grpc.Server {
+	auth := &authenticator{users: users, jwtSecret: jwtSecret}
+	server := grpc.NewServer(grpc.ChainUnaryInterceptor(logCalls, mapErrors, auth.intercept))
+
+	pb.RegisterUserServiceServer(server, &userServer{svc: users})
+	pb.RegisterMessageServiceServer(server, &messageServer{svc: messages})
+	pb.RegisterPaymentServiceServer(server, &paymentServer{svc: payments})
+	return server
+}
@@ -0,0 +1,124 @@
+package rpc
+
+import (
+	"context"
+
+	"github.com/LordMoMA/Hexagonal-Architecture/internal/adapters/repository"
+	"github.com/LordMoMA/Hexagonal-Architecture/internal/adapters/rpc/pb"
+	"github.com/LordMoMA/Hexagonal-Architecture/internal/adapters/validation"
+	"github.com/LordMoMA/Hexagonal-Architecture/internal/core/domain"
+	"github.com/LordMoMA/Hexagonal-Architecture/internal/core/services"
+	"google.golang.org/protobuf/types/known/emptypb"
+)
+
+type userServer struct {
+	pb.UnimplementedUserServiceServer
+	svc *services.UserService
+}
+
+func (s *userServer) CreateUser(ctx context.Context, req *pb.CreateUserRequest) (*pb.User, error) {
+	if err := validation.Struct(validation.CreateUserRequest{Email: req.Email, Password: req.Password}); err != nil {
+		return nil, err
+	}
+	user, err := s.svc.CreateUser(req.Email, req.Password)
+	if err != nil {
+		return nil, err
+	}
+	return userMessage(user, true), nil
+}
+
+func (s *userServer) GetUser(ctx context.Context, req *pb.GetUserRequest) (*pb.User, error) {
+	user, err := s.svc.ReadUser(req.Id)
+	if err != nil {
+		return nil, err
+	}
+	caller := callerID(ctx)
+	return userMessage(user, caller == user.ID || s.callerIsAdmin(caller)), nil
+}
+
+func (s *userServer) ListUsers(ctx context.Context, req *pb.ListUsersRequest) (*pb.ListUsersResponse, error) {
+	opts, err := listOptions(req.Options)
+	if err != nil {
+		return nil, err
+	}
+	page, err := s.svc.ReadUsers(opts)
+	if err != nil {
+		return nil, err
+	}
+	caller := callerID(ctx)
+	admin := s.callerIsAdmin(caller)
+	users := make([]*pb.User, len(page.Items))
+	for i, user := range page.Items {
+		users[i] = userMessage(user, admin || caller == user.ID)
+	}
+	return &pb.ListUsersResponse{Users: users, NextCursor: page.NextCursor}, nil
+}
+
+func (s *userServer) UpdateUser(ctx context.Context, req *pb.UpdateUserRequest) (*emptypb.Empty, error) {
+	if err := validation.Struct(validation.UpdateUserRequest{Email: req.Email, Password: req.Password}); err != nil {
+		return nil, err
+	}
+	return &emptypb.Empty{}, s.svc.UpdateUser(callerID(ctx), req.Email, req.Password)
+}
+
+func (s *userServer) DeleteUser(ctx context.Context, _ *emptypb.Empty) (*emptypb.Empty, error) {
+	return &emptypb.Empty{}, s.svc.DeleteUser(callerID(ctx))
+}
+
+func (s *userServer) Login(ctx context.Context, req *pb.LoginRequest) (*pb.LoginResponse, error) {
+	if err := validation.Struct(validation.LoginRequest{Email: req.Email, Password: req.Password}); err != nil {
+		return nil, err
+	}
+	return loginMessage(s.svc.LoginUser(req.Email, req.Password, clientInfo(ctx)))
+}
+
+func (s *userServer) LoginMFA(ctx context.Context, req *pb.LoginMFARequest) (*pb.LoginResponse, error) {
+	if err := validation.Struct(validation.MFALoginRequest{MFAToken: req.MfaToken, Code: req.Code}); err != nil {
+		return nil, err
+	}
+	return loginMessage(s.svc.LoginUserMFA(req.MfaToken, req.Code, clientInfo(ctx)))
+}
+
+func (s *userServer) RefreshSession(ctx context.Context, req *pb.RefreshSessionRequest) (*pb.LoginResponse, error) {
+	if err := validation.Struct(validation.RefreshRequest{RefreshToken: req.RefreshToken}); err != nil {
+		return nil, err
+	}
+	return loginMessage(s.svc.RefreshSession(req.RefreshToken, clientInfo(ctx)))
+}
+
+func (s *userServer) callerIsAdmin(caller string) bool {
+	user, err := s.svc.ReadUser(caller)
+	return err == nil && user.Admin
+}
+
+// userMessage copies over only what UserResponse shows over HTTP: admin and
+// totp_enabled stay false unless account is set, for the user themselves and
+// admins.
+func userMessage(user *domain.User, account bool) *pb.User {
+	message := &pb.User{
+		Id:         user.ID,
+		Email:      user.Email,
+		Membership: user.Membership,
+	}
+	if account {
+		message.Admin = user.Admin
+		message.TotpEnabled = user.TOTPEnabled
+	}
+	return message
+}
+
+func loginMessage(response *repository.LoginResponse, err error) (*pb.LoginResponse, error) {
+	if err != nil {
+		return nil, err
+	}
+	if response.MFARequired {
+		return &pb.LoginResponse{MfaRequired: true, MfaToken: response.MFAToken}, nil
+	}
+	return &pb.LoginResponse{
+		Id:           response.ID,
+		Email:        response.Email,
+		AccessToken:  response.AccessToken,
+		RefreshToken: response.RefreshToken,
+		IsMember:     response.Membership,
+	}, nil
+}
@@ -0,0 +1,100 @@
+package search
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/LordMoMA/Hexagonal-Architecture/internal/core/domain"
+	"github.com/jinzhu/gorm"
+)
+
+// textSearchConfig is the Postgres text search configuration used both to
+// build the index and to parse queries; the two must agree.
+const textSearchConfig = "english"
+
+// headlineOptions keeps snippets short and marks matches with <mark>.
+const headlineOptions = "StartSel=<mark>, StopSel=</mark>, MaxWords=35, MinWords=15, MaxFragments=2"
+
+// PostgresIndex searches messages with Postgres full-text search. The
+// tsvector is a generated column, so Postgres keeps it in step with the
+// body and IndexMessage and RemoveMessage have nothing to do.
+type PostgresIndex struct {
+	db *gorm.DB
+}
+
+type searchRow struct {
+	ID             string
+	ConversationID string
+	UserID         string
+	Body           string
+	CreatedAt      time.Time
+	Rank           float64
+	Snippet        string
+}
+
+func NewPostgresIndex(db *gorm.DB) *PostgresIndex {
+	return &PostgresIndex{db: db}
+}
+
+// Migrate adds the search_vector column and its GIN index to messages. It
+// needs Postgres 12 or later for generated columns.
+func (p *PostgresIndex) Migrate() error {
+	err := p.db.Exec(fmt.Sprintf(`ALTER TABLE messages ADD COLUMN IF NOT EXISTS search_vector tsvector
+		GENERATED ALWAYS AS (to_tsvector('%s', coalesce(body, ''))) STORED`, textSearchConfig)).Error
+	if err != nil {
+		return fmt.Errorf("search column not added: %v", err)
+	}
+	err = p.db.Exec("CREATE INDEX IF NOT EXISTS messages_search_vector_idx ON messages USING GIN (search_vector)").Error
+	if err != nil {
+		return fmt.Errorf("search index not created: %v", err)
+	}
+	return nil
+}
+
+func (p *PostgresIndex) IndexMessage(message domain.Message) error {
+	return nil
+}
+
+func (p *PostgresIndex) RemoveMessage(id string) error {
+	return nil
+}
+
+// SearchMessages accepts web search syntax: quoted phrases, "or" and a
+// leading "-" to exclude a term. The body is HTML escaped before the
+// headline is built so the only markup in a snippet is the <mark> tags.
+// Deleted messages are never found, whatever is left of their body.
+func (p *PostgresIndex) SearchMessages(query domain.SearchQuery) ([]*domain.SearchHit, error) {
+	if len(query.ConversationIDs) == 0 {
+		return []*domain.SearchHit{}, nil
+	}
+
+	var rows []searchRow
+	req := p.db.Raw(fmt.Sprintf(`
+		SELECT m.id, m.conversation_id, m.user_id, m.body, m.created_at,
+			ts_rank(m.search_vector, q) AS rank,
+			ts_headline('%[1]s', replace(replace(replace(m.body, '&', '&amp;'), '<', '&lt;'), '>', '&gt;'), q, ?) AS snippet
+		FROM messages m, websearch_to_tsquery('%[1]s', ?) q
+		WHERE m.search_vector @@ q AND m.conversation_id IN (?) AND NOT m.deleted
+		ORDER BY rank DESC, m.created_at DESC, m.id
+		LIMIT ?`, textSearchConfig),
+		headlineOptions, query.Text, query.ConversationIDs, query.Limit).Scan(&rows)
+	if req.Error != nil {
+		return nil, fmt.Errorf("messages not searched: %v", req.Error)
+	}
+
+	hits := make([]*domain.SearchHit, len(rows))
+	for i, row := range rows {
+		hits[i] = &domain.SearchHit{
+			Message: domain.Message{
+				ID:             row.ID,
+				ConversationID: row.ConversationID,
+				UserID:         row.UserID,
+				Body:           row.Body,
+				CreatedAt:      row.CreatedAt,
+			},
+			Rank:    row.Rank,
+			Snippet: row.Snippet,
+		}
+	}
+	return hits, nil
+}
@@ -0,0 +1,72 @@
+package validation
+
+import (
+	"time"
+
+	"github.com/LordMoMA/Hexagonal-Architecture/internal/core/domain"
+)
+
+// CreateUserRequest is what a client may set on sign up; everything else
+// about a user is the server's to decide.
+type CreateUserRequest struct {
+	Email    string `json:"email" binding:"required,email,max=255"`
+	Password string `json:"password" binding:"required,password"`
+}
+
+type UpdateUserRequest struct {
+	Email    string `json:"email" binding:"required,email,max=255"`
+	Password string `json:"password" binding:"required,password"`
+}
+
+// LoginRequest doesn't apply the password policy: accounts from before it
+// must still be able to log in.
+type LoginRequest struct {
+	Email    string `json:"email" binding:"required"`
+	Password string `json:"password" binding:"required"`
+}
+
+type MFALoginRequest struct {
+	MFAToken string `json:"mfa_token" binding:"required"`
+	Code     string `json:"code" binding:"required"`
+}
+
+type RefreshRequest struct {
+	RefreshToken string `json:"refresh_token" binding:"required"`
+}
+
+type CreateConversationRequest struct {
+	Kind           string   `json:"kind" binding:"required,oneof=direct group"`
+	Title          string   `json:"title" binding:"max=255"`
+	ParticipantIDs []string `json:"participant_ids" binding:"required,min=1,dive,required"`
+}
+
+// CreateMessageRequest posts a message, a reply when parent_id is set, or
+// schedules one when send_at is. Files is set for a multipart post, where
+// attachments alone are enough and body may be left out.
+type CreateMessageRequest struct {
+	ConversationID string     `json:"conversation_id" binding:"required_without=ParentID"`
+	ParentID       string     `json:"parent_id"`
+	Body           string     `json:"body" binding:"required_without=Files,max=4000"`
+	SendAt         *time.Time `json:"send_at"`
+	ExpiresAt      *time.Time `json:"expires_at"`
+	Files          int        `json:"-"`
+}
+
+// Message is the message the request asks for.
+func (r CreateMessageRequest) Message() domain.Message {
+	return domain.Message{
+		ConversationID: r.ConversationID,
+		ParentID:       r.ParentID,
+		Body:           r.Body,
+		SendAt:         r.SendAt,
+		ExpiresAt:      r.ExpiresAt,
+	}
+}
+
+type UpdateMessageRequest struct {
+	Body string `json:"body" binding:"required,max=4000"`
+}
+
+type ReactionRequest struct {
+	Emoji string `json:"emoji" binding:"required"`
+}
@@ -0,0 +1,59 @@
+package validation
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/LordMoMA/Hexagonal-Architecture/internal/core/domain"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// AccessToken checks the "Bearer <token>" of an Authorization header, or the
+// gRPC metadata standing in for it, and returns the user the access token
+// was issued to. Refresh and MFA challenge tokens are turned away.
+func AccessToken(authHeader string, jwtSecret string) (string, error) {
+	// Check if token exists in the header
+	if len(authHeader) < 7 || !strings.EqualFold(authHeader[:7], "Bearer ") {
+		return "", domain.Unauthorized("missing_token", "token not found")
+	}
+
+	// Extract token from header
+	tokenString := authHeader[7:]
+
+	// Parse and validate token
+	token, err := jwt.ParseWithClaims(tokenString, &jwt.RegisteredClaims{}, func(token *jwt.Token) (interface{}, error) {
+		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
+		}
+		return []byte(jwtSecret), nil
+	})
+	if err != nil {
+		return "", domain.Unauthorized("invalid_token", "%w", err)
+	}
+
+	if !token.Valid {
+		return "", domain.Unauthorized("invalid_token", "token not valid")
+	}
+
+	// Check if token has expired
+	claims, ok := token.Claims.(*jwt.RegisteredClaims)
+	if !ok || claims.ExpiresAt == nil || claims.ExpiresAt.Before(time.Now().UTC()) {
+		return "", domain.Unauthorized("token_expired", "token has expired")
+	}
+
+	// Check if token is a refresh token
+	if claims.Issuer == "LordMoMA-refresh" {
+		return "", domain.Unauthorized("wrong_token_type", "token is a refresh token, please use access token")
+	}
+
+	// An MFA challenge token only proves the password, not the second factor
+	if claims.Issuer == "LordMoMA-mfa" {
+		return "", domain.Unauthorized("wrong_token_type", "token is an mfa token, please complete two-factor login")
+	}
+
+	// Extract user ID from token
+	userID := claims.Subject
+
+	return userID, nil
+}
@@ -0,0 +1,156 @@
+// Package validation holds what the driving adapters check their input
+// against: the requests they share, with their binding rules, and the access
+// token that authenticates them. A request is held to the same rules whether
+// it arrives over HTTP, gRPC or the admin command line.
+package validation
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"unicode"
+
+	"github.com/LordMoMA/Hexagonal-Architecture/internal/core/domain"
+	"github.com/go-playground/validator/v10"
+)
+
+const (
+	minPasswordLength = 8
+	// bcrypt ignores everything past 72 bytes
+	maxPasswordBytes = 72
+)
+
+var validate = newValidator()
+
+func newValidator() *validator.Validate {
+	v := validator.New()
+	v.SetTagName("binding")
+	Register(v)
+	return v
+}
+
+// Register adds the rules of this package to v, such as gin's binding
+// validator, so structs it checks report fields the same way.
+func Register(v *validator.Validate) {
+	// report fields by the names clients use for them
+	v.RegisterTagNameFunc(func(field reflect.StructField) string {
+		for _, tag := range []string{"json", "form"} {
+			name := strings.Split(field.Tag.Get(tag), ",")[0]
+			if name == "-" {
+				return ""
+			}
+			if name != "" {
+				return name
+			}
+		}
+		return field.Name
+	})
+	_ = v.RegisterValidation("password", func(fl validator.FieldLevel) bool {
+		return validPassword(fl.Field().String())
+	})
+}
+
+// validPassword is the password policy: 8 to 72 bytes with at least one
+// letter and one digit.
+func validPassword(password string) bool {
+	if len(password) < minPasswordLength || len(password) > maxPasswordBytes {
+		return false
+	}
+	var letter, digit bool
+	for _, r := range password {
+		letter = letter || unicode.IsLetter(r)
+		digit = digit || unicode.IsDigit(r)
+	}
+	return letter && digit
+}
+
+// Struct checks req, a struct or a pointer to one, against its binding
+// rules and reports every invalid field at once.
+func Struct(req interface{}) error {
+	if err := validate.Struct(req); err != nil {
+		return Error(err)
+	}
+	return nil
+}
+
+// Error turns what binding reports into field errors. Anything else, such as
+// a body that isn't JSON at all, is returned as it is.
+func Error(err error) error {
+	var typeErr *json.UnmarshalTypeError
+	if errors.As(err, &typeErr) {
+		return domain.InvalidFields([]domain.FieldError{{
+			Field:   typeErr.Field,
+			Code:    "type",
+			Message: "must be " + typeName(typeErr.Type),
+		}})
+	}
+
+	var invalid validator.ValidationErrors
+	if !errors.As(err, &invalid) {
+		return err
+	}
+	fields := make([]domain.FieldError, len(invalid))
+	for i, fe := range invalid {
+		fields[i] = domain.FieldError{
+			Field:   fieldPath(fe),
+			Code:    fe.Tag(),
+			Message: fieldMessage(fe),
+		}
+	}
+	return domain.InvalidFields(fields)
+}
+
+// fieldPath drops the struct name validator puts first, leaving e.g.
+// "participant_ids[1]".
+func fieldPath(fe validator.FieldError) string {
+	path := fe.Namespace()
+	if i := strings.Index(path, "."); i >= 0 {
+		path = path[i+1:]
+	}
+	return path
+}
+
+func fieldMessage(fe validator.FieldError) string {
+	switch fe.Tag() {
+	case "required", "required_without":
+		return "is required"
+	case "email":
+		return "must be a valid email address"
+	case "password":
+		return fmt.Sprintf("must be %d to %d characters with at least one letter and one digit", minPasswordLength, maxPasswordBytes)
+	case "min":
+		if fe.Kind() == reflect.Slice {
+			return fmt.Sprintf("must have at least %s items", fe.Param())
+		}
+		return fmt.Sprintf("must be at least %s characters", fe.Param())
+	case "max":
+		if fe.Kind() == reflect.Slice {
+			return fmt.Sprintf("must have at most %s items", fe.Param())
+		}
+		return fmt.Sprintf("must be at most %s characters", fe.Param())
+	case "oneof":
+		return "must be one of " + strings.Join(strings.Fields(fe.Param()), ", ")
+	default:
+		return "is not valid"
+	}
+}
+
+func typeName(t reflect.Type) string {
+	switch t.Kind() {
+	case reflect.String:
+		return "a string"
+	case reflect.Bool:
+		return "a boolean"
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return "an integer"
+	case reflect.Float32, reflect.Float64:
+		return "a number"
+	case reflect.Slice, reflect.Array:
+		return "an array"
+	default:
+		return "an object"
+	}
+}
@@ -0,0 +1,130 @@
+package domain
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// The kinds of failure the core reports. Adapters decide what each means to
+// their callers, e.g. the HTTP handlers turn ErrNotFound into a 404. Check
+// for them with errors.Is.
+var (
+	ErrNotFound     = errors.New("not found")
+	ErrConflict     = errors.New("conflict")
+	ErrUnauthorized = errors.New("unauthorized")
+	ErrForbidden    = errors.New("forbidden")
+	ErrValidation   = errors.New("validation failed")
+	ErrUnavailable  = errors.New("unavailable")
+	ErrRateLimited  = errors.New("rate limited")
+	ErrTooLarge     = errors.New("too large")
+	ErrUnsupported  = errors.New("unsupported type")
+)
+
+// Error is a failure of one of the kinds above, with a stable code, such as
+// "message_not_found", that clients can rely on where the message may change.
+type Error struct {
+	Kind    error
+	Code    string
+	Message string
+	Err     error
+	// Fields lists what is wrong with each field of a rejected request
+	Fields []FieldError
+}
+
+// FieldError is one invalid field: Field is its name as the client sent it,
+// e.g. "email" or "participant_ids[1]", and Code the rule it broke.
+type FieldError struct {
+	Field   string `json:"field"`
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+func (e *Error) Error() string {
+	return e.Message
+}
+
+func (e *Error) Is(target error) bool {
+	return target == e.Kind
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// ItemError is the failure of one item of a batch saved all or nothing: the
+// item at Index couldn't be saved, so none were.
+type ItemError struct {
+	Index int
+	Err   error
+}
+
+func (e *ItemError) Error() string {
+	return fmt.Sprintf("item %d: %v", e.Index, e.Err)
+}
+
+func (e *ItemError) Unwrap() error {
+	return e.Err
+}
+
+// NotFound and the functions below build an Error of their kind. The message
+// is formatted like fmt.Errorf, so %w keeps the cause around.
+func NotFound(code, format string, args ...interface{}) error {
+	return newError(ErrNotFound, code, format, args)
+}
+
+func Conflict(code, format string, args ...interface{}) error {
+	return newError(ErrConflict, code, format, args)
+}
+
+func Unauthorized(code, format string, args ...interface{}) error {
+	return newError(ErrUnauthorized, code, format, args)
+}
+
+func Forbidden(code, format string, args ...interface{}) error {
+	return newError(ErrForbidden, code, format, args)
+}
+
+func Validation(code, format string, args ...interface{}) error {
+	return newError(ErrValidation, code, format, args)
+}
+
+func Unavailable(code, format string, args ...interface{}) error {
+	return newError(ErrUnavailable, code, format, args)
+}
+
+func RateLimited(code, format string, args ...interface{}) error {
+	return newError(ErrRateLimited, code, format, args)
+}
+
+func TooLarge(code, format string, args ...interface{}) error {
+	return newError(ErrTooLarge, code, format, args)
+}
+
+func Unsupported(code, format string, args ...interface{}) error {
+	return newError(ErrUnsupported, code, format, args)
+}
+
+// InvalidFields is a validation error reporting every invalid field at once.
+func InvalidFields(fields []FieldError) error {
+	problems := make([]string, len(fields))
+	for i, field := range fields {
+		problems[i] = field.Field + " " + field.Message
+	}
+	return &Error{
+		Kind:    ErrValidation,
+		Code:    "invalid_request",
+		Message: "invalid request: " + strings.Join(problems, "; "),
+		Fields:  fields,
+	}
+}
+
+func newError(kind error, code, format string, args []interface{}) error {
+	err := fmt.Errorf(format, args...)
+	return &Error{
+		Kind:    kind,
+		Code:    code,
+		Message: err.Error(),
+		Err:     errors.Unwrap(err),
+	}
+}
@@ -1,17 +1,382 @@
 package domain
 
+import (
+	"io"
+	"strings"
+	"time"
+)
+
+// Message is a post in a conversation. A deleted message stays behind as a
+// tombstone with an empty body; what it said is kept in its revisions.
+//
+// A message with a ParentID is a reply in that message's thread. Threads are
+// one level deep, and ReplyCount counts a message's replies that are not
+// deleted.
 type Message struct {
-	ID     string `json:"id" db:"id"`
-	UserID string `json:"user_id" db:"user_id"`
-	Body   string `json:"body" db:"body"`
+	ID             string     `json:"id" db:"id"`
+	ConversationID string     `json:"conversation_id" db:"conversation_id"`
+	UserID         string     `json:"user_id" db:"user_id"`
+	ParentID       string     `json:"parent_id,omitempty" db:"parent_id" gorm:"not null;default:''"`
+	ReplyCount     int        `json:"reply_count" db:"reply_count" gorm:"not null;default:0"`
+	Body           string     `json:"body" db:"body"`
+	CreatedAt      time.Time  `json:"created_at" db:"created_at"`
+	Edited         bool       `json:"edited" db:"edited" gorm:"not null;default:false"`
+	EditedAt       *time.Time `json:"edited_at,omitempty" db:"edited_at"`
+	Deleted        bool       `json:"deleted" db:"deleted" gorm:"not null;default:false"`
+	// not DeletedAt: gorm would treat that as its own soft delete and hide
+	// the row, but a tombstone must still show up in the conversation
+	RemovedAt   *time.Time      `json:"removed_at,omitempty" db:"removed_at"`
+	Reactions   []ReactionCount `json:"reactions" gorm:"-"`
+	Attachments []Attachment    `json:"attachments" gorm:"-"`
+	// FlaggedFor is set by moderation on a message it wants reviewed; the
+	// repository files it in the review queue when saving the message
+	FlaggedFor []string `json:"-" gorm:"-"`
+	// SendAt asks for the message to be delivered later instead of now
+	SendAt    *time.Time `json:"send_at,omitempty" gorm:"-"`
+	ExpiresAt *time.Time `json:"expires_at,omitempty" db:"expires_at" gorm:"index"`
+}
+
+// ScheduledMessage is a message waiting for its SendAt. It becomes a Message
+// with the same ID when delivered. FlagReasons carries moderation's verdict,
+// given when it was scheduled, over to the delivered message.
+type ScheduledMessage struct {
+	ID             string     `json:"id" db:"id"`
+	ConversationID string     `json:"conversation_id" db:"conversation_id"`
+	UserID         string     `json:"user_id" db:"user_id" gorm:"index"`
+	ParentID       string     `json:"parent_id,omitempty" db:"parent_id" gorm:"not null;default:''"`
+	Body           string     `json:"body" db:"body"`
+	SendAt         time.Time  `json:"send_at" db:"send_at" gorm:"index"`
+	ExpiresAt      *time.Time `json:"expires_at,omitempty" db:"expires_at"`
+	FlagReasons    string     `json:"-" db:"flag_reasons"`
+	CreatedAt      time.Time  `json:"created_at" db:"created_at"`
+}
+
+// ErrScheduledMessageGone means a scheduled message was cancelled, or
+// delivered by another instance, before it could be delivered.
+var ErrScheduledMessageGone = NotFound("scheduled_message_not_found", "scheduled message not found")
+
+const (
+	ModerationAllow  = "allow"
+	ModerationFlag   = "flag"
+	ModerationReject = "reject"
+)
+
+// ModerationVerdict is a Moderator's decision on a message, with the reasons
+// for anything other than allow.
+type ModerationVerdict struct {
+	Action  string   `json:"action"`
+	Reasons []string `json:"reasons"`
+}
+
+const (
+	ReviewApprove = "approve"
+	ReviewRemove  = "remove"
+)
+
+// FlaggedMessage is a message waiting in, or reviewed from, the admin review
+// queue. Reasons is "; " separated.
+type FlaggedMessage struct {
+	ID         string     `json:"id" db:"id"`
+	MessageID  string     `json:"message_id" db:"message_id" gorm:"index"`
+	UserID     string     `json:"user_id" db:"user_id"`
+	Reasons    string     `json:"reasons" db:"reasons"`
+	CreatedAt  time.Time  `json:"created_at" db:"created_at"`
+	ReviewedAt *time.Time `json:"reviewed_at,omitempty" db:"reviewed_at"`
+	ReviewedBy string     `json:"reviewed_by,omitempty" db:"reviewed_by"`
+	Decision   string     `json:"decision,omitempty" db:"decision"`
+	Message    *Message   `json:"message,omitempty" gorm:"-"`
+}
+
+// MaxMessageBodyLength is in characters. The max= binding on message
+// requests repeats it.
+const MaxMessageBodyLength = 4000
+
+const (
+	MaxAttachmentSize     = 10 << 20
+	MaxAttachmentsPerPost = 5
+)
+
+// AttachmentContentTypes are the sniffed content types a file may have to
+// be attached. Anything else is rejected rather than served back to other
+// participants' browsers.
+var AttachmentContentTypes = []string{
+	"image/png",
+	"image/jpeg",
+	"image/gif",
+	"image/webp",
+	"application/pdf",
+	"text/plain; charset=utf-8",
+}
+
+// Attachment is a file posted with a message. The file itself lives in a
+// BlobStore under BlobKey; downloads go through short-lived signed links.
+type Attachment struct {
+	ID          string    `json:"id" db:"id"`
+	MessageID   string    `json:"message_id" db:"message_id" gorm:"index"`
+	UserID      string    `json:"user_id" db:"user_id"`
+	Filename    string    `json:"filename" db:"filename"`
+	ContentType string    `json:"content_type" db:"content_type"`
+	Size        int64     `json:"size" db:"size"`
+	BlobKey     string    `json:"-" db:"blob_key" gorm:"unique_index"`
+	CreatedAt   time.Time `json:"created_at" db:"created_at"`
+}
+
+// AttachmentUpload is a file on its way into the BlobStore. Size is what the
+// client declared; Content is never read past it.
+type AttachmentUpload struct {
+	Filename string
+	Size     int64
+	Content  io.Reader
+}
+
+// BlobInfo describes an object in a BlobStore.
+type BlobInfo struct {
+	Key        string
+	Size       int64
+	ModifiedAt time.Time
+}
+
+// Reaction is one user's emoji on a message. A user can use each emoji once
+// per message; reacting again with it takes the reaction back.
+type Reaction struct {
+	MessageID string    `json:"message_id" db:"message_id" gorm:"primary_key"`
+	UserID    string    `json:"user_id" db:"user_id" gorm:"primary_key"`
+	Emoji     string    `json:"emoji" db:"emoji" gorm:"primary_key"`
+	CreatedAt time.Time `json:"created_at" db:"created_at"`
+}
+
+// ReactionCount is how many users reacted to a message with Emoji.
+type ReactionCount struct {
+	Emoji string `json:"emoji"`
+	Count int    `json:"count"`
+}
+
+const (
+	MessageCreated  = "message.created"
+	MessageUpdated  = "message.updated"
+	MessageDeleted  = "message.deleted"
+	MessageRestored = "message.restored"
+)
+
+const (
+	RevisionEdit   = "edit"
+	RevisionDelete = "delete"
+)
+
+// MessageRevision is a body a message had before an edit or delete replaced
+// it. Kind says which of the two it was.
+type MessageRevision struct {
+	ID        string    `json:"id" db:"id"`
+	MessageID string    `json:"message_id" db:"message_id" gorm:"index"`
+	Kind      string    `json:"kind" db:"kind"`
+	Body      string    `json:"body" db:"body"`
+	CreatedAt time.Time `json:"created_at" db:"created_at"`
+}
+
+// MessageEvent is pushed to a conversation's participants when one of its
+// messages changes.
+type MessageEvent struct {
+	Type    string    `json:"type"`
+	Message Message   `json:"message"`
+	SentAt  time.Time `json:"sent_at"`
+}
+
+// SearchQuery asks a SearchIndex for the messages matching Text, limited to
+// the conversations in ConversationIDs.
+type SearchQuery struct {
+	Text            string
+	ConversationIDs []string
+	Limit           int
+}
+
+// SearchHit is a message matching a search. Snippet is the matching part of
+// the body, HTML escaped, with matched terms wrapped in <mark> tags.
+type SearchHit struct {
+	Message Message `json:"message"`
+	Rank    float64 `json:"rank"`
+	Snippet string  `json:"snippet"`
+}
+
+const (
+	ConversationDirect = "direct"
+	ConversationGroup  = "group"
+)
+
+// Conversation is a direct (exactly two participants) or group chat that
+// messages are posted to.
+type Conversation struct {
+	ID             string    `json:"id" db:"id"`
+	Kind           string    `json:"kind" db:"kind"`
+	Title          string    `json:"title" db:"title"`
+	CreatedBy      string    `json:"created_by" db:"created_by"`
+	CreatedAt      time.Time `json:"created_at" db:"created_at"`
+	ParticipantIDs []string  `json:"participant_ids" gorm:"-"`
+	// UnreadCount is filled in for the user listing their conversations
+	UnreadCount int `json:"unread_count" gorm:"-"`
+	// RetentionDays overrides the default message retention; nil follows
+	// the default and 0 keeps messages forever
+	RetentionDays *int `json:"retention_days" db:"retention_days"`
+}
+
+// MaxRetentionDays bounds retention overrides to something a typo can't
+// turn into forever by accident; 0 is the way to ask for forever.
+const MaxRetentionDays = 36500
+
+// RetentionPurge records one batch of messages of a conversation deleted
+// for being past retention, for compliance reporting. MessageIDs is space
+// separated.
+type RetentionPurge struct {
+	ID             string    `json:"id" db:"id"`
+	ConversationID string    `json:"conversation_id" db:"conversation_id" gorm:"index"`
+	RetentionDays  int       `json:"retention_days" db:"retention_days"`
+	MessageCount   int       `json:"message_count" db:"message_count"`
+	OldestAt       time.Time `json:"oldest_at" db:"oldest_at"`
+	NewestAt       time.Time `json:"newest_at" db:"newest_at"`
+	MessageIDs     string    `json:"message_ids" db:"message_ids"`
+	PurgedAt       time.Time `json:"purged_at" db:"purged_at"`
+}
+
+// ConversationParticipant is a user's membership of a conversation. Messages
+// from others created after LastReadAt are unread.
+type ConversationParticipant struct {
+	ConversationID string     `json:"conversation_id" db:"conversation_id" gorm:"primary_key"`
+	UserID         string     `json:"user_id" db:"user_id" gorm:"primary_key"`
+	JoinedAt       time.Time  `json:"joined_at" db:"joined_at"`
+	LastReadAt     *time.Time `json:"last_read_at" db:"last_read_at"`
 }
 
 // add a balance in user
 type User struct {
 	ID         string `json:"id" db:"id"`
 	Email      string `json:"email" db:"email"`
-	Password   string `json:"password" db:"password"`
+	Password   string `json:"-" db:"password"`
 	Membership bool   `json:"membership" db:"membership"`
+	Admin      bool   `json:"admin" db:"admin"`
+
+	TOTPSecret   string `json:"-" db:"totp_secret"`
+	TOTPEnabled  bool   `json:"totp_enabled" db:"totp_enabled"`
+	TOTPLastStep int64  `json:"-" db:"totp_last_step"`
+}
+
+// NewUser is one user of a batch to be signed up
+type NewUser struct {
+	Email    string
+	Password string
+}
+
+// RecoveryCode is a single-use fallback for a user's TOTP device, stored hashed
+type RecoveryCode struct {
+	ID       string `json:"id" db:"id"`
+	UserID   string `json:"user_id" db:"user_id"`
+	CodeHash string `json:"-" db:"code_hash"`
+	Used     bool   `json:"used" db:"used"`
+}
+
+// ClientInfo describes the device a request came from
+type ClientInfo struct {
+	IP        string `json:"ip"`
+	UserAgent string `json:"user_agent"`
+}
+
+// Session is one signed-in device. Each session owns a family of rotating
+// refresh tokens; only the token whose ID matches RefreshTokenID is valid.
+type Session struct {
+	ID             string     `json:"id" db:"id"`
+	UserID         string     `json:"user_id" db:"user_id"`
+	UserAgent      string     `json:"user_agent" db:"user_agent"`
+	IP             string     `json:"ip" db:"ip"`
+	RefreshTokenID string     `json:"-" db:"refresh_token_id"`
+	CreatedAt      time.Time  `json:"created_at" db:"created_at"`
+	LastSeenAt     time.Time  `json:"last_seen_at" db:"last_seen_at"`
+	RevokedAt      *time.Time `json:"revoked_at,omitempty" db:"revoked_at"`
+}
+
+// ExternalIdentity is a user as asserted by an external identity provider
+type ExternalIdentity struct {
+	Issuer        string `json:"issuer"`
+	Subject       string `json:"subject"`
+	Email         string `json:"email"`
+	EmailVerified bool   `json:"email_verified"`
+}
+
+// UserIdentity links a local user to their account at an identity provider
+type UserIdentity struct {
+	ID        string    `json:"id" db:"id"`
+	UserID    string    `json:"user_id" db:"user_id"`
+	Issuer    string    `json:"issuer" db:"issuer" gorm:"unique_index:idx_user_identities_issuer_subject"`
+	Subject   string    `json:"subject" db:"subject" gorm:"unique_index:idx_user_identities_issuer_subject"`
+	CreatedAt time.Time `json:"created_at" db:"created_at"`
+}
+
+// APIKey is a long-lived personal credential for scripts and integrations.
+// Only a hash of the key is stored; Prefix stays in clear so users can tell
+// their keys apart. Scopes is space separated, e.g. "messages:read users:read".
+type APIKey struct {
+	ID         string     `json:"id" db:"id"`
+	UserID     string     `json:"user_id" db:"user_id"`
+	Name       string     `json:"name" db:"name"`
+	Prefix     string     `json:"prefix" db:"prefix" gorm:"unique_index"`
+	KeyHash    string     `json:"-" db:"key_hash"`
+	Scopes     string     `json:"scopes" db:"scopes"`
+	CreatedAt  time.Time  `json:"created_at" db:"created_at"`
+	ExpiresAt  *time.Time `json:"expires_at" db:"expires_at"`
+	LastUsedAt *time.Time `json:"last_used_at" db:"last_used_at"`
+	RevokedAt  *time.Time `json:"revoked_at,omitempty" db:"revoked_at"`
+}
+
+// APIKeyScopes lists the scopes a key can be granted. Each is a resource
+// under /v1 and an access level: read covers GET, write everything else.
+// GraphQL only has queries, so graphql:read covers POST /v1/graphql, and
+// with it everything the schema reads.
+var APIKeyScopes = []string{
+	"attachments:read",
+	"conversations:read",
+	"conversations:write",
+	"graphql:read",
+	"messages:read",
+	"messages:write",
+	"users:read",
+	"users:write",
+}
+
+func (k *APIKey) HasScope(scope string) bool {
+	for _, s := range strings.Fields(k.Scopes) {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+const (
+	DefaultPageLimit = 50
+	MaxPageLimit     = 100
+)
+
+// MaxBatchSize is the most items a batch call takes at once.
+const MaxBatchSize = 1000
+
+// MaxUserBatchSize is the most users a batch signs up at once: bcrypt takes
+// tens of milliseconds over each password, all within the one request.
+const MaxUserBatchSize = 100
+
+// ListOptions selects one page of a list endpoint. Cursor is the opaque
+// NextCursor of the previous page. Sort names a field, prefixed with "-" for
+// descending order.
+type ListOptions struct {
+	Limit  int
+	Cursor string
+	Sort   string
+	UserID string
+	Since  *time.Time
+	Until  *time.Time
+}
+
+// Page is one page of a keyset-paginated list. NextCursor is empty on the
+// last page.
+type Page[T any] struct {
+	Items      []T    `json:"items"`
+	NextCursor string `json:"next_cursor"`
 }
 
 type Payment struct {
@@ -27,10 +392,20 @@ type BuyerInfo struct {
 	UserID    string `json:"user_id" db:"user_id"`
 }
 
+// OrderInfo is one order of a checkout. The repository keeps it under the
+// buyer's UserID when the checkout session is created, with an ID of its own;
+// OrderID is whatever reference the client gave the order, if any.
 type OrderInfo struct {
-	OrderID       string `json:"order_id" db:"order_id"`
-	SellerAccount string `json:"seller_account" db:"seller_account"`
-	Amount        string `json:"amount" db:"amount"`
-	Currency      string `json:"currency" db:"currency"`
-	Status        string `json:"status" db:"status"`
+	ID            string    `json:"id" db:"id" gorm:"primary_key"`
+	OrderID       string    `json:"order_id" db:"order_id"`
+	UserID        string    `json:"user_id" db:"user_id" gorm:"index"`
+	CheckoutID    string    `json:"checkout_id" db:"checkout_id"`
+	SellerAccount string    `json:"seller_account" db:"seller_account"`
+	Amount        string    `json:"amount" db:"amount"`
+	Currency      string    `json:"currency" db:"currency"`
+	Status        string    `json:"status" db:"status"`
+	CreatedAt     time.Time `json:"created_at" db:"created_at"`
 }
+
+// OrderPending is the status of an order until its payment goes through.
+const OrderPending = "pending"
@@ -0,0 +1,17 @@
+package ports
+
+import (
+	"io"
+
+	"github.com/LordMoMA/Hexagonal-Architecture/internal/core/domain"
+)
+
+// BlobStore keeps attachment files. Keys are slash separated paths such as
+// "attachments/<message id>/<attachment id>".
+type BlobStore interface {
+	Put(key string, content io.Reader, size int64, contentType string) error
+	Get(key string) (io.ReadCloser, error)
+	Delete(key string) error
+	// List returns every blob whose key starts with prefix.
+	List(prefix string) ([]domain.BlobInfo, error)
+}
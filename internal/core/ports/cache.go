@@ -6,4 +6,5 @@ type CacheRepository interface {
 	Set(key string, value interface{}, expiration time.Duration) error
 	Get(key string, value interface{}) error
 	Delete(key string) error
+	Increment(key string, expiration time.Duration) (int64, error)
 }
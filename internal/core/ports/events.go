@@ -0,0 +1,20 @@
+package ports
+
+import (
+	"context"
+
+	"github.com/LordMoMA/Hexagonal-Architecture/internal/core/domain"
+)
+
+// PubSub carries raw payloads between server instances.
+type PubSub interface {
+	Publish(channel string, payload []byte) error
+	// Subscribe delivers payloads published to channel until ctx is done,
+	// then closes the returned channel.
+	Subscribe(ctx context.Context, channel string) (<-chan []byte, error)
+}
+
+// MessageEvents delivers message changes to connected participants.
+type MessageEvents interface {
+	PublishMessageEvent(event domain.MessageEvent, recipientIDs []string) error
+}
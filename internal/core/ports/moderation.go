@@ -0,0 +1,13 @@
+package ports
+
+import "github.com/LordMoMA/Hexagonal-Architecture/internal/core/domain"
+
+// Moderator decides whether a new message may be posted. It sees the message
+// before it is saved, with UserID, ConversationID and Body set.
+type Moderator interface {
+	Moderate(message domain.Message) (domain.ModerationVerdict, error)
+	// ModerateBatch judges messages one user posts together, returning a
+	// verdict for each. The batch counts as a single post against any
+	// posting rate, so a bulk import isn't taken for flooding.
+	ModerateBatch(messages []domain.Message) ([]domain.ModerationVerdict, error)
+}
@@ -1,52 +1,148 @@
 package ports
 
 import (
+	"io"
+	"time"
+
 	"github.com/LordMoMA/Hexagonal-Architecture/internal/adapters/repository"
 	"github.com/LordMoMA/Hexagonal-Architecture/internal/core/domain"
 )
 
 type MessengerService interface {
-	CreateMessage(userID string, message domain.Message) error
-	ReadMessage(id string) (*domain.Message, error)
-	ReadMessages() ([]*domain.Message, error)
+	CreateMessage(userID string, message domain.Message, uploads ...domain.AttachmentUpload) error
+	CreateMessages(userID string, messages []domain.Message, atomic bool) ([]*domain.Message, []error)
+	ReadMessage(userID, id string) (*domain.Message, error)
+	ReadMessages(userID string, opts domain.ListOptions) (*domain.Page[*domain.Message], error)
 	UpdateMessage(id string, message domain.Message) error
 	DeleteMessage(id string) error
+	CreateConversation(userID string, conversation domain.Conversation) (*domain.Conversation, error)
+	ReadConversations(userID string) ([]*domain.Conversation, error)
+	ReadConversationsByIDs(userID string, ids []string) ([]*domain.Conversation, error)
+	ReadConversationMessages(userID, conversationID string, opts domain.ListOptions) (*domain.Page[*domain.Message], error)
+	SearchMessages(userID, text, conversationID string, limit int) ([]*domain.SearchHit, error)
+	ReadMessageRevisions(id string) ([]*domain.MessageRevision, error)
+	RestoreMessage(id string) error
+	ReadReplies(userID, id string, opts domain.ListOptions) (*domain.Page[*domain.Message], error)
+	ToggleReaction(userID, messageID, emoji string) (bool, error)
+	MarkConversationRead(userID, conversationID, messageID string) error
+	ReadAttachment(userID, id string) (*domain.Attachment, error)
+	OpenAttachment(id string) (*domain.Attachment, io.ReadCloser, error)
+	CleanupOrphanedBlobs(olderThan time.Duration) (int, error)
+	ReadFlaggedMessages(pending bool, opts domain.ListOptions) (*domain.Page[*domain.FlaggedMessage], error)
+	ReviewFlaggedMessage(reviewerID, id, decision string) (*domain.FlaggedMessage, error)
+	SetConversationRetention(conversationID string, days *int) error
+	PurgeExpiredMessages(defaultDays int) (int, error)
+	ReadRetentionPurges(opts domain.ListOptions) (*domain.Page[*domain.RetentionPurge], error)
+	ScheduleMessage(userID string, message domain.Message) (*domain.ScheduledMessage, error)
+	ReadScheduledMessages(userID string, opts domain.ListOptions) (*domain.Page[*domain.ScheduledMessage], error)
+	CancelScheduledMessage(userID, id string) error
+	DeliverScheduledMessages(now time.Time) (int, error)
+	ExpireMessages(now time.Time) (int, error)
 }
 
 type MessengerRepository interface {
 	CreateMessage(userID string, message domain.Message) error
+	CreateMessages(userID string, messages []domain.Message) error
 	ReadMessage(id string) (*domain.Message, error)
-	ReadMessages() ([]*domain.Message, error)
+	ReadMessages(userID string, opts domain.ListOptions) (*domain.Page[*domain.Message], error)
 	UpdateMessage(id string, message domain.Message) error
 	DeleteMessage(id string) error
+	ReadMessageRevisions(id string) ([]*domain.MessageRevision, error)
+	RestoreMessage(id string) error
+	ReadReplies(parentID string, opts domain.ListOptions) (*domain.Page[*domain.Message], error)
+	CreateConversation(conversation domain.Conversation) (*domain.Conversation, error)
+	FindDirectConversation(userID, otherUserID string) (*domain.Conversation, error)
+	ReadConversations(userID string) ([]*domain.Conversation, error)
+	ReadConversationsByIDs(userID string, ids []string) ([]*domain.Conversation, error)
+	ReadConversationIDs(userID string) ([]string, error)
+	ReadConversationMessages(conversationID string, opts domain.ListOptions) (*domain.Page[*domain.Message], error)
+	IsParticipant(conversationID, userID string) (bool, error)
+	ReadParticipantIDs(conversationID string) ([]string, error)
+	ToggleReaction(messageID, userID, emoji string) (bool, error)
+	MarkConversationRead(conversationID, userID string, readAt time.Time) error
+	ReadAttachment(id string) (*domain.Attachment, error)
+	ReadAttachmentKeys(keys []string) ([]string, error)
+	ReadFlaggedMessages(pending bool, opts domain.ListOptions) (*domain.Page[*domain.FlaggedMessage], error)
+	ReadFlaggedMessage(id string) (*domain.FlaggedMessage, error)
+	ReviewFlaggedMessage(id, reviewerID, decision string) (*domain.FlaggedMessage, error)
+	SetConversationRetention(conversationID string, days *int) error
+	PurgeExpiredMessages(defaultDays, limit int) ([]*domain.RetentionPurge, []string, error)
+	ReadRetentionPurges(opts domain.ListOptions) (*domain.Page[*domain.RetentionPurge], error)
+	CreateScheduledMessage(scheduled domain.ScheduledMessage) error
+	ReadScheduledMessages(userID string, opts domain.ListOptions) (*domain.Page[*domain.ScheduledMessage], error)
+	ReadDueScheduledMessages(now time.Time, limit int) ([]*domain.ScheduledMessage, error)
+	DeliverScheduledMessage(id string, message domain.Message) error
+	DeleteScheduledMessage(userID, id string) error
+	ReadExpiredMessageIDs(now time.Time, limit int) ([]string, error)
 }
 
 type UserService interface {
 	CreateUser(email, password string) (*domain.User, error)
+	CreateUsers(users []domain.NewUser, atomic bool) ([]*domain.User, []error)
 	ReadUser(id string) (*domain.User, error)
-	ReadUsers() ([]*domain.User, error)
+	ReadUsers(opts domain.ListOptions) (*domain.Page[*domain.User], error)
+	ReadUsersByIDs(ids []string) ([]*domain.User, error)
 	UpdateUser(id, email, password string) error
 	DeleteUser(id string) error
-	LoginUser(email, password string) (*repository.LoginResponse, error)
+	LoginUser(email, password string, client domain.ClientInfo) (*repository.LoginResponse, error)
+	LoginUserMFA(mfaToken, code string, client domain.ClientInfo) (*repository.LoginResponse, error)
+	RefreshSession(refreshToken string, client domain.ClientInfo) (*repository.LoginResponse, error)
+	ListSessions(userID string) ([]*domain.Session, error)
+	RevokeSession(userID, id string) error
+	EnrollTOTP(id string) (*repository.TOTPEnrollment, error)
+	ActivateTOTP(id, code string) error
 	UpdateMembershipStatus(id string, status bool) error
+	UpdateAdminStatus(id string, admin bool) error
+	UnlockUser(id string) error
+	CreateAPIKey(userID, name string, scopes []string, expiresAt *time.Time) (*domain.APIKey, string, error)
+	ListAPIKeys(userID string) ([]*domain.APIKey, error)
+	RevokeAPIKey(userID, id string) error
+	AuthenticateAPIKey(key string) (*domain.APIKey, error)
 }
 
 type UserRepository interface {
 	CreateUser(email, password string) (*domain.User, error)
+	CreateUsers(users []domain.NewUser) ([]*domain.User, error)
 	ReadUser(id string) (*domain.User, error)
-	ReadUsers() ([]*domain.User, error)
+	ReadUsers(opts domain.ListOptions) (*domain.Page[*domain.User], error)
+	ReadUsersByIDs(ids []string) ([]*domain.User, error)
 	UpdateUser(id, email, password string) error
 	DeleteUser(id string) error
-	LoginUser(email, password string) (*repository.LoginResponse, error)
+	LoginUser(email, password string, client domain.ClientInfo) (*repository.LoginResponse, error)
+	LoginUserMFA(mfaToken, code string, client domain.ClientInfo) (*repository.LoginResponse, error)
+	RefreshSession(refreshToken string, client domain.ClientInfo) (*repository.LoginResponse, error)
+	ListSessions(userID string) ([]*domain.Session, error)
+	RevokeSession(userID, id string) error
+	EnrollTOTP(id string) (*repository.TOTPEnrollment, error)
+	ActivateTOTP(id, code string) error
 	UpdateMembershipStatus(id string, status bool) error
+	UpdateAdminStatus(id string, admin bool) error
+	UnlockUser(id string) error
+	CreateAPIKey(userID, name string, scopes []string, expiresAt *time.Time) (*domain.APIKey, string, error)
+	ListAPIKeys(userID string) ([]*domain.APIKey, error)
+	RevokeAPIKey(userID, id string) error
+	AuthenticateAPIKey(key string) (*domain.APIKey, error)
+	LoginUserOIDC(identity domain.ExternalIdentity, client domain.ClientInfo) (*repository.LoginResponse, error)
+}
+
+type OIDCService interface {
+	BeginLogin() (string, error)
+	CompleteLogin(state, code string, client domain.ClientInfo) (*repository.LoginResponse, error)
+}
+
+type IdentityProvider interface {
+	AuthCodeURL(state, nonce, codeVerifier string) string
+	Exchange(code, codeVerifier, nonce string) (*domain.ExternalIdentity, error)
 }
 
 type PaymentService interface {
 	CreateCheckoutSession(userID string, payment domain.Payment) error
+	ReadOrders(userIDs []string) ([]*domain.OrderInfo, error)
 	// ProcessPaymentWithStripe(userID string, payment domain.Payment) error
 }
 
 type PaymentRepository interface {
 	CreateCheckoutSession(userID string, payment domain.Payment) error
+	ReadOrders(userIDs []string) ([]*domain.OrderInfo, error)
 	// ProcessPaymentWithStripe(userID string, payment domain.Payment) error
 }
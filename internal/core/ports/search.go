@@ -0,0 +1,11 @@
+package ports
+
+import "github.com/LordMoMA/Hexagonal-Architecture/internal/core/domain"
+
+// SearchIndex finds messages by their text, best match first. An index kept
+// outside the database is fed through IndexMessage and RemoveMessage.
+type SearchIndex interface {
+	IndexMessage(message domain.Message) error
+	RemoveMessage(id string) error
+	SearchMessages(query domain.SearchQuery) ([]*domain.SearchHit, error)
+}
@@ -0,0 +1,209 @@
+package services
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/LordMoMA/Hexagonal-Architecture/internal/core/domain"
+	"github.com/google/uuid"
+)
+
+const attachmentKeyPrefix = "attachments/"
+
+var (
+	ErrAttachmentsUnavailable = domain.Unavailable("attachments_unavailable", "attachments are not available")
+	ErrAttachmentTooLarge     = domain.TooLarge("attachment_too_large", "attachments can be at most %d MiB", domain.MaxAttachmentSize>>20)
+	ErrTooManyAttachments     = domain.Validation("too_many_attachments", "a message can have at most %d attachments", domain.MaxAttachmentsPerPost)
+	ErrAttachmentType         = domain.Unsupported("attachment_type_not_allowed", "attachment type is not allowed")
+)
+
+// ReadAttachment returns an attachment of a message userID can read.
+func (m *MessengerService) ReadAttachment(userID, id string) (*domain.Attachment, error) {
+	attachment, err := m.repo.ReadAttachment(id)
+	if err != nil {
+		return nil, err
+	}
+	message, err := m.ReadMessage(userID, attachment.MessageID)
+	if err != nil {
+		return nil, err
+	}
+	if message.Deleted {
+		return nil, domain.NotFound("attachment_not_found", "attachment not found")
+	}
+	return attachment, nil
+}
+
+// OpenAttachment returns an attachment and its content. It does no access
+// check: callers reach it through a signed link handed out after
+// ReadAttachment.
+func (m *MessengerService) OpenAttachment(id string) (*domain.Attachment, io.ReadCloser, error) {
+	if m.blobs == nil {
+		return nil, nil, ErrAttachmentsUnavailable
+	}
+	attachment, err := m.repo.ReadAttachment(id)
+	if err != nil {
+		return nil, nil, err
+	}
+	message, err := m.repo.ReadMessage(attachment.MessageID)
+	if err != nil || message.Deleted {
+		return nil, nil, domain.NotFound("attachment_not_found", "attachment not found")
+	}
+	content, err := m.blobs.Get(attachment.BlobKey)
+	if err != nil {
+		return nil, nil, err
+	}
+	return attachment, content, nil
+}
+
+// CleanupOrphanedBlobs deletes attachment blobs no attachment record points
+// to, left behind when saving a message failed after its uploads. Blobs
+// younger than olderThan are skipped as their message may still be saving.
+func (m *MessengerService) CleanupOrphanedBlobs(olderThan time.Duration) (int, error) {
+	if m.blobs == nil {
+		return 0, nil
+	}
+	blobs, err := m.blobs.List(attachmentKeyPrefix)
+	if err != nil {
+		return 0, err
+	}
+
+	cutoff := time.Now().Add(-olderThan)
+	var candidates []string
+	for _, blob := range blobs {
+		if blob.ModifiedAt.Before(cutoff) {
+			candidates = append(candidates, blob.Key)
+		}
+	}
+
+	deleted := 0
+	for start := 0; start < len(candidates); start += domain.MaxPageLimit {
+		end := start + domain.MaxPageLimit
+		if end > len(candidates) {
+			end = len(candidates)
+		}
+		batch := candidates[start:end]
+		known, err := m.repo.ReadAttachmentKeys(batch)
+		if err != nil {
+			return deleted, err
+		}
+		inUse := make(map[string]bool, len(known))
+		for _, key := range known {
+			inUse[key] = true
+		}
+		for _, key := range batch {
+			if inUse[key] {
+				continue
+			}
+			if err := m.blobs.Delete(key); err != nil {
+				return deleted, err
+			}
+			deleted++
+		}
+	}
+	return deleted, nil
+}
+
+// storeAttachments checks and uploads each file. The content type is sniffed
+// from the bytes rather than trusted from the client. On error nothing is
+// left in the blob store.
+func (m *MessengerService) storeAttachments(userID, messageID string, uploads []domain.AttachmentUpload) ([]domain.Attachment, error) {
+	if len(uploads) == 0 {
+		return nil, nil
+	}
+	if m.blobs == nil {
+		return nil, ErrAttachmentsUnavailable
+	}
+	if len(uploads) > domain.MaxAttachmentsPerPost {
+		return nil, ErrTooManyAttachments
+	}
+	for _, upload := range uploads {
+		if upload.Size <= 0 {
+			return nil, domain.Validation("empty_attachment", "attachment is empty")
+		}
+		if upload.Size > domain.MaxAttachmentSize {
+			return nil, ErrAttachmentTooLarge
+		}
+	}
+
+	attachments := make([]domain.Attachment, 0, len(uploads))
+	for _, upload := range uploads {
+		attachment, err := m.storeAttachment(userID, messageID, upload)
+		if err != nil {
+			m.deleteBlobs(attachments)
+			return nil, err
+		}
+		attachments = append(attachments, *attachment)
+	}
+	return attachments, nil
+}
+
+func (m *MessengerService) storeAttachment(userID, messageID string, upload domain.AttachmentUpload) (*domain.Attachment, error) {
+	content := io.LimitReader(upload.Content, upload.Size)
+	head := make([]byte, 512)
+	n, err := io.ReadFull(content, head)
+	if err != nil && !errors.Is(err, io.ErrUnexpectedEOF) {
+		return nil, domain.Validation("attachment_unreadable", "attachment not readable: %v", err)
+	}
+	head = head[:n]
+
+	contentType := http.DetectContentType(head)
+	if !allowedContentType(contentType) {
+		return nil, fmt.Errorf("%w: %s", ErrAttachmentType, contentType)
+	}
+
+	id := uuid.New().String()
+	attachment := &domain.Attachment{
+		ID:          id,
+		MessageID:   messageID,
+		UserID:      userID,
+		Filename:    attachmentFilename(upload.Filename),
+		ContentType: contentType,
+		Size:        upload.Size,
+		BlobKey:     attachmentKeyPrefix + messageID + "/" + id,
+		CreatedAt:   time.Now().UTC(),
+	}
+	err = m.blobs.Put(attachment.BlobKey, io.MultiReader(bytes.NewReader(head), content), upload.Size, contentType)
+	if err != nil {
+		return nil, err
+	}
+	return attachment, nil
+}
+
+// deleteBlobs is best effort; whatever it misses is found by
+// CleanupOrphanedBlobs.
+func (m *MessengerService) deleteBlobs(attachments []domain.Attachment) {
+	for _, attachment := range attachments {
+		if err := m.blobs.Delete(attachment.BlobKey); err != nil {
+			fmt.Printf("Error deleting blob %s: %v", attachment.BlobKey, err)
+		}
+	}
+}
+
+func allowedContentType(contentType string) bool {
+	for _, allowed := range domain.AttachmentContentTypes {
+		if contentType == allowed {
+			return true
+		}
+	}
+	return false
+}
+
+// attachmentFilename keeps the base name the client sent, which is only
+// ever shown back to users, never used as a path.
+func attachmentFilename(name string) string {
+	name = strings.TrimSpace(filepath.Base(strings.ReplaceAll(name, "\\", "/")))
+	if name == "" || name == "." || name == "/" || !utf8.ValidString(name) {
+		return "attachment"
+	}
+	if utf8.RuneCountInString(name) > 255 {
+		name = string([]rune(name)[:255])
+	}
+	return name
+}
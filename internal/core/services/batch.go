@@ -0,0 +1,47 @@
+package services
+
+import (
+	"errors"
+
+	"github.com/LordMoMA/Hexagonal-Architecture/internal/core/domain"
+)
+
+// ErrBatchAborted is the error of the items of an atomic batch that were
+// fine but weren't saved, as another one failed.
+var ErrBatchAborted = domain.Conflict("batch_aborted", "not saved, as another item of the batch failed")
+
+// AbortBatch gives every item of an atomic batch that hasn't failed itself
+// ErrBatchAborted.
+func AbortBatch(errs []error) {
+	for i, err := range errs {
+		if err == nil {
+			errs[i] = ErrBatchAborted
+		}
+	}
+}
+
+// batchFailed tells whether any item of a batch failed.
+func batchFailed(errs []error) bool {
+	for _, err := range errs {
+		if err != nil {
+			return true
+		}
+	}
+	return false
+}
+
+// itemErrors lines up err, from saving a batch all or nothing, with the
+// batch's n items.
+func itemErrors(err error, n int) []error {
+	errs := make([]error, n)
+	var itemErr *domain.ItemError
+	if errors.As(err, &itemErr) {
+		errs[itemErr.Index] = itemErr.Err
+		AbortBatch(errs)
+		return errs
+	}
+	for i := range errs {
+		errs[i] = err
+	}
+	return errs
+}
@@ -1,38 +1,441 @@
 package services
 
 import (
+	"fmt"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
 	"github.com/LordMoMA/Hexagonal-Architecture/internal/core/domain"
 	"github.com/LordMoMA/Hexagonal-Architecture/internal/core/ports"
 	"github.com/google/uuid"
 )
 
+var (
+	ErrNotParticipant    = domain.Forbidden("not_participant", "you are not a participant of this conversation")
+	ErrSearchUnavailable = domain.Unavailable("search_unavailable", "message search is not available")
+	ErrEmptySearchQuery  = domain.Validation("empty_search_query", "search query is required")
+	ErrNestedReply       = domain.Validation("nested_reply", "replies can't be replied to, reply to the thread's first message")
+	ErrInvalidEmoji      = domain.Validation("invalid_emoji", "emoji must be 1 to 16 characters with no spaces")
+)
+
 type MessengerService struct {
-	repo ports.MessengerRepository
+	repo      ports.MessengerRepository
+	events    ports.MessageEvents
+	search    ports.SearchIndex
+	blobs     ports.BlobStore
+	moderator ports.Moderator
 }
 
-func NewMessengerService(repo ports.MessengerRepository) *MessengerService {
+// events may be nil when nothing needs real-time delivery, search when
+// message search is not offered, blobs when attachments are not and
+// moderator when every message is allowed.
+func NewMessengerService(repo ports.MessengerRepository, events ports.MessageEvents, search ports.SearchIndex, blobs ports.BlobStore, moderator ports.Moderator) *MessengerService {
 	return &MessengerService{
-		repo: repo,
+		repo:      repo,
+		events:    events,
+		search:    search,
+		blobs:     blobs,
+		moderator: moderator,
+	}
+}
+
+// CreateMessage posts a message, or a reply when ParentID is set, with any
+// uploads as attachments. A reply goes to its parent's conversation;
+// replying to a reply or to a deleted message is not allowed.
+func (m *MessengerService) CreateMessage(userID string, message domain.Message, uploads ...domain.AttachmentUpload) error {
+	_, err := m.createMessage(userID, message, uploads)
+	return err
+}
+
+// CreateMessages posts messages for userID. Atomically, they are all posted
+// or, when any fails, none are; otherwise each is posted on its own. The
+// errors line up with messages, nil for those posted, as do the messages
+// posted. Moderation sees the batch as one post.
+func (m *MessengerService) CreateMessages(userID string, messages []domain.Message, atomic bool) ([]*domain.Message, []error) {
+	created := make([]*domain.Message, len(messages))
+	errs := make([]error, len(messages))
+	messages = append([]domain.Message(nil), messages...)
+	for i := range messages {
+		errs[i] = m.readyMessage(userID, &messages[i])
+	}
+	m.moderateBatch(messages, errs)
+
+	if !atomic {
+		for i := range messages {
+			if errs[i] == nil {
+				created[i], errs[i] = m.saveMessage(userID, messages[i], nil)
+			}
+		}
+		return created, errs
+	}
+	if batchFailed(errs) {
+		AbortBatch(errs)
+		return created, errs
+	}
+	if err := m.repo.CreateMessages(userID, messages); err != nil {
+		return created, itemErrors(err, len(messages))
+	}
+	for i := range messages {
+		created[i] = &messages[i]
+		m.index(messages[i])
+		m.publish(domain.MessageCreated, messages[i])
 	}
+	return created, errs
 }
 
-func (m *MessengerService) CreateMessage(userID string, message domain.Message) error {
+func (m *MessengerService) createMessage(userID string, message domain.Message, uploads []domain.AttachmentUpload) (*domain.Message, error) {
+	if err := m.newMessage(userID, &message); err != nil {
+		return nil, err
+	}
+	return m.saveMessage(userID, message, uploads)
+}
+
+// saveMessage saves a message newMessage readied, with uploads as its
+// attachments, and lets everyone concerned know.
+func (m *MessengerService) saveMessage(userID string, message domain.Message, uploads []domain.AttachmentUpload) (*domain.Message, error) {
+	attachments, err := m.storeAttachments(userID, message.ID, uploads)
+	if err != nil {
+		return nil, err
+	}
+	message.Attachments = attachments
+	if err := m.repo.CreateMessage(userID, message); err != nil {
+		m.deleteBlobs(attachments)
+		return nil, err
+	}
+	m.index(message)
+	m.publish(domain.MessageCreated, message)
+	return &message, nil
+}
+
+// newMessage readies message to be saved as posted by userID now, once it
+// has passed every check and moderation.
+func (m *MessengerService) newMessage(userID string, message *domain.Message) error {
+	if err := m.readyMessage(userID, message); err != nil {
+		return err
+	}
+	flaggedFor, err := m.moderate(*message)
+	if err != nil {
+		return err
+	}
+	message.FlaggedFor = flaggedFor
+	return nil
+}
+
+// readyMessage is newMessage short of moderation, which a batch goes
+// through as a whole.
+func (m *MessengerService) readyMessage(userID string, message *domain.Message) error {
+	if err := m.prepareMessage(userID, message); err != nil {
+		return err
+	}
 	message.ID = uuid.New().String()
-	return m.repo.CreateMessage(userID, message)
+	message.UserID = userID
+	message.CreatedAt = time.Now().UTC()
+	return checkExpiry(message.ExpiresAt, message.CreatedAt)
 }
 
-func (m *MessengerService) ReadMessage(id string) (*domain.Message, error) {
-	return m.repo.ReadMessage(id)
+// prepareMessage checks userID may post message where it is going and, for
+// a reply, moves it into its parent's conversation.
+func (m *MessengerService) prepareMessage(userID string, message *domain.Message) error {
+	if err := checkBody(message.Body); err != nil {
+		return err
+	}
+	if message.ParentID != "" {
+		parent, err := m.repo.ReadMessage(message.ParentID)
+		if err != nil {
+			return err
+		}
+		if parent.ParentID != "" {
+			return ErrNestedReply
+		}
+		if parent.Deleted {
+			return domain.Conflict("message_deleted", "can't reply to a deleted message")
+		}
+		if message.ConversationID != "" && message.ConversationID != parent.ConversationID {
+			return domain.Validation("conversation_mismatch", "a reply must be in the same conversation as its parent")
+		}
+		message.ConversationID = parent.ConversationID
+	}
+	if message.ConversationID == "" {
+		return domain.Validation("conversation_id_required", "conversation_id is required")
+	}
+	return m.checkParticipant(message.ConversationID, userID)
 }
 
-func (m *MessengerService) ReadMessages() ([]*domain.Message, error) {
-	return m.repo.ReadMessages()
+func (m *MessengerService) ReadMessage(userID, id string) (*domain.Message, error) {
+	message, err := m.repo.ReadMessage(id)
+	if err != nil {
+		return nil, err
+	}
+	if err := m.checkParticipant(message.ConversationID, userID); err != nil {
+		return nil, err
+	}
+	return message, nil
+}
+
+// ReadMessages returns the messages of every conversation userID is in.
+func (m *MessengerService) ReadMessages(userID string, opts domain.ListOptions) (*domain.Page[*domain.Message], error) {
+	return m.repo.ReadMessages(userID, opts)
 }
 
 func (m *MessengerService) UpdateMessage(id string, message domain.Message) error {
-	return m.repo.UpdateMessage(id, message)
+	if err := checkBody(message.Body); err != nil {
+		return err
+	}
+	if err := m.repo.UpdateMessage(id, message); err != nil {
+		return err
+	}
+	if updated, err := m.repo.ReadMessage(id); err == nil {
+		m.index(*updated)
+		m.publish(domain.MessageUpdated, *updated)
+	}
+	return nil
 }
 
+// DeleteMessage replaces the message with a tombstone. Participants are sent
+// the tombstone, not what the message said.
 func (m *MessengerService) DeleteMessage(id string) error {
-	return m.repo.DeleteMessage(id)
+	if err := m.repo.DeleteMessage(id); err != nil {
+		return err
+	}
+	if m.search != nil {
+		if err := m.search.RemoveMessage(id); err != nil {
+			fmt.Printf("Error removing message %s from search index: %v", id, err)
+		}
+	}
+	if tombstone, err := m.repo.ReadMessage(id); err == nil {
+		m.publish(domain.MessageDeleted, *tombstone)
+	}
+	return nil
+}
+
+// ReadReplies pages through the thread under message id. Deleting the parent
+// leaves its tombstone at the top of the thread, so replies stay readable.
+func (m *MessengerService) ReadReplies(userID, id string, opts domain.ListOptions) (*domain.Page[*domain.Message], error) {
+	parent, err := m.ReadMessage(userID, id)
+	if err != nil {
+		return nil, err
+	}
+	return m.repo.ReadReplies(parent.ID, opts)
+}
+
+// ToggleReaction adds or takes back userID's emoji on a message and reports
+// whether it is now there. Participants get the new counts as an update.
+func (m *MessengerService) ToggleReaction(userID, messageID, emoji string) (bool, error) {
+	if !validEmoji(emoji) {
+		return false, ErrInvalidEmoji
+	}
+	message, err := m.ReadMessage(userID, messageID)
+	if err != nil {
+		return false, err
+	}
+	if message.Deleted {
+		return false, domain.Conflict("message_deleted", "can't react to a deleted message")
+	}
+
+	reacted, err := m.repo.ToggleReaction(messageID, userID, emoji)
+	if err != nil {
+		return false, err
+	}
+	if updated, err := m.repo.ReadMessage(messageID); err == nil {
+		m.publish(domain.MessageUpdated, *updated)
+	}
+	return reacted, nil
+}
+
+// MarkConversationRead marks the conversation read up to messageID, or up
+// to now when messageID is empty.
+func (m *MessengerService) MarkConversationRead(userID, conversationID, messageID string) error {
+	if err := m.checkParticipant(conversationID, userID); err != nil {
+		return err
+	}
+	readAt := time.Now().UTC()
+	if messageID != "" {
+		message, err := m.repo.ReadMessage(messageID)
+		if err != nil {
+			return err
+		}
+		if message.ConversationID != conversationID {
+			return domain.Validation("conversation_mismatch", "message is not in this conversation")
+		}
+		readAt = message.CreatedAt
+	}
+	return m.repo.MarkConversationRead(conversationID, userID, readAt)
+}
+
+func checkBody(body string) error {
+	if utf8.RuneCountInString(body) > domain.MaxMessageBodyLength {
+		return domain.Validation("body_too_long", "body must be at most %d characters", domain.MaxMessageBodyLength)
+	}
+	return nil
+}
+
+// validEmoji accepts a short token such as "👍" or ":shipit:". Anything
+// longer or containing spaces is almost certainly not a reaction.
+func validEmoji(emoji string) bool {
+	n := utf8.RuneCountInString(emoji)
+	if n == 0 || n > 16 || !utf8.ValidString(emoji) {
+		return false
+	}
+	for _, r := range emoji {
+		if unicode.IsSpace(r) || unicode.IsControl(r) {
+			return false
+		}
+	}
+	return true
+}
+
+// ReadMessageRevisions lists the earlier bodies of a message, oldest first.
+// It is meant for admins and does no participant check.
+func (m *MessengerService) ReadMessageRevisions(id string) ([]*domain.MessageRevision, error) {
+	if _, err := m.repo.ReadMessage(id); err != nil {
+		return nil, err
+	}
+	return m.repo.ReadMessageRevisions(id)
+}
+
+// RestoreMessage undoes a delete. It is meant for admins.
+func (m *MessengerService) RestoreMessage(id string) error {
+	if err := m.repo.RestoreMessage(id); err != nil {
+		return err
+	}
+	if restored, err := m.repo.ReadMessage(id); err == nil {
+		m.index(*restored)
+		m.publish(domain.MessageRestored, *restored)
+	}
+	return nil
+}
+
+// CreateConversation starts a conversation between userID and the given
+// participants. Asking for a direct conversation that already exists returns
+// the existing one rather than a duplicate.
+func (m *MessengerService) CreateConversation(userID string, conversation domain.Conversation) (*domain.Conversation, error) {
+	participants := []string{userID}
+	seen := map[string]bool{userID: true}
+	for _, id := range conversation.ParticipantIDs {
+		if id != "" && !seen[id] {
+			seen[id] = true
+			participants = append(participants, id)
+		}
+	}
+
+	switch conversation.Kind {
+	case domain.ConversationDirect:
+		if len(participants) != 2 {
+			return nil, domain.Validation("invalid_participants", "a direct conversation needs exactly one other participant")
+		}
+		existing, err := m.repo.FindDirectConversation(participants[0], participants[1])
+		if err == nil {
+			return existing, nil
+		}
+		conversation.Title = ""
+	case domain.ConversationGroup:
+		if len(participants) < 2 {
+			return nil, domain.Validation("invalid_participants", "a group conversation needs at least one other participant")
+		}
+	default:
+		return nil, domain.Validation("invalid_conversation_kind", "conversation kind must be direct or group")
+	}
+
+	conversation.ID = uuid.New().String()
+	conversation.CreatedBy = userID
+	conversation.ParticipantIDs = participants
+	return m.repo.CreateConversation(conversation)
+}
+
+func (m *MessengerService) ReadConversations(userID string) ([]*domain.Conversation, error) {
+	return m.repo.ReadConversations(userID)
+}
+
+// ReadConversationsByIDs reads those of ids userID takes part in, leaving
+// out the rest.
+func (m *MessengerService) ReadConversationsByIDs(userID string, ids []string) ([]*domain.Conversation, error) {
+	return m.repo.ReadConversationsByIDs(userID, ids)
+}
+
+func (m *MessengerService) ReadConversationMessages(userID, conversationID string, opts domain.ListOptions) (*domain.Page[*domain.Message], error) {
+	if err := m.checkParticipant(conversationID, userID); err != nil {
+		return nil, err
+	}
+	return m.repo.ReadConversationMessages(conversationID, opts)
+}
+
+// SearchMessages finds messages matching text in the conversations userID is
+// in, or only in conversationID when it is set.
+func (m *MessengerService) SearchMessages(userID, text, conversationID string, limit int) ([]*domain.SearchHit, error) {
+	if m.search == nil {
+		return nil, ErrSearchUnavailable
+	}
+	text = strings.TrimSpace(text)
+	if text == "" {
+		return nil, ErrEmptySearchQuery
+	}
+	if limit <= 0 || limit > domain.MaxPageLimit {
+		limit = domain.DefaultPageLimit
+	}
+
+	var conversationIDs []string
+	if conversationID != "" {
+		if err := m.checkParticipant(conversationID, userID); err != nil {
+			return nil, err
+		}
+		conversationIDs = []string{conversationID}
+	} else {
+		ids, err := m.repo.ReadConversationIDs(userID)
+		if err != nil {
+			return nil, err
+		}
+		conversationIDs = ids
+	}
+
+	return m.search.SearchMessages(domain.SearchQuery{
+		Text:            text,
+		ConversationIDs: conversationIDs,
+		Limit:           limit,
+	})
+}
+
+// index keeps an external search index in step. Like publish it is best
+// effort: a message missing from the index is still readable.
+func (m *MessengerService) index(message domain.Message) {
+	if m.search == nil {
+		return
+	}
+	if err := m.search.IndexMessage(message); err != nil {
+		fmt.Printf("Error indexing message %s: %v", message.ID, err)
+	}
+}
+
+// publish notifies the conversation's participants. Delivery is best effort:
+// the change is already saved, so a failure here only means clients catch up
+// on their next fetch.
+func (m *MessengerService) publish(eventType string, message domain.Message) {
+	if m.events == nil {
+		return
+	}
+	recipients, err := m.repo.ReadParticipantIDs(message.ConversationID)
+	if err != nil {
+		fmt.Printf("Error reading participants for %s event: %v", eventType, err)
+		return
+	}
+	event := domain.MessageEvent{
+		Type:    eventType,
+		Message: message,
+		SentAt:  time.Now().UTC(),
+	}
+	if err := m.events.PublishMessageEvent(event, recipients); err != nil {
+		fmt.Printf("Error publishing %s event: %v", eventType, err)
+	}
+}
+
+func (m *MessengerService) checkParticipant(conversationID, userID string) error {
+	ok, err := m.repo.IsParticipant(conversationID, userID)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return ErrNotParticipant
+	}
+	return nil
 }
@@ -0,0 +1,105 @@
+package services
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/LordMoMA/Hexagonal-Architecture/internal/core/domain"
+)
+
+var ErrMessageRejected = domain.Validation("message_rejected", "message rejected by moderation")
+
+// ErrFlagReviewed is what the repository says of a second review of a flag.
+var ErrFlagReviewed = domain.NotFound("flagged_message_not_found", "flagged message not found or already reviewed")
+
+// ReadFlaggedMessages pages through the review queue. It is meant for admins.
+func (m *MessengerService) ReadFlaggedMessages(pending bool, opts domain.ListOptions) (*domain.Page[*domain.FlaggedMessage], error) {
+	return m.repo.ReadFlaggedMessages(pending, opts)
+}
+
+// ReviewFlaggedMessage settles a flagged message: approve leaves it up,
+// remove deletes it like its author could. It is meant for admins.
+func (m *MessengerService) ReviewFlaggedMessage(reviewerID, id, decision string) (*domain.FlaggedMessage, error) {
+	if decision != domain.ReviewApprove && decision != domain.ReviewRemove {
+		return nil, domain.Validation("invalid_decision", "decision must be %q or %q", domain.ReviewApprove, domain.ReviewRemove)
+	}
+	flagged, err := m.repo.ReadFlaggedMessage(id)
+	if err != nil {
+		return nil, err
+	}
+	if flagged.ReviewedAt != nil {
+		return nil, ErrFlagReviewed
+	}
+	// the message goes before the flag is closed, so a removal that fails
+	// leaves the flag in the queue to be reviewed again
+	if decision == domain.ReviewRemove {
+		message, err := m.repo.ReadMessage(flagged.MessageID)
+		if err != nil {
+			return nil, err
+		}
+		// the author may have deleted it while it waited for review
+		if !message.Deleted {
+			if err := m.DeleteMessage(message.ID); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return m.repo.ReviewFlaggedMessage(id, reviewerID, decision)
+}
+
+// moderate runs the moderator over a message about to be saved and returns
+// why it should be reviewed, if it should.
+func (m *MessengerService) moderate(message domain.Message) ([]string, error) {
+	if m.moderator == nil {
+		return nil, nil
+	}
+	verdict, err := m.moderator.Moderate(message)
+	if err != nil {
+		return nil, domain.Unavailable("moderation_unavailable", "message not moderated: %v", err)
+	}
+	return applyVerdict(verdict)
+}
+
+// moderateBatch runs the moderator once over the messages of a batch that
+// have no error in errs yet, so the batch counts as one post. It sets why
+// each should be reviewed, or its error when it may not be posted.
+func (m *MessengerService) moderateBatch(messages []domain.Message, errs []error) {
+	if m.moderator == nil {
+		return
+	}
+	var pending []int
+	var batch []domain.Message
+	for i := range messages {
+		if errs[i] == nil {
+			pending = append(pending, i)
+			batch = append(batch, messages[i])
+		}
+	}
+	if len(batch) == 0 {
+		return
+	}
+	verdicts, err := m.moderator.ModerateBatch(batch)
+	if err == nil && len(verdicts) != len(batch) {
+		err = fmt.Errorf("%d verdicts for %d messages", len(verdicts), len(batch))
+	}
+	for j, i := range pending {
+		if err != nil {
+			errs[i] = domain.Unavailable("moderation_unavailable", "message not moderated: %v", err)
+			continue
+		}
+		messages[i].FlaggedFor, errs[i] = applyVerdict(verdicts[j])
+	}
+}
+
+func applyVerdict(verdict domain.ModerationVerdict) ([]string, error) {
+	switch verdict.Action {
+	case domain.ModerationAllow:
+		return nil, nil
+	case domain.ModerationFlag:
+		return verdict.Reasons, nil
+	case domain.ModerationReject:
+		return nil, fmt.Errorf("%w: %s", ErrMessageRejected, strings.Join(verdict.Reasons, ", "))
+	default:
+		return nil, domain.Unavailable("moderation_unavailable", "message not moderated: unknown action %q", verdict.Action)
+	}
+}
@@ -19,6 +19,11 @@ func (p *PaymentService) CreateCheckoutSession(userID string, payment domain.Pay
 	return p.repo.CreateCheckoutSession(userID, payment)
 }
 
+// ReadOrders reads the orders of each of userIDs, newest first.
+func (p *PaymentService) ReadOrders(userIDs []string) ([]*domain.OrderInfo, error) {
+	return p.repo.ReadOrders(userIDs)
+}
+
 // func (p *PaymentService) ProcessPaymentWithStripe(userID string, payment domain.Payment) error {
 // 	return p.repo.ProcessPaymentWithStripe(userID, payment)
 // }
@@ -0,0 +1,79 @@
+package services
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/LordMoMA/Hexagonal-Architecture/internal/core/domain"
+)
+
+// retentionBatchSize keeps each purge transaction, and the row locks it
+// holds, short enough not to get in the way of people posting.
+const retentionBatchSize = 500
+
+// SetConversationRetention overrides how long a conversation keeps its
+// messages. nil goes back to the default and 0 keeps them forever. It is
+// meant for admins.
+func (m *MessengerService) SetConversationRetention(conversationID string, days *int) error {
+	if days != nil && (*days < 0 || *days > domain.MaxRetentionDays) {
+		return domain.Validation("invalid_retention_days", "retention_days must be between 0 and %d", domain.MaxRetentionDays)
+	}
+	return m.repo.SetConversationRetention(conversationID, days)
+}
+
+// PurgeExpiredMessages deletes messages older than their conversation's
+// retention, or defaultDays where it has none, a batch at a time. A
+// defaultDays of 0 keeps messages without an override forever. It returns
+// how many messages were purged, including those of batches before an error.
+func (m *MessengerService) PurgeExpiredMessages(defaultDays int) (int, error) {
+	if defaultDays < 0 {
+		return 0, domain.Validation("invalid_retention", "default retention must not be negative")
+	}
+	purged := 0
+	for {
+		purges, keys, err := m.repo.PurgeExpiredMessages(defaultDays, retentionBatchSize)
+		if err != nil {
+			return purged, err
+		}
+		n := 0
+		for _, purge := range purges {
+			n += purge.MessageCount
+			m.unindex(purge)
+		}
+		m.deleteBlobKeys(keys)
+		purged += n
+		if n < retentionBatchSize {
+			return purged, nil
+		}
+	}
+}
+
+// ReadRetentionPurges pages through what retention has deleted, most recent
+// first. It is meant for admins.
+func (m *MessengerService) ReadRetentionPurges(opts domain.ListOptions) (*domain.Page[*domain.RetentionPurge], error) {
+	return m.repo.ReadRetentionPurges(opts)
+}
+
+func (m *MessengerService) unindex(purge *domain.RetentionPurge) {
+	if m.search == nil {
+		return
+	}
+	for _, id := range strings.Fields(purge.MessageIDs) {
+		if err := m.search.RemoveMessage(id); err != nil {
+			fmt.Printf("Error removing message %s from search index: %v", id, err)
+		}
+	}
+}
+
+// deleteBlobKeys removes the files of purged attachments. A file left behind
+// is picked up by CleanupOrphanedBlobs later.
+func (m *MessengerService) deleteBlobKeys(keys []string) {
+	if m.blobs == nil {
+		return
+	}
+	for _, key := range keys {
+		if err := m.blobs.Delete(key); err != nil {
+			fmt.Printf("Error deleting blob %s: %v", key, err)
+		}
+	}
+}
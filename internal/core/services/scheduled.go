@@ -0,0 +1,179 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/LordMoMA/Hexagonal-Architecture/internal/core/domain"
+	"github.com/google/uuid"
+)
+
+var ErrScheduledAttachments = domain.Validation("scheduled_attachments", "messages with attachments can't be scheduled")
+
+// schedulerBatchSize bounds how many messages one scheduler pass reads at a
+// time; a pass keeps going until it has caught up.
+const schedulerBatchSize = 100
+
+// ScheduleMessage saves message to be delivered at its SendAt. It is checked
+// and moderated now, so a message that would be refused is refused up front,
+// and checked again on delivery in case its author has left or its parent
+// is gone by then.
+func (m *MessengerService) ScheduleMessage(userID string, message domain.Message) (*domain.ScheduledMessage, error) {
+	now := time.Now().UTC()
+	if message.SendAt == nil || !message.SendAt.After(now) {
+		return nil, domain.Validation("invalid_send_at", "send_at must be in the future")
+	}
+	if err := checkExpiry(message.ExpiresAt, *message.SendAt); err != nil {
+		return nil, err
+	}
+	if err := m.prepareMessage(userID, &message); err != nil {
+		return nil, err
+	}
+	message.ID = uuid.New().String()
+	message.UserID = userID
+	message.CreatedAt = now
+
+	flaggedFor, err := m.moderate(message)
+	if err != nil {
+		return nil, err
+	}
+
+	scheduled := domain.ScheduledMessage{
+		ID:             message.ID,
+		ConversationID: message.ConversationID,
+		UserID:         userID,
+		ParentID:       message.ParentID,
+		Body:           message.Body,
+		SendAt:         message.SendAt.UTC(),
+		ExpiresAt:      message.ExpiresAt,
+		FlagReasons:    strings.Join(flaggedFor, "; "),
+		CreatedAt:      now,
+	}
+	if err := m.repo.CreateScheduledMessage(scheduled); err != nil {
+		return nil, err
+	}
+	return &scheduled, nil
+}
+
+func (m *MessengerService) ReadScheduledMessages(userID string, opts domain.ListOptions) (*domain.Page[*domain.ScheduledMessage], error) {
+	return m.repo.ReadScheduledMessages(userID, opts)
+}
+
+// CancelScheduledMessage drops one of userID's messages that is yet to be
+// delivered.
+func (m *MessengerService) CancelScheduledMessage(userID, id string) error {
+	return m.repo.DeleteScheduledMessage(userID, id)
+}
+
+// DeliverScheduledMessages posts every scheduled message due by now and
+// returns how many it posted. Messages that can no longer be posted, or
+// have expired while waiting, are dropped. An outage, such as the database
+// being unreachable, stops the pass instead, leaving what is left for the
+// next one.
+func (m *MessengerService) DeliverScheduledMessages(now time.Time) (int, error) {
+	delivered := 0
+	for {
+		due, err := m.repo.ReadDueScheduledMessages(now, schedulerBatchSize)
+		if err != nil {
+			return delivered, err
+		}
+		for _, scheduled := range due {
+			ok, err := m.deliver(scheduled, now)
+			if err != nil {
+				return delivered, err
+			}
+			if ok {
+				delivered++
+			}
+		}
+		if len(due) < schedulerBatchSize {
+			return delivered, nil
+		}
+	}
+}
+
+func (m *MessengerService) deliver(scheduled *domain.ScheduledMessage, now time.Time) (bool, error) {
+	message := domain.Message{
+		ID:             scheduled.ID,
+		ConversationID: scheduled.ConversationID,
+		UserID:         scheduled.UserID,
+		ParentID:       scheduled.ParentID,
+		Body:           scheduled.Body,
+		CreatedAt:      now.UTC(),
+		ExpiresAt:      scheduled.ExpiresAt,
+	}
+	if scheduled.FlagReasons != "" {
+		message.FlaggedFor = strings.Split(scheduled.FlagReasons, "; ")
+	}
+
+	reason := checkExpiry(message.ExpiresAt, message.CreatedAt)
+	if reason == nil {
+		reason = m.prepareMessage(scheduled.UserID, &message)
+	}
+	if reason != nil && !undeliverable(reason) {
+		return false, reason
+	}
+	if reason != nil {
+		fmt.Printf("Error delivering scheduled message %s, dropping it: %v", scheduled.ID, reason)
+		err := m.repo.DeleteScheduledMessage("", scheduled.ID)
+		if err != nil && !errors.Is(err, domain.ErrScheduledMessageGone) {
+			return false, err
+		}
+		return false, nil
+	}
+
+	err := m.repo.DeliverScheduledMessage(scheduled.ID, message)
+	if errors.Is(err, domain.ErrScheduledMessageGone) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	m.index(message)
+	m.publish(domain.MessageCreated, message)
+	return true, nil
+}
+
+// undeliverable tells the failures that would recur on every retry, such as
+// the author having left the conversation or the parent being deleted, from
+// those worth waiting out.
+func undeliverable(err error) bool {
+	for _, kind := range []error{domain.ErrForbidden, domain.ErrNotFound, domain.ErrValidation, domain.ErrConflict} {
+		if errors.Is(err, kind) {
+			return true
+		}
+	}
+	return false
+}
+
+// ExpireMessages deletes the messages whose expires_at has passed by now,
+// leaving tombstones as if their authors had deleted them, and returns how
+// many it deleted.
+func (m *MessengerService) ExpireMessages(now time.Time) (int, error) {
+	expired := 0
+	for {
+		ids, err := m.repo.ReadExpiredMessageIDs(now, schedulerBatchSize)
+		if err != nil {
+			return expired, err
+		}
+		for _, id := range ids {
+			if err := m.DeleteMessage(id); err != nil {
+				return expired, err
+			}
+			expired++
+		}
+		if len(ids) < schedulerBatchSize {
+			return expired, nil
+		}
+	}
+}
+
+// checkExpiry makes sure a message doesn't expire before it is sent.
+func checkExpiry(expiresAt *time.Time, sentAt time.Time) error {
+	if expiresAt != nil && !expiresAt.After(sentAt) {
+		return domain.Validation("invalid_expires_at", "expires_at must be after the message is sent")
+	}
+	return nil
+}
@@ -1,6 +1,8 @@
 package services
 
 import (
+	"time"
+
 	"github.com/LordMoMA/Hexagonal-Architecture/internal/adapters/repository"
 	"github.com/LordMoMA/Hexagonal-Architecture/internal/core/domain"
 	"github.com/LordMoMA/Hexagonal-Architecture/internal/core/ports"
@@ -20,12 +22,37 @@ func (u *UserService) CreateUser(email, password string) (*domain.User, error) {
 	return u.repo.CreateUser(email, password)
 }
 
+// CreateUsers signs up users. Atomically, they are all created or, when any
+// fails, none are; otherwise each is created on its own. The errors line up
+// with users, nil for those created, as do the users created.
+func (u *UserService) CreateUsers(users []domain.NewUser, atomic bool) ([]*domain.User, []error) {
+	if !atomic {
+		created := make([]*domain.User, len(users))
+		errs := make([]error, len(users))
+		for i, nu := range users {
+			created[i], errs[i] = u.repo.CreateUser(nu.Email, nu.Password)
+		}
+		return created, errs
+	}
+
+	created, err := u.repo.CreateUsers(users)
+	if err != nil {
+		return make([]*domain.User, len(users)), itemErrors(err, len(users))
+	}
+	return created, make([]error, len(users))
+}
+
 func (u *UserService) ReadUser(id string) (*domain.User, error) {
 	return u.repo.ReadUser(id)
 }
 
-func (u *UserService) ReadUsers() ([]*domain.User, error) {
-	return u.repo.ReadUsers()
+func (u *UserService) ReadUsers(opts domain.ListOptions) (*domain.Page[*domain.User], error) {
+	return u.repo.ReadUsers(opts)
+}
+
+// ReadUsersByIDs reads many users at once, leaving out ids with no user.
+func (u *UserService) ReadUsersByIDs(ids []string) ([]*domain.User, error) {
+	return u.repo.ReadUsersByIDs(ids)
 }
 
 func (u *UserService) UpdateUser(id, email, password string) error {
@@ -36,10 +63,58 @@ func (u *UserService) DeleteUser(id string) error {
 	return u.repo.DeleteUser(id)
 }
 
-func (u *UserService) LoginUser(email, password string) (*repository.LoginResponse, error) {
-	return u.repo.LoginUser(email, password)
+func (u *UserService) LoginUser(email, password string, client domain.ClientInfo) (*repository.LoginResponse, error) {
+	return u.repo.LoginUser(email, password, client)
+}
+
+func (u *UserService) LoginUserMFA(mfaToken, code string, client domain.ClientInfo) (*repository.LoginResponse, error) {
+	return u.repo.LoginUserMFA(mfaToken, code, client)
+}
+
+func (u *UserService) RefreshSession(refreshToken string, client domain.ClientInfo) (*repository.LoginResponse, error) {
+	return u.repo.RefreshSession(refreshToken, client)
+}
+
+func (u *UserService) ListSessions(userID string) ([]*domain.Session, error) {
+	return u.repo.ListSessions(userID)
+}
+
+func (u *UserService) RevokeSession(userID, id string) error {
+	return u.repo.RevokeSession(userID, id)
+}
+
+func (u *UserService) EnrollTOTP(id string) (*repository.TOTPEnrollment, error) {
+	return u.repo.EnrollTOTP(id)
+}
+
+func (u *UserService) ActivateTOTP(id, code string) error {
+	return u.repo.ActivateTOTP(id, code)
 }
 
 func (u *UserService) UpdateMembershipStatus(id string, status bool) error {
 	return u.repo.UpdateMembershipStatus(id, status)
 }
+
+func (u *UserService) UpdateAdminStatus(id string, admin bool) error {
+	return u.repo.UpdateAdminStatus(id, admin)
+}
+
+func (u *UserService) UnlockUser(id string) error {
+	return u.repo.UnlockUser(id)
+}
+
+func (u *UserService) CreateAPIKey(userID, name string, scopes []string, expiresAt *time.Time) (*domain.APIKey, string, error) {
+	return u.repo.CreateAPIKey(userID, name, scopes, expiresAt)
+}
+
+func (u *UserService) ListAPIKeys(userID string) ([]*domain.APIKey, error) {
+	return u.repo.ListAPIKeys(userID)
+}
+
+func (u *UserService) RevokeAPIKey(userID, id string) error {
+	return u.repo.RevokeAPIKey(userID, id)
+}
+
+func (u *UserService) AuthenticateAPIKey(key string) (*domain.APIKey, error) {
+	return u.repo.AuthenticateAPIKey(key)
+}
@@ -4,7 +4,188 @@ CREATE TABLE users (
     id         UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
     email      VARCHAR(255) NOT NULL UNIQUE,
     password   VARCHAR(255) NOT NULL,
-    membership  BOOLEAN NOT NULL
+    membership  BOOLEAN NOT NULL,
+    admin       BOOLEAN NOT NULL DEFAULT FALSE,
+    totp_secret VARCHAR(64) NOT NULL DEFAULT '',
+    totp_enabled BOOLEAN NOT NULL DEFAULT FALSE,
+    totp_last_step BIGINT NOT NULL DEFAULT 0
 );
 
-ALTER TABLE users OWNER TO test;
\ No newline at end of file
+ALTER TABLE users OWNER TO test;
+
+CREATE TABLE recovery_codes (
+    id         UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    user_id    UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    code_hash  VARCHAR(64) NOT NULL,
+    used       BOOLEAN NOT NULL DEFAULT FALSE
+);
+
+CREATE INDEX recovery_codes_user_id_idx ON recovery_codes (user_id);
+
+ALTER TABLE recovery_codes OWNER TO test;
+
+CREATE TABLE api_keys (
+    id           UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    user_id      UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    name         VARCHAR(255) NOT NULL,
+    prefix       VARCHAR(16) NOT NULL UNIQUE,
+    key_hash     VARCHAR(64) NOT NULL,
+    scopes       TEXT NOT NULL,
+    created_at   TIMESTAMPTZ NOT NULL DEFAULT now(),
+    expires_at   TIMESTAMPTZ,
+    last_used_at TIMESTAMPTZ,
+    revoked_at   TIMESTAMPTZ
+);
+
+CREATE INDEX api_keys_user_id_idx ON api_keys (user_id);
+
+ALTER TABLE api_keys OWNER TO test;
+
+CREATE TABLE user_identities (
+    id         UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    user_id    UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    issuer     VARCHAR(255) NOT NULL,
+    subject    VARCHAR(255) NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    UNIQUE (issuer, subject)
+);
+
+ALTER TABLE user_identities OWNER TO test;
+
+CREATE TABLE sessions (
+    id               UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    user_id          UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    user_agent       TEXT NOT NULL DEFAULT '',
+    ip               VARCHAR(64) NOT NULL DEFAULT '',
+    refresh_token_id UUID NOT NULL,
+    created_at       TIMESTAMPTZ NOT NULL DEFAULT now(),
+    last_seen_at     TIMESTAMPTZ NOT NULL DEFAULT now(),
+    revoked_at       TIMESTAMPTZ
+);
+
+CREATE INDEX sessions_user_id_idx ON sessions (user_id);
+
+ALTER TABLE sessions OWNER TO test;
+
+CREATE TABLE conversations (
+    id         UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    kind       VARCHAR(16) NOT NULL,
+    title      VARCHAR(255) NOT NULL DEFAULT '',
+    created_by UUID NOT NULL REFERENCES users(id),
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    retention_days INT
+);
+
+ALTER TABLE conversations OWNER TO test;
+
+CREATE TABLE conversation_participants (
+    conversation_id UUID NOT NULL REFERENCES conversations(id) ON DELETE CASCADE,
+    user_id         UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    joined_at       TIMESTAMPTZ NOT NULL DEFAULT now(),
+    last_read_at    TIMESTAMPTZ,
+    PRIMARY KEY (conversation_id, user_id)
+);
+
+CREATE INDEX conversation_participants_user_id_idx ON conversation_participants (user_id);
+
+ALTER TABLE conversation_participants OWNER TO test;
+
+CREATE TABLE message_revisions (
+    id         UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    message_id UUID NOT NULL,
+    kind       VARCHAR(16) NOT NULL,
+    body       TEXT NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX message_revisions_message_id_idx ON message_revisions (message_id);
+
+ALTER TABLE message_revisions OWNER TO test;
+
+CREATE TABLE reactions (
+    message_id UUID NOT NULL,
+    user_id    UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    emoji      VARCHAR(64) NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    PRIMARY KEY (message_id, user_id, emoji)
+);
+
+ALTER TABLE reactions OWNER TO test;
+
+CREATE TABLE attachments (
+    id           UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    message_id   UUID NOT NULL,
+    user_id      UUID NOT NULL REFERENCES users(id),
+    filename     VARCHAR(255) NOT NULL,
+    content_type VARCHAR(255) NOT NULL,
+    size         BIGINT NOT NULL,
+    blob_key     VARCHAR(255) NOT NULL UNIQUE,
+    created_at   TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX attachments_message_id_idx ON attachments (message_id);
+
+ALTER TABLE attachments OWNER TO test;
+
+CREATE TABLE flagged_messages (
+    id          UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    message_id  UUID NOT NULL,
+    user_id     UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    reasons     TEXT NOT NULL,
+    created_at  TIMESTAMPTZ NOT NULL DEFAULT now(),
+    reviewed_at TIMESTAMPTZ,
+    reviewed_by UUID REFERENCES users(id),
+    decision    VARCHAR(16)
+);
+
+CREATE INDEX flagged_messages_message_id_idx ON flagged_messages (message_id);
+
+ALTER TABLE flagged_messages OWNER TO test;
+
+CREATE TABLE retention_purges (
+    id              UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    conversation_id UUID NOT NULL,
+    retention_days  INT NOT NULL,
+    message_count   INT NOT NULL,
+    oldest_at       TIMESTAMPTZ NOT NULL,
+    newest_at       TIMESTAMPTZ NOT NULL,
+    message_ids     TEXT NOT NULL,
+    purged_at       TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX retention_purges_conversation_id_idx ON retention_purges (conversation_id);
+
+ALTER TABLE retention_purges OWNER TO test;
+
+CREATE TABLE scheduled_messages (
+    id              UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    conversation_id UUID NOT NULL REFERENCES conversations(id) ON DELETE CASCADE,
+    user_id         UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    parent_id       VARCHAR(36) NOT NULL DEFAULT '',
+    body            TEXT NOT NULL,
+    send_at         TIMESTAMPTZ NOT NULL,
+    expires_at      TIMESTAMPTZ,
+    flag_reasons    TEXT NOT NULL DEFAULT '',
+    created_at      TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX scheduled_messages_send_at_idx ON scheduled_messages (send_at);
+CREATE INDEX scheduled_messages_user_id_idx ON scheduled_messages (user_id);
+
+ALTER TABLE scheduled_messages OWNER TO test;
+
+CREATE TABLE order_infos (
+    id              VARCHAR(36) PRIMARY KEY,
+    order_id        VARCHAR(255) NOT NULL DEFAULT '',
+    user_id         UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    checkout_id     VARCHAR(255) NOT NULL DEFAULT '',
+    seller_account  VARCHAR(255) NOT NULL DEFAULT '',
+    amount          VARCHAR(32) NOT NULL,
+    currency        VARCHAR(3) NOT NULL,
+    status          VARCHAR(32) NOT NULL DEFAULT 'pending',
+    created_at      TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX order_infos_user_id_idx ON order_infos (user_id);
+
+ALTER TABLE order_infos OWNER TO test;
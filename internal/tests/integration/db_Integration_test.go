@@ -8,6 +8,7 @@ import (
 
 	"github.com/LordMoMA/Hexagonal-Architecture/internal/adapters/cache"
 	"github.com/LordMoMA/Hexagonal-Architecture/internal/adapters/repository"
+	"github.com/LordMoMA/Hexagonal-Architecture/internal/core/domain"
 	"github.com/jinzhu/gorm"
 	"github.com/sirupsen/logrus"
 	"golang.org/x/crypto/bcrypt"
@@ -58,15 +59,15 @@ func TestDBIntegration(t *testing.T) {
 	}
 
 	// test reading all users
-	users, err := store.ReadUsers()
+	users, err := store.ReadUsers(domain.ListOptions{})
 	if err != nil {
 		t.Fatalf("failed to read users: %v", err)
 	}
-	if len(users) != 1 {
-		t.Errorf("expected 1 user, got %d", len(users))
+	if len(users.Items) != 1 {
+		t.Errorf("expected 1 user, got %d", len(users.Items))
 	}
-	if users[0].Email != email {
-		t.Errorf("expected email %q, got %q", email, users[0].Email)
+	if users.Items[0].Email != email {
+		t.Errorf("expected email %q, got %q", email, users.Items[0].Email)
 	}
 
 	// test updating a user
@@ -0,0 +1,129 @@
+package unit
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/LordMoMA/Hexagonal-Architecture/internal/adapters/handler"
+	"github.com/LordMoMA/Hexagonal-Architecture/internal/adapters/repository"
+	"github.com/LordMoMA/Hexagonal-Architecture/internal/core/domain"
+	"github.com/LordMoMA/Hexagonal-Architecture/internal/core/ports"
+	"github.com/LordMoMA/Hexagonal-Architecture/internal/core/services"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// scopedKeyRepo accepts any well-formed key as one holding scopes.
+type scopedKeyRepo struct {
+	ports.UserRepository
+	scopes []string
+}
+
+func (r *scopedKeyRepo) AuthenticateAPIKey(key string) (*domain.APIKey, error) {
+	return &domain.APIKey{ID: "k1", UserID: "u1", Scopes: strings.Join(r.scopes, " ")}, nil
+}
+
+type scopedRoute struct {
+	method, route, path string
+	scope               string
+}
+
+// scopedRouter serves routes behind APIKeyAuth, answering 204 to whatever
+// gets through.
+func scopedRouter(repo ports.UserRepository, routes []scopedRoute) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	v := router.Group("/v1", handler.APIKeyAuth(*services.NewUserService(repo)))
+	for _, r := range routes {
+		v.Handle(r.method, r.route, func(ctx *gin.Context) { ctx.Status(http.StatusNoContent) })
+	}
+	return router
+}
+
+func callWithKey(router *gin.Engine, method, path, key string) int {
+	req := httptest.NewRequest(method, path, nil)
+	req.Header.Set("Authorization", "ApiKey "+key)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w.Code
+}
+
+func TestAPIKeyNeedsTheRouteScope(t *testing.T) {
+	routes := []scopedRoute{
+		{http.MethodGet, "/messages/:id", "/v1/messages/m1", "messages:read"},
+		{http.MethodHead, "/messages/:id", "/v1/messages/m1", "messages:read"},
+		{http.MethodPost, "/messages", "/v1/messages", "messages:write"},
+		{http.MethodPut, "/users/:id", "/v1/users/u1", "users:write"},
+		{http.MethodPatch, "/users/:id", "/v1/users/u1", "users:write"},
+		{http.MethodDelete, "/conversations/:id", "/v1/conversations/c1", "conversations:write"},
+		{http.MethodPost, "/users:batch", "/v1/users:batch", "users:write"},
+		{http.MethodGet, "/conversations/:id/messages", "/v1/conversations/c1/messages", "conversations:read"},
+		// queries only read, though they are posted
+		{http.MethodPost, "/graphql", "/v1/graphql", "graphql:read"},
+		// too short to name a resource, so no key is enough
+		{http.MethodGet, "", "/v1", ""},
+	}
+	repo := &scopedKeyRepo{}
+	router := scopedRouter(repo, routes)
+
+	for _, r := range routes {
+		name := r.method + " " + r.path
+		var others []string
+		for _, scope := range domain.APIKeyScopes {
+			if scope != r.scope {
+				others = append(others, scope)
+			}
+		}
+
+		repo.scopes = others
+		assert.Equal(t, http.StatusForbidden, callWithKey(router, r.method, r.path, "hex_abcd1234_secret"), name)
+
+		if r.scope != "" {
+			repo.scopes = []string{r.scope}
+			assert.Equal(t, http.StatusNoContent, callWithKey(router, r.method, r.path, "hex_abcd1234_secret"), name)
+		}
+	}
+}
+
+func TestMalformedAPIKeysAreUnauthorized(t *testing.T) {
+	// splitAPIKey turns these away before the database is asked
+	router := scopedRouter(repository.NewDB(nil, nil), []scopedRoute{
+		{http.MethodGet, "/messages/:id", "/v1/messages/m1", "messages:read"},
+	})
+
+	for _, key := range []string{"hex_", "hex_abcd1234", "hex_abcd1234_", "hex__secret"} {
+		assert.Equal(t, http.StatusUnauthorized, callWithKey(router, http.MethodGet, "/v1/messages/m1", key), key)
+	}
+}
+
+func TestExpiredAndRevokedAPIKeysAreUnauthorized(t *testing.T) {
+	store, db := testStore(t)
+	user, err := store.CreateUser(testEmail("apikey"), "correct-horse-battery")
+	require.NoError(t, err)
+	router := scopedRouter(store, []scopedRoute{
+		{http.MethodGet, "/messages/:id", "/v1/messages/m1", "messages:read"},
+	})
+	newKey := func() (*domain.APIKey, string) {
+		expiresAt := time.Now().Add(time.Hour)
+		key, plaintext, err := store.CreateAPIKey(user.ID, "test", []string{"messages:read"}, &expiresAt)
+		require.NoError(t, err)
+		assert.Equal(t, http.StatusNoContent, callWithKey(router, http.MethodGet, "/v1/messages/m1", plaintext))
+		return key, plaintext
+	}
+
+	key, expired := newKey()
+	require.NoError(t, db.Model(&domain.APIKey{}).Where("id = ?", key.ID).Update("expires_at", time.Now().Add(-time.Minute)).Error)
+	assert.Equal(t, http.StatusUnauthorized, callWithKey(router, http.MethodGet, "/v1/messages/m1", expired))
+
+	key, revoked := newKey()
+	require.NoError(t, store.RevokeAPIKey(user.ID, key.ID))
+	assert.Equal(t, http.StatusUnauthorized, callWithKey(router, http.MethodGet, "/v1/messages/m1", revoked))
+
+	// the right prefix with the wrong secret
+	_, valid := newKey()
+	assert.Equal(t, http.StatusUnauthorized, callWithKey(router, http.MethodGet, "/v1/messages/m1", valid+"0"))
+}
@@ -0,0 +1,352 @@
+package unit
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/LordMoMA/Hexagonal-Architecture/internal/adapters/handler"
+	"github.com/LordMoMA/Hexagonal-Architecture/internal/adapters/realtime"
+	"github.com/LordMoMA/Hexagonal-Architecture/internal/core/domain"
+	"github.com/LordMoMA/Hexagonal-Architecture/internal/core/ports"
+	"github.com/LordMoMA/Hexagonal-Architecture/internal/core/services"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// batchMessageRepo lets u1 post in c1 only, and fails to save any message
+// whose body is "unsaveable".
+type batchMessageRepo struct {
+	ports.MessengerRepository
+	saved        []domain.Message
+	transactions int
+}
+
+func (r *batchMessageRepo) IsParticipant(conversationID, userID string) (bool, error) {
+	return conversationID == "c1" && userID == "u1", nil
+}
+
+func (r *batchMessageRepo) CreateMessage(userID string, message domain.Message) error {
+	if message.Body == "unsaveable" {
+		return domain.Unavailable("database_unavailable", "message not saved")
+	}
+	r.saved = append(r.saved, message)
+	return nil
+}
+
+func (r *batchMessageRepo) CreateMessages(userID string, messages []domain.Message) error {
+	r.transactions++
+	for i, message := range messages {
+		if message.Body == "unsaveable" {
+			return &domain.ItemError{Index: i, Err: domain.Unavailable("database_unavailable", "message not saved")}
+		}
+	}
+	r.saved = append(r.saved, messages...)
+	return nil
+}
+
+// batchUserRepo signs users up unless their email is taken, by a user
+// already there or by an earlier one of the same batch.
+type batchUserRepo struct {
+	ports.UserRepository
+	users map[string]*domain.User
+}
+
+func (r *batchUserRepo) emailTaken(email string) bool {
+	for _, u := range r.users {
+		if u.Email == email {
+			return true
+		}
+	}
+	return false
+}
+
+func (r *batchUserRepo) CreateUser(email, password string) (*domain.User, error) {
+	if r.emailTaken(email) {
+		return nil, domain.Conflict("user_exists", "user already exists")
+	}
+	user := &domain.User{ID: "new-" + email, Email: email}
+	r.users[user.ID] = user
+	return user, nil
+}
+
+func (r *batchUserRepo) ReadUser(id string) (*domain.User, error) {
+	if u, ok := r.users[id]; ok {
+		return u, nil
+	}
+	return nil, domain.NotFound("user_not_found", "user not found")
+}
+
+func (r *batchUserRepo) CreateUsers(users []domain.NewUser) ([]*domain.User, error) {
+	created := make([]*domain.User, len(users))
+	seen := map[string]bool{}
+	for i, nu := range users {
+		if r.emailTaken(nu.Email) || seen[nu.Email] {
+			return nil, &domain.ItemError{Index: i, Err: domain.Conflict("user_exists", "user already exists")}
+		}
+		seen[nu.Email] = true
+		created[i] = &domain.User{ID: "new-" + nu.Email, Email: nu.Email}
+	}
+	for _, u := range created {
+		r.users[u.ID] = u
+	}
+	return created, nil
+}
+
+func batchRouter(messages *batchMessageRepo, users *batchUserRepo) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	handler.RegisterRoutes(router, handler.Services{
+		Messages: services.NewMessengerService(messages, nil, nil, nil, nil),
+		Users:    services.NewUserService(users),
+		Payments: services.NewPaymentService(nil),
+		Hub:      realtime.NewHub(nil),
+	})
+	return router
+}
+
+func postBatch(t *testing.T, router *gin.Engine, path, userID string, body interface{}) *httptest.ResponseRecorder {
+	payload, err := json.Marshal(body)
+	require.NoError(t, err)
+	req := httptest.NewRequest(http.MethodPost, path, bytes.NewReader(payload))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+accessToken(t, userID))
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w
+}
+
+func batchResults(t *testing.T, w *httptest.ResponseRecorder) handler.BatchResponse {
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var response handler.BatchResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	return response
+}
+
+func resultStatuses(response handler.BatchResponse) []int {
+	statuses := make([]int, len(response.Results))
+	for i, result := range response.Results {
+		statuses[i] = result.Status
+	}
+	return statuses
+}
+
+func TestMessageBatchReportsEachItem(t *testing.T) {
+	withAPIConfig(t)
+	repo := &batchMessageRepo{}
+	router := batchRouter(repo, nil)
+
+	w := postBatch(t, router, "/v2/messages:batch", "u1", map[string]interface{}{
+		"items": []map[string]interface{}{
+			{"conversation_id": "c1", "body": "hello"},
+			{"conversation_id": "c1"},
+			{"conversation_id": "c2", "body": "not mine"},
+			{"conversation_id": "c1", "body": "later", "send_at": "2030-01-01T00:00:00Z"},
+			{"conversation_id": "c1", "body": "unsaveable"},
+		},
+	})
+	response := batchResults(t, w)
+
+	assert.Equal(t, []int{
+		http.StatusCreated,
+		http.StatusUnprocessableEntity,
+		http.StatusForbidden,
+		http.StatusUnprocessableEntity,
+		http.StatusServiceUnavailable,
+	}, resultStatuses(response))
+	assert.Equal(t, 1, response.Succeeded)
+	assert.Equal(t, 4, response.Failed)
+	assert.NotEmpty(t, response.Results[0].ID)
+	assert.Equal(t, "invalid_request", response.Results[1].Error.Code)
+	assert.Equal(t, "not_participant", response.Results[2].Error.Code)
+	assert.Equal(t, "scheduled_batch", response.Results[3].Error.Code)
+
+	require.Len(t, repo.saved, 1)
+	assert.Equal(t, response.Results[0].ID, repo.saved[0].ID)
+	assert.Zero(t, repo.transactions)
+}
+
+func TestAtomicMessageBatchSavesAllOrNothing(t *testing.T) {
+	withAPIConfig(t)
+	repo := &batchMessageRepo{}
+	router := batchRouter(repo, nil)
+
+	// an item the handler rejects keeps the rest from being saved
+	response := batchResults(t, postBatch(t, router, "/v2/messages:batch", "u1", map[string]interface{}{
+		"atomic": true,
+		"items":  []map[string]interface{}{{"conversation_id": "c1", "body": "hello"}, {"conversation_id": "c1"}},
+	}))
+	assert.Equal(t, []int{http.StatusConflict, http.StatusUnprocessableEntity}, resultStatuses(response))
+	assert.Equal(t, "batch_aborted", response.Results[0].Error.Code)
+
+	// so does one the service rejects, or the transaction fails on
+	for _, failing := range []map[string]interface{}{
+		{"conversation_id": "c2", "body": "not mine"},
+		{"conversation_id": "c1", "body": "unsaveable"},
+	} {
+		response = batchResults(t, postBatch(t, router, "/v2/messages:batch", "u1", map[string]interface{}{
+			"atomic": true,
+			"items":  []map[string]interface{}{{"conversation_id": "c1", "body": "hello"}, failing},
+		}))
+		assert.Equal(t, 0, response.Succeeded)
+		assert.Equal(t, "batch_aborted", response.Results[0].Error.Code)
+		assert.Empty(t, response.Results[0].ID)
+	}
+	assert.Empty(t, repo.saved)
+	assert.Equal(t, 1, repo.transactions)
+
+	response = batchResults(t, postBatch(t, router, "/v1/messages:batch", "u1", map[string]interface{}{
+		"atomic": true,
+		"items":  []map[string]interface{}{{"conversation_id": "c1", "body": "one"}, {"conversation_id": "c1", "body": "two"}},
+	}))
+	assert.Equal(t, []int{http.StatusCreated, http.StatusCreated}, resultStatuses(response))
+	assert.Equal(t, 2, repo.transactions)
+	require.Len(t, repo.saved, 2)
+	assert.Equal(t, response.Results[1].ID, repo.saved[1].ID)
+}
+
+func TestBatchRequestsAreBounded(t *testing.T) {
+	withAPIConfig(t)
+	router := batchRouter(&batchMessageRepo{}, nil)
+
+	w := postBatch(t, router, "/v2/messages:batch", "u1", map[string]interface{}{"items": []interface{}{}})
+	assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+
+	items := make([]map[string]interface{}, domain.MaxBatchSize+1)
+	for i := range items {
+		items[i] = map[string]interface{}{"conversation_id": "c1", "body": "hi"}
+	}
+	w = postBatch(t, router, "/v2/messages:batch", "u1", map[string]interface{}{"items": items})
+	assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+
+	// signing users up is slower, so fewer of them fit in a batch
+	users := &batchUserRepo{users: map[string]*domain.User{"admin": {ID: "admin", Admin: true}}}
+	router = batchRouter(&batchMessageRepo{}, users)
+	newUsers := make([]map[string]interface{}, domain.MaxUserBatchSize+1)
+	for i := range newUsers {
+		newUsers[i] = map[string]interface{}{"email": fmt.Sprintf("user%d@example.com", i), "password": "s3cret-password1"}
+	}
+	w = postBatch(t, router, "/v2/users:batch", "admin", map[string]interface{}{"items": newUsers})
+	assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+	var problem handler.Problem
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &problem))
+	require.Len(t, problem.Errors, 1)
+	assert.Equal(t, "items", problem.Errors[0].Field)
+	assert.Equal(t, "max", problem.Errors[0].Code)
+	assert.Len(t, users.users, 1)
+
+	w = postBatch(t, router, "/v2/messages:batch", "u1", map[string]interface{}{"items": items[:domain.MaxUserBatchSize+1]})
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	// gin reads :batch as a parameter, which mustn't match other paths
+	w = postBatch(t, router, "/v2/messagesbatch", "u1", map[string]interface{}{"items": items[:1]})
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
+
+func TestUserBatchIsForAdmins(t *testing.T) {
+	withAPIConfig(t)
+	users := &batchUserRepo{users: map[string]*domain.User{
+		"admin": {ID: "admin", Admin: true},
+		"u1":    {ID: "u1"},
+	}}
+	router := batchRouter(&batchMessageRepo{}, users)
+	body := map[string]interface{}{
+		"atomic": true,
+		"items": []map[string]interface{}{
+			{"email": "a@example.com", "password": "s3cret-password1"},
+			{"email": "b@example.com", "password": "s3cret-password2"},
+		},
+	}
+
+	w := postBatch(t, router, "/v2/users:batch", "u1", body)
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	assert.Len(t, users.users, 2)
+
+	response := batchResults(t, postBatch(t, router, "/v2/users:batch", "admin", body))
+	assert.Equal(t, []int{http.StatusCreated, http.StatusCreated}, resultStatuses(response))
+	assert.Equal(t, "new-b@example.com", response.Results[1].ID)
+	assert.Len(t, users.users, 4)
+}
+
+func TestAtomicUserBatchWithTakenEmailSavesNothing(t *testing.T) {
+	withAPIConfig(t)
+	users := &batchUserRepo{users: map[string]*domain.User{
+		"admin": {ID: "admin", Email: "admin@example.com", Admin: true},
+	}}
+	router := batchRouter(&batchMessageRepo{}, users)
+
+	for _, taken := range []string{"admin@example.com", "a@example.com"} {
+		response := batchResults(t, postBatch(t, router, "/v2/users:batch", "admin", map[string]interface{}{
+			"atomic": true,
+			"items": []map[string]interface{}{
+				{"email": "a@example.com", "password": "s3cret-password1"},
+				{"email": taken, "password": "s3cret-password2"},
+				{"email": "c@example.com", "password": "s3cret-password3"},
+			},
+		}))
+		assert.Equal(t, []int{http.StatusConflict, http.StatusConflict, http.StatusConflict}, resultStatuses(response), taken)
+		assert.Equal(t, 0, response.Succeeded, taken)
+		assert.Equal(t, "batch_aborted", response.Results[0].Error.Code, taken)
+		assert.Equal(t, "user_exists", response.Results[1].Error.Code, taken)
+		assert.Equal(t, "batch_aborted", response.Results[2].Error.Code, taken)
+		for _, result := range response.Results {
+			assert.Empty(t, result.ID, taken)
+		}
+	}
+	assert.Len(t, users.users, 1)
+}
+
+func TestUserBatchWithTakenEmailFailsThatItemAlone(t *testing.T) {
+	withAPIConfig(t)
+	users := &batchUserRepo{users: map[string]*domain.User{
+		"admin": {ID: "admin", Email: "admin@example.com", Admin: true},
+	}}
+	router := batchRouter(&batchMessageRepo{}, users)
+
+	response := batchResults(t, postBatch(t, router, "/v2/users:batch", "admin", map[string]interface{}{
+		"items": []map[string]interface{}{
+			{"email": "a@example.com", "password": "s3cret-password1"},
+			{"email": "admin@example.com", "password": "s3cret-password2"},
+			{"email": "a@example.com", "password": "s3cret-password3"},
+		},
+	}))
+	assert.Equal(t, []int{http.StatusCreated, http.StatusConflict, http.StatusConflict}, resultStatuses(response))
+	assert.Equal(t, 1, response.Succeeded)
+	assert.Equal(t, 2, response.Failed)
+	assert.Equal(t, "new-a@example.com", response.Results[0].ID)
+	assert.Equal(t, "user_exists", response.Results[1].Error.Code)
+	assert.Equal(t, "user_exists", response.Results[2].Error.Code)
+	assert.Len(t, users.users, 2)
+}
+
+func TestCreateUsersReportsTheTakenEmailsIndex(t *testing.T) {
+	store, db := testStore(t)
+	taken := testEmail("taken")
+	_, err := store.CreateUser(taken, "correct-horse-battery")
+	require.NoError(t, err)
+
+	fresh, again := testEmail("fresh"), testEmail("again")
+	for index, batch := range map[int][]string{
+		1: {fresh, taken, testEmail("never")},
+		2: {fresh, again, again},
+	} {
+		users := make([]domain.NewUser, len(batch))
+		for i, email := range batch {
+			users[i] = domain.NewUser{Email: email, Password: "correct-horse-battery"}
+		}
+		_, err := store.CreateUsers(users)
+		var itemErr *domain.ItemError
+		require.ErrorAs(t, err, &itemErr)
+		assert.Equal(t, index, itemErr.Index)
+		assert.ErrorIs(t, err, domain.ErrConflict)
+	}
+
+	// neither batch left anyone behind
+	var count int
+	require.NoError(t, db.Model(&domain.User{}).Where("email IN (?)", []string{fresh, again}).Count(&count).Error)
+	assert.Zero(t, count)
+}
@@ -0,0 +1,280 @@
+package unit
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/LordMoMA/Hexagonal-Architecture/internal/adapters/cli"
+	"github.com/LordMoMA/Hexagonal-Architecture/internal/core/domain"
+	"github.com/LordMoMA/Hexagonal-Architecture/internal/core/ports"
+	"github.com/LordMoMA/Hexagonal-Architecture/internal/core/services"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// cliUserRepo keeps users in a map and records what was changed.
+type cliUserRepo struct {
+	ports.UserRepository
+	users   map[string]*domain.User
+	deleted []string
+}
+
+func newCLIUserRepo() *cliUserRepo {
+	return &cliUserRepo{users: map[string]*domain.User{
+		"u1": {ID: "u1", Email: "one@example.com", Password: secretPasswordHash},
+	}}
+}
+
+func (r *cliUserRepo) CreateUser(email, password string) (*domain.User, error) {
+	user := &domain.User{ID: "u2", Email: email, Password: secretPasswordHash}
+	r.users[user.ID] = user
+	return user, nil
+}
+
+func (r *cliUserRepo) ReadUser(id string) (*domain.User, error) {
+	if u, ok := r.users[id]; ok {
+		return u, nil
+	}
+	return nil, domain.NotFound("user_not_found", "user not found")
+}
+
+func (r *cliUserRepo) ReadUsers(opts domain.ListOptions) (*domain.Page[*domain.User], error) {
+	return &domain.Page[*domain.User]{Items: []*domain.User{r.users["u1"]}, NextCursor: "next"}, nil
+}
+
+func (r *cliUserRepo) UpdateAdminStatus(id string, admin bool) error {
+	r.users[id].Admin = admin
+	return nil
+}
+
+// failAdminRepo makes granting admin rights fail, as when the database goes
+// away between two calls.
+type failAdminRepo struct {
+	*cliUserRepo
+}
+
+func (r failAdminRepo) UpdateAdminStatus(id string, admin bool) error {
+	return domain.Unavailable("database_unavailable", "unable to update admin status")
+}
+
+func (r *cliUserRepo) DeleteUser(id string) error {
+	r.deleted = append(r.deleted, id)
+	delete(r.users, id)
+	return nil
+}
+
+func runCLI(repo *cliUserRepo, args ...string) (string, error) {
+	return runApp(cli.New(services.NewUserService(repo), nil, nil), args...)
+}
+
+func runApp(app *cli.App, args ...string) (string, error) {
+	var out, errOut bytes.Buffer
+	app.Out, app.Err = &out, &errOut
+	app.In = strings.NewReader("s3cret-password1\n")
+	err := app.Run(args)
+	return out.String(), err
+}
+
+func TestCLIDryRunChangesNothing(t *testing.T) {
+	repo := newCLIUserRepo()
+
+	out, err := runCLI(repo, "-dry-run", "users", "delete", "u1")
+	require.NoError(t, err)
+	assert.Contains(t, out, "Would delete user u1 (one@example.com)")
+	assert.Empty(t, repo.deleted)
+
+	out, err = runCLI(repo, "-dry-run", "users", "create", "-email", "new@example.com", "-password", "-", "-admin")
+	require.NoError(t, err)
+	assert.Contains(t, out, "Would create admin new@example.com")
+	assert.Len(t, repo.users, 1)
+
+	out, err = runCLI(repo, "users", "delete", "u1")
+	require.NoError(t, err)
+	assert.Contains(t, out, "Deleted user u1")
+	assert.Equal(t, []string{"u1"}, repo.deleted)
+}
+
+func TestCLICreatesAdmins(t *testing.T) {
+	repo := newCLIUserRepo()
+
+	out, err := runCLI(repo, "-o", "json", "users", "create", "-email", "admin@example.com", "-password", "-", "-admin")
+	require.NoError(t, err)
+	assert.True(t, repo.users["u2"].Admin)
+
+	var user map[string]interface{}
+	require.NoError(t, json.Unmarshal([]byte(out), &user))
+	assert.Equal(t, "admin@example.com", user["email"])
+	assert.Equal(t, true, user["admin"])
+	assert.NotContains(t, out, secretPasswordHash)
+}
+
+func TestCLISaysWhichStepOfCreatingAnAdminFailed(t *testing.T) {
+	repo := newCLIUserRepo()
+	app := cli.New(services.NewUserService(failAdminRepo{repo}), nil, nil)
+
+	_, err := runApp(app, "users", "create", "-email", "admin@example.com", "-password", "-", "-admin")
+	assert.ErrorIs(t, err, domain.ErrUnavailable)
+	assert.Contains(t, err.Error(), "created user u2 (admin@example.com)")
+	assert.Contains(t, err.Error(), "users grant-admin u2")
+	require.Contains(t, repo.users, "u2")
+	assert.False(t, repo.users["u2"].Admin)
+}
+
+func TestCLIAppliesTheAPIsRules(t *testing.T) {
+	repo := newCLIUserRepo()
+
+	_, err := runCLI(repo, "users", "create", "-email", "not-an-email", "-password", "short")
+	assert.ErrorIs(t, err, domain.ErrValidation)
+	assert.Len(t, repo.users, 1)
+
+	_, err = runCLI(repo, "users", "show", "missing")
+	assert.ErrorIs(t, err, domain.ErrNotFound)
+}
+
+func TestCLITableOutput(t *testing.T) {
+	out, err := runCLI(newCLIUserRepo(), "users", "list")
+	require.NoError(t, err)
+
+	lines := strings.Split(strings.TrimSpace(out), "\n")
+	require.Len(t, lines, 4)
+	assert.Equal(t, []string{"ID", "EMAIL", "ADMIN", "MEMBERSHIP", "TOTP"}, strings.Fields(lines[0]))
+	assert.Equal(t, []string{"u1", "one@example.com", "false", "false", "false"}, strings.Fields(lines[1]))
+	assert.Equal(t, "next page: -cursor next", strings.TrimSpace(lines[3]))
+}
+
+func TestCLIUsageErrors(t *testing.T) {
+	for _, args := range [][]string{
+		{"users"},
+		{"users", "frobnicate"},
+		{"-o", "yaml", "users", "list"},
+		{"users", "show"},
+		{"users", "list", "-bogus"},
+	} {
+		_, err := runCLI(newCLIUserRepo(), args...)
+		assert.ErrorIs(t, err, cli.ErrUsage, "%v", args)
+	}
+}
+
+// cliMessages serves message m1, with one flagged message in the review
+// queue, and records what was changed.
+type cliMessages struct {
+	ports.MessengerService
+	deleted, restored []string
+	pending           []bool
+}
+
+func (m *cliMessages) ReadMessageRevisions(id string) ([]*domain.MessageRevision, error) {
+	if id != "m1" {
+		return nil, domain.NotFound("message_not_found", "message not found")
+	}
+	return []*domain.MessageRevision{}, nil
+}
+
+func (m *cliMessages) DeleteMessage(id string) error {
+	m.deleted = append(m.deleted, id)
+	return nil
+}
+
+func (m *cliMessages) RestoreMessage(id string) error {
+	m.restored = append(m.restored, id)
+	return nil
+}
+
+func (m *cliMessages) ReadFlaggedMessages(pending bool, opts domain.ListOptions) (*domain.Page[*domain.FlaggedMessage], error) {
+	m.pending = append(m.pending, pending)
+	return &domain.Page[*domain.FlaggedMessage]{Items: []*domain.FlaggedMessage{{
+		ID:        "f1",
+		MessageID: "m1",
+		UserID:    "u1",
+		Reasons:   "profanity",
+		CreatedAt: time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC),
+	}}}, nil
+}
+
+func TestCLIMessageDryRunChangesNothing(t *testing.T) {
+	messages := &cliMessages{}
+	app := func() *cli.App { return cli.New(nil, messages, nil) }
+
+	for _, command := range []string{"delete", "restore"} {
+		out, err := runApp(app(), "-dry-run", "messages", command, "m1")
+		require.NoError(t, err, command)
+		assert.Contains(t, out, "Would "+command+" message m1 (dry run)")
+
+		// a dry run still says when there is nothing to act on
+		_, err = runApp(app(), "-dry-run", "messages", command, "missing")
+		assert.ErrorIs(t, err, domain.ErrNotFound, command)
+	}
+	assert.Empty(t, messages.deleted)
+	assert.Empty(t, messages.restored)
+
+	out, err := runApp(app(), "messages", "delete", "m1")
+	require.NoError(t, err)
+	assert.Contains(t, out, "Deleted message m1")
+	out, err = runApp(app(), "messages", "restore", "m1")
+	require.NoError(t, err)
+	assert.Contains(t, out, "Restored message m1")
+	assert.Equal(t, []string{"m1"}, messages.deleted)
+	assert.Equal(t, []string{"m1"}, messages.restored)
+}
+
+func TestCLIListsFlaggedMessages(t *testing.T) {
+	messages := &cliMessages{}
+
+	out, err := runApp(cli.New(nil, messages, nil), "messages", "flagged")
+	require.NoError(t, err)
+	lines := strings.Split(strings.TrimSpace(out), "\n")
+	require.Len(t, lines, 2)
+	assert.Equal(t, []string{"ID", "MESSAGE", "USER", "FLAGGED", "REASONS", "DECISION"}, strings.Fields(lines[0]))
+	assert.Equal(t, []string{"f1", "m1", "u1", "2024-01-02T03:04:05Z", "profanity"}, strings.Fields(lines[1]))
+
+	out, err = runApp(cli.New(nil, messages, nil), "-o", "json", "messages", "flagged", "-status", "reviewed")
+	require.NoError(t, err)
+	var page domain.Page[*domain.FlaggedMessage]
+	require.NoError(t, json.Unmarshal([]byte(out), &page))
+	require.Len(t, page.Items, 1)
+	assert.Equal(t, "f1", page.Items[0].ID)
+	assert.Equal(t, []bool{true, false}, messages.pending)
+
+	_, err = runApp(cli.New(nil, messages, nil), "messages", "flagged", "-status", "all")
+	assert.ErrorIs(t, err, domain.ErrValidation)
+}
+
+// cliPayments records whose orders were asked for.
+type cliPayments struct {
+	ports.PaymentService
+	userIDs [][]string
+}
+
+func (p *cliPayments) ReadOrders(userIDs []string) ([]*domain.OrderInfo, error) {
+	p.userIDs = append(p.userIDs, userIDs)
+	return []*domain.OrderInfo{
+		{ID: "id2", OrderID: "o2", UserID: "u2", CheckoutID: "cs_2", Amount: "20.00", Currency: "eur", Status: domain.OrderPending, CreatedAt: time.Date(2024, 1, 3, 0, 0, 0, 0, time.UTC)},
+		{ID: "id1", OrderID: "o1", UserID: "u1", CheckoutID: "cs_1", Amount: "10.00", Currency: "usd", Status: domain.OrderPending, CreatedAt: time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC)},
+	}, nil
+}
+
+func TestCLIListsOrders(t *testing.T) {
+	payments := &cliPayments{}
+
+	out, err := runApp(cli.New(nil, nil, payments), "payments", "orders", "u1", "u2")
+	require.NoError(t, err)
+	assert.Equal(t, [][]string{{"u1", "u2"}}, payments.userIDs)
+	lines := strings.Split(strings.TrimSpace(out), "\n")
+	require.Len(t, lines, 3)
+	assert.Equal(t, []string{"ORDER", "REFERENCE", "USER", "CHECKOUT", "AMOUNT", "CURRENCY", "STATUS", "CREATED"}, strings.Fields(lines[0]))
+	assert.Equal(t, []string{"id2", "o2", "u2", "cs_2", "20.00", "eur", "pending", "2024-01-03T00:00:00Z"}, strings.Fields(lines[1]))
+	assert.Equal(t, []string{"id1", "o1", "u1", "cs_1", "10.00", "usd", "pending", "2024-01-02T00:00:00Z"}, strings.Fields(lines[2]))
+
+	out, err = runApp(cli.New(nil, nil, payments), "-o", "json", "payments", "orders", "u1")
+	require.NoError(t, err)
+	var orders []domain.OrderInfo
+	require.NoError(t, json.Unmarshal([]byte(out), &orders))
+	require.Len(t, orders, 2)
+	assert.Equal(t, "o2", orders[0].OrderID)
+
+	_, err = runApp(cli.New(nil, nil, payments), "payments", "orders")
+	assert.ErrorIs(t, err, cli.ErrUsage)
+}
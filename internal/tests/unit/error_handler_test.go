@@ -0,0 +1,76 @@
+package unit
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/LordMoMA/Hexagonal-Architecture/internal/adapters/handler"
+	"github.com/LordMoMA/Hexagonal-Architecture/internal/core/domain"
+	"github.com/LordMoMA/Hexagonal-Architecture/internal/core/services"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func handleError(t *testing.T, status int, err error) (*httptest.ResponseRecorder, handler.Problem) {
+	gin.SetMode(gin.TestMode)
+	w := httptest.NewRecorder()
+	ctx, _ := gin.CreateTestContext(w)
+	ctx.Request = httptest.NewRequest(http.MethodGet, "/v1/messages/42", nil)
+
+	handler.HandleError(ctx, status, err)
+
+	var problem handler.Problem
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &problem))
+	return w, problem
+}
+
+func TestHandleErrorMapsDomainErrors(t *testing.T) {
+	cases := []struct {
+		err    error
+		status int
+		code   string
+	}{
+		{domain.NotFound("message_not_found", "message not found"), http.StatusNotFound, "message_not_found"},
+		{domain.Conflict("user_exists", "user already exists"), http.StatusConflict, "user_exists"},
+		{domain.Unauthorized("token_expired", "token has expired"), http.StatusUnauthorized, "token_expired"},
+		{services.ErrNotParticipant, http.StatusForbidden, "not_participant"},
+		{domain.Validation("invalid_limit", "limit must be between 1 and %d", 100), http.StatusUnprocessableEntity, "invalid_limit"},
+		{services.ErrSearchUnavailable, http.StatusServiceUnavailable, "search_unavailable"},
+		// wrapping keeps the kind
+		{fmt.Errorf("%w: spam", services.ErrMessageRejected), http.StatusUnprocessableEntity, "message_rejected"},
+		{services.ErrAttachmentTooLarge, http.StatusRequestEntityTooLarge, "attachment_too_large"},
+		{fmt.Errorf("%w: application/x-msdownload", services.ErrAttachmentType), http.StatusUnsupportedMediaType, "attachment_type_not_allowed"},
+	}
+	for _, c := range cases {
+		w, problem := handleError(t, http.StatusBadRequest, c.err)
+		assert.Equal(t, c.status, w.Code, c.code)
+		assert.Equal(t, "application/problem+json", w.Header().Get("Content-Type"))
+		assert.Equal(t, c.code, problem.Code)
+		assert.Equal(t, c.status, problem.Status)
+		assert.Equal(t, http.StatusText(c.status), problem.Title)
+		assert.Equal(t, c.err.Error(), problem.Detail)
+		assert.Equal(t, "/v1/messages/42", problem.Instance)
+	}
+}
+
+func TestHandleErrorKeepsStatusOfPlainErrors(t *testing.T) {
+	w, problem := handleError(t, http.StatusBadRequest, errors.New("invalid character 'x' looking for beginning of value"))
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Equal(t, "bad_request", problem.Code)
+	assert.Equal(t, "about:blank", problem.Type)
+}
+
+func TestDomainErrorsMatchTheirKind(t *testing.T) {
+	cause := errors.New("connection refused")
+	err := domain.Unavailable("database_unavailable", "messages not found: %w", cause)
+
+	assert.ErrorIs(t, err, domain.ErrUnavailable)
+	assert.ErrorIs(t, err, cause)
+	assert.NotErrorIs(t, err, domain.ErrNotFound)
+	assert.Equal(t, "messages not found: connection refused", err.Error())
+}
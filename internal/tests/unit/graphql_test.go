@@ -0,0 +1,403 @@
+package unit
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/LordMoMA/Hexagonal-Architecture/internal/adapters/graph"
+	"github.com/LordMoMA/Hexagonal-Architecture/internal/adapters/handler"
+	"github.com/LordMoMA/Hexagonal-Architecture/internal/core/domain"
+	"github.com/LordMoMA/Hexagonal-Architecture/internal/core/ports"
+	"github.com/LordMoMA/Hexagonal-Architecture/internal/core/services"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// graphRepo backs all three services with fixed data and records every
+// batch read, so tests can count the repository calls a query makes.
+type graphRepo struct {
+	ports.UserRepository
+	ports.MessengerRepository
+	ports.PaymentRepository
+
+	users         map[string]*domain.User
+	messages      []*domain.Message
+	conversations map[string]*domain.Conversation
+	orders        []*domain.OrderInfo
+
+	mu                sync.Mutex
+	userBatches       [][]string
+	conversationReads int
+	orderReads        int
+	messageSorts      []string
+}
+
+func newGraphRepo() *graphRepo {
+	r := &graphRepo{
+		users: map[string]*domain.User{
+			"u1": {ID: "u1", Email: "one@example.com"},
+			"u2": {ID: "u2", Email: "two@example.com"},
+			"u3": {ID: "u3", Email: "three@example.com"},
+			"u4": {ID: "u4", Email: "admin@example.com", Admin: true},
+		},
+		conversations: map[string]*domain.Conversation{
+			"c1": {ID: "c1", Kind: domain.ConversationGroup, Title: "first", CreatedBy: "u1", ParticipantIDs: []string{"u1", "u2", "u3"}},
+			"c2": {ID: "c2", Kind: domain.ConversationDirect, CreatedBy: "u1", ParticipantIDs: []string{"u1", "u3"}},
+		},
+		orders: []*domain.OrderInfo{
+			{ID: "id1", OrderID: "o1", UserID: "u1", Amount: "10.00", Currency: "usd", Status: domain.OrderPending},
+			{ID: "id2", OrderID: "o2", UserID: "u2", Amount: "20.00", Currency: "usd", Status: domain.OrderPending},
+		},
+	}
+	for i, author := range []string{"u1", "u2", "u3", "u1", "u2", "u3"} {
+		conversationID := "c1"
+		if i%2 == 1 && author != "u2" {
+			conversationID = "c2"
+		}
+		r.messages = append(r.messages, &domain.Message{ID: fmt.Sprintf("m%d", i+1), ConversationID: conversationID, UserID: author, Body: "hello"})
+	}
+	return r
+}
+
+func (r *graphRepo) ReadUser(id string) (*domain.User, error) {
+	if u, ok := r.users[id]; ok {
+		return u, nil
+	}
+	return nil, domain.NotFound("user_not_found", "user not found")
+}
+
+func (r *graphRepo) ReadUsers(opts domain.ListOptions) (*domain.Page[*domain.User], error) {
+	page := &domain.Page[*domain.User]{}
+	for _, id := range []string{"u1", "u2", "u3", "u4"} {
+		page.Items = append(page.Items, r.users[id])
+	}
+	return page, nil
+}
+
+func (r *graphRepo) ReadUsersByIDs(ids []string) ([]*domain.User, error) {
+	r.mu.Lock()
+	r.userBatches = append(r.userBatches, ids)
+	r.mu.Unlock()
+
+	var users []*domain.User
+	for _, id := range ids {
+		if u, ok := r.users[id]; ok {
+			users = append(users, u)
+		}
+	}
+	return users, nil
+}
+
+func (r *graphRepo) ReadMessages(userID string, opts domain.ListOptions) (*domain.Page[*domain.Message], error) {
+	r.messageSorts = append(r.messageSorts, opts.Sort)
+	return &domain.Page[*domain.Message]{Items: r.messages}, nil
+}
+
+func (r *graphRepo) ReadConversationsByIDs(userID string, ids []string) ([]*domain.Conversation, error) {
+	r.mu.Lock()
+	r.conversationReads++
+	r.mu.Unlock()
+
+	var conversations []*domain.Conversation
+	for _, id := range ids {
+		if c, ok := r.conversations[id]; ok && contains(c.ParticipantIDs, userID) {
+			conversations = append(conversations, c)
+		}
+	}
+	return conversations, nil
+}
+
+func (r *graphRepo) ReadOrders(userIDs []string) ([]*domain.OrderInfo, error) {
+	r.mu.Lock()
+	r.orderReads++
+	r.mu.Unlock()
+
+	var orders []*domain.OrderInfo
+	for _, o := range r.orders {
+		if contains(userIDs, o.UserID) {
+			orders = append(orders, o)
+		}
+	}
+	return orders, nil
+}
+
+func (r *graphRepo) schema() *graph.Schema {
+	return graph.NewSchema(
+		services.NewUserService(r),
+		services.NewMessengerService(r, nil, nil, nil, nil),
+		services.NewPaymentService(r),
+	)
+}
+
+type graphQLResult struct {
+	Data   json.RawMessage `json:"data"`
+	Errors []struct {
+		Message    string                 `json:"message"`
+		Extensions map[string]interface{} `json:"extensions"`
+	} `json:"errors"`
+}
+
+func runQuery(t *testing.T, schema *graph.Schema, userID, query string) graphQLResult {
+	body, err := json.Marshal(schema.Exec(context.Background(), userID, query, "", nil))
+	require.NoError(t, err)
+	var result graphQLResult
+	require.NoError(t, json.Unmarshal(body, &result))
+	return result
+}
+
+func TestGraphQLBatchesAuthors(t *testing.T) {
+	repo := newGraphRepo()
+	result := runQuery(t, repo.schema(), "u1", `{ messages { items { id author { email } } } }`)
+	require.Empty(t, result.Errors)
+
+	var data struct {
+		Messages struct {
+			Items []struct {
+				Author struct{ Email string }
+			}
+		}
+	}
+	require.NoError(t, json.Unmarshal(result.Data, &data))
+	require.Len(t, data.Messages.Items, 6)
+	assert.Equal(t, "three@example.com", data.Messages.Items[2].Author.Email)
+	assert.Equal(t, []string{"-created_at"}, repo.messageSorts, "messages are newest first")
+
+	// six messages, one read of their three authors
+	require.Len(t, repo.userBatches, 1)
+	assert.ElementsMatch(t, []string{"u1", "u2", "u3"}, repo.userBatches[0])
+}
+
+func TestGraphQLBatchesNestedReads(t *testing.T) {
+	repo := newGraphRepo()
+	result := runQuery(t, repo.schema(), "u1", `{
+		messages { items { author { id } conversation { title createdBy { id } participants { email } } } }
+	}`)
+	require.Empty(t, result.Errors)
+
+	assert.Equal(t, 1, repo.conversationReads)
+	// authors and participants may land in separate batches, but no user is
+	// ever read twice
+	assert.LessOrEqual(t, len(repo.userBatches), 2)
+	var read []string
+	for _, batch := range repo.userBatches {
+		read = append(read, batch...)
+	}
+	assert.ElementsMatch(t, []string{"u1", "u2", "u3"}, read)
+}
+
+func TestGraphQLOrdersAreOnlyForTheirUserAndAdmins(t *testing.T) {
+	repo := newGraphRepo()
+	schema := repo.schema()
+
+	result := runQuery(t, schema, "u1", `{ me { orders { id orderId } } user(id: "u2") { email orders { id } } }`)
+	var data struct {
+		Me struct {
+			Orders []struct{ ID, OrderID string }
+		}
+		User struct {
+			Email  string
+			Orders *[]struct{ ID string }
+		}
+	}
+	require.NoError(t, json.Unmarshal(result.Data, &data))
+	require.Len(t, data.Me.Orders, 1)
+	assert.Equal(t, "id1", data.Me.Orders[0].ID)
+	assert.Equal(t, "o1", data.Me.Orders[0].OrderID)
+	assert.Equal(t, "two@example.com", data.User.Email)
+	assert.Nil(t, data.User.Orders)
+	require.Len(t, result.Errors, 1)
+	assert.Equal(t, "orders_forbidden", result.Errors[0].Extensions["code"])
+
+	repo.orderReads = 0
+	result = runQuery(t, schema, "u4", `{ users { items { id orders { id } } } }`)
+	require.Empty(t, result.Errors)
+	assert.Equal(t, 1, repo.orderReads)
+}
+
+func TestGraphQLEndpoint(t *testing.T) {
+	withAPIConfig(t)
+	gin.SetMode(gin.TestMode)
+
+	router := gin.New()
+	router.POST("/v1/graphql", handler.NewGraphQLHandler(newGraphRepo().schema()).Query)
+	query := `{"query": "{ me { id email } }"}`
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/v1/graphql", strings.NewReader(query)))
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+	assert.Contains(t, w.Header().Get("Content-Type"), "application/problem+json")
+
+	w = httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodPost, "/v1/graphql", strings.NewReader(query))
+	req.Header.Set("Authorization", "Bearer "+accessToken(t, "u2"))
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `{"data": {"me": {"id": "u2", "email": "two@example.com"}}}`, w.Body.String())
+}
+
+func TestReadUsersByIDs(t *testing.T) {
+	store, _ := testStore(t)
+	first, err := store.CreateUser(testEmail("batch"), "correct-horse-battery")
+	require.NoError(t, err)
+	second, err := store.CreateUser(testEmail("batch"), "correct-horse-battery")
+	require.NoError(t, err)
+
+	// an ID nobody has is left out rather than failing the batch
+	users, err := store.ReadUsersByIDs([]string{first.ID, uuid.NewString(), second.ID, first.ID})
+	require.NoError(t, err)
+	var emails []string
+	for _, user := range users {
+		emails = append(emails, user.Email)
+	}
+	assert.ElementsMatch(t, []string{first.Email, second.Email}, emails)
+
+	users, err = store.ReadUsersByIDs(nil)
+	require.NoError(t, err)
+	assert.Empty(t, users)
+}
+
+func TestReadConversationsByIDsOnlyReadsTheCallersOwn(t *testing.T) {
+	store, _ := testStore(t)
+	var alice, bob, carol *domain.User
+	for _, user := range []**domain.User{&alice, &bob, &carol} {
+		var err error
+		*user, err = store.CreateUser(testEmail("graph"), "correct-horse-battery")
+		require.NoError(t, err)
+	}
+	aliceAndBob, err := store.CreateConversation(domain.Conversation{ID: uuid.NewString(), Kind: domain.ConversationGroup, CreatedBy: alice.ID, ParticipantIDs: []string{alice.ID, bob.ID}})
+	require.NoError(t, err)
+	bobAndCarol, err := store.CreateConversation(domain.Conversation{ID: uuid.NewString(), Kind: domain.ConversationGroup, CreatedBy: bob.ID, ParticipantIDs: []string{bob.ID, carol.ID}})
+	require.NoError(t, err)
+	require.NoError(t, store.CreateMessage(bob.ID, domain.Message{ConversationID: aliceAndBob.ID, Body: "hello", CreatedAt: time.Now().UTC()}))
+
+	ids := []string{aliceAndBob.ID, bobAndCarol.ID, uuid.NewString()}
+	read := func(userID string) map[string]*domain.Conversation {
+		conversations, err := store.ReadConversationsByIDs(userID, ids)
+		require.NoError(t, err)
+		byID := map[string]*domain.Conversation{}
+		for _, c := range conversations {
+			byID[c.ID] = c
+		}
+		return byID
+	}
+
+	// asking by ID is no way into someone else's conversation
+	own := read(alice.ID)
+	require.Len(t, own, 1)
+	require.Contains(t, own, aliceAndBob.ID)
+	assert.ElementsMatch(t, []string{alice.ID, bob.ID}, own[aliceAndBob.ID].ParticipantIDs)
+	assert.Equal(t, 1, own[aliceAndBob.ID].UnreadCount)
+
+	own = read(bob.ID)
+	require.Len(t, own, 2)
+	assert.Equal(t, 0, own[aliceAndBob.ID].UnreadCount)
+	assert.ElementsMatch(t, []string{bob.ID, carol.ID}, own[bobAndCarol.ID].ParticipantIDs)
+
+	assert.Empty(t, read(uuid.NewString()))
+	conversations, err := store.ReadConversationsByIDs(alice.ID, nil)
+	require.NoError(t, err)
+	assert.Empty(t, conversations)
+}
+
+func TestCheckoutOrdersAreReadByBuyer(t *testing.T) {
+	store, _ := testStore(t)
+	buyer, err := store.CreateUser(testEmail("buyer"), "correct-horse-battery")
+	require.NoError(t, err)
+	other, err := store.CreateUser(testEmail("buyer"), "correct-horse-battery")
+	require.NoError(t, err)
+
+	require.NoError(t, store.CreateCheckoutSession(buyer.ID, domain.Payment{
+		CheckoutID: "cs_first",
+		Orders:     []*domain.OrderInfo{{Amount: "10.00", Currency: "usd"}},
+	}))
+	time.Sleep(10 * time.Millisecond)
+	require.NoError(t, store.CreateCheckoutSession(buyer.ID, domain.Payment{
+		CheckoutID: "cs_second",
+		// the buyer is whoever checks out, not whoever the order names
+		Orders: []*domain.OrderInfo{{OrderID: uuid.NewString(), UserID: other.ID, Amount: "20.00", Currency: "usd"}},
+	}))
+	require.NoError(t, store.CreateCheckoutSession(other.ID, domain.Payment{
+		CheckoutID: "cs_other",
+		Orders:     []*domain.OrderInfo{{Amount: "30.00", Currency: "eur"}},
+	}))
+
+	orders, err := store.ReadOrders([]string{buyer.ID})
+	require.NoError(t, err)
+	require.Len(t, orders, 2)
+	assert.Equal(t, "cs_second", orders[0].CheckoutID)
+	assert.Equal(t, "cs_first", orders[1].CheckoutID)
+	for _, order := range orders {
+		assert.Equal(t, buyer.ID, order.UserID)
+		assert.NotEmpty(t, order.ID)
+		assert.Equal(t, domain.OrderPending, order.Status)
+	}
+
+	orders, err = store.ReadOrders([]string{buyer.ID, other.ID})
+	require.NoError(t, err)
+	assert.Len(t, orders, 3)
+	orders, err = store.ReadOrders(nil)
+	require.NoError(t, err)
+	assert.Empty(t, orders)
+}
+
+func TestCheckoutOrdersAreKeyedByTheServer(t *testing.T) {
+	store, _ := testStore(t)
+	buyer, err := store.CreateUser(testEmail("buyer"), "correct-horse-battery")
+	require.NoError(t, err)
+	other, err := store.CreateUser(testEmail("buyer"), "correct-horse-battery")
+	require.NoError(t, err)
+
+	// clients reuse their own order references, within and across buyers
+	reference := uuid.NewString()
+	require.NoError(t, store.CreateCheckoutSession(buyer.ID, domain.Payment{
+		CheckoutID: "cs_duplicate",
+		Orders: []*domain.OrderInfo{
+			{OrderID: reference, Amount: "10.00", Currency: "usd"},
+			{OrderID: reference, Amount: "20.00", Currency: "usd"},
+		},
+	}))
+	require.NoError(t, store.CreateCheckoutSession(other.ID, domain.Payment{
+		CheckoutID: "cs_other",
+		Orders:     []*domain.OrderInfo{{OrderID: reference, Amount: "30.00", Currency: "usd"}},
+	}))
+
+	orders, err := store.ReadOrders([]string{buyer.ID, other.ID})
+	require.NoError(t, err)
+	require.Len(t, orders, 3)
+	ids := map[string]bool{}
+	for _, order := range orders {
+		assert.Equal(t, reference, order.OrderID)
+		ids[order.ID] = true
+	}
+	assert.Len(t, ids, 3)
+}
+
+func TestCheckoutSavesAllOrdersOrNone(t *testing.T) {
+	store, _ := testStore(t)
+	buyer, err := store.CreateUser(testEmail("buyer"), "correct-horse-battery")
+	require.NoError(t, err)
+
+	// only the server picks IDs, so the second order failing takes a bug
+	id := uuid.NewString()
+	err = store.CreateCheckoutSession(buyer.ID, domain.Payment{
+		CheckoutID: "cs_failing",
+		Orders: []*domain.OrderInfo{
+			{ID: id, Amount: "10.00", Currency: "usd"},
+			{ID: id, Amount: "20.00", Currency: "usd"},
+		},
+	})
+	assert.ErrorIs(t, err, domain.ErrUnavailable)
+
+	orders, err := store.ReadOrders([]string{buyer.ID})
+	require.NoError(t, err)
+	assert.Empty(t, orders)
+}
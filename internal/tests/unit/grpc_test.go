@@ -0,0 +1,249 @@
+package unit
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strconv"
+	"testing"
+
+	"github.com/LordMoMA/Hexagonal-Architecture/internal/adapters/rpc"
+	"github.com/LordMoMA/Hexagonal-Architecture/internal/adapters/rpc/pb"
+	"github.com/LordMoMA/Hexagonal-Architecture/internal/core/domain"
+	"github.com/LordMoMA/Hexagonal-Architecture/internal/core/ports"
+	"github.com/LordMoMA/Hexagonal-Architecture/internal/core/services"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+type missingUserRepo struct {
+	secretsRepo
+}
+
+func (r *missingUserRepo) ReadUser(id string) (*domain.User, error) {
+	return nil, domain.NotFound("user_not_found", "user %s not found", id)
+}
+
+// grpcClient serves users from repo over an in-memory connection.
+func grpcClient(t *testing.T, repo ports.UserRepository) pb.UserServiceClient {
+	return pb.NewUserServiceClient(grpcConn(t, repo, nil, nil))
+}
+
+// grpcConn serves all three services from their repositories over an
+// in-memory connection.
+func grpcConn(t *testing.T, users ports.UserRepository, messages ports.MessengerRepository, payments ports.PaymentRepository) *grpc.ClientConn {
+	server := rpc.NewServer(services.NewUserService(users), services.NewMessengerService(messages, nil, nil, nil, nil), services.NewPaymentService(payments), secretsJWTKey)
+
+	listener := bufconn.Listen(1 << 20)
+	go func() { _ = server.Serve(listener) }()
+	t.Cleanup(server.Stop)
+
+	conn, err := grpc.Dial("bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return listener.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	require.NoError(t, err)
+	t.Cleanup(func() { conn.Close() })
+	return conn
+}
+
+func withToken(t *testing.T, userID string) context.Context {
+	return metadata.AppendToOutgoingContext(context.Background(), "authorization", "Bearer "+accessToken(t, userID))
+}
+
+func errorReason(err error) string {
+	for _, detail := range status.Convert(err).Details() {
+		if info, ok := detail.(*errdetails.ErrorInfo); ok {
+			return info.Reason
+		}
+	}
+	return ""
+}
+
+func TestGRPCRequiresAuthentication(t *testing.T) {
+	client := grpcClient(t, &secretsRepo{})
+
+	_, err := client.GetUser(context.Background(), &pb.GetUserRequest{Id: "admin-1"})
+	assert.Equal(t, codes.Unauthenticated, status.Code(err))
+	assert.Equal(t, "missing_token", errorReason(err))
+
+	user, err := client.GetUser(withToken(t, "admin-1"), &pb.GetUserRequest{Id: "admin-1"})
+	require.NoError(t, err)
+	assert.Equal(t, "admin@example.com", user.Email)
+	assert.True(t, user.TotpEnabled)
+}
+
+func TestGRPCLoginIsPublic(t *testing.T) {
+	client := grpcClient(t, &secretsRepo{})
+
+	login, err := client.Login(context.Background(), &pb.LoginRequest{Email: "admin@example.com", Password: "hunter2"})
+	require.NoError(t, err)
+	assert.Equal(t, "access", login.AccessToken)
+	assert.Equal(t, "refresh", login.RefreshToken)
+}
+
+func TestGRPCMapsDomainErrors(t *testing.T) {
+	client := grpcClient(t, &missingUserRepo{})
+
+	_, err := client.GetUser(withToken(t, "admin-1"), &pb.GetUserRequest{Id: "nobody"})
+	assert.Equal(t, codes.NotFound, status.Code(err))
+	assert.Equal(t, "user_not_found", errorReason(err))
+	assert.Equal(t, "user nobody not found", status.Convert(err).Message())
+}
+
+func TestGRPCValidatesLikeHTTP(t *testing.T) {
+	client := grpcClient(t, &secretsRepo{})
+
+	_, err := client.CreateUser(context.Background(), &pb.CreateUserRequest{Email: "not-an-email", Password: "short"})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+	assert.Equal(t, "invalid_request", errorReason(err))
+
+	var fields []string
+	for _, detail := range status.Convert(err).Details() {
+		if badRequest, ok := detail.(*errdetails.BadRequest); ok {
+			for _, violation := range badRequest.FieldViolations {
+				fields = append(fields, violation.Field)
+			}
+		}
+	}
+	assert.ElementsMatch(t, []string{"email", "password"}, fields)
+}
+
+// grpcMessageRepo holds conversation c1 between u1 and u2, with five
+// messages by u1, and c2 of u3 alone. A page cursor is the index of the
+// next message.
+type grpcMessageRepo struct {
+	ports.MessengerRepository
+	messages []*domain.Message
+}
+
+func newGRPCMessageRepo() *grpcMessageRepo {
+	r := &grpcMessageRepo{}
+	for i := 0; i < 5; i++ {
+		r.messages = append(r.messages, &domain.Message{ID: fmt.Sprintf("m%d", i+1), ConversationID: "c1", UserID: "u1", Body: "hello"})
+	}
+	return r
+}
+
+func (r *grpcMessageRepo) IsParticipant(conversationID, userID string) (bool, error) {
+	participants := map[string][]string{"c1": {"u1", "u2"}, "c2": {"u3"}}
+	for _, id := range participants[conversationID] {
+		if id == userID {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (r *grpcMessageRepo) ReadMessage(id string) (*domain.Message, error) {
+	for _, message := range r.messages {
+		if message.ID == id {
+			return message, nil
+		}
+	}
+	return nil, domain.NotFound("message_not_found", "message not found")
+}
+
+func (r *grpcMessageRepo) ReadConversationMessages(conversationID string, opts domain.ListOptions) (*domain.Page[*domain.Message], error) {
+	start, _ := strconv.Atoi(opts.Cursor)
+	end := start + opts.Limit
+	if opts.Limit == 0 || end > len(r.messages) {
+		end = len(r.messages)
+	}
+	page := &domain.Page[*domain.Message]{Items: r.messages[start:end]}
+	if end < len(r.messages) {
+		page.NextCursor = strconv.Itoa(end)
+	}
+	return page, nil
+}
+
+func TestGRPCMessagesAreForParticipants(t *testing.T) {
+	client := pb.NewMessageServiceClient(grpcConn(t, &secretsRepo{}, newGRPCMessageRepo(), nil))
+
+	message, err := client.GetMessage(withToken(t, "u2"), &pb.GetMessageRequest{Id: "m1"})
+	require.NoError(t, err)
+	assert.Equal(t, "u1", message.UserId)
+
+	_, err = client.GetMessage(withToken(t, "u3"), &pb.GetMessageRequest{Id: "m1"})
+	assert.Equal(t, codes.PermissionDenied, status.Code(err))
+	assert.Equal(t, "not_participant", errorReason(err))
+
+	_, err = client.ListConversationMessages(withToken(t, "u3"), &pb.ListConversationMessagesRequest{ConversationId: "c1"})
+	assert.Equal(t, codes.PermissionDenied, status.Code(err))
+	assert.Equal(t, "not_participant", errorReason(err))
+
+	// a participant may read a message but only its author may change it
+	_, err = client.DeleteMessage(withToken(t, "u2"), &pb.DeleteMessageRequest{Id: "m1"})
+	assert.Equal(t, codes.PermissionDenied, status.Code(err))
+	assert.Equal(t, "not_message_author", errorReason(err))
+}
+
+func TestGRPCMessagesArePaged(t *testing.T) {
+	client := pb.NewMessageServiceClient(grpcConn(t, &secretsRepo{}, newGRPCMessageRepo(), nil))
+
+	var ids []string
+	opts := &pb.ListOptions{Limit: 2}
+	for pages := 1; ; pages++ {
+		page, err := client.ListConversationMessages(withToken(t, "u1"), &pb.ListConversationMessagesRequest{ConversationId: "c1", Options: opts})
+		require.NoError(t, err)
+		assert.LessOrEqual(t, len(page.Messages), 2)
+		for _, message := range page.Messages {
+			ids = append(ids, message.Id)
+		}
+		if page.NextCursor == "" {
+			assert.Equal(t, 3, pages)
+			break
+		}
+		opts.Cursor = page.NextCursor
+	}
+	assert.Equal(t, []string{"m1", "m2", "m3", "m4", "m5"}, ids)
+
+	for _, limit := range []int32{-1, domain.MaxPageLimit + 1} {
+		_, err := client.ListConversationMessages(withToken(t, "u1"), &pb.ListConversationMessagesRequest{ConversationId: "c1", Options: &pb.ListOptions{Limit: limit}})
+		assert.Equal(t, codes.InvalidArgument, status.Code(err), "limit %d", limit)
+		assert.Equal(t, "invalid_limit", errorReason(err), "limit %d", limit)
+	}
+}
+
+// checkoutRepo records the checkout sessions created through it.
+type checkoutRepo struct {
+	ports.PaymentRepository
+	userIDs  []string
+	payments []domain.Payment
+}
+
+func (r *checkoutRepo) CreateCheckoutSession(userID string, payment domain.Payment) error {
+	r.userIDs = append(r.userIDs, userID)
+	r.payments = append(r.payments, payment)
+	return nil
+}
+
+func TestGRPCCheckoutIsForTheCaller(t *testing.T) {
+	repo := &checkoutRepo{}
+	client := pb.NewPaymentServiceClient(grpcConn(t, &secretsRepo{}, nil, repo))
+	req := &pb.CreateCheckoutSessionRequest{
+		CheckoutId: "cs_1",
+		BuyerInfo:  &pb.BuyerInfo{FirstName: "Ada", LastName: "Lovelace", Email: "ada@example.com"},
+		Orders:     []*pb.OrderInfo{{OrderId: "o1", SellerAccount: "acct_1", Amount: "10.00", Currency: "usd"}},
+	}
+
+	_, err := client.CreateCheckoutSession(context.Background(), req)
+	assert.Equal(t, codes.Unauthenticated, status.Code(err))
+	assert.Empty(t, repo.payments)
+
+	_, err = client.CreateCheckoutSession(withToken(t, "u1"), req)
+	require.NoError(t, err)
+	require.Len(t, repo.payments, 1)
+	assert.Equal(t, []string{"u1"}, repo.userIDs)
+	payment := repo.payments[0]
+	assert.Equal(t, "cs_1", payment.CheckoutID)
+	assert.Equal(t, &domain.BuyerInfo{UserID: "u1", FirstName: "Ada", LastName: "Lovelace", Email: "ada@example.com"}, payment.BuyerInfo)
+	require.Len(t, payment.Orders, 1)
+	assert.Equal(t, &domain.OrderInfo{OrderID: "o1", SellerAccount: "acct_1", Amount: "10.00", Currency: "usd"}, payment.Orders[0])
+}
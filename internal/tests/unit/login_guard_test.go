@@ -0,0 +1,173 @@
+package unit
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/LordMoMA/Hexagonal-Architecture/internal/adapters/handler"
+	"github.com/LordMoMA/Hexagonal-Architecture/internal/adapters/repository"
+	"github.com/LordMoMA/Hexagonal-Architecture/internal/core/domain"
+	"github.com/LordMoMA/Hexagonal-Architecture/internal/core/ports"
+	"github.com/LordMoMA/Hexagonal-Architecture/internal/core/services"
+	"github.com/gin-gonic/gin"
+	"github.com/go-redis/redis/v8"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testClient is a device of its own, so the per-IP lockout of one test
+// doesn't reach into another.
+func testClient() domain.ClientInfo {
+	return domain.ClientInfo{IP: uuid.NewString(), UserAgent: "unit-test"}
+}
+
+func TestLoginLocksAccountAfterRepeatedFailures(t *testing.T) {
+	withAPIConfig(t)
+	store, _ := testStore(t)
+	email, password := testEmail("locked"), "correct-horse-battery"
+	_, err := store.CreateUser(email, password)
+	require.NoError(t, err)
+	client := testClient()
+
+	for i := 0; i < 5; i++ {
+		_, err = store.LoginUser(email, "wrong-password", client)
+		assert.ErrorIs(t, err, repository.ErrInvalidCredentials, "attempt %d", i+1)
+	}
+
+	// even the right password is turned away until the lockout ends
+	_, err = store.LoginUser(email, password, client)
+	assert.ErrorIs(t, err, repository.ErrLoginLocked)
+	assert.ErrorIs(t, err, domain.ErrRateLimited)
+}
+
+func TestUnknownAccountsFailLikeKnownOnes(t *testing.T) {
+	withAPIConfig(t)
+	store, _ := testStore(t)
+	known := testEmail("known")
+	_, err := store.CreateUser(known, "correct-horse-battery")
+	require.NoError(t, err)
+	unknown := testEmail("unknown")
+	client := testClient()
+
+	// the same error at every attempt, locked out or not, tells nobody
+	// which of the two accounts exists
+	for i := 0; i < 6; i++ {
+		_, knownErr := store.LoginUser(known, "wrong-password", client)
+		_, unknownErr := store.LoginUser(unknown, "wrong-password", client)
+		assert.Equal(t, knownErr, unknownErr, "attempt %d", i+1)
+		if i < 5 {
+			assert.ErrorIs(t, unknownErr, repository.ErrInvalidCredentials, "attempt %d", i+1)
+		} else {
+			assert.ErrorIs(t, unknownErr, repository.ErrLoginLocked, "attempt %d", i+1)
+		}
+	}
+}
+
+func TestLockoutBacksOffWithEveryFurtherFailure(t *testing.T) {
+	withAPIConfig(t)
+	store, _ := testStore(t)
+	rdb := redis.NewClient(&redis.Options{Addr: testRedisAddr})
+	t.Cleanup(func() { rdb.Close() })
+	ctx := context.Background()
+
+	email := testEmail("backoff")
+	lock := "login:lock:account:" + email
+	client := testClient()
+	for i := 0; i < 5; i++ {
+		_, _ = store.LoginUser(email, "wrong-password", client)
+	}
+
+	for _, want := range []time.Duration{time.Minute, 2 * time.Minute, 4 * time.Minute} {
+		ttl, err := rdb.TTL(ctx, lock).Result()
+		require.NoError(t, err)
+		assert.InDelta(t, want.Seconds(), ttl.Seconds(), 5, "lockout after %v", want)
+
+		// let the lockout run out, then fail once more
+		require.NoError(t, rdb.Del(ctx, lock).Err())
+		_, err = store.LoginUser(email, "wrong-password", client)
+		assert.ErrorIs(t, err, repository.ErrInvalidCredentials)
+	}
+}
+
+func TestUnlockUserLiftsLockout(t *testing.T) {
+	withAPIConfig(t)
+	store, _ := testStore(t)
+	email, password := testEmail("unlock"), "correct-horse-battery"
+	user, err := store.CreateUser(email, password)
+	require.NoError(t, err)
+	client := testClient()
+	for i := 0; i < 5; i++ {
+		_, _ = store.LoginUser(email, "wrong-password", client)
+	}
+
+	require.NoError(t, store.UnlockUser(user.ID))
+	response, err := store.LoginUser(email, password, client)
+	require.NoError(t, err)
+	assert.Equal(t, user.ID, response.ID)
+
+	assert.ErrorIs(t, store.UnlockUser(uuid.NewString()), domain.ErrNotFound)
+}
+
+// unlockRepo records the accounts unlocked through it.
+type unlockRepo struct {
+	ports.UserRepository
+	unlocked []string
+}
+
+func (r *unlockRepo) ReadUser(id string) (*domain.User, error) {
+	switch id {
+	case "admin":
+		return &domain.User{ID: id, Admin: true}, nil
+	case "u1":
+		return &domain.User{ID: id}, nil
+	}
+	return nil, domain.NotFound("user_not_found", "user not found")
+}
+
+func (r *unlockRepo) UnlockUser(id string) error {
+	if _, err := r.ReadUser(id); err != nil {
+		return err
+	}
+	r.unlocked = append(r.unlocked, id)
+	return nil
+}
+
+func TestUnlockUserEndpointIsForAdmins(t *testing.T) {
+	withAPIConfig(t)
+	gin.SetMode(gin.TestMode)
+	repo := &unlockRepo{}
+	router := gin.New()
+	router.POST("/v1/admin/users/:id/unlock", handler.NewUserHandler(*services.NewUserService(repo)).UnlockUser)
+
+	unlock := func(caller, id string) int {
+		req := httptest.NewRequest(http.MethodPost, "/v1/admin/users/"+id+"/unlock", nil)
+		req.Header.Set("Authorization", "Bearer "+accessToken(t, caller))
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	assert.Equal(t, http.StatusForbidden, unlock("u1", "u1"))
+	assert.Empty(t, repo.unlocked)
+	assert.Equal(t, http.StatusNotFound, unlock("admin", "nobody"))
+	assert.Equal(t, http.StatusOK, unlock("admin", "u1"))
+	assert.Equal(t, []string{"u1"}, repo.unlocked)
+}
+
+func TestLockedLoginIsTooManyRequests(t *testing.T) {
+	withAPIConfig(t)
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.GET("/", func(ctx *gin.Context) {
+		handler.HandleError(ctx, http.StatusBadRequest, repository.ErrLoginLocked)
+	})
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+	assert.Equal(t, http.StatusTooManyRequests, w.Code)
+	assert.Contains(t, w.Body.String(), `"code":"login_locked"`)
+}
@@ -0,0 +1,305 @@
+package unit
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/LordMoMA/Hexagonal-Architecture/internal/core/domain"
+	"github.com/LordMoMA/Hexagonal-Architecture/internal/core/ports"
+	"github.com/LordMoMA/Hexagonal-Architecture/internal/core/services"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// memoryMessengerRepo keeps conversations and messages in maps so service
+// rules can be tested without Postgres.
+type memoryMessengerRepo struct {
+	ports.MessengerRepository
+	conversations map[string]*domain.Conversation
+	messages      map[string]*domain.Message
+	revisions     []*domain.MessageRevision
+	reactions     map[string]bool
+	readAt        time.Time
+	lastMessageID string
+	scheduled     map[string]*domain.ScheduledMessage
+}
+
+func newMemoryMessengerRepo() *memoryMessengerRepo {
+	return &memoryMessengerRepo{
+		conversations: map[string]*domain.Conversation{},
+		messages:      map[string]*domain.Message{},
+		scheduled:     map[string]*domain.ScheduledMessage{},
+	}
+}
+
+func (r *memoryMessengerRepo) CreateConversation(c domain.Conversation) (*domain.Conversation, error) {
+	r.conversations[c.ID] = &c
+	return &c, nil
+}
+
+func (r *memoryMessengerRepo) FindDirectConversation(a, b string) (*domain.Conversation, error) {
+	for _, c := range r.conversations {
+		if c.Kind == domain.ConversationDirect && contains(c.ParticipantIDs, a) && contains(c.ParticipantIDs, b) {
+			return c, nil
+		}
+	}
+	return nil, errors.New("conversation not found")
+}
+
+func (r *memoryMessengerRepo) IsParticipant(conversationID, userID string) (bool, error) {
+	c, ok := r.conversations[conversationID]
+	return ok && contains(c.ParticipantIDs, userID), nil
+}
+
+func (r *memoryMessengerRepo) CreateMessage(userID string, m domain.Message) error {
+	m.UserID = userID
+	r.messages[m.ID] = &m
+	r.lastMessageID = m.ID
+	return nil
+}
+
+func (r *memoryMessengerRepo) ReadMessage(id string) (*domain.Message, error) {
+	m, ok := r.messages[id]
+	if !ok {
+		return nil, errors.New("message not found")
+	}
+	return m, nil
+}
+
+func contains(ids []string, id string) bool {
+	for _, v := range ids {
+		if v == id {
+			return true
+		}
+	}
+	return false
+}
+
+func TestCreateDirectConversationIsDeduplicated(t *testing.T) {
+	svc := services.NewMessengerService(newMemoryMessengerRepo(), nil, nil, nil, nil)
+
+	first, err := svc.CreateConversation("alice", domain.Conversation{Kind: domain.ConversationDirect, ParticipantIDs: []string{"bob"}})
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []string{"alice", "bob"}, first.ParticipantIDs)
+
+	second, err := svc.CreateConversation("bob", domain.Conversation{Kind: domain.ConversationDirect, ParticipantIDs: []string{"alice"}})
+	require.NoError(t, err)
+	assert.Equal(t, first.ID, second.ID)
+
+	_, err = svc.CreateConversation("alice", domain.Conversation{Kind: domain.ConversationDirect, ParticipantIDs: []string{"bob", "carol"}})
+	assert.Error(t, err)
+}
+
+func TestMessagesRequireMembership(t *testing.T) {
+	repo := newMemoryMessengerRepo()
+	svc := services.NewMessengerService(repo, nil, nil, nil, nil)
+
+	c, err := svc.CreateConversation("alice", domain.Conversation{Kind: domain.ConversationGroup, Title: "team", ParticipantIDs: []string{"bob"}})
+	require.NoError(t, err)
+
+	require.NoError(t, svc.CreateMessage("bob", domain.Message{ConversationID: c.ID, Body: "hi"}))
+	assert.ErrorIs(t, svc.CreateMessage("mallory", domain.Message{ConversationID: c.ID, Body: "hi"}), services.ErrNotParticipant)
+
+	_, err = svc.ReadMessage("alice", repo.lastMessageID)
+	assert.NoError(t, err)
+	_, err = svc.ReadMessage("mallory", repo.lastMessageID)
+	assert.ErrorIs(t, err, services.ErrNotParticipant)
+}
+
+func (r *memoryMessengerRepo) ReadConversationIDs(userID string) ([]string, error) {
+	var ids []string
+	for _, c := range r.conversations {
+		if contains(c.ParticipantIDs, userID) {
+			ids = append(ids, c.ID)
+		}
+	}
+	return ids, nil
+}
+
+// recordingSearchIndex remembers the last query it was asked to run
+type recordingSearchIndex struct {
+	query domain.SearchQuery
+}
+
+func (s *recordingSearchIndex) IndexMessage(domain.Message) error { return nil }
+
+func (s *recordingSearchIndex) RemoveMessage(string) error { return nil }
+
+func (s *recordingSearchIndex) SearchMessages(q domain.SearchQuery) ([]*domain.SearchHit, error) {
+	s.query = q
+	return []*domain.SearchHit{}, nil
+}
+
+func TestSearchMessagesIsScopedToCallersConversations(t *testing.T) {
+	repo := newMemoryMessengerRepo()
+	index := &recordingSearchIndex{}
+	svc := services.NewMessengerService(repo, nil, index, nil, nil)
+
+	team, err := svc.CreateConversation("alice", domain.Conversation{Kind: domain.ConversationGroup, ParticipantIDs: []string{"bob"}})
+	require.NoError(t, err)
+	_, err = svc.CreateConversation("carol", domain.Conversation{Kind: domain.ConversationDirect, ParticipantIDs: []string{"dave"}})
+	require.NoError(t, err)
+
+	_, err = svc.SearchMessages("alice", "  deploy  ", "", 0)
+	require.NoError(t, err)
+	assert.Equal(t, "deploy", index.query.Text)
+	assert.Equal(t, []string{team.ID}, index.query.ConversationIDs)
+	assert.Equal(t, domain.DefaultPageLimit, index.query.Limit)
+
+	_, err = svc.SearchMessages("carol", "deploy", team.ID, 10)
+	assert.ErrorIs(t, err, services.ErrNotParticipant)
+
+	_, err = svc.SearchMessages("alice", "   ", "", 10)
+	assert.ErrorIs(t, err, services.ErrEmptySearchQuery)
+
+	_, err = services.NewMessengerService(repo, nil, nil, nil, nil).SearchMessages("alice", "deploy", "", 10)
+	assert.ErrorIs(t, err, services.ErrSearchUnavailable)
+}
+
+func (r *memoryMessengerRepo) ReadParticipantIDs(conversationID string) ([]string, error) {
+	return r.conversations[conversationID].ParticipantIDs, nil
+}
+
+func (r *memoryMessengerRepo) DeleteMessage(id string) error {
+	m, ok := r.messages[id]
+	if !ok || m.Deleted {
+		return errors.New("message not found")
+	}
+	r.revisions = append(r.revisions, &domain.MessageRevision{MessageID: id, Kind: domain.RevisionDelete, Body: m.Body})
+	m.Body, m.Deleted = "", true
+	return nil
+}
+
+func (r *memoryMessengerRepo) RestoreMessage(id string) error {
+	m := r.messages[id]
+	for i := len(r.revisions) - 1; i >= 0; i-- {
+		if rev := r.revisions[i]; rev.MessageID == id && rev.Kind == domain.RevisionDelete {
+			m.Body, m.Deleted = rev.Body, false
+			return nil
+		}
+	}
+	return errors.New("message has no deleted revision to restore")
+}
+
+// recordingEvents keeps every event published instead of delivering it
+type recordingEvents struct {
+	events []domain.MessageEvent
+}
+
+func (e *recordingEvents) PublishMessageEvent(event domain.MessageEvent, _ []string) error {
+	e.events = append(e.events, event)
+	return nil
+}
+
+func TestDeleteMessageLeavesTombstone(t *testing.T) {
+	repo := newMemoryMessengerRepo()
+	events := &recordingEvents{}
+	svc := services.NewMessengerService(repo, events, nil, nil, nil)
+
+	c, err := svc.CreateConversation("alice", domain.Conversation{Kind: domain.ConversationDirect, ParticipantIDs: []string{"bob"}})
+	require.NoError(t, err)
+	require.NoError(t, svc.CreateMessage("alice", domain.Message{ConversationID: c.ID, Body: "the launch code is 1234"}))
+	id := repo.lastMessageID
+
+	require.NoError(t, svc.DeleteMessage(id))
+	tombstone, err := svc.ReadMessage("bob", id)
+	require.NoError(t, err)
+	assert.True(t, tombstone.Deleted)
+	assert.Empty(t, tombstone.Body)
+
+	// participants are told about the delete without being sent the body
+	last := events.events[len(events.events)-1]
+	assert.Equal(t, domain.MessageDeleted, last.Type)
+	assert.Empty(t, last.Message.Body)
+
+	assert.Error(t, svc.DeleteMessage(id))
+
+	require.NoError(t, svc.RestoreMessage(id))
+	restored, err := svc.ReadMessage("bob", id)
+	require.NoError(t, err)
+	assert.False(t, restored.Deleted)
+	assert.Equal(t, "the launch code is 1234", restored.Body)
+	assert.Equal(t, domain.MessageRestored, events.events[len(events.events)-1].Type)
+}
+
+func TestRepliesStayInTheirThread(t *testing.T) {
+	repo := newMemoryMessengerRepo()
+	svc := services.NewMessengerService(repo, nil, nil, nil, nil)
+
+	team, err := svc.CreateConversation("alice", domain.Conversation{Kind: domain.ConversationGroup, ParticipantIDs: []string{"bob"}})
+	require.NoError(t, err)
+	other, err := svc.CreateConversation("alice", domain.Conversation{Kind: domain.ConversationDirect, ParticipantIDs: []string{"carol"}})
+	require.NoError(t, err)
+
+	require.NoError(t, svc.CreateMessage("alice", domain.Message{ConversationID: team.ID, Body: "release notes?"}))
+	parentID := repo.lastMessageID
+
+	// the reply picks up its parent's conversation
+	require.NoError(t, svc.CreateMessage("bob", domain.Message{ParentID: parentID, Body: "on it"}))
+	reply := repo.messages[repo.lastMessageID]
+	assert.Equal(t, team.ID, reply.ConversationID)
+
+	assert.ErrorIs(t, svc.CreateMessage("alice", domain.Message{ParentID: reply.ID, Body: "thanks"}), services.ErrNestedReply)
+	assert.Error(t, svc.CreateMessage("alice", domain.Message{ParentID: parentID, ConversationID: other.ID, Body: "wrong room"}))
+	assert.ErrorIs(t, svc.CreateMessage("carol", domain.Message{ParentID: parentID, Body: "hi"}), services.ErrNotParticipant)
+
+	require.NoError(t, svc.DeleteMessage(parentID))
+	assert.Error(t, svc.CreateMessage("bob", domain.Message{ParentID: parentID, Body: "too late"}))
+}
+
+func (r *memoryMessengerRepo) ToggleReaction(messageID, userID, emoji string) (bool, error) {
+	if r.reactions == nil {
+		r.reactions = map[string]bool{}
+	}
+	key := messageID + "/" + userID + "/" + emoji
+	r.reactions[key] = !r.reactions[key]
+	return r.reactions[key], nil
+}
+
+func (r *memoryMessengerRepo) MarkConversationRead(conversationID, userID string, readAt time.Time) error {
+	r.readAt = readAt
+	return nil
+}
+
+func TestToggleReaction(t *testing.T) {
+	repo := newMemoryMessengerRepo()
+	svc := services.NewMessengerService(repo, nil, nil, nil, nil)
+
+	c, err := svc.CreateConversation("alice", domain.Conversation{Kind: domain.ConversationDirect, ParticipantIDs: []string{"bob"}})
+	require.NoError(t, err)
+	require.NoError(t, svc.CreateMessage("alice", domain.Message{ConversationID: c.ID, Body: "shipped"}))
+	id := repo.lastMessageID
+
+	reacted, err := svc.ToggleReaction("bob", id, "🎉")
+	require.NoError(t, err)
+	assert.True(t, reacted)
+	reacted, err = svc.ToggleReaction("bob", id, "🎉")
+	require.NoError(t, err)
+	assert.False(t, reacted)
+
+	for _, emoji := range []string{"", "thumbs up", "this-is-far-too-long-to-be-an-emoji"} {
+		_, err = svc.ToggleReaction("bob", id, emoji)
+		assert.ErrorIs(t, err, services.ErrInvalidEmoji, emoji)
+	}
+	_, err = svc.ToggleReaction("mallory", id, "🎉")
+	assert.ErrorIs(t, err, services.ErrNotParticipant)
+}
+
+func TestMarkConversationReadUsesMessageTime(t *testing.T) {
+	repo := newMemoryMessengerRepo()
+	svc := services.NewMessengerService(repo, nil, nil, nil, nil)
+
+	c, err := svc.CreateConversation("alice", domain.Conversation{Kind: domain.ConversationDirect, ParticipantIDs: []string{"bob"}})
+	require.NoError(t, err)
+	other, err := svc.CreateConversation("alice", domain.Conversation{Kind: domain.ConversationDirect, ParticipantIDs: []string{"carol"}})
+	require.NoError(t, err)
+	require.NoError(t, svc.CreateMessage("alice", domain.Message{ConversationID: c.ID, Body: "hello"}))
+	message := repo.messages[repo.lastMessageID]
+
+	require.NoError(t, svc.MarkConversationRead("bob", c.ID, message.ID))
+	assert.Equal(t, message.CreatedAt, repo.readAt)
+
+	assert.Error(t, svc.MarkConversationRead("alice", other.ID, message.ID))
+	assert.ErrorIs(t, svc.MarkConversationRead("carol", c.ID, ""), services.ErrNotParticipant)
+}
@@ -0,0 +1,177 @@
+package unit
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/LordMoMA/Hexagonal-Architecture/internal/adapters/moderation"
+	"github.com/LordMoMA/Hexagonal-Architecture/internal/core/domain"
+	"github.com/LordMoMA/Hexagonal-Architecture/internal/core/ports"
+	"github.com/LordMoMA/Hexagonal-Architecture/internal/core/services"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRuleModerator(t *testing.T) {
+	cfg := moderation.DefaultConfig()
+	cfg.RejectWords = []string{"scam"}
+	cfg.FlagWords = []string{"crypto"}
+	cfg.Rules = []moderation.Rule{{Pattern: `\b\d{4}[- ]?\d{4}[- ]?\d{4}[- ]?\d{4}\b`, Action: domain.ModerationReject, Reason: "card number"}}
+	cfg.MaxLinks = 2
+	m, err := moderation.NewRuleModerator(cfg, nil)
+	require.NoError(t, err)
+
+	cases := []struct {
+		body   string
+		action string
+	}{
+		{"lunch at noon?", domain.ModerationAllow},
+		{"this is a SCAM", domain.ModerationReject},
+		{"scammer is not a listed word", domain.ModerationAllow},
+		{"anyone into crypto?", domain.ModerationFlag},
+		{"my card is 4111 1111 1111 1111", domain.ModerationReject},
+		{"see https://a.example https://b.example www.c.example", domain.ModerationFlag},
+		{"wow" + strings.Repeat("!", 40), domain.ModerationFlag},
+	}
+	for _, c := range cases {
+		verdict, err := m.Moderate(domain.Message{UserID: "alice", Body: c.body})
+		require.NoError(t, err)
+		assert.Equal(t, c.action, verdict.Action, c.body)
+	}
+
+	_, err = moderation.NewRuleModerator(moderation.Config{Rules: []moderation.Rule{{Pattern: "x", Action: "ban"}}}, nil)
+	assert.Error(t, err)
+}
+
+func TestRuleModeratorPostingRate(t *testing.T) {
+	cfg := moderation.DefaultConfig()
+	cfg.PostsPerMinute = 3
+	m, err := moderation.NewRuleModerator(cfg, newMemoryCache())
+	require.NoError(t, err)
+
+	for i := 0; i < 3; i++ {
+		verdict, err := m.Moderate(domain.Message{UserID: "alice", Body: "hi"})
+		require.NoError(t, err)
+		assert.Equal(t, domain.ModerationAllow, verdict.Action)
+	}
+	verdict, err := m.Moderate(domain.Message{UserID: "alice", Body: "hi"})
+	require.NoError(t, err)
+	assert.Equal(t, domain.ModerationReject, verdict.Action)
+
+	// the limit is per user
+	verdict, err = m.Moderate(domain.Message{UserID: "bob", Body: "hi"})
+	require.NoError(t, err)
+	assert.Equal(t, domain.ModerationAllow, verdict.Action)
+}
+
+func TestCreateMessageIsModerated(t *testing.T) {
+	cfg := moderation.DefaultConfig()
+	cfg.RejectWords = []string{"scam"}
+	cfg.FlagWords = []string{"crypto"}
+	moderator, err := moderation.NewRuleModerator(cfg, nil)
+	require.NoError(t, err)
+
+	repo := newMemoryMessengerRepo()
+	svc := services.NewMessengerService(repo, nil, nil, nil, moderator)
+	c, err := svc.CreateConversation("alice", domain.Conversation{Kind: domain.ConversationDirect, ParticipantIDs: []string{"bob"}})
+	require.NoError(t, err)
+
+	err = svc.CreateMessage("alice", domain.Message{ConversationID: c.ID, Body: "totally not a scam"})
+	assert.ErrorIs(t, err, services.ErrMessageRejected)
+	assert.Empty(t, repo.messages)
+
+	require.NoError(t, svc.CreateMessage("alice", domain.Message{ConversationID: c.ID, Body: "crypto tips inside"}))
+	assert.Equal(t, []string{"contains a watched word"}, repo.messages[repo.lastMessageID].FlaggedFor)
+}
+
+func TestMessageBatchCountsAsOnePost(t *testing.T) {
+	cfg := moderation.DefaultConfig()
+	cfg.FlagWords = []string{"crypto"}
+	moderator, err := moderation.NewRuleModerator(cfg, newMemoryCache())
+	require.NoError(t, err)
+	svc := services.NewMessengerService(&batchMessageRepo{}, nil, nil, nil, moderator)
+
+	items := make([]domain.Message, cfg.PostsPerMinute+20)
+	for i := range items {
+		items[i] = domain.Message{ConversationID: "c1", Body: fmt.Sprintf("imported %d", i)}
+	}
+	items[40].Body = "crypto tips inside"
+	for _, atomic := range []bool{true, false} {
+		created, errs := svc.CreateMessages("u1", items, atomic)
+		for i, err := range errs {
+			require.NoError(t, err, "atomic %v, item %d", atomic, i)
+			require.NotNil(t, created[i])
+		}
+		// each item is still judged on its own
+		assert.Equal(t, []string{"contains a watched word"}, created[40].FlaggedFor)
+		assert.Empty(t, created[0].FlaggedFor)
+	}
+
+	// the batches took two posts of the minute, leaving the rest to single posts
+	for i := 2; i < cfg.PostsPerMinute; i++ {
+		require.NoError(t, svc.CreateMessage("u1", domain.Message{ConversationID: "c1", Body: "hi"}), "post %d", i+1)
+	}
+	assert.ErrorIs(t, svc.CreateMessage("u1", domain.Message{ConversationID: "c1", Body: "hi"}), services.ErrMessageRejected)
+	_, errs := svc.CreateMessages("u1", items[:2], false)
+	for _, err := range errs {
+		assert.ErrorIs(t, err, services.ErrMessageRejected)
+	}
+}
+
+// reviewRepo holds one flagged message, whose deletion fails until
+// deleteErr is cleared
+type reviewRepo struct {
+	ports.MessengerRepository
+	message   *domain.Message
+	flag      *domain.FlaggedMessage
+	deleteErr error
+}
+
+func (r *reviewRepo) ReadFlaggedMessage(id string) (*domain.FlaggedMessage, error) {
+	if id != r.flag.ID {
+		return nil, domain.NotFound("flagged_message_not_found", "flagged message not found")
+	}
+	return r.flag, nil
+}
+
+func (r *reviewRepo) ReviewFlaggedMessage(id, reviewerID, decision string) (*domain.FlaggedMessage, error) {
+	now := time.Now()
+	r.flag.ReviewedAt, r.flag.ReviewedBy, r.flag.Decision = &now, reviewerID, decision
+	return r.flag, nil
+}
+
+func (r *reviewRepo) ReadMessage(id string) (*domain.Message, error) {
+	return r.message, nil
+}
+
+func (r *reviewRepo) DeleteMessage(id string) error {
+	if r.deleteErr != nil {
+		return r.deleteErr
+	}
+	r.message.Deleted = true
+	return nil
+}
+
+func TestRemovalFailingLeavesFlagInQueue(t *testing.T) {
+	repo := &reviewRepo{
+		message:   &domain.Message{ID: "m1", Body: "crypto tips inside"},
+		flag:      &domain.FlaggedMessage{ID: "f1", MessageID: "m1"},
+		deleteErr: domain.Unavailable("database_unavailable", "message not deleted"),
+	}
+	svc := services.NewMessengerService(repo, nil, nil, nil, nil)
+
+	_, err := svc.ReviewFlaggedMessage("admin", "f1", domain.ReviewRemove)
+	assert.ErrorIs(t, err, domain.ErrUnavailable)
+	assert.Nil(t, repo.flag.ReviewedAt)
+
+	repo.deleteErr = nil
+	flagged, err := svc.ReviewFlaggedMessage("admin", "f1", domain.ReviewRemove)
+	require.NoError(t, err)
+	assert.True(t, repo.message.Deleted)
+	assert.Equal(t, domain.ReviewRemove, flagged.Decision)
+
+	_, err = svc.ReviewFlaggedMessage("admin", "f1", domain.ReviewApprove)
+	assert.ErrorIs(t, err, services.ErrFlagReviewed)
+}
@@ -0,0 +1,325 @@
+package unit
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/LordMoMA/Hexagonal-Architecture/internal/adapters/handler"
+	"github.com/LordMoMA/Hexagonal-Architecture/internal/adapters/oidc"
+	"github.com/LordMoMA/Hexagonal-Architecture/internal/adapters/repository"
+	"github.com/LordMoMA/Hexagonal-Architecture/internal/core/domain"
+	"github.com/LordMoMA/Hexagonal-Architecture/internal/core/ports"
+	"github.com/LordMoMA/Hexagonal-Architecture/internal/core/services"
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	stubClientID     = "hex-arch"
+	stubClientSecret = "s3cret"
+	stubRedirectURL  = "http://localhost:4242/v1/auth/oidc/callback"
+)
+
+// stubIdP is a minimal OpenID provider: it issues one code per /authorize
+// call and redeems it at /token when the PKCE verifier matches.
+type stubIdP struct {
+	*httptest.Server
+	key           *rsa.PrivateKey
+	email         string
+	emailVerified bool
+
+	mu    sync.Mutex
+	codes map[string]stubGrant
+}
+
+type stubGrant struct {
+	challenge string
+	nonce     string
+}
+
+func newStubIdP(t *testing.T) *stubIdP {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+
+	idp := &stubIdP{key: key, email: "alan@example.com", emailVerified: true, codes: map[string]stubGrant{}}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, map[string]string{
+			"issuer":                 idp.URL,
+			"authorization_endpoint": idp.URL + "/authorize",
+			"token_endpoint":         idp.URL + "/token",
+			"jwks_uri":               idp.URL + "/jwks",
+		})
+	})
+	mux.HandleFunc("/jwks", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, map[string]interface{}{"keys": []map[string]string{{
+			"kid": "stub-key",
+			"kty": "RSA",
+			"use": "sig",
+			"n":   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+			"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+		}}})
+	})
+	mux.HandleFunc("/authorize", func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		if q.Get("client_id") != stubClientID || q.Get("code_challenge_method") != "S256" {
+			http.Error(w, "bad request", http.StatusBadRequest)
+			return
+		}
+		code := fmt.Sprintf("code-%d", time.Now().UnixNano())
+		idp.mu.Lock()
+		idp.codes[code] = stubGrant{challenge: q.Get("code_challenge"), nonce: q.Get("nonce")}
+		idp.mu.Unlock()
+		http.Redirect(w, r, q.Get("redirect_uri")+"?code="+code+"&state="+url.QueryEscape(q.Get("state")), http.StatusFound)
+	})
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		id, secret, _ := r.BasicAuth()
+		if id != stubClientID || secret != stubClientSecret {
+			w.WriteHeader(http.StatusUnauthorized)
+			writeJSON(w, map[string]string{"error": "invalid_client"})
+			return
+		}
+		idp.mu.Lock()
+		grant, ok := idp.codes[r.PostFormValue("code")]
+		delete(idp.codes, r.PostFormValue("code"))
+		idp.mu.Unlock()
+		if !ok || oidc.CodeChallengeS256(r.PostFormValue("code_verifier")) != grant.challenge {
+			w.WriteHeader(http.StatusBadRequest)
+			writeJSON(w, map[string]string{"error": "invalid_grant"})
+			return
+		}
+		writeJSON(w, map[string]string{
+			"access_token": "opaque",
+			"token_type":   "Bearer",
+			"id_token":     idp.idToken(t, stubClientID, grant.nonce),
+		})
+	})
+	idp.Server = httptest.NewServer(mux)
+	t.Cleanup(idp.Close)
+	return idp
+}
+
+func (idp *stubIdP) idToken(t *testing.T, audience, nonce string) string {
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, jwt.MapClaims{
+		"iss":            idp.URL,
+		"sub":            "stub-user-1",
+		"aud":            audience,
+		"iat":            time.Now().Unix(),
+		"exp":            time.Now().Add(5 * time.Minute).Unix(),
+		"nonce":          nonce,
+		"email":          idp.email,
+		"email_verified": idp.emailVerified,
+	})
+	token.Header["kid"] = "stub-key"
+	signed, err := token.SignedString(idp.key)
+	require.NoError(t, err)
+	return signed
+}
+
+// authorize follows the provider's sign-in step and returns the code and
+// state it redirects back with.
+func (idp *stubIdP) authorize(t *testing.T, authURL string) (string, string) {
+	client := &http.Client{CheckRedirect: func(*http.Request, []*http.Request) error {
+		return http.ErrUseLastResponse
+	}}
+	resp, err := client.Get(authURL)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusFound, resp.StatusCode)
+
+	location, err := url.Parse(resp.Header.Get("Location"))
+	require.NoError(t, err)
+	return location.Query().Get("code"), location.Query().Get("state")
+}
+
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+type memoryCache struct {
+	items map[string][]byte
+}
+
+func newMemoryCache() *memoryCache {
+	return &memoryCache{items: map[string][]byte{}}
+}
+
+func (c *memoryCache) Set(key string, value interface{}, _ time.Duration) error {
+	data, err := json.Marshal(value)
+	c.items[key] = data
+	return err
+}
+
+func (c *memoryCache) Get(key string, value interface{}) error {
+	data, ok := c.items[key]
+	if !ok {
+		return fmt.Errorf("cache miss for key %q", key)
+	}
+	return json.Unmarshal(data, value)
+}
+
+func (c *memoryCache) Delete(key string) error {
+	delete(c.items, key)
+	return nil
+}
+
+func (c *memoryCache) Increment(key string, _ time.Duration) (int64, error) {
+	var n int64
+	_ = c.Get(key, &n)
+	n++
+	return n, c.Set(key, n, 0)
+}
+
+// oidcUserRepo records the identity handed to it instead of touching the DB.
+// With mfa set it answers as for a user with 2FA on.
+type oidcUserRepo struct {
+	ports.UserRepository
+	identities []domain.ExternalIdentity
+	mfa        bool
+}
+
+func (r *oidcUserRepo) LoginUserOIDC(identity domain.ExternalIdentity, _ domain.ClientInfo) (*repository.LoginResponse, error) {
+	r.identities = append(r.identities, identity)
+	if r.mfa {
+		return &repository.LoginResponse{ID: "user-1", Email: identity.Email, MFARequired: true, MFAToken: "challenge"}, nil
+	}
+	return &repository.LoginResponse{ID: "user-1", Email: identity.Email, AccessToken: "access"}, nil
+}
+
+func TestOIDCLoginWithStubProvider(t *testing.T) {
+	idp := newStubIdP(t)
+	provider, err := oidc.NewProvider(idp.URL, stubClientID, stubClientSecret, stubRedirectURL)
+	require.NoError(t, err)
+
+	repo := &oidcUserRepo{}
+	svc := services.NewOIDCService(provider, repo, newMemoryCache())
+
+	authURL, err := svc.BeginLogin()
+	require.NoError(t, err)
+	code, state := idp.authorize(t, authURL)
+
+	response, err := svc.CompleteLogin(state, code, domain.ClientInfo{})
+	require.NoError(t, err)
+	assert.Equal(t, "alan@example.com", response.Email)
+	require.Len(t, repo.identities, 1)
+	assert.Equal(t, idp.URL, repo.identities[0].Issuer)
+	assert.Equal(t, "stub-user-1", repo.identities[0].Subject)
+
+	// the state was consumed by the first callback
+	_, err = svc.CompleteLogin(state, code, domain.ClientInfo{})
+	assert.Error(t, err)
+}
+
+func TestOIDCLoginRequiresVerifiedEmail(t *testing.T) {
+	idp := newStubIdP(t)
+	idp.emailVerified = false
+	provider, err := oidc.NewProvider(idp.URL, stubClientID, stubClientSecret, stubRedirectURL)
+	require.NoError(t, err)
+
+	repo := &oidcUserRepo{}
+	svc := services.NewOIDCService(provider, repo, newMemoryCache())
+
+	authURL, err := svc.BeginLogin()
+	require.NoError(t, err)
+	code, state := idp.authorize(t, authURL)
+
+	_, err = svc.CompleteLogin(state, code, domain.ClientInfo{})
+	assert.Error(t, err)
+	assert.Empty(t, repo.identities)
+}
+
+func TestOIDCExchangeRejectsWrongVerifier(t *testing.T) {
+	idp := newStubIdP(t)
+	provider, err := oidc.NewProvider(idp.URL, stubClientID, stubClientSecret, stubRedirectURL)
+	require.NoError(t, err)
+
+	code, _ := idp.authorize(t, provider.AuthCodeURL("state", "nonce", "the-real-verifier"))
+	_, err = provider.Exchange(code, "some-other-verifier", "nonce")
+	assert.Error(t, err)
+}
+
+func TestOIDCVerifyIDToken(t *testing.T) {
+	idp := newStubIdP(t)
+	provider, err := oidc.NewProvider(idp.URL, stubClientID, stubClientSecret, stubRedirectURL)
+	require.NoError(t, err)
+
+	identity, err := provider.VerifyIDToken(idp.idToken(t, stubClientID, "n1"), "n1")
+	require.NoError(t, err)
+	assert.True(t, identity.EmailVerified)
+
+	_, err = provider.VerifyIDToken(idp.idToken(t, stubClientID, "n1"), "n2")
+	assert.Error(t, err, "nonce mismatch")
+
+	_, err = provider.VerifyIDToken(idp.idToken(t, "someone-else", "n1"), "n1")
+	assert.Error(t, err, "wrong audience")
+
+	// a token signed by a key outside the provider's JWKS
+	other, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	forged := &stubIdP{Server: idp.Server, key: other, email: idp.email, emailVerified: true}
+	_, err = provider.VerifyIDToken(forged.idToken(t, stubClientID, "n1"), "n1")
+	assert.Error(t, err, "bad signature")
+}
+
+func TestOIDCCallbackPassesOnMFAChallenge(t *testing.T) {
+	idp := newStubIdP(t)
+	provider, err := oidc.NewProvider(idp.URL, stubClientID, stubClientSecret, stubRedirectURL)
+	require.NoError(t, err)
+	svc := services.NewOIDCService(provider, &oidcUserRepo{mfa: true}, newMemoryCache())
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.GET("/v1/auth/oidc/callback", handler.NewOIDCHandler(*svc).Callback)
+
+	authURL, err := svc.BeginLogin()
+	require.NoError(t, err)
+	code, state := idp.authorize(t, authURL)
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/v1/auth/oidc/callback?"+url.Values{"state": {state}, "code": {code}}.Encode(), nil))
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var body map[string]interface{}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+	assert.Equal(t, map[string]interface{}{"mfa_required": true, "mfa_token": "challenge"}, body)
+}
+
+func TestOIDCLoginChallengesTOTPUsers(t *testing.T) {
+	withAPIConfig(t)
+	store, db := testStore(t)
+	email := testEmail("oidc-totp")
+	user, err := store.CreateUser(email, "correct-horse-battery")
+	require.NoError(t, err)
+	secret, err := repository.GenerateTOTPSecret()
+	require.NoError(t, err)
+	require.NoError(t, db.Model(&domain.User{}).Where("id = ?", user.ID).
+		Updates(map[string]interface{}{"totp_secret": secret, "totp_enabled": true}).Error)
+
+	identity := domain.ExternalIdentity{Issuer: "https://idp.example.com", Subject: uuid.NewString(), Email: email, EmailVerified: true}
+	// first when the identity is linked by email, then once it is linked
+	for i := 0; i < 2; i++ {
+		response, err := store.LoginUserOIDC(identity, testClient())
+		require.NoError(t, err)
+		assert.Equal(t, user.ID, response.ID)
+		assert.True(t, response.MFARequired)
+		assert.NotEmpty(t, response.MFAToken)
+		assert.Empty(t, response.AccessToken)
+		assert.Empty(t, response.RefreshToken)
+	}
+
+	sessions, err := store.ListSessions(user.ID)
+	require.NoError(t, err)
+	assert.Empty(t, sessions)
+}
@@ -0,0 +1,137 @@
+package unit
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/LordMoMA/Hexagonal-Architecture/internal/adapters/handler"
+	"github.com/LordMoMA/Hexagonal-Architecture/internal/adapters/realtime"
+	"github.com/LordMoMA/Hexagonal-Architecture/internal/core/services"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type openAPIDocument struct {
+	OpenAPI    string                                `json:"openapi"`
+	Paths      map[string]map[string]json.RawMessage `json:"paths"`
+	Components struct {
+		Schemas map[string]json.RawMessage `json:"schemas"`
+	} `json:"components"`
+}
+
+// apiRouter mounts every route the server does, SSO included. The services
+// are never called, so they need no repositories.
+func apiRouter() *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	svc := handler.Services{
+		Messages: services.NewMessengerService(nil, nil, nil, nil, nil),
+		Users:    services.NewUserService(nil),
+		Payments: services.NewPaymentService(nil),
+		OIDC:     services.NewOIDCService(nil, nil, nil),
+		Hub:      realtime.NewHub(nil),
+	}
+	handler.RegisterRoutes(router, svc)
+	return router
+}
+
+func fetchOpenAPI(t *testing.T, router *gin.Engine) ([]byte, openAPIDocument) {
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/openapi.json", nil))
+	require.Equal(t, http.StatusOK, w.Code)
+
+	var doc openAPIDocument
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &doc))
+	return w.Body.Bytes(), doc
+}
+
+// specPath turns gin's /v1/messages/:id into OpenAPI's /v1/messages/{id}.
+func specPath(path string) string {
+	segments := strings.Split(path, "/")
+	for i, segment := range segments {
+		if strings.HasPrefix(segment, ":") || strings.HasPrefix(segment, "*") {
+			segments[i] = "{" + segment[1:] + "}"
+		}
+	}
+	return strings.Join(segments, "/")
+}
+
+func TestOpenAPIDocumentsEveryRoute(t *testing.T) {
+	router := apiRouter()
+	_, doc := fetchOpenAPI(t, router)
+	assert.Equal(t, "3.0.3", doc.OpenAPI)
+
+	routed := map[string]bool{}
+	for _, route := range router.Routes() {
+		path, method := specPath(route.Path), strings.ToLower(route.Method)
+		routed[method+" "+path] = true
+		_, ok := doc.Paths[path][method]
+		assert.True(t, ok, "%s %s is missing from the OpenAPI document", route.Method, route.Path)
+	}
+
+	for path, item := range doc.Paths {
+		for method := range item {
+			assert.True(t, routed[method+" "+path], "%s %s is documented but not routed", strings.ToUpper(method), path)
+		}
+	}
+}
+
+func TestOpenAPIReferencesResolve(t *testing.T) {
+	body, doc := fetchOpenAPI(t, apiRouter())
+
+	const prefix = `"$ref":"#/components/schemas/`
+	refs := strings.Split(string(body), prefix)[1:]
+	require.NotEmpty(t, refs)
+	for _, ref := range refs {
+		name := ref[:strings.Index(ref, `"`)]
+		assert.Contains(t, doc.Components.Schemas, name)
+	}
+}
+
+func TestOpenAPIDescribesErrorsAndSchemas(t *testing.T) {
+	_, doc := fetchOpenAPI(t, apiRouter())
+
+	var problem struct {
+		Properties map[string]json.RawMessage `json:"properties"`
+	}
+	require.NoError(t, json.Unmarshal(doc.Components.Schemas["Problem"], &problem))
+	for _, field := range []string{"type", "title", "status", "detail", "code", "errors"} {
+		assert.Contains(t, problem.Properties, field)
+	}
+	// v1 sends the detail under "error" too, as it always has
+	problem.Properties = nil
+	require.NoError(t, json.Unmarshal(doc.Components.Schemas["V1Problem"], &problem))
+	for _, field := range []string{"error", "detail", "code"} {
+		assert.Contains(t, problem.Properties, field)
+	}
+	assert.Contains(t, string(doc.Paths["/v1/messages"]["get"]), `"application/json; charset=utf-8"`)
+	assert.Contains(t, string(doc.Paths["/v2/messages"]["get"]), `"application/problem+json"`)
+
+	var createUser struct {
+		Required   []string `json:"required"`
+		Properties map[string]struct {
+			Format    string `json:"format"`
+			MaxLength int    `json:"maxLength"`
+		} `json:"properties"`
+	}
+	require.NoError(t, json.Unmarshal(doc.Components.Schemas["CreateUserRequest"], &createUser))
+	assert.ElementsMatch(t, []string{"email", "password"}, createUser.Required)
+	assert.Equal(t, "email", createUser.Properties["email"].Format)
+	assert.Equal(t, 255, createUser.Properties["email"].MaxLength)
+
+	// secrets never make it into the documented user
+	assert.NotContains(t, string(doc.Components.Schemas["UserResponse"]), "password")
+}
+
+func TestAPIDocsPage(t *testing.T) {
+	w := httptest.NewRecorder()
+	apiRouter().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/docs", nil))
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Header().Get("Content-Type"), "text/html")
+	assert.Contains(t, w.Body.String(), "/openapi.json")
+}
@@ -0,0 +1,197 @@
+package unit
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/LordMoMA/Hexagonal-Architecture/internal/adapters/handler"
+	"github.com/LordMoMA/Hexagonal-Architecture/internal/adapters/repository"
+	"github.com/LordMoMA/Hexagonal-Architecture/internal/core/domain"
+	"github.com/LordMoMA/Hexagonal-Architecture/internal/core/ports"
+	"github.com/LordMoMA/Hexagonal-Architecture/internal/core/services"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/jinzhu/gorm"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// listOptionsRepo records the options each list call was made with
+type listOptionsRepo struct {
+	ports.UserRepository
+	opts []domain.ListOptions
+}
+
+func (r *listOptionsRepo) ReadUsers(opts domain.ListOptions) (*domain.Page[*domain.User], error) {
+	r.opts = append(r.opts, opts)
+	return &domain.Page[*domain.User]{}, nil
+}
+
+func TestParseListOptions(t *testing.T) {
+	since := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	until := since.Add(time.Hour)
+	tests := []struct {
+		query string
+		want  domain.ListOptions
+		code  string
+	}{
+		{query: "", want: domain.ListOptions{}},
+		{query: "limit=10&cursor=abc&sort=-email&user_id=u1", want: domain.ListOptions{Limit: 10, Cursor: "abc", Sort: "-email", UserID: "u1"}},
+		{query: "limit=1", want: domain.ListOptions{Limit: 1}},
+		{query: fmt.Sprintf("limit=%d", domain.MaxPageLimit), want: domain.ListOptions{Limit: domain.MaxPageLimit}},
+		{query: "since=2024-01-02T03:04:05Z&until=2024-01-02T05:04:05%2B01:00", want: domain.ListOptions{Since: &since, Until: &until}},
+		{query: "limit=0", code: "invalid_limit"},
+		{query: "limit=-5", code: "invalid_limit"},
+		{query: fmt.Sprintf("limit=%d", domain.MaxPageLimit+1), code: "invalid_limit"},
+		{query: "limit=ten", code: "invalid_limit"},
+		{query: "since=yesterday", code: "invalid_time"},
+		{query: "until=2024-01-02", code: "invalid_time"},
+	}
+
+	gin.SetMode(gin.TestMode)
+	for _, tt := range tests {
+		repo := &listOptionsRepo{}
+		router := gin.New()
+		router.GET("/v2/users", handler.NewUserHandler(*services.NewUserService(repo)).ReadUsers)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/v2/users?"+tt.query, nil))
+
+		if tt.code != "" {
+			assert.Equal(t, http.StatusUnprocessableEntity, w.Code, tt.query)
+			var problem handler.Problem
+			require.NoError(t, json.Unmarshal(w.Body.Bytes(), &problem))
+			assert.Equal(t, tt.code, problem.Code, tt.query)
+			assert.Empty(t, repo.opts, tt.query)
+			continue
+		}
+		require.Equal(t, http.StatusOK, w.Code, tt.query)
+		require.Len(t, repo.opts, 1, tt.query)
+		got := repo.opts[0]
+		assert.Equal(t, tt.want.Limit, got.Limit, tt.query)
+		assert.Equal(t, tt.want.Cursor, got.Cursor, tt.query)
+		assert.Equal(t, tt.want.Sort, got.Sort, tt.query)
+		assert.Equal(t, tt.want.UserID, got.UserID, tt.query)
+		for name, pair := range map[string][2]*time.Time{"since": {tt.want.Since, got.Since}, "until": {tt.want.Until, got.Until}} {
+			if pair[0] == nil {
+				assert.Nil(t, pair[1], "%s %s", tt.query, name)
+			} else if assert.NotNil(t, pair[1], "%s %s", tt.query, name) {
+				assert.True(t, pair[0].Equal(*pair[1]), "%s %s", tt.query, name)
+			}
+		}
+	}
+}
+
+// seedUsers inserts n users straight into the table, skipping bcrypt, so
+// there are always more than a page of them.
+func seedUsers(t *testing.T, db *gorm.DB, n int) {
+	for i := 0; i < n; i++ {
+		require.NoError(t, db.Create(&domain.User{ID: uuid.NewString(), Email: testEmail("page"), Password: "x"}).Error)
+	}
+}
+
+// readAllUsers follows the cursors through every page of users in order.
+func readAllUsers(t *testing.T, store *repository.DB, sort string) []string {
+	var ids []string
+	opts := domain.ListOptions{Sort: sort, Limit: 7}
+	for {
+		page, err := store.ReadUsers(opts)
+		require.NoError(t, err, sort)
+		for _, user := range page.Items {
+			ids = append(ids, user.ID)
+		}
+		if page.NextCursor == "" {
+			return ids
+		}
+		opts.Cursor = page.NextCursor
+	}
+}
+
+func encodeTestCursor(v interface{}) string {
+	data, _ := json.Marshal(v)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func TestPageLimitIsClamped(t *testing.T) {
+	store, db := testStore(t)
+	seedUsers(t, db, domain.MaxPageLimit+1)
+
+	tests := []struct {
+		limit, want int
+	}{
+		{0, domain.DefaultPageLimit},
+		{-1, domain.DefaultPageLimit},
+		{1, 1},
+		{7, 7},
+		{domain.MaxPageLimit, domain.MaxPageLimit},
+		{domain.MaxPageLimit + 1, domain.MaxPageLimit},
+		{10000, domain.MaxPageLimit},
+	}
+	for _, tt := range tests {
+		page, err := store.ReadUsers(domain.ListOptions{Limit: tt.limit})
+		require.NoError(t, err, "limit %d", tt.limit)
+		assert.Len(t, page.Items, tt.want, "limit %d", tt.limit)
+		assert.NotEmpty(t, page.NextCursor, "limit %d", tt.limit)
+	}
+}
+
+func TestSortOrder(t *testing.T) {
+	store, db := testStore(t)
+	seedUsers(t, db, 3)
+
+	var count int
+	require.NoError(t, db.Model(&domain.User{}).Count(&count).Error)
+
+	// whatever the collation, descending is ascending backwards, and the
+	// cursors walk every row once
+	asc := readAllUsers(t, store, "email")
+	assert.Len(t, asc, count)
+	assert.Equal(t, asc, readAllUsers(t, store, ""))
+	desc := readAllUsers(t, store, "-email")
+	require.Len(t, desc, len(asc))
+	for i := range asc {
+		assert.Equal(t, asc[i], desc[len(desc)-1-i])
+	}
+
+	for _, sort := range []string{"created_at", "-created_at", "-", "--email", "email-", "EMAIL", " email"} {
+		_, err := store.ReadUsers(domain.ListOptions{Sort: sort})
+		assert.Equal(t, "invalid_sort", errorCode(err), sort)
+	}
+	_, err := store.ReadMessages(uuid.NewString(), domain.ListOptions{Sort: "-created_at"})
+	assert.NoError(t, err)
+	_, err = store.ReadMessages(uuid.NewString(), domain.ListOptions{Sort: "email"})
+	assert.Equal(t, "invalid_sort", errorCode(err))
+}
+
+func TestMalformedAndTamperedCursorsAreRejected(t *testing.T) {
+	store, db := testStore(t)
+	seedUsers(t, db, 2)
+	page, err := store.ReadUsers(domain.ListOptions{Limit: 1})
+	require.NoError(t, err)
+	_, err = store.ReadUsers(domain.ListOptions{Limit: 1, Cursor: page.NextCursor})
+	require.NoError(t, err)
+
+	for name, cursor := range map[string]string{
+		"not base64":       "not a cursor!",
+		"padded base64":    base64.URLEncoding.EncodeToString([]byte(`{"k":"a","id":"bc"}`)),
+		"not JSON":         base64.RawURLEncoding.EncodeToString([]byte("k=a,id=b")),
+		"JSON array":       encodeTestCursor([]string{"a", "b"}),
+		"no id":            encodeTestCursor(map[string]string{"k": "a@example.com"}),
+		"id of wrong type": encodeTestCursor(map[string]interface{}{"k": "a@example.com", "id": 7}),
+		"truncated":        page.NextCursor[:len(page.NextCursor)/2],
+	} {
+		_, err := store.ReadUsers(domain.ListOptions{Cursor: cursor})
+		assert.ErrorIs(t, err, repository.ErrInvalidCursor, name)
+	}
+
+	// a user cursor decodes, but its key is no message timestamp
+	_, err = store.ReadMessages(uuid.NewString(), domain.ListOptions{Cursor: page.NextCursor})
+	assert.ErrorIs(t, err, repository.ErrInvalidCursor)
+	messageCursor := encodeTestCursor(map[string]string{"k": time.Now().UTC().Format(time.RFC3339Nano), "id": uuid.NewString()})
+	_, err = store.ReadMessages(uuid.NewString(), domain.ListOptions{Cursor: messageCursor})
+	assert.NoError(t, err)
+}
@@ -0,0 +1,109 @@
+package unit
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/LordMoMA/Hexagonal-Architecture/internal/adapters/pubsub"
+	"github.com/LordMoMA/Hexagonal-Architecture/internal/adapters/realtime"
+	"github.com/LordMoMA/Hexagonal-Architecture/internal/core/domain"
+	"github.com/LordMoMA/Hexagonal-Architecture/internal/core/ports"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHubFansOutAcrossInstances(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// two hubs sharing one PubSub stand in for two server instances
+	ps := pubsub.NewMemoryPubSub()
+	hubA, hubB := realtime.NewHub(ps), realtime.NewHub(ps)
+	go hubA.Run(ctx)
+	go hubB.Run(ctx)
+
+	bob := hubB.Subscribe("bob")
+	mallory := hubB.Subscribe("mallory")
+	defer hubB.Unsubscribe(bob)
+	defer hubB.Unsubscribe(mallory)
+
+	event := domain.MessageEvent{Type: domain.MessageCreated, Message: domain.Message{ID: "m1", Body: "hi"}}
+	require.Eventually(t, func() bool {
+		// retry until both hubs have finished subscribing
+		if !assert.NoError(t, hubA.PublishMessageEvent(event, []string{"alice", "bob"})) {
+			return false
+		}
+		select {
+		case got := <-bob.Events():
+			assert.Equal(t, "m1", got.Message.ID)
+			return true
+		case <-time.After(50 * time.Millisecond):
+			return false
+		}
+	}, 2*time.Second, 10*time.Millisecond)
+
+	select {
+	case <-mallory.Events():
+		t.Fatal("event delivered to a non-participant")
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+// flakyPubSub fails to subscribe until it has been asked failures times,
+// as when Redis is still starting.
+type flakyPubSub struct {
+	ports.PubSub
+	mu       sync.Mutex
+	failures int
+	attempts int
+}
+
+func (p *flakyPubSub) Subscribe(ctx context.Context, channel string) (<-chan []byte, error) {
+	p.mu.Lock()
+	p.attempts++
+	failed := p.attempts <= p.failures
+	p.mu.Unlock()
+	if failed {
+		return nil, errors.New("connection refused")
+	}
+	return p.PubSub.Subscribe(ctx, channel)
+}
+
+func TestHubKeepsTryingToSubscribe(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	ps := &flakyPubSub{PubSub: pubsub.NewMemoryPubSub(), failures: 3}
+	hub := realtime.NewHub(ps)
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		hub.RunWithRetry(ctx, time.Millisecond)
+	}()
+
+	bob := hub.Subscribe("bob")
+	defer hub.Unsubscribe(bob)
+	event := domain.MessageEvent{Type: domain.MessageCreated, Message: domain.Message{ID: "m1"}}
+	require.Eventually(t, func() bool {
+		require.NoError(t, hub.PublishMessageEvent(event, []string{"bob"}))
+		select {
+		case <-bob.Events():
+			return true
+		case <-time.After(20 * time.Millisecond):
+			return false
+		}
+	}, 2*time.Second, 10*time.Millisecond)
+	ps.mu.Lock()
+	assert.Equal(t, 4, ps.attempts)
+	ps.mu.Unlock()
+
+	cancel()
+	select {
+	case <-stopped:
+	case <-time.After(time.Second):
+		t.Fatal("hub kept running after its context was done")
+	}
+}
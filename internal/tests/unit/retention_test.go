@@ -0,0 +1,111 @@
+package unit
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/LordMoMA/Hexagonal-Architecture/internal/core/domain"
+	"github.com/LordMoMA/Hexagonal-Architecture/internal/core/ports"
+	"github.com/LordMoMA/Hexagonal-Architecture/internal/core/services"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// purgeRepo hands out prepared batches, one per PurgeExpiredMessages call.
+type purgeRepo struct {
+	ports.MessengerRepository
+	batches   [][]*domain.RetentionPurge
+	calls     int
+	retention map[string]*int
+}
+
+func (r *purgeRepo) PurgeExpiredMessages(defaultDays, limit int) ([]*domain.RetentionPurge, []string, error) {
+	r.calls++
+	if len(r.batches) == 0 {
+		return nil, nil, nil
+	}
+	batch := r.batches[0]
+	r.batches = r.batches[1:]
+	if batch == nil {
+		return nil, nil, errors.New("purge not committed")
+	}
+	return batch, nil, nil
+}
+
+func (r *purgeRepo) SetConversationRetention(conversationID string, days *int) error {
+	r.retention[conversationID] = days
+	return nil
+}
+
+func TestPurgeExpiredMessagesRunsUntilShortBatch(t *testing.T) {
+	repo := &purgeRepo{batches: [][]*domain.RetentionPurge{
+		{{ConversationID: "c1", MessageCount: 300}, {ConversationID: "c2", MessageCount: 200}},
+		{{ConversationID: "c1", MessageCount: 42}},
+		{{ConversationID: "c3", MessageCount: 1}},
+	}}
+	svc := services.NewMessengerService(repo, nil, nil, nil, nil)
+
+	purged, err := svc.PurgeExpiredMessages(30)
+	require.NoError(t, err)
+	assert.Equal(t, 542, purged)
+	assert.Equal(t, 2, repo.calls, "a short batch means nothing is left to purge")
+}
+
+func TestPurgeExpiredMessagesReportsProgressOnError(t *testing.T) {
+	repo := &purgeRepo{batches: [][]*domain.RetentionPurge{
+		{{ConversationID: "c1", MessageCount: 500}},
+		nil,
+	}}
+	svc := services.NewMessengerService(repo, nil, nil, nil, nil)
+
+	purged, err := svc.PurgeExpiredMessages(30)
+	assert.Error(t, err)
+	assert.Equal(t, 500, purged)
+
+	_, err = svc.PurgeExpiredMessages(-1)
+	assert.Error(t, err)
+}
+
+func TestSetConversationRetentionValidatesDays(t *testing.T) {
+	repo := &purgeRepo{retention: map[string]*int{}}
+	svc := services.NewMessengerService(repo, nil, nil, nil, nil)
+
+	forever, tooLong, negative := 0, domain.MaxRetentionDays+1, -1
+	require.NoError(t, svc.SetConversationRetention("c1", &forever))
+	assert.Equal(t, 0, *repo.retention["c1"])
+	require.NoError(t, svc.SetConversationRetention("c1", nil))
+	assert.Nil(t, repo.retention["c1"])
+
+	assert.Error(t, svc.SetConversationRetention("c1", &tooLong))
+	assert.Error(t, svc.SetConversationRetention("c1", &negative))
+}
+
+func TestMessagesWithoutConversationFallUnderDefaultRetention(t *testing.T) {
+	store, db := testStore(t)
+	orphan := &domain.Message{ID: uuid.NewString(), ConversationID: uuid.NewString(), UserID: "u1", Body: "left behind", CreatedAt: time.Now().AddDate(0, 0, -40)}
+	recent := &domain.Message{ID: uuid.NewString(), ConversationID: orphan.ConversationID, UserID: "u1", Body: "still young", CreatedAt: time.Now()}
+	require.NoError(t, db.Create(orphan).Error)
+	require.NoError(t, db.Create(recent).Error)
+
+	var purged []string
+	for {
+		purges, _, err := store.PurgeExpiredMessages(30, 100)
+		require.NoError(t, err)
+		if len(purges) == 0 {
+			break
+		}
+		for _, purge := range purges {
+			if purge.ConversationID == orphan.ConversationID {
+				assert.Equal(t, 30, purge.RetentionDays)
+				purged = append(purged, purge.MessageIDs)
+			}
+		}
+	}
+	assert.Equal(t, []string{orphan.ID}, purged)
+
+	var left int
+	require.NoError(t, db.Model(&domain.Message{}).Where("conversation_id = ?", orphan.ConversationID).Count(&left).Error)
+	assert.Equal(t, 1, left)
+}
@@ -0,0 +1,204 @@
+package unit
+
+import (
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/LordMoMA/Hexagonal-Architecture/internal/core/domain"
+	"github.com/LordMoMA/Hexagonal-Architecture/internal/core/services"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func (r *memoryMessengerRepo) CreateScheduledMessage(s domain.ScheduledMessage) error {
+	r.scheduled[s.ID] = &s
+	return nil
+}
+
+func (r *memoryMessengerRepo) ReadDueScheduledMessages(now time.Time, limit int) ([]*domain.ScheduledMessage, error) {
+	var due []*domain.ScheduledMessage
+	for _, s := range r.scheduled {
+		if !s.SendAt.After(now) {
+			due = append(due, s)
+		}
+	}
+	sort.Slice(due, func(i, j int) bool { return due[i].SendAt.Before(due[j].SendAt) })
+	if len(due) > limit {
+		due = due[:limit]
+	}
+	return due, nil
+}
+
+func (r *memoryMessengerRepo) DeliverScheduledMessage(id string, m domain.Message) error {
+	if err := r.DeleteScheduledMessage("", id); err != nil {
+		return err
+	}
+	return r.CreateMessage(m.UserID, m)
+}
+
+func (r *memoryMessengerRepo) DeleteScheduledMessage(userID, id string) error {
+	s, ok := r.scheduled[id]
+	if !ok || (userID != "" && s.UserID != userID) {
+		return domain.ErrScheduledMessageGone
+	}
+	delete(r.scheduled, id)
+	return nil
+}
+
+func (r *memoryMessengerRepo) ReadExpiredMessageIDs(now time.Time, limit int) ([]string, error) {
+	var ids []string
+	for id, m := range r.messages {
+		if m.ExpiresAt != nil && !m.ExpiresAt.After(now) && !m.Deleted && len(ids) < limit {
+			ids = append(ids, id)
+		}
+	}
+	return ids, nil
+}
+
+func TestScheduledMessageIsDeliveredWhenDue(t *testing.T) {
+	repo := newMemoryMessengerRepo()
+	events := &recordingEvents{}
+	svc := services.NewMessengerService(repo, events, nil, nil, nil)
+
+	c, err := svc.CreateConversation("alice", domain.Conversation{Kind: domain.ConversationDirect, ParticipantIDs: []string{"bob"}})
+	require.NoError(t, err)
+
+	sendAt := time.Now().Add(time.Hour)
+	scheduled, err := svc.ScheduleMessage("alice", domain.Message{ConversationID: c.ID, Body: "happy birthday", SendAt: &sendAt})
+	require.NoError(t, err)
+	assert.Empty(t, repo.messages)
+
+	delivered, err := svc.DeliverScheduledMessages(time.Now())
+	require.NoError(t, err)
+	assert.Zero(t, delivered, "not due yet")
+
+	delivered, err = svc.DeliverScheduledMessages(sendAt.Add(time.Second))
+	require.NoError(t, err)
+	assert.Equal(t, 1, delivered)
+	require.Contains(t, repo.messages, scheduled.ID)
+	assert.Equal(t, "happy birthday", repo.messages[scheduled.ID].Body)
+	assert.Empty(t, repo.scheduled)
+	require.Len(t, events.events, 1)
+	assert.Equal(t, domain.MessageCreated, events.events[0].Type)
+
+	// a second pass, say from another instance, finds nothing left to send
+	delivered, err = svc.DeliverScheduledMessages(sendAt.Add(time.Minute))
+	require.NoError(t, err)
+	assert.Zero(t, delivered)
+}
+
+func TestScheduleMessageValidatesTimes(t *testing.T) {
+	repo := newMemoryMessengerRepo()
+	svc := services.NewMessengerService(repo, nil, nil, nil, nil)
+
+	c, err := svc.CreateConversation("alice", domain.Conversation{Kind: domain.ConversationDirect, ParticipantIDs: []string{"bob"}})
+	require.NoError(t, err)
+
+	past := time.Now().Add(-time.Minute)
+	_, err = svc.ScheduleMessage("alice", domain.Message{ConversationID: c.ID, Body: "hi", SendAt: &past})
+	assert.Error(t, err)
+
+	sendAt := time.Now().Add(time.Hour)
+	expiresAt := sendAt.Add(-time.Minute)
+	_, err = svc.ScheduleMessage("alice", domain.Message{ConversationID: c.ID, Body: "hi", SendAt: &sendAt, ExpiresAt: &expiresAt})
+	assert.Error(t, err, "expires before it is sent")
+
+	_, err = svc.ScheduleMessage("mallory", domain.Message{ConversationID: c.ID, Body: "hi", SendAt: &sendAt})
+	assert.ErrorIs(t, err, services.ErrNotParticipant)
+}
+
+func TestCancelScheduledMessageOnlyByItsAuthor(t *testing.T) {
+	repo := newMemoryMessengerRepo()
+	svc := services.NewMessengerService(repo, nil, nil, nil, nil)
+
+	c, err := svc.CreateConversation("alice", domain.Conversation{Kind: domain.ConversationDirect, ParticipantIDs: []string{"bob"}})
+	require.NoError(t, err)
+	sendAt := time.Now().Add(time.Hour)
+	scheduled, err := svc.ScheduleMessage("alice", domain.Message{ConversationID: c.ID, Body: "hi", SendAt: &sendAt})
+	require.NoError(t, err)
+
+	assert.ErrorIs(t, svc.CancelScheduledMessage("bob", scheduled.ID), domain.ErrScheduledMessageGone)
+	require.NoError(t, svc.CancelScheduledMessage("alice", scheduled.ID))
+
+	delivered, err := svc.DeliverScheduledMessages(sendAt.Add(time.Second))
+	require.NoError(t, err)
+	assert.Zero(t, delivered)
+}
+
+func TestExpiredMessagesAreDeleted(t *testing.T) {
+	repo := newMemoryMessengerRepo()
+	events := &recordingEvents{}
+	svc := services.NewMessengerService(repo, events, nil, nil, nil)
+
+	c, err := svc.CreateConversation("alice", domain.Conversation{Kind: domain.ConversationDirect, ParticipantIDs: []string{"bob"}})
+	require.NoError(t, err)
+	expiresAt := time.Now().Add(time.Minute)
+	require.NoError(t, svc.CreateMessage("alice", domain.Message{ConversationID: c.ID, Body: "this message will self-destruct", ExpiresAt: &expiresAt}))
+	id := repo.lastMessageID
+
+	expired, err := svc.ExpireMessages(time.Now())
+	require.NoError(t, err)
+	assert.Zero(t, expired)
+
+	expired, err = svc.ExpireMessages(expiresAt.Add(time.Second))
+	require.NoError(t, err)
+	assert.Equal(t, 1, expired)
+	assert.True(t, repo.messages[id].Deleted)
+	assert.Empty(t, repo.messages[id].Body)
+	assert.Equal(t, domain.MessageDeleted, events.events[len(events.events)-1].Type)
+}
+
+// flakyParticipantRepo fails the participant check with err while it is set
+type flakyParticipantRepo struct {
+	*memoryMessengerRepo
+	err error
+}
+
+func (r *flakyParticipantRepo) IsParticipant(conversationID, userID string) (bool, error) {
+	if r.err != nil {
+		return false, r.err
+	}
+	return r.memoryMessengerRepo.IsParticipant(conversationID, userID)
+}
+
+func TestScheduledMessageOutlivesAnOutage(t *testing.T) {
+	repo := &flakyParticipantRepo{memoryMessengerRepo: newMemoryMessengerRepo()}
+	svc := services.NewMessengerService(repo, nil, nil, nil, nil)
+
+	c, err := svc.CreateConversation("alice", domain.Conversation{Kind: domain.ConversationDirect, ParticipantIDs: []string{"bob"}})
+	require.NoError(t, err)
+	sendAt := time.Now().Add(time.Hour)
+	scheduled, err := svc.ScheduleMessage("alice", domain.Message{ConversationID: c.ID, Body: "hi", SendAt: &sendAt})
+	require.NoError(t, err)
+
+	repo.err = domain.Unavailable("database_unavailable", "participant not checked")
+	delivered, err := svc.DeliverScheduledMessages(sendAt.Add(time.Second))
+	assert.ErrorIs(t, err, domain.ErrUnavailable)
+	assert.Zero(t, delivered)
+	assert.Contains(t, repo.scheduled, scheduled.ID, "kept for the next pass")
+
+	repo.err = nil
+	delivered, err = svc.DeliverScheduledMessages(sendAt.Add(time.Minute))
+	require.NoError(t, err)
+	assert.Equal(t, 1, delivered)
+	assert.Contains(t, repo.messages, scheduled.ID)
+}
+
+func TestScheduledMessageOfAnAuthorWhoLeftIsDropped(t *testing.T) {
+	repo := newMemoryMessengerRepo()
+	svc := services.NewMessengerService(repo, nil, nil, nil, nil)
+
+	c, err := svc.CreateConversation("alice", domain.Conversation{Kind: domain.ConversationGroup, ParticipantIDs: []string{"bob", "carol"}})
+	require.NoError(t, err)
+	sendAt := time.Now().Add(time.Hour)
+	scheduled, err := svc.ScheduleMessage("carol", domain.Message{ConversationID: c.ID, Body: "hi", SendAt: &sendAt})
+	require.NoError(t, err)
+	repo.conversations[c.ID].ParticipantIDs = []string{"alice", "bob"}
+
+	delivered, err := svc.DeliverScheduledMessages(sendAt.Add(time.Second))
+	require.NoError(t, err)
+	assert.Zero(t, delivered)
+	assert.NotContains(t, repo.scheduled, scheduled.ID)
+	assert.NotContains(t, repo.messages, scheduled.ID)
+}
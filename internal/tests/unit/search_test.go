@@ -0,0 +1,30 @@
+package unit
+
+import (
+	"testing"
+	"time"
+
+	"github.com/LordMoMA/Hexagonal-Architecture/internal/adapters/search"
+	"github.com/LordMoMA/Hexagonal-Architecture/internal/core/domain"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSearchSkipsDeletedMessages(t *testing.T) {
+	_, db := testStore(t)
+	index := search.NewPostgresIndex(db)
+	require.NoError(t, index.Migrate())
+
+	// the deleted row keeps its body, so only the filter keeps it out
+	conversationID := uuid.NewString()
+	live := &domain.Message{ID: uuid.NewString(), ConversationID: conversationID, UserID: "u1", Body: "a quokka sighting", CreatedAt: time.Now().UTC()}
+	deleted := &domain.Message{ID: uuid.NewString(), ConversationID: conversationID, UserID: "u1", Body: "another quokka sighting", CreatedAt: time.Now().UTC(), Deleted: true}
+	require.NoError(t, db.Create(live).Error)
+	require.NoError(t, db.Create(deleted).Error)
+
+	hits, err := index.SearchMessages(domain.SearchQuery{Text: "quokka", ConversationIDs: []string{conversationID}, Limit: 10})
+	require.NoError(t, err)
+	require.Len(t, hits, 1)
+	assert.Equal(t, live.ID, hits[0].Message.ID)
+}
@@ -0,0 +1,712 @@
+package unit
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/LordMoMA/Hexagonal-Architecture/internal/adapters/blobstore"
+	"github.com/LordMoMA/Hexagonal-Architecture/internal/adapters/cli"
+	"github.com/LordMoMA/Hexagonal-Architecture/internal/adapters/handler"
+	"github.com/LordMoMA/Hexagonal-Architecture/internal/adapters/oidc"
+	"github.com/LordMoMA/Hexagonal-Architecture/internal/adapters/realtime"
+	"github.com/LordMoMA/Hexagonal-Architecture/internal/adapters/repository"
+	"github.com/LordMoMA/Hexagonal-Architecture/internal/adapters/rpc/pb"
+	"github.com/LordMoMA/Hexagonal-Architecture/internal/core/domain"
+	"github.com/LordMoMA/Hexagonal-Architecture/internal/core/ports"
+	"github.com/LordMoMA/Hexagonal-Architecture/internal/core/services"
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+)
+
+const (
+	secretPasswordHash = "$2a$10$SECRET-PASSWORD-HASH"
+	secretTOTP         = "SECRET-TOTP-SEED"
+	secretKeyHash      = "SECRET-KEY-HASH"
+	secretRefreshID    = "SECRET-REFRESH-TOKEN-ID"
+	secretBlobKey      = "attachments/m1/SECRET-BLOB-KEY"
+	secretsJWTKey      = "secrets-test-jwt-key"
+	secretsWebhookKey  = "secrets-test-webhook-key"
+)
+
+// secretFields are JSON keys no response may have, whatever their value
+var secretFields = []string{"password", "totp_secret", "totp_last_step", "key_hash", "code_hash", "refresh_token_id", "blob_key", "flag_reasons"}
+
+// secretsRepo hands out records with every secret field filled in
+type secretsRepo struct {
+	ports.UserRepository
+}
+
+func secretUser() *domain.User {
+	return &domain.User{
+		ID:           "admin-1",
+		Email:        "admin@example.com",
+		Password:     secretPasswordHash,
+		Admin:        true,
+		TOTPSecret:   secretTOTP,
+		TOTPEnabled:  true,
+		TOTPLastStep: 56789012,
+	}
+}
+
+func (r *secretsRepo) CreateUser(email, password string) (*domain.User, error) {
+	return secretUser(), nil
+}
+
+func (r *secretsRepo) CreateUsers(users []domain.NewUser) ([]*domain.User, error) {
+	created := make([]*domain.User, len(users))
+	for i := range users {
+		created[i] = secretUser()
+	}
+	return created, nil
+}
+
+func (r *secretsRepo) ReadUser(id string) (*domain.User, error) {
+	return secretUser(), nil
+}
+
+func (r *secretsRepo) ReadUsers(opts domain.ListOptions) (*domain.Page[*domain.User], error) {
+	return &domain.Page[*domain.User]{Items: []*domain.User{secretUser()}}, nil
+}
+
+func (r *secretsRepo) ReadUsersByIDs(ids []string) ([]*domain.User, error) {
+	return []*domain.User{secretUser()}, nil
+}
+
+func (r *secretsRepo) UpdateUser(id, email, password string) error {
+	return nil
+}
+
+func (r *secretsRepo) DeleteUser(id string) error {
+	return nil
+}
+
+func (r *secretsRepo) UpdateMembershipStatus(id string, status bool) error {
+	return nil
+}
+
+func (r *secretsRepo) UpdateAdminStatus(id string, admin bool) error {
+	return nil
+}
+
+func (r *secretsRepo) UnlockUser(id string) error {
+	return nil
+}
+
+func (r *secretsRepo) login() (*repository.LoginResponse, error) {
+	return &repository.LoginResponse{ID: "admin-1", Email: "admin@example.com", AccessToken: "access", RefreshToken: "refresh"}, nil
+}
+
+func (r *secretsRepo) LoginUser(email, password string, _ domain.ClientInfo) (*repository.LoginResponse, error) {
+	return r.login()
+}
+
+func (r *secretsRepo) LoginUserMFA(mfaToken, code string, _ domain.ClientInfo) (*repository.LoginResponse, error) {
+	return r.login()
+}
+
+func (r *secretsRepo) LoginUserOIDC(identity domain.ExternalIdentity, _ domain.ClientInfo) (*repository.LoginResponse, error) {
+	return r.login()
+}
+
+func (r *secretsRepo) RefreshSession(refreshToken string, _ domain.ClientInfo) (*repository.LoginResponse, error) {
+	return r.login()
+}
+
+func (r *secretsRepo) ListSessions(userID string) ([]*domain.Session, error) {
+	return []*domain.Session{{ID: "s1", UserID: userID, RefreshTokenID: secretRefreshID}}, nil
+}
+
+func (r *secretsRepo) RevokeSession(userID, id string) error {
+	return nil
+}
+
+// EnrollTOTP answers with a new seed: showing it once is the point of the
+// call, so it must be a different one from the seed kept on the user.
+func (r *secretsRepo) EnrollTOTP(id string) (*repository.TOTPEnrollment, error) {
+	return &repository.TOTPEnrollment{Secret: "NEW-TOTP-SEED", URI: "otpauth://totp/hex?secret=NEW-TOTP-SEED", RecoveryCodes: []string{"recovery-1"}}, nil
+}
+
+func (r *secretsRepo) ActivateTOTP(id, code string) error {
+	return nil
+}
+
+func secretAPIKey(userID string) *domain.APIKey {
+	return &domain.APIKey{ID: "k1", UserID: userID, Prefix: "hex_abc", KeyHash: secretKeyHash, Scopes: "messages:read"}
+}
+
+func (r *secretsRepo) CreateAPIKey(userID, name string, scopes []string, expiresAt *time.Time) (*domain.APIKey, string, error) {
+	return secretAPIKey(userID), "hex_abc_plaintext", nil
+}
+
+func (r *secretsRepo) ListAPIKeys(userID string) ([]*domain.APIKey, error) {
+	return []*domain.APIKey{secretAPIKey(userID)}, nil
+}
+
+func (r *secretsRepo) RevokeAPIKey(userID, id string) error {
+	return nil
+}
+
+// secretsMessages holds conversation c1 of admin-1 with its message m1, which
+// has attachment a1 and is in the moderation queue. Every record carries the
+// fields kept from clients filled in.
+type secretsMessages struct{}
+
+func secretMessage(id string) *domain.Message {
+	return &domain.Message{
+		ID:             id,
+		ConversationID: "c1",
+		UserID:         "admin-1",
+		Body:           "hello",
+		CreatedAt:      time.Now(),
+		Attachments:    []domain.Attachment{*secretAttachment("a1")},
+		FlaggedFor:     []string{"SECRET-FLAG-REASON"},
+	}
+}
+
+func secretAttachment(id string) *domain.Attachment {
+	return &domain.Attachment{ID: id, MessageID: "m1", UserID: "admin-1", Filename: "notes.txt", ContentType: "text/plain; charset=utf-8", Size: 5, BlobKey: secretBlobKey}
+}
+
+func secretMessagePage() *domain.Page[*domain.Message] {
+	return &domain.Page[*domain.Message]{Items: []*domain.Message{secretMessage("m1")}}
+}
+
+func secretConversation(id string) *domain.Conversation {
+	return &domain.Conversation{ID: id, Kind: domain.ConversationGroup, Title: "team", CreatedBy: "admin-1", ParticipantIDs: []string{"admin-1"}}
+}
+
+func secretFlaggedMessage(id string) *domain.FlaggedMessage {
+	return &domain.FlaggedMessage{ID: id, MessageID: "m1", UserID: "admin-1", Reasons: "links", Message: secretMessage("m1")}
+}
+
+func secretScheduledMessage(id string) *domain.ScheduledMessage {
+	return &domain.ScheduledMessage{ID: id, ConversationID: "c1", UserID: "admin-1", Body: "later", SendAt: time.Now().Add(time.Hour), FlagReasons: "SECRET-FLAG-REASON"}
+}
+
+func (r *secretsMessages) CreateMessage(userID string, message domain.Message) error {
+	return nil
+}
+
+func (r *secretsMessages) CreateMessages(userID string, messages []domain.Message) error {
+	return nil
+}
+
+func (r *secretsMessages) ReadMessage(id string) (*domain.Message, error) {
+	return secretMessage(id), nil
+}
+
+func (r *secretsMessages) ReadMessages(userID string, opts domain.ListOptions) (*domain.Page[*domain.Message], error) {
+	return secretMessagePage(), nil
+}
+
+func (r *secretsMessages) UpdateMessage(id string, message domain.Message) error {
+	return nil
+}
+
+func (r *secretsMessages) DeleteMessage(id string) error {
+	return nil
+}
+
+func (r *secretsMessages) ReadMessageRevisions(id string) ([]*domain.MessageRevision, error) {
+	return []*domain.MessageRevision{{ID: "r1", MessageID: id, Kind: domain.RevisionEdit, Body: "helo"}}, nil
+}
+
+func (r *secretsMessages) RestoreMessage(id string) error {
+	return nil
+}
+
+func (r *secretsMessages) ReadReplies(parentID string, opts domain.ListOptions) (*domain.Page[*domain.Message], error) {
+	return secretMessagePage(), nil
+}
+
+func (r *secretsMessages) CreateConversation(conversation domain.Conversation) (*domain.Conversation, error) {
+	return &conversation, nil
+}
+
+func (r *secretsMessages) FindDirectConversation(userID, otherUserID string) (*domain.Conversation, error) {
+	return nil, domain.NotFound("conversation_not_found", "conversation not found")
+}
+
+func (r *secretsMessages) ReadConversations(userID string) ([]*domain.Conversation, error) {
+	return []*domain.Conversation{secretConversation("c1")}, nil
+}
+
+func (r *secretsMessages) ReadConversationsByIDs(userID string, ids []string) ([]*domain.Conversation, error) {
+	return []*domain.Conversation{secretConversation("c1")}, nil
+}
+
+func (r *secretsMessages) ReadConversationIDs(userID string) ([]string, error) {
+	return []string{"c1"}, nil
+}
+
+func (r *secretsMessages) ReadConversationMessages(conversationID string, opts domain.ListOptions) (*domain.Page[*domain.Message], error) {
+	return secretMessagePage(), nil
+}
+
+func (r *secretsMessages) IsParticipant(conversationID, userID string) (bool, error) {
+	return true, nil
+}
+
+func (r *secretsMessages) ReadParticipantIDs(conversationID string) ([]string, error) {
+	return []string{"admin-1"}, nil
+}
+
+func (r *secretsMessages) ToggleReaction(messageID, userID, emoji string) (bool, error) {
+	return true, nil
+}
+
+func (r *secretsMessages) MarkConversationRead(conversationID, userID string, readAt time.Time) error {
+	return nil
+}
+
+func (r *secretsMessages) ReadAttachment(id string) (*domain.Attachment, error) {
+	return secretAttachment(id), nil
+}
+
+func (r *secretsMessages) ReadAttachmentKeys(keys []string) ([]string, error) {
+	return keys, nil
+}
+
+func (r *secretsMessages) ReadFlaggedMessages(pending bool, opts domain.ListOptions) (*domain.Page[*domain.FlaggedMessage], error) {
+	return &domain.Page[*domain.FlaggedMessage]{Items: []*domain.FlaggedMessage{secretFlaggedMessage("f1")}}, nil
+}
+
+func (r *secretsMessages) ReadFlaggedMessage(id string) (*domain.FlaggedMessage, error) {
+	return secretFlaggedMessage(id), nil
+}
+
+func (r *secretsMessages) ReviewFlaggedMessage(id, reviewerID, decision string) (*domain.FlaggedMessage, error) {
+	flagged := secretFlaggedMessage(id)
+	flagged.ReviewedBy, flagged.Decision = reviewerID, decision
+	return flagged, nil
+}
+
+func (r *secretsMessages) SetConversationRetention(conversationID string, days *int) error {
+	return nil
+}
+
+func (r *secretsMessages) PurgeExpiredMessages(defaultDays, limit int) ([]*domain.RetentionPurge, []string, error) {
+	return nil, nil, nil
+}
+
+func (r *secretsMessages) ReadRetentionPurges(opts domain.ListOptions) (*domain.Page[*domain.RetentionPurge], error) {
+	return &domain.Page[*domain.RetentionPurge]{Items: []*domain.RetentionPurge{{ID: "p1", ConversationID: "c1", RetentionDays: 30, MessageCount: 1, MessageIDs: "m0"}}}, nil
+}
+
+func (r *secretsMessages) CreateScheduledMessage(scheduled domain.ScheduledMessage) error {
+	return nil
+}
+
+func (r *secretsMessages) ReadScheduledMessages(userID string, opts domain.ListOptions) (*domain.Page[*domain.ScheduledMessage], error) {
+	return &domain.Page[*domain.ScheduledMessage]{Items: []*domain.ScheduledMessage{secretScheduledMessage("sm1")}}, nil
+}
+
+func (r *secretsMessages) ReadDueScheduledMessages(now time.Time, limit int) ([]*domain.ScheduledMessage, error) {
+	return nil, nil
+}
+
+func (r *secretsMessages) DeliverScheduledMessage(id string, message domain.Message) error {
+	return nil
+}
+
+func (r *secretsMessages) DeleteScheduledMessage(userID, id string) error {
+	return nil
+}
+
+func (r *secretsMessages) ReadExpiredMessageIDs(now time.Time, limit int) ([]string, error) {
+	return nil, nil
+}
+
+// secretsPayments has one order of admin-1
+type secretsPayments struct{}
+
+func (r *secretsPayments) CreateCheckoutSession(userID string, payment domain.Payment) error {
+	return nil
+}
+
+func (r *secretsPayments) ReadOrders(userIDs []string) ([]*domain.OrderInfo, error) {
+	return []*domain.OrderInfo{{OrderID: "o1", UserID: "admin-1", CheckoutID: "cs_1", Amount: "10.00", Currency: "usd", Status: "paid"}}, nil
+}
+
+// withAPIConfig points LoadAPIConfig at a throwaway .env for the test.
+func withAPIConfig(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, ".env"), []byte("JWT_SECRET="+secretsJWTKey+"\n"), 0o600))
+	wd, err := os.Getwd()
+	require.NoError(t, err)
+	require.NoError(t, os.Chdir(dir))
+	t.Cleanup(func() { _ = os.Chdir(wd) })
+	t.Setenv("JWT_SECRET", secretsJWTKey)
+}
+
+func accessToken(t *testing.T, userID string) string {
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.RegisteredClaims{
+		Issuer:    "LordMoMA-access",
+		Subject:   userID,
+		ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour)),
+	})
+	signed, err := token.SignedString([]byte(secretsJWTKey))
+	require.NoError(t, err)
+	return signed
+}
+
+// secretsRouter mounts every route the server does on the stubs above, SSO
+// against a stub identity provider included. A handler that panics fails
+// the test rather than passing for a 500.
+func secretsRouter(t *testing.T) (*gin.Engine, *services.OIDCService, *stubIdP) {
+	idp := newStubIdP(t)
+	provider, err := oidc.NewProvider(idp.URL, stubClientID, stubClientSecret, stubRedirectURL)
+	require.NoError(t, err)
+	blobs, err := blobstore.NewLocalStore(t.TempDir())
+	require.NoError(t, err)
+	require.NoError(t, blobs.Put(secretBlobKey, strings.NewReader("hello"), 5, "text/plain; charset=utf-8"))
+
+	oidcService := services.NewOIDCService(provider, &secretsRepo{}, newMemoryCache())
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(func(ctx *gin.Context) {
+		defer func() {
+			if err := recover(); err != nil {
+				t.Errorf("%s %s panicked: %v", ctx.Request.Method, ctx.FullPath(), err)
+				ctx.AbortWithStatus(http.StatusInternalServerError)
+			}
+		}()
+		ctx.Next()
+	})
+	handler.RegisterRoutes(router, handler.Services{
+		Messages: services.NewMessengerService(&secretsMessages{}, nil, &recordingSearchIndex{}, blobs, nil),
+		Users:    services.NewUserService(&secretsRepo{}),
+		Payments: services.NewPaymentService(&secretsPayments{}),
+		OIDC:     oidcService,
+		Hub:      realtime.NewHub(nil),
+	})
+	return router, oidcService, idp
+}
+
+// streamRecorder lets gin stream into a recorder: the stream ends when the
+// request's context does.
+type streamRecorder struct {
+	*httptest.ResponseRecorder
+}
+
+func (streamRecorder) CloseNotify() <-chan bool {
+	return make(chan bool)
+}
+
+// secretRequestBodies are valid bodies for the routes that need one, keyed
+// by method and path without the version. Every other route gets none.
+var secretRequestBodies = map[string]string{
+	"POST /messages":                          `{"conversation_id": "c1", "body": "hello"}`,
+	"POST /messages:batch":                    `{"items": [{"conversation_id": "c1", "body": "hello"}]}`,
+	"PUT /messages/:id":                       `{"body": "edited"}`,
+	"POST /messages/:id/reactions":            `{"emoji": "+1"}`,
+	"POST /conversations":                     `{"kind": "group", "title": "team", "participant_ids": ["u2"]}`,
+	"POST /conversations/:id/read":            `{"message_id": "m1"}`,
+	"POST /users":                             `{"email": "new@example.com", "password": "correct-horse-1"}`,
+	"POST /users:batch":                       `{"items": [{"email": "new@example.com", "password": "correct-horse-1"}]}`,
+	"PUT /users":                              `{"email": "new@example.com", "password": "correct-horse-1"}`,
+	"POST /login":                             `{"email": "admin@example.com", "password": "hunter2"}`,
+	"POST /login/mfa":                         `{"mfa_token": "t", "code": "123456"}`,
+	"POST /refresh":                           `{"refresh_token": "r"}`,
+	"POST /mfa/totp/activate":                 `{"code": "123456"}`,
+	"POST /membership/webhooks":               `{"event": "membership_status_updated", "user_id": "admin-1"}`,
+	"POST /admin/moderation/queue/:id/review": `{"decision": "approve"}`,
+	"PUT /admin/conversations/:id/retention":  `{"retention_days": 30}`,
+	"POST /api-keys":                          `{"name": "ci", "scopes": ["messages:read"]}`,
+	"POST /graphql":                           `{"query": "{ me { id email membership admin totpEnabled orders { id } } user(id: \"admin-1\") { id email totpEnabled } users { items { id email membership admin totpEnabled orders { id amount } } nextCursor } message(id: \"m1\") { id body author { id email totpEnabled } } messages { items { id author { email } conversation { id createdBy { email } participants { id email admin totpEnabled } } } } conversations { id createdBy { email } participants { id email totpEnabled } messages { items { id author { email } } } } }"}`,
+}
+
+// secretRouteStatus are the routes not answered with success however valid
+// the request, with the status they do answer with.
+var secretRouteStatus = map[string]int{
+	// it redirects to the identity provider
+	"GET /auth/oidc/login": http.StatusFound,
+	// a recorder can't be hijacked, so the upgrade is refused before
+	// anything of ours is written
+	"GET /ws": http.StatusBadRequest,
+}
+
+func TestResponsesNeverContainSecrets(t *testing.T) {
+	withAPIConfig(t)
+	t.Setenv("API_KEY", secretsWebhookKey)
+	router, oidcService, idp := secretsRouter(t)
+
+	call := func(method, path, body string, header http.Header) *httptest.ResponseRecorder {
+		ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+		defer cancel()
+		req := httptest.NewRequest(method, path, strings.NewReader(body)).WithContext(ctx)
+		req.Header = header
+		w := streamRecorder{httptest.NewRecorder()}
+		router.ServeHTTP(w, req)
+		return w.ResponseRecorder
+	}
+	bearer := func() http.Header {
+		return http.Header{
+			"Content-Type":  {"application/json"},
+			"Authorization": {"Bearer " + accessToken(t, "admin-1")},
+		}
+	}
+
+	// the download route only serves what a link was handed out for
+	link := call(http.MethodGet, "/v1/attachments/a1/link", "", bearer())
+	require.Equal(t, http.StatusOK, link.Code, link.Body.String())
+	var linked struct{ URL string }
+	require.NoError(t, json.Unmarshal(link.Body.Bytes(), &linked))
+	download, err := url.Parse(linked.URL)
+	require.NoError(t, err)
+
+	checked := 0
+	for _, route := range router.Routes() {
+		version, rest, _ := strings.Cut(strings.TrimPrefix(route.Path, "/"), "/")
+		key := route.Method + " /" + rest
+		if !strings.HasPrefix(version, "v") {
+			// /openapi.json and /docs describe the API, they serve no records
+			continue
+		}
+		if key == "POST /create-checkout-session" {
+			// it calls Stripe, which a unit test can't reach
+			continue
+		}
+
+		// the stubs answer for any ID, but a message read must be in the
+		// conversation it's marked read in
+		id := "a1"
+		if strings.Contains(route.Path, "/conversations/") {
+			id = "c1"
+		}
+		path := strings.ReplaceAll(route.Path, ":id", id)
+		header := bearer()
+		switch key {
+		case "GET /messages/search":
+			path += "?q=hello"
+		case "GET /attachments/:id/download":
+			path += "?" + download.RawQuery
+		case "GET /auth/oidc/callback":
+			authURL, err := oidcService.BeginLogin()
+			require.NoError(t, err)
+			code, state := idp.authorize(t, authURL)
+			path += "?" + url.Values{"code": {code}, "state": {state}}.Encode()
+		case "POST /membership/webhooks":
+			header.Set("Authorization", "ApiKey "+secretsWebhookKey)
+		}
+
+		name := route.Method + " " + path
+		w := call(route.Method, path, secretRequestBodies[key], header)
+		if want, ok := secretRouteStatus[key]; ok {
+			assert.Equal(t, want, w.Code, "%s: %s", name, w.Body)
+		} else {
+			assert.Less(t, w.Code, http.StatusBadRequest, "%s: %s", name, w.Body)
+		}
+		if strings.HasPrefix(w.Header().Get("Content-Type"), "application/json") {
+			assertNoSecrets(t, name, w.Body.Bytes())
+			if key == "POST /graphql" {
+				// a query that fails comes back 200 with the fields left out
+				assert.NotContains(t, w.Body.String(), `"errors"`, name)
+			}
+		} else {
+			assertNoSecretValues(t, name, w.Body.Bytes())
+		}
+		for key, values := range w.Header() {
+			assertNoSecretValues(t, name+" "+key, []byte(strings.Join(values, " ")))
+		}
+		checked++
+	}
+	assert.NotZero(t, checked)
+}
+
+func TestGRPCResponsesNeverContainSecrets(t *testing.T) {
+	client := grpcClient(t, &secretsRepo{})
+	ctx := withToken(t, "admin-1")
+
+	responses := map[string]func() (proto.Message, error){
+		"CreateUser": func() (proto.Message, error) {
+			return client.CreateUser(ctx, &pb.CreateUserRequest{Email: "new@example.com", Password: "correct-horse-1"})
+		},
+		"GetUser": func() (proto.Message, error) {
+			return client.GetUser(ctx, &pb.GetUserRequest{Id: "admin-1"})
+		},
+		"ListUsers": func() (proto.Message, error) {
+			return client.ListUsers(ctx, &pb.ListUsersRequest{})
+		},
+		"Login": func() (proto.Message, error) {
+			return client.Login(ctx, &pb.LoginRequest{Email: "admin@example.com", Password: "hunter2"})
+		},
+		"LoginMFA": func() (proto.Message, error) {
+			return client.LoginMFA(ctx, &pb.LoginMFARequest{MfaToken: "t", Code: "123456"})
+		},
+		"RefreshSession": func() (proto.Message, error) {
+			return client.RefreshSession(ctx, &pb.RefreshSessionRequest{RefreshToken: "r"})
+		},
+	}
+	for name, call := range responses {
+		response, err := call()
+		require.NoError(t, err, name)
+		data, err := protojson.MarshalOptions{UseProtoNames: true, EmitUnpopulated: true}.Marshal(response)
+		require.NoError(t, err, name)
+		assertNoSecrets(t, name, data)
+	}
+}
+
+func TestCLIJSONNeverContainsSecrets(t *testing.T) {
+	app := func() *cli.App {
+		return cli.New(services.NewUserService(&secretsRepo{}), services.NewMessengerService(&secretsMessages{}, nil, nil, nil, nil), services.NewPaymentService(&secretsPayments{}))
+	}
+
+	commands := [][]string{
+		{"users", "show", "admin-1"},
+		{"users", "list"},
+		{"users", "create", "-email", "new@example.com", "-password", "-", "-admin"},
+		{"users", "grant-admin", "admin-1"},
+		{"users", "grant-membership", "admin-1"},
+		{"users", "unlock", "admin-1"},
+		{"messages", "revisions", "m1"},
+		{"messages", "flagged"},
+		{"payments", "orders", "admin-1"},
+	}
+	for _, args := range commands {
+		name := strings.Join(args, " ")
+		out, err := runApp(app(), append([]string{"-o", "json"}, args...)...)
+		require.NoError(t, err, name)
+		assertNoSecrets(t, name, []byte(out))
+	}
+}
+
+func TestDomainSecretsAreNotSerialised(t *testing.T) {
+	records := []interface{}{
+		secretUser(),
+		&domain.Session{RefreshTokenID: secretRefreshID},
+		&domain.APIKey{KeyHash: secretKeyHash},
+		&domain.RecoveryCode{CodeHash: secretKeyHash},
+		secretMessage("m1"),
+		secretScheduledMessage("sm1"),
+		&domain.MessageEvent{Message: *secretMessage("m1")},
+	}
+	for _, record := range records {
+		data, err := json.Marshal(record)
+		require.NoError(t, err)
+		assertNoSecrets(t, fmt.Sprintf("%T", record), data)
+	}
+}
+
+// assertNoSecrets checks a JSON body for both the secret values and the
+// fields that would hold them.
+func assertNoSecrets(t *testing.T, name string, body []byte) {
+	t.Helper()
+	assertNoSecretValues(t, name, body)
+
+	var doc interface{}
+	require.NoError(t, json.Unmarshal(body, &doc), name)
+	for _, key := range jsonKeys(doc) {
+		for _, field := range secretFields {
+			assert.NotEqual(t, field, key, name)
+		}
+	}
+}
+
+func assertNoSecretValues(t *testing.T, name string, body []byte) {
+	t.Helper()
+	for _, secret := range []string{secretPasswordHash, secretTOTP, secretKeyHash, secretRefreshID, secretBlobKey, "SECRET-FLAG-REASON", "56789012"} {
+		assert.NotContains(t, string(body), secret, name)
+	}
+}
+
+// jsonKeys lists every object key in a decoded JSON document, however deep.
+func jsonKeys(doc interface{}) []string {
+	var keys []string
+	switch v := doc.(type) {
+	case map[string]interface{}:
+		for key, value := range v {
+			keys = append(keys, key)
+			keys = append(keys, jsonKeys(value)...)
+		}
+	case []interface{}:
+		for _, value := range v {
+			keys = append(keys, jsonKeys(value)...)
+		}
+	}
+	return keys
+}
+
+// accountRepo has u1 with two-factor authentication on, admin-2, and u3.
+func accountRepo() *cliUserRepo {
+	return &cliUserRepo{users: map[string]*domain.User{
+		"u1":      {ID: "u1", Email: "one@example.com", TOTPEnabled: true},
+		"admin-2": {ID: "admin-2", Email: "admin@example.com", Admin: true},
+		"u3":      {ID: "u3", Email: "three@example.com"},
+	}}
+}
+
+func TestUsersShowTheirAccountOnlyToThemselvesAndAdmins(t *testing.T) {
+	withAPIConfig(t)
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	userHandler := handler.NewUserHandler(*services.NewUserService(accountRepo()))
+	router.GET("/v1/users/:id", userHandler.ReadUser)
+	router.GET("/v1/users", userHandler.ReadUsers)
+
+	read := func(path, caller string) map[string]interface{} {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		if caller != "" {
+			req.Header.Set("Authorization", "Bearer "+accessToken(t, caller))
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		var user map[string]interface{}
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &user))
+		return user
+	}
+
+	for _, caller := range []string{"", "u3"} {
+		user := read("/v1/users/u1", caller)
+		assert.Equal(t, "one@example.com", user["email"], caller)
+		assert.NotContains(t, user, "admin", caller)
+		assert.NotContains(t, user, "totp_enabled", caller)
+	}
+	for _, caller := range []string{"u1", "admin-2"} {
+		user := read("/v1/users/u1", caller)
+		assert.Equal(t, true, user["totp_enabled"], caller)
+		assert.Equal(t, false, user["admin"], caller)
+	}
+	page := read("/v1/users", "")
+	assert.NotContains(t, page["items"].([]interface{})[0], "totp_enabled")
+
+	client := grpcClient(t, accountRepo())
+	user, err := client.GetUser(withToken(t, "u3"), &pb.GetUserRequest{Id: "u1"})
+	require.NoError(t, err)
+	assert.False(t, user.TotpEnabled)
+	user, err = client.GetUser(withToken(t, "admin-2"), &pb.GetUserRequest{Id: "u1"})
+	require.NoError(t, err)
+	assert.True(t, user.TotpEnabled)
+	users, err := client.ListUsers(withToken(t, "u3"), &pb.ListUsersRequest{})
+	require.NoError(t, err)
+	require.NotEmpty(t, users.Users)
+	assert.False(t, users.Users[0].TotpEnabled)
+}
+
+func TestAccessLogLeavesOutStreamTokens(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	var log strings.Builder
+	router := gin.New()
+	router.Use(handler.AccessLog(&log))
+	router.GET("/v1/events", func(ctx *gin.Context) { ctx.Status(http.StatusNoContent) })
+
+	token := accessToken(t, "u1")
+	router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/v1/events?access_token="+token+"&cursor=c1", nil))
+	assert.NotContains(t, log.String(), token)
+	assert.Contains(t, log.String(), "access_token=REDACTED")
+	assert.Contains(t, log.String(), "cursor=c1")
+}
@@ -0,0 +1,143 @@
+package unit
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/LordMoMA/Hexagonal-Architecture/internal/adapters/handler"
+	"github.com/LordMoMA/Hexagonal-Architecture/internal/adapters/repository"
+	"github.com/LordMoMA/Hexagonal-Architecture/internal/core/domain"
+	"github.com/LordMoMA/Hexagonal-Architecture/internal/core/services"
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// errorCode is the code of the domain error in err's chain.
+func errorCode(err error) string {
+	var domainErr *domain.Error
+	if errors.As(err, &domainErr) {
+		return domainErr.Code
+	}
+	return ""
+}
+
+// signedInUser signs a new user up and in, returning the first token pair.
+func signedInUser(t *testing.T, store *repository.DB, name string) (*domain.User, *repository.LoginResponse) {
+	email, password := testEmail(name), "correct-horse-battery"
+	user, err := store.CreateUser(email, password)
+	require.NoError(t, err)
+	response, err := store.LoginUser(email, password, testClient())
+	require.NoError(t, err)
+	return user, response
+}
+
+func TestRefreshTokenIsGoodForOneRotation(t *testing.T) {
+	withAPIConfig(t)
+	store, _ := testStore(t)
+	_, first := signedInUser(t, store, "rotate")
+
+	second, err := store.RefreshSession(first.RefreshToken, testClient())
+	require.NoError(t, err)
+	assert.NotEqual(t, first.RefreshToken, second.RefreshToken)
+	assert.NotEmpty(t, second.AccessToken)
+
+	third, err := store.RefreshSession(second.RefreshToken, testClient())
+	require.NoError(t, err)
+
+	// a token that was already rotated out has leaked: the session goes,
+	// taking the newest token with it
+	_, err = store.RefreshSession(second.RefreshToken, testClient())
+	assert.ErrorIs(t, err, repository.ErrRefreshTokenReused)
+	_, err = store.RefreshSession(third.RefreshToken, testClient())
+	assert.Equal(t, "session_revoked", errorCode(err))
+}
+
+func TestRevokedSessionsCantBeRefreshed(t *testing.T) {
+	withAPIConfig(t)
+	store, _ := testStore(t)
+	user, login := signedInUser(t, store, "revoked")
+	other, _ := signedInUser(t, store, "other")
+
+	sessions, err := store.ListSessions(user.ID)
+	require.NoError(t, err)
+	require.Len(t, sessions, 1)
+
+	assert.ErrorIs(t, store.RevokeSession(other.ID, sessions[0].ID), domain.ErrNotFound)
+	require.NoError(t, store.RevokeSession(user.ID, sessions[0].ID))
+	assert.ErrorIs(t, store.RevokeSession(user.ID, sessions[0].ID), domain.ErrNotFound)
+
+	_, err = store.RefreshSession(login.RefreshToken, testClient())
+	assert.Equal(t, "session_revoked", errorCode(err))
+	sessions, err = store.ListSessions(user.ID)
+	require.NoError(t, err)
+	assert.Empty(t, sessions)
+}
+
+func TestExpiredRefreshTokensAreRejected(t *testing.T) {
+	withAPIConfig(t)
+	store, _ := testStore(t)
+	_, login := signedInUser(t, store, "expired")
+
+	// the same session and token ID, but past its expiry
+	claims := jwt.MapClaims{}
+	_, _, err := jwt.NewParser().ParseUnverified(login.RefreshToken, claims)
+	require.NoError(t, err)
+	claims["exp"] = time.Now().Add(-time.Minute).Unix()
+	expired, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte(secretsJWTKey))
+	require.NoError(t, err)
+
+	_, err = store.RefreshSession(expired, testClient())
+	assert.ErrorIs(t, err, jwt.ErrTokenExpired)
+
+	// and it isn't taken for a reused token: the session lives on
+	_, err = store.RefreshSession(login.RefreshToken, testClient())
+	assert.NoError(t, err)
+}
+
+func TestSessionEndpointsOnlyReachOwnSessions(t *testing.T) {
+	withAPIConfig(t)
+	store, _ := testStore(t)
+	alice, _ := signedInUser(t, store, "alice")
+	bob, _ := signedInUser(t, store, "bob")
+
+	gin.SetMode(gin.TestMode)
+	users := handler.NewUserHandler(*services.NewUserService(store))
+	router := gin.New()
+	router.GET("/v2/sessions", users.ListSessions)
+	router.DELETE("/v2/sessions/:id", users.RevokeSession)
+	call := func(method, path, userID string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, nil)
+		req.Header.Set("Authorization", "Bearer "+accessToken(t, userID))
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+	list := func(userID string) []*domain.Session {
+		w := call(http.MethodGet, "/v2/sessions", userID)
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		var page domain.Page[*domain.Session]
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &page))
+		return page.Items
+	}
+
+	aliceSessions := list(alice.ID)
+	require.Len(t, aliceSessions, 1)
+	assert.Equal(t, alice.ID, aliceSessions[0].UserID)
+	bobSessions := list(bob.ID)
+	require.Len(t, bobSessions, 1)
+
+	w := call(http.MethodDelete, "/v2/sessions/"+bobSessions[0].ID, alice.ID)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	assert.Len(t, list(bob.ID), 1)
+
+	w = call(http.MethodDelete, "/v2/sessions/"+aliceSessions[0].ID, alice.ID)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Empty(t, list(alice.ID))
+	assert.Len(t, list(bob.ID), 1)
+}
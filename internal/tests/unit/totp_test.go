@@ -0,0 +1,56 @@
+package unit
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/LordMoMA/Hexagonal-Architecture/internal/adapters/repository"
+	"github.com/stretchr/testify/assert"
+)
+
+// base32 of the RFC 6238 SHA1 test seed "12345678901234567890"
+const rfcSecret = "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+
+func TestGenerateTOTPCodeRFC6238(t *testing.T) {
+	// RFC 6238 appendix B vectors, truncated to the last six digits
+	vectors := map[int64]string{
+		59:          "287082",
+		1111111109:  "081804",
+		1111111111:  "050471",
+		1234567890:  "005924",
+		2000000000:  "279037",
+		20000000000: "353130",
+	}
+	for unix, want := range vectors {
+		code, err := repository.GenerateTOTPCode(rfcSecret, time.Unix(unix, 0))
+		assert.NoError(t, err)
+		assert.Equal(t, want, code, "time %d", unix)
+	}
+}
+
+func TestValidateTOTPCodeClockSkew(t *testing.T) {
+	now := time.Unix(1234567890, 0)
+	code, err := repository.GenerateTOTPCode(rfcSecret, now)
+	assert.NoError(t, err)
+
+	_, ok := repository.ValidateTOTPCode(rfcSecret, code, now.Add(29*time.Second))
+	assert.True(t, ok)
+	_, ok = repository.ValidateTOTPCode(rfcSecret, code, now.Add(-30*time.Second))
+	assert.True(t, ok)
+	_, ok = repository.ValidateTOTPCode(rfcSecret, code, now.Add(90*time.Second))
+	assert.False(t, ok)
+	_, ok = repository.ValidateTOTPCode(rfcSecret, "abc123", now)
+	assert.False(t, ok)
+}
+
+func TestTOTPURI(t *testing.T) {
+	secret, err := repository.GenerateTOTPSecret()
+	assert.NoError(t, err)
+	assert.Len(t, secret, 32)
+
+	uri := repository.TOTPURI("alan@example.com", secret)
+	assert.True(t, strings.HasPrefix(uri, "otpauth://totp/LordMoMA:alan@example.com?"))
+	assert.Contains(t, uri, "secret="+secret)
+	assert.Contains(t, uri, "issuer=LordMoMA")
+}
@@ -0,0 +1,97 @@
+package unit
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/LordMoMA/Hexagonal-Architecture/internal/adapters/handler"
+	"github.com/LordMoMA/Hexagonal-Architecture/internal/core/domain"
+	"github.com/LordMoMA/Hexagonal-Architecture/internal/core/ports"
+	"github.com/LordMoMA/Hexagonal-Architecture/internal/core/services"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// signupRepo records the users it is asked to create
+type signupRepo struct {
+	ports.UserRepository
+	created []string
+}
+
+func (r *signupRepo) CreateUser(email, password string) (*domain.User, error) {
+	r.created = append(r.created, email)
+	return &domain.User{ID: "user-1", Email: email}, nil
+}
+
+func postSignup(t *testing.T, repo *signupRepo, body string) (*httptest.ResponseRecorder, handler.Problem) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.POST("/v1/users", handler.NewUserHandler(*services.NewUserService(repo)).CreateUser)
+
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodPost, "/v1/users", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	router.ServeHTTP(w, req)
+
+	var problem handler.Problem
+	if w.Code >= 400 {
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &problem))
+	}
+	return w, problem
+}
+
+func TestCreateUserReportsEveryInvalidField(t *testing.T) {
+	repo := &signupRepo{}
+	w, problem := postSignup(t, repo, `{"email": "not-an-email", "password": "short"}`)
+
+	assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+	assert.Equal(t, "invalid_request", problem.Code)
+	require.Len(t, problem.Errors, 2)
+	assert.Equal(t, domain.FieldError{Field: "email", Code: "email", Message: "must be a valid email address"}, problem.Errors[0])
+	assert.Equal(t, "password", problem.Errors[1].Field)
+	assert.Equal(t, "password", problem.Errors[1].Code)
+	assert.Empty(t, repo.created)
+}
+
+func TestCreateUserIgnoresServerOwnedFields(t *testing.T) {
+	repo := &signupRepo{}
+	w, _ := postSignup(t, repo, `{"email": "ada@example.com", "password": "correct horse 42", "id": "admin", "membership": true}`)
+
+	assert.Equal(t, http.StatusCreated, w.Code)
+	assert.Equal(t, []string{"ada@example.com"}, repo.created)
+}
+
+func TestCreateUserReportsWrongTypes(t *testing.T) {
+	w, problem := postSignup(t, &signupRepo{}, `{"email": 42, "password": "correct horse 42"}`)
+
+	assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+	require.Len(t, problem.Errors, 1)
+	assert.Equal(t, domain.FieldError{Field: "email", Code: "type", Message: "must be a string"}, problem.Errors[0])
+}
+
+func TestCreateUserRejectsMalformedJSON(t *testing.T) {
+	w, problem := postSignup(t, &signupRepo{}, `{"email": `)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Equal(t, "bad_request", problem.Code)
+	assert.Empty(t, problem.Errors)
+}
+
+func TestMessageBodyLengthIsLimited(t *testing.T) {
+	repo := newMemoryMessengerRepo()
+	svc := services.NewMessengerService(repo, nil, nil, nil, nil)
+	c, err := svc.CreateConversation("alice", domain.Conversation{Kind: domain.ConversationDirect, ParticipantIDs: []string{"bob"}})
+	require.NoError(t, err)
+
+	long := strings.Repeat("ä", domain.MaxMessageBodyLength+1)
+	err = svc.CreateMessage("alice", domain.Message{ConversationID: c.ID, Body: long})
+	assert.ErrorIs(t, err, domain.ErrValidation)
+
+	// the limit is in characters, not bytes
+	require.NoError(t, svc.CreateMessage("alice", domain.Message{ConversationID: c.ID, Body: long[len("ä"):]}))
+	assert.ErrorIs(t, svc.UpdateMessage(repo.lastMessageID, domain.Message{Body: long}), domain.ErrValidation)
+}
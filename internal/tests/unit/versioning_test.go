@@ -0,0 +1,173 @@
+package unit
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/LordMoMA/Hexagonal-Architecture/internal/adapters/handler"
+	"github.com/LordMoMA/Hexagonal-Architecture/internal/adapters/realtime"
+	"github.com/LordMoMA/Hexagonal-Architecture/internal/core/domain"
+	"github.com/LordMoMA/Hexagonal-Architecture/internal/core/ports"
+	"github.com/LordMoMA/Hexagonal-Architecture/internal/core/services"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type versionedConversationRepo struct {
+	ports.MessengerRepository
+}
+
+func (versionedConversationRepo) ReadConversations(userID string) ([]*domain.Conversation, error) {
+	return []*domain.Conversation{{ID: "c1", Kind: domain.ConversationDirect, CreatedBy: userID}}, nil
+}
+
+func (versionedConversationRepo) ReadMessages(userID string, opts domain.ListOptions) (*domain.Page[*domain.Message], error) {
+	return &domain.Page[*domain.Message]{Items: []*domain.Message{{ID: "m1", UserID: userID, Body: "hi"}}}, nil
+}
+
+func versionedRouter() *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	handler.RegisterRoutes(router, handler.Services{
+		Messages: services.NewMessengerService(versionedConversationRepo{}, nil, nil, nil, nil),
+		Users:    services.NewUserService(nil),
+		Payments: services.NewPaymentService(nil),
+		Hub:      realtime.NewHub(nil),
+	})
+	return router
+}
+
+func getConversations(t *testing.T, router *gin.Engine, version string) *httptest.ResponseRecorder {
+	w := getVersioned(t, router, "/"+version+"/conversations")
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	return w
+}
+
+func getVersioned(t *testing.T, router *gin.Engine, path string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodGet, path, nil)
+	req.Header.Set("Authorization", "Bearer "+accessToken(t, "u1"))
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w
+}
+
+func TestOlderVersionsGetTheirOwnRepresentation(t *testing.T) {
+	withAPIConfig(t)
+	router := versionedRouter()
+
+	w := getConversations(t, router, "v2")
+	var page domain.Page[*domain.Conversation]
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &page))
+	require.Len(t, page.Items, 1)
+	assert.Equal(t, "c1", page.Items[0].ID)
+
+	w = getConversations(t, router, "v1")
+	var conversations []*domain.Conversation
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &conversations), w.Body.String())
+	require.Len(t, conversations, 1)
+	assert.Equal(t, "c1", conversations[0].ID)
+	assert.Equal(t, "u1", conversations[0].CreatedBy)
+}
+
+func TestV1KeepsItsBaselineMessageList(t *testing.T) {
+	withAPIConfig(t)
+	router := versionedRouter()
+
+	w := getVersioned(t, router, "/v2/messages")
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var page domain.Page[*domain.Message]
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &page))
+	require.Len(t, page.Items, 1)
+
+	w = getVersioned(t, router, "/v1/messages")
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var messages []*domain.Message
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &messages), w.Body.String())
+	require.Len(t, messages, 1)
+	assert.Equal(t, "m1", messages[0].ID)
+}
+
+func TestV1ErrorsKeepTheErrorMember(t *testing.T) {
+	withAPIConfig(t)
+	router := versionedRouter()
+
+	w := getVersioned(t, router, "/v2/messages?limit=0")
+	assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+	assert.Equal(t, "application/problem+json", w.Header().Get("Content-Type"))
+	var problem map[string]interface{}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &problem))
+	assert.Equal(t, "invalid_limit", problem["code"])
+	assert.NotContains(t, problem, "error")
+
+	w = getVersioned(t, router, "/v1/messages?limit=0")
+	assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+	assert.Contains(t, w.Header().Get("Content-Type"), "application/json")
+	problem = nil
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &problem))
+	assert.Equal(t, "invalid_limit", problem["code"])
+	assert.Equal(t, problem["detail"], problem["error"])
+	assert.NotEmpty(t, problem["error"])
+}
+
+func TestDeprecatedVersionsSayWhenTheyGo(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	deprecated := handler.APIVersion{
+		Name:       "v0",
+		Deprecated: time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC),
+		Sunset:     time.Date(2026, 7, 1, 0, 0, 0, 0, time.UTC),
+	}
+	router.Group("/v0", deprecated.Serve).GET("/ping", func(ctx *gin.Context) { ctx.Status(http.StatusNoContent) })
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/v0/ping", nil))
+	assert.Equal(t, fmt.Sprintf("@%d", deprecated.Deprecated.Unix()), w.Header().Get("Deprecation"))
+	assert.Equal(t, "Wed, 01 Jul 2026 00:00:00 GMT", w.Header().Get("Sunset"))
+	assert.Contains(t, w.Header().Get("Link"), `rel="deprecation"`)
+
+	// no version served today is deprecated
+	withAPIConfig(t)
+	versioned := versionedRouter()
+	for _, version := range []string{"v1", "v2"} {
+		w = getConversations(t, versioned, version)
+		assert.Empty(t, w.Header().Get("Deprecation"), version)
+		assert.Empty(t, w.Header().Get("Sunset"), version)
+	}
+}
+
+func TestCheckoutIsServedUnderEveryVersion(t *testing.T) {
+	router := versionedRouter()
+	routes := map[string]bool{}
+	for _, route := range router.Routes() {
+		routes[route.Method+" "+route.Path] = true
+	}
+	assert.True(t, routes["POST /v1/create-checkout-session"])
+	assert.True(t, routes["POST /v2/create-checkout-session"])
+}
+
+func TestVersionUsageIsCounted(t *testing.T) {
+	withAPIConfig(t)
+	router := versionedRouter()
+	handler.TakeVersionUsage()
+
+	getConversations(t, router, "v1")
+	getConversations(t, router, "v1")
+	getConversations(t, router, "v2")
+
+	usage := map[string]handler.VersionUsage{}
+	for _, u := range handler.TakeVersionUsage() {
+		usage[u.Version] = u
+	}
+	assert.Equal(t, 2, usage["v1"].Requests)
+	assert.Equal(t, 1, usage["v1"].Callers)
+	assert.Equal(t, map[string]int{"GET /conversations": 2}, usage["v1"].Routes)
+	assert.Equal(t, 1, usage["v2"].Requests)
+
+	for _, u := range handler.TakeVersionUsage() {
+		assert.Zero(t, u.Requests, u.Version)
+	}
+}